@@ -101,6 +101,11 @@ func main() {
 			if err := client.ApplyIfChanged(configurationInGit); err != nil {
 				log.Errorf("error when applying changes: %s", err.Error())
 			}
+			if current, err := client.ScrapeResourcesForNamespace(configurationInGit.Namespace); err != nil {
+				log.Errorf("error loading environment for canaries: %s", err.Error())
+			} else if err := client.ProgressCanaries(configurationInGit, current); err != nil {
+				log.Errorf("error progressing canaries: %s", err.Error())
+			}
 			if err := reap.Cleanup(configurationInGit); err != nil {
 				log.Errorf("error reaper failed: %s", err.Error())
 			}
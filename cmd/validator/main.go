@@ -1,13 +1,66 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
 
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
 	"github.com/pearsontechnology/environment-operator/version"
 )
 
 // This package adds environment-validator binary, which can be used to
 // validate environments.bitesize file
 func main() {
-	fmt.Println(version.Version)
+	showVersion := flag.Bool("version", false, "print version and exit")
+	showSchema := flag.Bool("schema", false, "print JSON Schema of environments.bitesize and exit")
+	migrate := flag.Bool("migrate", false, fmt.Sprintf("rewrite files to environments.bitesize version %d before validating them, keeping the originals as <file>.bak", bitesize.LatestVersion))
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-version] [-schema] [-migrate] [environments.bitesize ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(version.Version)
+		return
+	}
+	if *showSchema {
+		schema, err := bitesize.SchemaJSON()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(string(schema))
+		return
+	}
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"environments.bitesize"}
+	}
+
+	failed := false
+	for _, path := range paths {
+		if *migrate {
+			changes, err := bitesize.MigrateFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+				failed = true
+				continue
+			}
+			for _, change := range changes {
+				fmt.Printf("%s: migrated %s\n", path, change)
+			}
+		}
+		if _, err := bitesize.LoadFromFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: OK\n", path)
+	}
+	if failed {
+		os.Exit(1)
+	}
 }
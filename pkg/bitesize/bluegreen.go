@@ -93,6 +93,68 @@ func copyBlueGreenService(svc Service, kind BlueGreenServiceSet) Service {
 		}
 	}
 	retval.ExternalURL = externalURLs
+	// only the parent service is exposed with service_type, blue and green
+	// services would provision their own node ports or load balancers
+	retval.ServiceType = nil
 
 	return retval
 }
+
+// SmokeCheck is an HTTP probe run against the inactive blue/green service set
+// before live traffic is switched to it
+type SmokeCheck struct {
+	Path    string `yaml:"path,omitempty"`
+	Scheme  string `yaml:"scheme,omitempty" validate:"regexp=^(http|https)*$"`
+	Status  int    `yaml:"status,omitempty"`                   // expected response status, 200 if not set
+	Timeout int    `yaml:"timeout,omitempty" validate:"min=0"` // seconds, 5 if not set
+}
+
+// ConfiguredDeploymentTag returns active deployment in bluegreen set as
+// configured in environments.bitesize, which differs from
+// ActiveDeploymentTag after live traffic was switched via API
+func (e Service) ConfiguredDeploymentTag() BlueGreenServiceSet {
+	if e.Deployment == nil || e.Deployment.BlueGreen == nil || e.Deployment.BlueGreen.Configured == nil {
+		return e.ActiveDeploymentTag()
+	}
+	return *e.Deployment.BlueGreen.Configured
+}
+
+// SetActiveDeployment switches bluegreen "parent" service name and its child
+// services to serve live traffic from active service set. The colour
+// configured in environments.bitesize is kept while it differs from active.
+func (e *Environment) SetActiveDeployment(name string, active BlueGreenServiceSet) error {
+	var parent *Service
+	for i := range e.Services {
+		if e.Services[i].Name == name {
+			parent = &e.Services[i]
+		}
+	}
+	if parent == nil || !parent.IsBlueGreenParentDeployment() {
+		return fmt.Errorf("service %s deployment method is not bluegreen", name)
+	}
+
+	configured := parent.ConfiguredDeploymentTag()
+	settings := &BlueGreenSettings{Active: &active}
+	if configured != active {
+		settings.Configured = &configured
+	}
+	deployment := *parent.Deployment
+	deployment.BlueGreen = settings
+	parent.Deployment = &deployment
+
+	for i, svc := range e.Services {
+		if !svc.IsBlueGreenChildDeployment() {
+			continue
+		}
+		colour := *svc.Deployment.BlueGreen.DeploymentColour
+		if svc.Name != fmt.Sprintf("%s-%s", name, colour) {
+			continue
+		}
+		child := *svc.Deployment
+		childSettings := *child.BlueGreen
+		childSettings.ActiveFlag = colour == active
+		child.BlueGreen = &childSettings
+		e.Services[i].Deployment = &child
+	}
+	return nil
+}
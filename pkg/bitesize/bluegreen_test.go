@@ -49,6 +49,8 @@ func TestCopyBlueGreenService(t *testing.T) {
 	svc := Service{}
 	str := `
   name: test-service
+  service_type:
+    type: LoadBalancer
   deployment:
     method: bluegreen
     active: blue
@@ -84,4 +86,47 @@ func TestCopyBlueGreenService(t *testing.T) {
 		t.Errorf("unexpected url for blue service: expected www.blue.url, got %s", blueService.ExternalURL[0])
 	}
 
+	if blueService.ServiceType != nil {
+		t.Errorf("unexpected service type for blue service: %+v", blueService.ServiceType)
+	}
+	if svc.ServiceType == nil || svc.ServiceType.Type != ServiceTypeLoadBalancer {
+		t.Errorf("unexpected service type for parent service: %+v", svc.ServiceType)
+	}
+
+}
+
+func TestSetActiveDeployment(t *testing.T) {
+	e, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment27")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	checks := e.Services.FindByName("frontend").Deployment.SmokeChecks
+	if len(checks) != 2 || checks[1].Status != 204 || checks[1].Scheme != "https" {
+		t.Errorf("Unexpected smoke checks: %+v", checks)
+	}
+
+	if err = e.SetActiveDeployment("frontend-blue", GreenService); err == nil {
+		t.Error("Expected error for service without bluegreen deployment method")
+	}
+
+	for _, active := range []BlueGreenServiceSet{GreenService, BlueService} {
+		if err = e.SetActiveDeployment("frontend", active); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		parent := e.Services.FindByName("frontend")
+		if parent.ActiveDeploymentTag() != active || parent.ConfiguredDeploymentTag() != BlueService {
+			t.Errorf("Expected %s active with blue configured, got %s and %s", active,
+				parent.ActiveDeploymentTag(), parent.ConfiguredDeploymentTag())
+		}
+		if parent.Deployment.BlueGreen.Configured != nil && active == BlueService {
+			t.Error("Expected configured colour to be cleared when it is active")
+		}
+		for _, colour := range []BlueGreenServiceSet{BlueService, GreenService} {
+			child := e.Services.FindByName("frontend-" + colour.String())
+			if child.IsActiveBlueGreenDeployment() != (colour == active) {
+				t.Errorf("Unexpected active flag %t for %s", child.IsActiveBlueGreenDeployment(), child.Name)
+			}
+		}
+	}
 }
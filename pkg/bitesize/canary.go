@@ -0,0 +1,189 @@
+package bitesize
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// CanaryProgressing is the status of a canary receiving traffic
+	CanaryProgressing = "progressing"
+	// CanaryPromoted is the status of a canary promoted to the stable service
+	CanaryPromoted = "promoted"
+	// CanaryAborted is the status of a canary stopped by a failed check
+	CanaryAborted = "aborted"
+
+	canarySuffix = "canary"
+)
+
+// DefaultCanarySteps are canary traffic weights used when steps are not set
+var DefaultCanarySteps = []int{10, 25, 50}
+
+// CanarySettings represents "deployment.canary" block in environments.bitesize
+type CanarySettings struct {
+	// Steps are percentages of traffic routed to the canary in turn
+	Steps []int `yaml:"steps,omitempty"`
+	// Interval is the number of seconds between steps. With no interval
+	// steps are only advanced by API call.
+	Interval int             `yaml:"interval,omitempty" validate:"min=0"`
+	Analysis *CanaryAnalysis `yaml:"analysis,omitempty"`
+}
+
+// CanaryAnalysis aborts the canary when the result of Prometheus query is
+// above Threshold
+type CanaryAnalysis struct {
+	Query     string  `yaml:"query" validate:"nonzero"`
+	Threshold float64 `yaml:"threshold"`
+}
+
+// CanaryState is the progress of canary rollout, stored in the annotations of
+// the "parent" kubernetes service
+type CanaryState struct {
+	Step      int
+	StartedAt time.Time
+	Status    string
+	Version   string // Version is the version released by the canary
+}
+
+// Annotations returns kubernetes annotations representing canary state
+func (s CanaryState) Annotations() map[string]string {
+	return map[string]string{
+		"canary_step":       strconv.Itoa(s.Step),
+		"canary_started_at": s.StartedAt.UTC().Format(time.RFC3339),
+		"canary_status":     s.Status,
+		"canary_version":    s.Version,
+	}
+}
+
+// CanaryStateFromAnnotations reads canary state written by Annotations,
+// returning nil if annotations hold no canary status
+func CanaryStateFromAnnotations(annotations map[string]string) *CanaryState {
+	if annotations["canary_status"] == "" {
+		return nil
+	}
+	step, _ := strconv.Atoi(annotations["canary_step"])
+	startedAt, _ := time.Parse(time.RFC3339, annotations["canary_started_at"])
+	return &CanaryState{
+		Step:      step,
+		StartedAt: startedAt,
+		Status:    annotations["canary_status"],
+		Version:   annotations["canary_version"],
+	}
+}
+
+// IsCanaryParentDeployment verifies if deployment method set for the service
+// is canary
+func (e Service) IsCanaryParentDeployment() bool {
+	return e.DeploymentMethod() == "canary"
+}
+
+// IsCanaryChildDeployment returns true if this service is the canary copy of
+// a "parent" canary service
+func (e Service) IsCanaryChildDeployment() bool {
+	return e.Deployment != nil && e.Deployment.CanaryParent != ""
+}
+
+// CanaryDeploymentName returns the name of the service running canary version
+func (e Service) CanaryDeploymentName() string {
+	return fmt.Sprintf("%s-%s", e.Name, canarySuffix)
+}
+
+// CanarySteps returns canary traffic weights
+func (e Service) CanarySteps() []int {
+	if e.Deployment == nil || e.Deployment.Canary == nil || len(e.Deployment.Canary.Steps) == 0 {
+		return DefaultCanarySteps
+	}
+	return e.Deployment.Canary.Steps
+}
+
+// CanaryState returns the progress of canary rollout, nil if canary was
+// never started
+func (e Service) CanaryState() *CanaryState {
+	if e.Deployment == nil {
+		return nil
+	}
+	return e.Deployment.CanaryState
+}
+
+// CanaryWeight returns the percentage of traffic currently routed to the
+// canary
+func (e Service) CanaryWeight() int {
+	state := e.CanaryState()
+	if !e.IsCanaryParentDeployment() || state == nil || state.Status != CanaryProgressing {
+		return 0
+	}
+	steps := e.CanarySteps()
+	if state.Step < 0 || state.Step >= len(steps) {
+		return 0
+	}
+	return steps[state.Step]
+}
+
+// validateCanary returns an error if the canary service can't split traffic
+func validateCanary(svc Service) error {
+	if !svc.IsCanaryParentDeployment() {
+		return nil
+	}
+	if svc.Type != "" || svc.IsStatefulSet() {
+		return fmt.Errorf("%s: canary deployment method is only supported for deployments", svc.Name)
+	}
+	if svc.HasExternalRoutes() {
+		return fmt.Errorf("%s: canary deployment method is not supported with external_routes", svc.Name)
+	}
+	if !svc.HasExternalURL() {
+		return fmt.Errorf("%s: canary deployment method requires external_url", svc.Name)
+	}
+	if !svc.IsServiceMeshEnabled() && svc.IngressProfile != "nginx" {
+		return fmt.Errorf("%s: canary deployment method requires service_mesh or ingress_profile nginx", svc.Name)
+	}
+
+	previous := 0
+	for _, weight := range svc.CanarySteps() {
+		if weight <= previous || weight >= 100 {
+			return fmt.Errorf("%s: canary steps must be increasing weights between 1 and 99", svc.Name)
+		}
+		previous = weight
+	}
+	return nil
+}
+
+// copyCanaryService creates a copy of current service with a name suffixed
+// with -canary. Canary service is not exposed with its own ingress, traffic
+// is routed to it from the "parent" service.
+func copyCanaryService(svc Service) Service {
+	retval := Service{}
+	byt, err := json.Marshal(svc)
+	if err != nil {
+		log.Errorf("copy canary service marshal error: %s", err.Error())
+	}
+	err = json.Unmarshal(byt, &retval)
+	if err != nil {
+		log.Errorf("copy canary service unmarshal error: %s", err.Error())
+	}
+
+	retval.Name = svc.CanaryDeploymentName()
+	retval.Deployment = &DeploymentSettings{Method: "rolling-upgrade", CanaryParent: svc.Name}
+	retval.ExternalURL = []string{}
+	retval.IngressClass = ""
+	retval.IngressProfile = ""
+	retval.IngressAnnotations = nil
+	retval.IngressTimeout = 0
+	retval.IngressMaxBodySize = ""
+	retval.IngressWhitelist = nil
+	retval.TLS = nil
+	// canary is reached through the parent service only, exposing it on a
+	// node port or load balancer would bypass the traffic split
+	retval.ServiceType = nil
+	// canary runs a fixed number of replicas, autoscalers copied from the
+	// parent would outlive it
+	retval.HPA = HorizontalPodAutoscaler{}
+	retval.Keda = nil
+	if retval.Replicas < 1 {
+		retval.Replicas = 1
+	}
+	return retval
+}
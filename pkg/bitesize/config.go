@@ -3,7 +3,10 @@ package bitesize
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 
+	"github.com/pearsontechnology/environment-operator/pkg/git"
 	validator "gopkg.in/validator.v2"
 	yaml "gopkg.in/yaml.v2"
 	v1 "k8s.io/api/core/v1"
@@ -11,16 +14,26 @@ import (
 
 // EnvironmentsBitesize is a 1:1 mapping to environments.bitesize file
 type EnvironmentsBitesize struct {
-	Project      string       `yaml:"project"`
-	Environments Environments `yaml:"environments"`
+	Version      int                    `yaml:"version,omitempty"`
+	Project      string                 `yaml:"project"`
+	Vars         map[string]interface{} `yaml:"vars,omitempty"`
+	Strict       string                 `yaml:"strict,omitempty" validate:"regexp=^(true|false|warn)*$"`
+	Environments Environments           `yaml:"environments"`
+
+	builtins map[string]interface{} // built-in variables set by the loader, e.g. git_commit
+	dir      string                 // directory include patterns are relative to
 }
 
 // DeploymentSettings represent "deployment" block in environments.bitesize
 type DeploymentSettings struct {
-	Method     string              `yaml:"method,omitempty" validate:"regexp=^(bluegreen|rolling-upgrade)*$"`
-	Mode       string              `yaml:"mode,omitempty" validate:"regexp=^(manual|auto)*$"`
-	BlueGreen  *BlueGreenSettings  `yaml:"-"`
-	CustomURLs map[string][]string `yaml:"custom_urls,omitempty"`
+	Method       string              `yaml:"method,omitempty" validate:"regexp=^(bluegreen|rolling-upgrade|canary)*$"`
+	Mode         string              `yaml:"mode,omitempty" validate:"regexp=^(manual|auto)*$"`
+	BlueGreen    *BlueGreenSettings  `yaml:"-"`
+	CustomURLs   map[string][]string `yaml:"custom_urls,omitempty"`
+	Canary       *CanarySettings     `yaml:"canary,omitempty"`
+	CanaryState  *CanaryState        `yaml:"-"` // CanaryState is set by the cluster from "parent" service annotations
+	CanaryParent string              `yaml:"-"` // CanaryParent is set on canary copies to the "parent" service name
+	SmokeChecks  []SmokeCheck        `yaml:"smoke_checks,omitempty"`
 	// XXX    map[string]interface{} `yaml:",inline"`
 }
 
@@ -30,13 +43,17 @@ type BlueGreenSettings struct {
 	Active           *BlueGreenServiceSet // used in "parent" service to determine which environment is active
 	DeploymentColour *BlueGreenServiceSet // used in "child" blue/green service to indicate it's colour
 	ActiveFlag       bool                 // used in "child" blue/green service to indicate whethen this environment is currently active
+	Configured       *BlueGreenServiceSet // used in "parent" service switched via API to keep the active environment set in environments.bitesize
 }
 
-// HorizontalPodAutoscaler maps to HPA in kubernetes
+// HorizontalPodAutoscaler maps to HPA in kubernetes. Metric is the single
+// metric form, moved to Metrics when the service is loaded.
 type HorizontalPodAutoscaler struct {
-	MinReplicas int32  `yaml:"min_replicas"`
-	MaxReplicas int32  `yaml:"max_replicas"`
-	Metric      Metric `yaml:"metric"`
+	MinReplicas int32            `yaml:"min_replicas"`
+	MaxReplicas int32            `yaml:"max_replicas"`
+	Metric      Metric           `yaml:"metric"`
+	Metrics     []Metric         `yaml:"metrics,omitempty"`
+	Behavior    *ScalingBehavior `yaml:"behavior,omitempty"`
 }
 
 // Container maps a single application container that you want to run within a pod
@@ -61,11 +78,23 @@ type ContainerLimits struct {
 	Memory string `yaml:"memory"`
 }
 
-// Metric maps to HPA targets in kubernetes
+// Metric maps to HPA targets in kubernetes. Type is resource for cpu and
+// memory and pods for other metrics if not set.
 type Metric struct {
-	Name                     string `yaml:"name"`
-	TargetAverageValue       string `yaml:"target_average_value,omitempty"`
-	TargetAverageUtilization int32  `yaml:"target_average_utilization,omitempty"`
+	Type                     string            `yaml:"type,omitempty" validate:"regexp=^(resource|pods|object|external)*$"`
+	Name                     string            `yaml:"name"`
+	Selector                 map[string]string `yaml:"selector,omitempty"`
+	Object                   *MetricObject     `yaml:"object,omitempty"`
+	TargetValue              string            `yaml:"target_value,omitempty"`
+	TargetAverageValue       string            `yaml:"target_average_value,omitempty"`
+	TargetAverageUtilization int32             `yaml:"target_average_utilization,omitempty"`
+}
+
+// MetricObject is the kubernetes object an object metric describes
+type MetricObject struct {
+	APIVersion string `yaml:"api_version,omitempty"`
+	Kind       string `yaml:"kind" validate:"nonzero"`
+	Name       string `yaml:"name" validate:"nonzero"`
 }
 
 // Test is obsolete and not used by environment-operator,
@@ -126,10 +155,11 @@ type TCPSocketAction struct {
 
 // EnvVar represents environment variables in pod
 type EnvVar struct {
-	Name     string `yaml:"name,omitempty"`
-	Value    string `yaml:"value,omitempty"`
-	Secret   string `yaml:"secret,omitempty"`
-	PodField string `yaml:"pod_field,omitempty"`
+	Name      string         `yaml:"name,omitempty"`
+	Value     string         `yaml:"value,omitempty"`
+	Secret    string         `yaml:"secret,omitempty"`
+	PodField  string         `yaml:"pod_field,omitempty"`
+	ValueFrom *ServiceOutput `yaml:"value_from,omitempty"`
 }
 
 // Pod represents Pod in Kubernetes
@@ -239,20 +269,34 @@ func (e *DeploymentSettings) UnmarshalYAML(unmarshal func(interface{}) error) er
 
 // LoadFromString returns BitesizeEnvironment object from yaml string
 func LoadFromString(cfg string) (*EnvironmentsBitesize, error) {
-	t := &EnvironmentsBitesize{}
+	return loadFromString(cfg, ".", nil)
+}
+
+func loadFromString(cfg, dir string, builtins map[string]interface{}) (*EnvironmentsBitesize, error) {
+	t := &EnvironmentsBitesize{builtins: builtins, dir: dir}
 	err := yaml.Unmarshal([]byte(cfg), t)
 	return t, err
 }
 
 // LoadFromFile returns BitesizeEnvironment object loaded from file, passed
-// as a path argument.
+// as a path argument. If path is a directory, environments.bitesize in it
+// is loaded.
 func LoadFromFile(path string) (*EnvironmentsBitesize, error) {
 	var err error
 	var contents []byte
 
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "environments.bitesize")
+	}
+
 	contents, err = ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	return LoadFromString(string(contents))
+
+	builtins := map[string]interface{}{}
+	if commit, err := git.HeadCommit(filepath.Dir(path)); err == nil {
+		builtins[VarGitCommit] = commit
+	}
+	return loadFromString(string(contents), filepath.Dir(path), builtins)
 }
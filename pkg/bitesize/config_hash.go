@@ -0,0 +1,72 @@
+package bitesize
+
+import (
+	"sort"
+	"strings"
+)
+
+// ConfigHashAnnotation holds combined content hash of configmaps and secrets
+// referenced by service's pod template. Changing it rolls the pods.
+const ConfigHashAnnotation = "config_hash"
+
+// ConfigMapReferences returns sorted names of configmaps mounted by service
+// and its init containers
+func (e Service) ConfigMapReferences() []string {
+	var names []string
+	for _, v := range e.allVolumes() {
+		if v.IsConfigMapVolume() {
+			names = append(names, v.Name)
+		}
+	}
+	return uniqueSorted(names)
+}
+
+// SecretReferences returns sorted names of secrets service and its init
+// containers mount or read environment variables from
+func (e Service) SecretReferences() []string {
+	var names []string
+	for _, v := range e.allVolumes() {
+		if v.IsSecretVolume() {
+			names = append(names, v.Name)
+		}
+	}
+
+	envVars := e.EnvVars
+	if e.InitContainers != nil {
+		for _, c := range *e.InitContainers {
+			envVars = append(envVars[:len(envVars):len(envVars)], c.EnvVars...)
+		}
+	}
+	for _, env := range envVars {
+		if env.Secret != "" {
+			names = append(names, strings.Split(env.Value, "/")[0])
+		}
+		if env.ValueFrom != nil && env.ValueFrom.Secret != "" {
+			names = append(names, env.ValueFrom.Secret)
+		}
+	}
+	return uniqueSorted(names)
+}
+
+func (e Service) allVolumes() []Volume {
+	volumes := e.Volumes
+	if e.InitContainers != nil {
+		for _, c := range *e.InitContainers {
+			volumes = append(volumes[:len(volumes):len(volumes)], c.Volumes...)
+		}
+	}
+	return volumes
+}
+
+func uniqueSorted(names []string) []string {
+	seen := map[string]bool{}
+	var retval []string
+	for _, n := range names {
+		if n != "" && !seen[n] {
+			seen[n] = true
+			retval = append(retval, n)
+		}
+	}
+	sort.Strings(retval)
+	return retval
+}
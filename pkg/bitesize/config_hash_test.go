@@ -0,0 +1,39 @@
+package bitesize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigReferences(t *testing.T) {
+	svc := Service{
+		Volumes: []Volume{
+			{Name: "app-config", Type: "configmap"},
+			{Name: "tls", Type: "secret"},
+			{Name: "data", Type: "efs"},
+		},
+		EnvVars: []EnvVar{
+			{Secret: "DB_PASSWORD", Value: "db/password"},
+			{Secret: "DB_USER", Value: "db/user"},
+			{Name: "PLAIN", Value: "value"},
+		},
+		InitContainers: &[]Container{
+			{
+				Volumes: []Volume{{Name: "init-config", Type: "configmap"}},
+				EnvVars: []EnvVar{{Secret: "API_TOKEN", Value: "api/token"}},
+			},
+		},
+	}
+
+	if refs := svc.ConfigMapReferences(); !reflect.DeepEqual(refs, []string{"app-config", "init-config"}) {
+		t.Errorf("Unexpected configmap references: %v", refs)
+	}
+
+	if refs := svc.SecretReferences(); !reflect.DeepEqual(refs, []string{"api", "db", "tls"}) {
+		t.Errorf("Unexpected secret references: %v", refs)
+	}
+
+	if len(svc.Volumes) != 3 || len(svc.EnvVars) != 3 {
+		t.Error("Expected service volumes and env vars to be left unchanged")
+	}
+}
@@ -0,0 +1,87 @@
+package bitesize
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// IsCustomResource returns true if service declares a custom resource of any
+// kind with api_version, kind and spec, applied to the cluster verbatim
+func (e Service) IsCustomResource() bool {
+	return e.Kind != ""
+}
+
+// APIGroup returns the API group of custom resource api_version
+func (e Service) APIGroup() string {
+	if i := strings.LastIndex(e.APIVersion, "/"); i > 0 {
+		return e.APIVersion[:i]
+	}
+	return ""
+}
+
+// validateCustomResource returns an error if custom resource service is
+// missing API group or sets a bitesize type as well
+func (e *Service) validateCustomResource() error {
+	if e.Kind == "" {
+		if e.APIVersion != "" || e.Spec != nil {
+			return fmt.Errorf("service.kind: required with api_version and spec for %s", e.Name)
+		}
+		return nil
+	}
+	if e.Type != "" {
+		return fmt.Errorf("service.kind: can't be combined with type %s for %s", e.Type, e.Name)
+	}
+	if e.APIGroup() == "" {
+		return fmt.Errorf("service.api_version: %s must be group/version for %s", e.APIVersion, e.Name)
+	}
+	e.Type = strings.ToLower(e.Kind)
+	return nil
+}
+
+// unmarshalSpec reads free-form custom resource spec, with values converted
+// the same way as in a spec read back from the cluster
+func unmarshalSpec(unmarshal func(interface{}) error) (map[string]interface{}, error) {
+	var bz struct {
+		Spec map[string]interface{} `yaml:"spec,omitempty"`
+	}
+	if err := unmarshal(&bz); err != nil {
+		return nil, err
+	}
+	if bz.Spec == nil {
+		return nil, nil
+	}
+
+	spec := map[string]interface{}{}
+	for k, v := range bz.Spec {
+		spec[k] = jsonValue(v)
+	}
+
+	byt, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	retval := map[string]interface{}{}
+	err = json.Unmarshal(byt, &retval)
+	return retval, err
+}
+
+// jsonValue converts yaml maps to maps with string keys, keeping value types
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		retval := map[string]interface{}{}
+		for k, val := range v {
+			retval[fmt.Sprintf("%v", k)] = jsonValue(val)
+		}
+		return retval
+	case []interface{}:
+		retval := make([]interface{}, len(v))
+		for i, val := range v {
+			retval[i] = jsonValue(val)
+		}
+		return retval
+	default:
+		return v
+	}
+}
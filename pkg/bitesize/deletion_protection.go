@@ -0,0 +1,57 @@
+package bitesize
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// DeletionProtectionAnnotation is set on custom resources created for
+// services with deletion_protection set, so that the reaper can read it after
+// the service is removed from the manifest
+const DeletionProtectionAnnotation = "deletion_protection"
+
+// DataBearingTypes are custom resource types holding data that is lost when
+// the resource is deleted. These are deletion protected unless service sets
+// deletion_protection: false.
+var DataBearingTypes = []string{
+	"aurora", "mongo", "mysql", "cassandra", "redis", "postgres", "neptune",
+	"docdb", "dynamodb", "s3", "es", "cb", "atlas", "kafka", "msk", "zookeeper",
+}
+
+// IsDataBearing returns true if service type holds data
+func (e Service) IsDataBearing() bool {
+	for _, t := range DataBearingTypes {
+		if e.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDeletionProtected returns true if service custom resource must not be
+// deleted. Defaults to true for data bearing types.
+func (e Service) IsDeletionProtected() bool {
+	if e.DeletionProtection != nil {
+		return *e.DeletionProtection
+	}
+	return e.IsDataBearing()
+}
+
+// DeletionProtectionFromAnnotation reads deletion protection set with
+// DeletionProtectionAnnotation, returning nil if it's not set
+func DeletionProtectionFromAnnotation(annotations map[string]string) *bool {
+	value, err := strconv.ParseBool(annotations[DeletionProtectionAnnotation])
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+// validateDeletionProtection returns an error if deletion_protection is set
+// on a service without custom resource
+func validateDeletionProtection(svc Service) error {
+	if svc.DeletionProtection != nil && svc.Type == "" {
+		return fmt.Errorf("%s: deletion_protection requires type or kind", svc.Name)
+	}
+	return nil
+}
@@ -21,13 +21,20 @@ import (
 // be either built from environments.bitesize configuration file
 // or Kubernetes cluster
 type Environment struct {
-	Name       string              `yaml:"name" validate:"nonzero"`
-	Namespace  string              `yaml:"namespace,omitempty" validate:"regexp=^[a-zA-Z0-9\\-]*$"` // This field should be optional now
-	Deployment *DeploymentSettings `yaml:"deployment,omitempty"`
-	Services   Services            `yaml:"services"`
-	Tests      []Test              `yaml:"tests,omitempty"`
-	Gists      Gists               `yaml:"gists,omitempty"`
-	Repo       GistsRepository     `yaml:"gists_repository,omitempty"`
+	Name           string                 `yaml:"name" validate:"nonzero"`
+	Namespace      string                 `yaml:"namespace,omitempty" validate:"regexp=^[a-zA-Z0-9\\-]*$"` // This field should be optional now
+	Extends        string                 `yaml:"extends,omitempty"`
+	Vars           map[string]interface{} `yaml:"vars,omitempty"`
+	Include        []string               `yaml:"include,omitempty"`
+	Deployment     *DeploymentSettings    `yaml:"deployment,omitempty"`
+	NetworkPolicy  NetworkPolicySettings  `yaml:"network_policy,omitempty"`
+	IngressClass   string                 `yaml:"ingress_class,omitempty"`
+	IngressProfile string                 `yaml:"ingress_profile,omitempty" validate:"regexp=^(nginx|traefik|alb)*$"`
+	TLS            TLSSettings            `yaml:"tls,omitempty"`
+	Services       Services               `yaml:"services"`
+	Tests          []Test                 `yaml:"tests,omitempty"`
+	Gists          Gists                  `yaml:"gists,omitempty"`
+	Repo           GistsRepository        `yaml:"gists_repository,omitempty"`
 }
 
 var gitClient *git.Git
@@ -68,6 +75,36 @@ func (e *Environment) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err = validator.Validate(e); err != nil {
 		return fmt.Errorf("environment.%s", err.Error())
 	}
+	if err = validateRouteClaims(e.Services); err != nil {
+		return fmt.Errorf("environment.services.%s", err.Error())
+	}
+	if err = validateServiceOutputs(e.Services); err != nil {
+		return fmt.Errorf("environment.services.%s", err.Error())
+	}
+	for i := range e.Services {
+		if e.Services[i].IngressProfile == "" && e.Services[i].HasExternalURL() {
+			e.Services[i].IngressProfile = e.IngressProfile
+		}
+		svc := e.Services[i]
+		if err = validateIngressProfile(svc); err != nil {
+			return fmt.Errorf("environment.services.%s", err.Error())
+		}
+		if err = validateCanary(svc); err != nil {
+			return fmt.Errorf("environment.services.%s", err.Error())
+		}
+		if err = validateTrafficPolicy(svc); err != nil {
+			return fmt.Errorf("environment.services.%s", err.Error())
+		}
+		if err = validateServiceType(svc); err != nil {
+			return fmt.Errorf("environment.services.%s", err.Error())
+		}
+		if err = validateKeda(svc); err != nil {
+			return fmt.Errorf("environment.services.%s", err.Error())
+		}
+		if err = validateDeletionProtection(svc); err != nil {
+			return fmt.Errorf("environment.services.%s", err.Error())
+		}
+	}
 	sort.Sort(e.Services)
 	return nil
 }
@@ -106,8 +143,9 @@ func LoadEnvironment(pathToBitesizeFile, envName string) (*Environment, error) {
 				rootPath = gitClient.LocalPath
 			}
 			// load imported resources
+			loader := &gistLoader{}
 			for k, im := range env.Gists {
-				err := LoadResource(&im, env.Namespace, rootPath)
+				err := loader.load(&im, env.Namespace, rootPath)
 				if err != nil {
 					return nil, fmt.Errorf("unable to load Gist type %s, in paths %s,%v %s", im.Type, im.Path, im.Files, err.Error())
 				}
@@ -124,20 +162,8 @@ func LoadEnvironment(pathToBitesizeFile, envName string) (*Environment, error) {
 func loadServices(env Environment) Services {
 	// load services from an environment
 	// Specifies their defaults and handles overrides of user-supplied config
-	var blueGreenServices Services
+	var childServices Services
 	for i, svc := range env.Services {
-		// Internally prepend env vars that come from secrets w/o name prefix
-		// All env from secrets in .bitesize files must be specified
-		// as name/key to remove this.
-		for j, envVar := range svc.EnvVars {
-			if envVar.Secret != "" && !strings.Contains(envVar.Value, "/") {
-				newValue := fmt.Sprintf("%s/%s", envVar.Value, envVar.Value)
-				log.Tracef("Service %s: Internally converting Secret %s Value from %s to %s",
-					svc.Name, envVar.Secret, envVar.Value, newValue)
-				env.Services[i].EnvVars[j].Value = newValue
-			}
-		}
-
 		// allow config file to specify any type to any letter case.
 		// e.g., "EFS" is stored as "efs"
 		for j, vol := range svc.Volumes {
@@ -160,13 +186,25 @@ func loadServices(env Environment) Services {
 			env.Services[i].Volumes = vols
 		}
 
+		// environment ingress class and profile are defaults for services with ingresses
+		if svc.IngressClass == "" && svc.HasExternalURL() {
+			env.Services[i].IngressClass = env.IngressClass
+		}
+		if svc.IngressProfile == "" && svc.HasExternalURL() {
+			env.Services[i].IngressProfile = env.IngressProfile
+		}
+		env.Services[i].TLS = env.serviceTLS(svc)
+
 		if svc.IsBlueGreenParentDeployment() {
-			blueGreenServices = append(blueGreenServices, copyBlueGreenService(env.Services[i], BlueService))
-			blueGreenServices = append(blueGreenServices, copyBlueGreenService(env.Services[i], GreenService))
+			childServices = append(childServices, copyBlueGreenService(env.Services[i], BlueService))
+			childServices = append(childServices, copyBlueGreenService(env.Services[i], GreenService))
+		}
+		if svc.IsCanaryParentDeployment() {
+			childServices = append(childServices, copyCanaryService(env.Services[i]))
 		}
 	}
 
-	services := append(env.Services, blueGreenServices...)
+	services := append(env.Services, childServices...)
 	util.LogTraceAsYaml("All services post-modification", services)
 	return services
 }
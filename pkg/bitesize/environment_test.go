@@ -1,9 +1,14 @@
 package bitesize
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/pearsontechnology/environment-operator/pkg/config"
 	"github.com/stretchr/testify/assert"
@@ -73,6 +78,274 @@ func TestEnvironmentBlueGreenLoading(t *testing.T) {
 	}
 }
 
+func TestEnvironmentIngressClass(t *testing.T) {
+	e, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment22")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	expected := map[string]string{
+		"frontend": "nginx",
+		"admin":    "nginx-internal",
+		"worker":   "",
+	}
+	for name, class := range expected {
+		svc := e.Services.FindByName(name)
+		if svc == nil {
+			t.Fatalf("Service %s not found", name)
+		}
+		if svc.IngressClass != class {
+			t.Errorf("Unexpected ingress class for %s: expected %q, got %q", name, class, svc.IngressClass)
+		}
+	}
+}
+
+func TestEnvironmentRouteClaims(t *testing.T) {
+	if _, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment23"); err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    services:
+      - name: front
+        external_url: www.example.com
+      - name: api
+        port: 8080
+        external_routes:
+          - host: www.example.com
+            path: /
+`
+	_, err := LoadFromString(str)
+	if err == nil || !strings.Contains(err.Error(), "claimed by both front and api") {
+		t.Errorf("Expected conflicting route error, got %v", err)
+	}
+}
+
+func TestEnvironmentTLS(t *testing.T) {
+	e, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment25")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	frontend := e.Services.FindByName("frontend")
+	expected := &TLSSettings{
+		Provider:   TLSProviderCertManager,
+		Issuer:     "letsencrypt",
+		IssuerKind: "ClusterIssuer",
+	}
+	if !reflect.DeepEqual(frontend.TLS, expected) {
+		t.Errorf("Unexpected frontend tls settings: expected %+v, got %+v", expected, frontend.TLS)
+	}
+	if frontend.TLSSecretName() != "frontend" {
+		t.Errorf("Unexpected frontend tls secret: %s", frontend.TLSSecretName())
+	}
+
+	// ssl is not enabled for admin
+	if admin := e.Services.FindByName("admin"); admin.TLS != nil {
+		t.Errorf("Unexpected admin tls settings: %+v", admin.TLS)
+	}
+}
+
+func TestEnvironmentTLSValidation(t *testing.T) {
+	var tests = []struct {
+		TLS   string
+		Error string
+	}{
+		{"{provider: cert-manager}", "tls.issuer: required for provider cert-manager"},
+		{"{provider: secret}", "tls.secret_name: required for provider secret"},
+		{"{provider: vault}", "Provider: regular expression mismatch"},
+		{"{provider: cert-manager, issuer: ca, issuer_kind: Other}", "IssuerKind: regular expression mismatch"},
+		{"{provider: secret, secret_name: wildcard-tls}", ""},
+	}
+
+	for _, tst := range tests {
+		str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    tls: ` + tst.TLS + `
+    services:
+      - name: front
+        ssl: "true"
+        external_url: www.example.com
+`
+		_, err := LoadFromString(str)
+		if tst.Error == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.TLS, err.Error())
+		}
+		if tst.Error != "" && (err == nil || !strings.Contains(err.Error(), tst.Error)) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.TLS, err)
+		}
+	}
+}
+
+func TestServiceTLSSecretName(t *testing.T) {
+	env := Environment{TLS: TLSSettings{Provider: TLSProviderSecret, SecretName: "wildcard-tls"}}
+	svc := Service{Name: "front", Ssl: "true", ExternalURL: []string{"www.example.com"}}
+
+	svc.TLS = env.serviceTLS(svc)
+	if svc.TLSSecretName() != "wildcard-tls" {
+		t.Errorf("Unexpected tls secret: %s", svc.TLSSecretName())
+	}
+
+	svc.Ssl = "false"
+	svc.TLS = env.serviceTLS(svc)
+	if svc.TLS != nil || svc.TLSSecretName() != "front" {
+		t.Errorf("Unexpected tls settings for service without ssl: %+v", svc.TLS)
+	}
+}
+
+func TestEnvironmentCanary(t *testing.T) {
+	e, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment26")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	frontend := e.Services.FindByName("frontend")
+	if !frontend.IsCanaryParentDeployment() || !reflect.DeepEqual(frontend.CanarySteps(), []int{20, 50}) {
+		t.Errorf("Unexpected frontend deployment: %+v", frontend.Deployment)
+	}
+
+	canary := e.Services.FindByName("frontend-canary")
+	if canary == nil {
+		t.Fatalf("Expected frontend-canary service")
+	}
+	if canary.IsCanaryParentDeployment() || canary.HasExternalURL() || canary.IngressProfile != "" {
+		t.Errorf("Unexpected canary service: %+v", canary)
+	}
+}
+
+func TestEnvironmentCanaryServiceType(t *testing.T) {
+	str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    services:
+      - name: front
+        external_url: www.example.com
+        ingress_profile: nginx
+        service_type: {type: NodePort}
+        deployment: {method: canary}
+`
+	e, err := LoadFromString(str)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	services := loadServices(e.Environments[0])
+	if front := services.FindByName("front"); front.KubernetesServiceType() != ServiceTypeNodePort {
+		t.Errorf("Expected NodePort parent service, got %s", front.KubernetesServiceType())
+	}
+	canary := services.FindByName("front-canary")
+	if canary == nil {
+		t.Fatalf("Expected front-canary service")
+	}
+	if canary.ServiceType != nil {
+		t.Errorf("Expected canary service not to be exposed with service_type, got %+v", canary.ServiceType)
+	}
+}
+
+func TestEnvironmentCanaryAutoscaling(t *testing.T) {
+	str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    services:
+      - name: worker
+        external_url: worker.example.com
+        ingress_profile: nginx
+        deployment: {method: canary}
+        keda:
+          min_replicas: 0
+          max_replicas: 5
+          triggers:
+            - type: aws-sqs-queue
+              metadata: {queueURL: "https://sqs.eu-west-1.amazonaws.com/123/jobs", queueLength: "5"}
+      - name: front
+        external_url: www.example.com
+        ingress_profile: nginx
+        deployment: {method: canary}
+        hpa: {min_replicas: 2, max_replicas: 4, target_cpu_utilization_percentage: 80}
+`
+	e, err := LoadFromString(str)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	services := loadServices(e.Environments[0])
+	if worker := services.FindByName("worker"); !worker.HasKeda() {
+		t.Errorf("Expected worker scaled by keda, got %+v", worker)
+	}
+	if canary := services.FindByName("worker-canary"); canary == nil || canary.HasKeda() || canary.Replicas < 1 {
+		t.Errorf("Expected worker-canary without keda, got %+v", canary)
+	}
+	if front := services.FindByName("front"); front.HPA.MinReplicas != 2 {
+		t.Errorf("Expected front hpa, got %+v", front.HPA)
+	}
+	if canary := services.FindByName("front-canary"); canary == nil || canary.HPA.MinReplicas != 0 || canary.HPA.MaxReplicas != 0 {
+		t.Errorf("Expected front-canary without hpa, got %+v", canary)
+	}
+}
+
+func TestEnvironmentCanaryValidation(t *testing.T) {
+	var tests = []struct {
+		Service string
+		Error   string
+	}{
+		{"{name: front, port: 80, external_url: www.example.com, ingress_profile: nginx, deployment: {method: canary}}", ""},
+		{"{name: front, port: 80, external_url: www.example.com, service_mesh: enable, deployment: {method: canary}}", ""},
+		{"{name: front, port: 80, deployment: {method: canary}}", "canary deployment method requires external_url"},
+		{"{name: front, port: 80, external_url: www.example.com, deployment: {method: canary}}", "requires service_mesh or ingress_profile nginx"},
+		{"{name: front, port: 80, external_url: www.example.com, ingress_profile: nginx, deployment: {method: canary, canary: {steps: [50, 20]}}}", "canary steps must be increasing"},
+		{"{name: front, port: 80, external_url: www.example.com, ingress_profile: nginx, deployment: {method: canary, canary: {steps: [100]}}}", "canary steps must be increasing"},
+	}
+
+	for _, tst := range tests {
+		str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    services:
+      - ` + tst.Service + `
+`
+		_, err := LoadFromString(str)
+		if tst.Error == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.Service, err.Error())
+		}
+		if tst.Error != "" && (err == nil || !strings.Contains(err.Error(), tst.Error)) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Service, err)
+		}
+	}
+}
+
+func TestServiceCanaryWeight(t *testing.T) {
+	svc := Service{Name: "front", Deployment: &DeploymentSettings{Method: "canary"}}
+	if svc.CanaryWeight() != 0 {
+		t.Errorf("Expected no canary weight without canary state, got %d", svc.CanaryWeight())
+	}
+
+	svc.Deployment.CanaryState = &CanaryState{Step: 1, Status: CanaryProgressing}
+	if svc.CanaryWeight() != DefaultCanarySteps[1] {
+		t.Errorf("Expected canary weight %d, got %d", DefaultCanarySteps[1], svc.CanaryWeight())
+	}
+
+	svc.Deployment.CanaryState.Status = CanaryAborted
+	if svc.CanaryWeight() != 0 {
+		t.Errorf("Expected no canary weight for aborted canary, got %d", svc.CanaryWeight())
+	}
+
+	state := CanaryState{Step: 2, StartedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Status: CanaryProgressing}
+	if parsed := CanaryStateFromAnnotations(state.Annotations()); !reflect.DeepEqual(*parsed, state) {
+		t.Errorf("Expected canary state %+v, got %+v", state, *parsed)
+	}
+}
+
 func TestEnvironmentImportConfigMap(t *testing.T) {
 	config.Env.UseAuth = false
 
@@ -165,3 +438,956 @@ func TestSortVolumesByVolName(t *testing.T) {
 	}
 	assert.Equal(t, volumesExpected, volumesSorted, "The volumes should be sorted")
 }
+
+func TestEnvironmentTrafficPolicy(t *testing.T) {
+	e, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment28")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	policy := e.Services.FindByName("api").TrafficPolicy
+	expected := &TrafficPolicy{
+		ConnectionPool: &ConnectionPool{
+			MaxConnections:           100,
+			ConnectTimeout:           "3s",
+			HTTP1MaxPendingRequests:  10,
+			MaxRequestsPerConnection: 1,
+		},
+		OutlierDetection: &OutlierDetection{
+			ConsecutiveErrors:  5,
+			Interval:           "10s",
+			BaseEjectionTime:   "30s",
+			MaxEjectionPercent: 50,
+		},
+		Retries: &Retries{Attempts: 3, PerTryTimeout: "2s", RetryOn: "5xx,connect-failure"},
+		Timeout: "10s",
+		TLSMode: "ISTIO_MUTUAL",
+	}
+	if !reflect.DeepEqual(policy, expected) {
+		t.Errorf("Unexpected traffic policy %+v", policy)
+	}
+	if !reflect.DeepEqual(TrafficPolicyFromAnnotation(TrafficPolicyAnnotation(policy)), expected) {
+		t.Error("Expected traffic policy to survive annotation round trip")
+	}
+}
+
+func TestEnvironmentTrafficPolicyValidation(t *testing.T) {
+	var tests = []struct {
+		Service string
+		Error   string
+	}{
+		{"{name: api, port: 80, service_mesh: enable, traffic_policy: {timeout: 5s}}", ""},
+		{"{name: api, port: 80, traffic_policy: {timeout: 5s}}", "traffic_policy requires service_mesh"},
+		{"{name: api, port: 80, service_mesh: enable, traffic_policy: {timeout: 5}}", "traffic_policy.timeout: invalid duration"},
+		{"{name: api, port: 80, service_mesh: enable, traffic_policy: {retries: {attempts: 2, per_try_timeout: soon}}}", "traffic_policy.retries.per_try_timeout"},
+		{"{name: api, port: 80, service_mesh: enable, traffic_policy: {tls_mode: STRICT}}", "TLSMode"},
+		{"{name: api, port: 80, service_mesh: enable, traffic_policy: {outlier_detection: {max_ejection_percent: 120}}}", "MaxEjectionPercent"},
+	}
+
+	for _, tst := range tests {
+		str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    services:
+      - ` + tst.Service + `
+`
+		_, err := LoadFromString(str)
+		if tst.Error == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.Service, err.Error())
+		}
+		if tst.Error != "" && (err == nil || !strings.Contains(err.Error(), tst.Error)) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Service, err)
+		}
+	}
+}
+
+func TestEnvironmentServiceType(t *testing.T) {
+	e, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment32")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	expected := map[string]*ServiceType{
+		"mqtt": {
+			Type:                     ServiceTypeLoadBalancer,
+			LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+			ExternalTrafficPolicy:    "Local",
+			Annotations:              map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"},
+		},
+		"sftp":      {Type: ServiceTypeNodePort, ExternalTrafficPolicy: "Cluster"},
+		"legacy-db": {Type: ServiceTypeExternalName, ExternalName: "db.legacy.example.com"},
+	}
+	for name, serviceType := range expected {
+		if svc := e.Services.FindByName(name); !reflect.DeepEqual(svc.ServiceType, serviceType) {
+			t.Errorf("Unexpected service_type of %s: %+v", name, svc.ServiceType)
+		}
+	}
+	if !e.Services.FindByName("legacy-db").IsExternalName() || e.Services.FindByName("mqtt").IsExternalName() {
+		t.Error("Expected only legacy-db to be an ExternalName service")
+	}
+}
+
+func TestEnvironmentServiceTypeValidation(t *testing.T) {
+	var tests = []struct {
+		Service string
+		Error   string
+	}{
+		{"{name: api, application: api, port: 80, service_type: {type: ClusterIP}}", ""},
+		{"{name: api, application: api, port: 80, service_type: {type: LoadBalancer, load_balancer_source_ranges: [10.0.0.0/8]}}", ""},
+		{"{name: api, port: 80, service_type: {type: ExternalName, external_name: api.example.com}}", ""},
+		{"{name: api, application: api, port: 80, service_type: {type: Headless}}", "Type"},
+		{"{name: api, application: api, port: 80, service_type: {type: LoadBalancer, load_balancer_source_ranges: [10.0.0.0]}}", "LoadBalancerSourceRanges"},
+		{"{name: api, application: api, port: 80, service_type: {type: LoadBalancer, external_traffic_policy: Node}}", "ExternalTrafficPolicy"},
+		{"{name: api, application: api, port: 80, service_type: {type: NodePort, load_balancer_source_ranges: [10.0.0.0/8]}}", "load_balancer_source_ranges requires type LoadBalancer"},
+		{"{name: api, application: api, port: 80, service_type: {external_traffic_policy: Local}}", "external_traffic_policy requires type NodePort or LoadBalancer"},
+		{"{name: api, application: api, port: 80, service_type: {external_name: api.example.com}}", "external_name requires type ExternalName"},
+		{"{name: api, port: 80, service_type: {type: ExternalName}}", "requires external_name"},
+		{"{name: api, port: 80, external_url: api.example.com, service_type: {type: ExternalName, external_name: api.example.com}}", "external_url not valid with service_type ExternalName"},
+		{"{name: api, port: 80, service_mesh: enable, application: api, service_type: {type: ExternalName, external_name: api.example.com}}", "service_mesh,application not valid"},
+		{"{name: api, application: api, port: 80, workload: statefulset, service_type: {type: NodePort}}", "not supported for statefulset"},
+		{"{name: db, type: mysql, version: '5.7', service_type: {type: LoadBalancer}}", "not supported for custom resources"},
+		{"{name: api, application: api, port: 80, service_type: {type: LoadBalancer, annotations: [{name: traffic_policy, value: x}]}}", "is set by environment operator"},
+	}
+
+	for _, tst := range tests {
+		str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    services:
+      - ` + tst.Service + `
+`
+		_, err := LoadFromString(str)
+		if tst.Error == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.Service, err.Error())
+		}
+		if tst.Error != "" && (err == nil || !strings.Contains(err.Error(), tst.Error)) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Service, err)
+		}
+	}
+}
+
+func TestEnvironmentHPAMetrics(t *testing.T) {
+	e, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment33")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	hpa := e.Services.FindByName("worker").HPA
+	expected := []Metric{
+		{Type: MetricTypeResource, Name: "cpu", TargetAverageUtilization: 80},
+		{Type: MetricTypeResource, Name: "memory", TargetAverageValue: "512Mi"},
+		{Type: MetricTypePods, Name: "requests_per_second", Selector: map[string]string{"route": "api"}, TargetAverageValue: "100"},
+		{
+			Type:        MetricTypeObject,
+			Name:        "requests_per_second",
+			Object:      &MetricObject{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Name: "worker"},
+			TargetValue: "2k",
+		},
+		{Type: MetricTypeExternal, Name: "queue_messages_ready", Selector: map[string]string{"queue": "worker_tasks"}, TargetAverageValue: "30"},
+	}
+	if !reflect.DeepEqual(hpa.Metrics, expected) || hpa.Metric.Name != "" {
+		t.Errorf("Unexpected hpa metrics %+v", hpa.Metrics)
+	}
+
+	up, down := int32(0), int32(300)
+	behavior := &ScalingBehavior{
+		ScaleUp: &ScalingRules{
+			StabilizationWindowSeconds: &up,
+			Policies:                   []ScalingPolicy{{Type: "Percent", Value: 100, PeriodSeconds: 15}},
+		},
+		ScaleDown: &ScalingRules{
+			StabilizationWindowSeconds: &down,
+			SelectPolicy:               "Min",
+			Policies:                   []ScalingPolicy{{Type: "Pods", Value: 1, PeriodSeconds: 60}},
+		},
+	}
+	if !reflect.DeepEqual(hpa.Behavior, behavior) {
+		t.Errorf("Unexpected hpa behavior %+v", hpa.Behavior)
+	}
+	if !reflect.DeepEqual(ScalingBehaviorFromJSON(ScalingBehaviorJSON(hpa.Behavior)), behavior) {
+		t.Error("Expected hpa behavior to survive JSON round trip")
+	}
+}
+
+func TestEnvironmentHPAValidation(t *testing.T) {
+	var tests = []struct {
+		HPA   string
+		Error string
+	}{
+		{"{min_replicas: 1, max_replicas: 2}", ""},
+		{"{min_replicas: 1, max_replicas: 2, metric: {name: cpu, target_average_utilization: 90}}", ""},
+		{"{min_replicas: 1, max_replicas: 2, metric: {name: cpu, target_average_utilization: 90}, metrics: [{name: memory, target_average_utilization: 90}]}", "hpa.metric: not valid with hpa.metrics"},
+		{"{min_replicas: 1, max_replicas: 2, metrics: [{name: cpu, target_average_utilization: 50}]}", "hpa.metrics[0]: resource metric cpu utilization invalid"},
+		{"{min_replicas: 1, max_replicas: 2, metrics: [{type: resource, name: gpu, target_average_utilization: 80}]}", "only cpu and memory are supported"},
+		{"{min_replicas: 1, max_replicas: 2, metrics: [{name: cpu}]}", "requires one of target_average_utilization or target_average_value"},
+		{"{min_replicas: 1, max_replicas: 2, metrics: [{name: rps, target_value: 10}]}", "pods metric rps requires target_average_value"},
+		{"{min_replicas: 1, max_replicas: 2, metrics: [{name: rps, target_average_value: lots}]}", "hpa.metrics[0].target_average_value: invalid quantity lots"},
+		{"{min_replicas: 1, max_replicas: 2, metrics: [{type: object, name: rps, target_value: 10}]}", "object metric rps requires object"},
+		{"{min_replicas: 1, max_replicas: 2, metrics: [{type: external, name: queue, target_value: 10, target_average_value: 1}]}", "requires one of target_value or target_average_value"},
+		{"{min_replicas: 1, max_replicas: 2, metrics: [{type: queue, name: queue, target_value: 10}]}", "Type"},
+		{"{min_replicas: 1, max_replicas: 2, behavior: {scale_down: {stabilization_window_seconds: 7200}}}", "hpa.behavior.scale_down.stabilization_window_seconds invalid"},
+		{"{min_replicas: 1, max_replicas: 2, behavior: {scale_up: {policies: [{type: Replicas, value: 1, period_seconds: 60}]}}}", "scale_up.policies[0].type invalid"},
+		{"{min_replicas: 1, max_replicas: 2, behavior: {scale_up: {policies: [{type: Pods, value: 0, period_seconds: 60}]}}}", "scale_up.policies[0].value invalid"},
+		{"{min_replicas: 1, max_replicas: 2, behavior: {scale_up: {select_policy: Average}}}", "SelectPolicy"},
+	}
+
+	for _, tst := range tests {
+		str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    services:
+      - {name: api, application: api, hpa: ` + tst.HPA + `}
+`
+		_, err := LoadFromString(str)
+		if tst.Error == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.HPA, err.Error())
+		}
+		if tst.Error != "" && (err == nil || !strings.Contains(err.Error(), tst.Error)) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.HPA, err)
+		}
+	}
+}
+
+func TestEnvironmentKeda(t *testing.T) {
+	e, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment34")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	sqs := e.Services.FindByName("sqs-consumer")
+	expected := &KedaScaling{
+		MinReplicas:     0,
+		MaxReplicas:     20,
+		PollingInterval: KedaDefaultPollingInterval,
+		CooldownPeriod:  KedaDefaultCooldownPeriod,
+		Triggers: []KedaTrigger{{
+			Type:              "aws-sqs-queue",
+			AuthenticationRef: "keda-aws",
+			Metadata: map[string]string{
+				"queueURL":    "https://sqs.eu-west-1.amazonaws.com/123456789012/orders",
+				"queueLength": "5",
+				"awsRegion":   "eu-west-1",
+			},
+		}},
+	}
+	if !reflect.DeepEqual(sqs.Keda, expected) {
+		t.Errorf("Unexpected keda settings %+v", sqs.Keda)
+	}
+	if sqs.Replicas != 1 {
+		t.Errorf("Expected services scaling from zero to start with 1 replica, got %d", sqs.Replicas)
+	}
+
+	kafka := e.Services.FindByName("kafka-consumer")
+	if kafka.Keda.PollingInterval != 15 || kafka.Keda.CooldownPeriod != 600 || kafka.Replicas != 1 {
+		t.Errorf("Unexpected keda settings %+v", kafka.Keda)
+	}
+	if kafka.HPA.MinReplicas != 0 {
+		t.Errorf("Expected no hpa for keda scaled service, got %+v", kafka.HPA)
+	}
+}
+
+func TestEnvironmentKedaValidation(t *testing.T) {
+	trigger := "triggers: [{type: aws-sqs-queue, metadata: {queueURL: orders}}]"
+	var tests = []struct {
+		Service string
+		Error   string
+	}{
+		{"{name: api, keda: {min_replicas: 0, max_replicas: 2, " + trigger + "}}", ""},
+		{"{name: api, hpa: {min_replicas: 1, max_replicas: 2}, keda: {max_replicas: 2, " + trigger + "}}", "api: keda not valid with hpa"},
+		{"{name: api, keda: {min_replicas: 0, max_replicas: 0, " + trigger + "}}", "api: keda.max_replicas invalid"},
+		{"{name: api, keda: {min_replicas: 3, max_replicas: 2, " + trigger + "}}", "api: keda.min_replicas invalid"},
+		{"{name: api, keda: {min_replicas: -1, max_replicas: 2, " + trigger + "}}", "api: keda.min_replicas invalid"},
+		{"{name: api, keda: {max_replicas: 2}}", "api: keda.triggers: at least one trigger is required"},
+		{"{name: api, keda: {max_replicas: 2, triggers: [{metadata: {queueURL: orders}}]}}", "api: keda.triggers[0].type invalid"},
+		{"{name: api, keda: {max_replicas: 2, triggers: [{type: kafka}]}}", "api: keda.triggers[0].metadata is required"},
+		{"{name: api, deployment: {method: bluegreen}, keda: {max_replicas: 2, " + trigger + "}}", "api: keda is not supported for bluegreen"},
+		{"{name: db, type: mysql, keda: {max_replicas: 2, " + trigger + "}}", "db: keda is not supported for custom resources"},
+		{"{name: api, service_type: {type: ExternalName, external_name: api.example.com}, keda: {max_replicas: 2, " + trigger + "}}", "api: keda not valid with service_type ExternalName"},
+	}
+
+	for _, tst := range tests {
+		str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    services:
+      - ` + tst.Service + `
+`
+		_, err := LoadFromString(str)
+		if tst.Error == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.Service, err.Error())
+		}
+		if tst.Error != "" && (err == nil || !strings.Contains(err.Error(), tst.Error)) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Service, err)
+		}
+	}
+}
+
+func TestEnvironmentCustomResource(t *testing.T) {
+	e, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment29")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	svc := e.Services.FindByName("api-monitor")
+	if !svc.IsCustomResource() || svc.Type != "servicemonitor" || svc.APIGroup() != "monitoring.coreos.com" {
+		t.Errorf("Unexpected custom resource service %+v", svc)
+	}
+	expected := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{"name": "api"},
+		},
+		"endpoints": []interface{}{
+			map[string]interface{}{"port": "tcp-80", "interval": "30s"},
+		},
+	}
+	if !reflect.DeepEqual(svc.Spec, expected) {
+		t.Errorf("Unexpected custom resource spec %+v", svc.Spec)
+	}
+	if e.Services.FindByName("api").IsCustomResource() {
+		t.Error("Expected api not to be a custom resource")
+	}
+}
+
+func TestEnvironmentCustomResourceValidation(t *testing.T) {
+	var tests = []struct {
+		Service string
+		Error   string
+	}{
+		{"{name: widget, api_version: example.com/v1, kind: Widget, spec: {size: 3}}", ""},
+		{"{name: widget, api_version: example.com/v1, spec: {size: 3}}", "service.kind: required"},
+		{"{name: widget, api_version: example.com/v1, kind: Widget, type: mysql}", "can't be combined with type"},
+		{"{name: widget, api_version: v1, kind: Widget}", "must be group/version"},
+	}
+
+	for _, tst := range tests {
+		str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    services:
+      - ` + tst.Service + `
+`
+		_, err := LoadFromString(str)
+		if tst.Error == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.Service, err.Error())
+		}
+		if tst.Error != "" && (err == nil || !strings.Contains(err.Error(), tst.Error)) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Service, err)
+		}
+	}
+}
+
+func TestEnvironmentDeletionProtection(t *testing.T) {
+	var tests = []struct {
+		Service   string
+		Protected bool
+		Error     string
+	}{
+		{"{name: db, type: mysql}", true, ""},
+		{"{name: db, type: mysql, deletion_protection: false}", false, ""},
+		{"{name: topic, type: sns}", false, ""},
+		{"{name: topic, type: sns, deletion_protection: true}", true, ""},
+		{"{name: api, port: 80, deletion_protection: true}", false, "deletion_protection requires type or kind"},
+	}
+
+	for _, tst := range tests {
+		str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    services:
+      - ` + tst.Service + `
+`
+		cfg, err := LoadFromString(str)
+		if tst.Error != "" {
+			if err == nil || !strings.Contains(err.Error(), tst.Error) {
+				t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Service, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.Service, err.Error())
+			continue
+		}
+		if svc := cfg.Environments[0].Services[0]; svc.IsDeletionProtected() != tst.Protected {
+			t.Errorf("Expected deletion protected %t for %s", tst.Protected, tst.Service)
+		}
+	}
+}
+
+func TestEnvironmentServiceOutputsValidation(t *testing.T) {
+	db := "{name: db, type: mysql}"
+	var tests = []struct {
+		Service string
+		Error   string
+	}{
+		{"{name: api, port: 80, env: [{name: DB_HOST, value_from: {service: db, field: endpoint}}]}", ""},
+		{"{name: api, port: 80, env: [{name: DB_HOST, value_from: {service: cache, field: endpoint}}]}", "service cache not found"},
+		{"{name: api, port: 80, env: [{name: DB_HOST, value_from: {service: web, field: endpoint}}]}", "service web has no outputs"},
+		{"{name: api, port: 80, env: [{name: DB_HOST, value_from: {service: db}}]}", "requires service and field"},
+		{"{name: api, port: 80, env: [{name: DB_HOST, value: x, value_from: {service: db, field: endpoint}}]}", "can't be combined"},
+		{"{name: api, port: 80, init_containers: [{name: init, application: init, version: 1, env: [{name: DB_HOST, value_from: {service: db, field: endpoint}}]}]}", "not supported in init_containers"},
+	}
+
+	for _, tst := range tests {
+		str := `
+project: test
+environments:
+  - name: dev
+    namespace: dev
+    services:
+      - ` + db + `
+      - {name: web, port: 80}
+      - ` + tst.Service + `
+`
+		_, err := LoadFromString(str)
+		if tst.Error == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.Service, err.Error())
+		}
+		if tst.Error != "" && (err == nil || !strings.Contains(err.Error(), tst.Error)) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Service, err)
+		}
+	}
+}
+
+func TestEnvironmentExtends(t *testing.T) {
+	e, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment31")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	if e.Namespace != "environment-extends" || e.IngressProfile != "nginx" {
+		t.Errorf("Unexpected environment settings: namespace %s, ingress profile %s", e.Namespace, e.IngressProfile)
+	}
+	if e.Services.FindByName("worker") != nil {
+		t.Error("Expected worker to be removed")
+	}
+	if e.Services.FindByName("web") == nil || e.Services.FindByName("cache") == nil {
+		t.Errorf("Expected web and cache services, got %+v", e.Services)
+	}
+
+	api := e.Services.FindByName("api")
+	if api == nil {
+		t.Fatal("Expected api service")
+	}
+	if api.Version != "1.1.0" || !reflect.DeepEqual(api.Ports.Numbers(), []int{80}) || api.Replicas != 2 {
+		t.Errorf("Unexpected api version %s, ports %v, replicas %d", api.Version, api.Ports, api.Replicas)
+	}
+	if len(api.EnvVars) != 1 || api.EnvVars[0].Value != "info" {
+		t.Errorf("Unexpected api env %+v", api.EnvVars)
+	}
+	if api.Deployment == nil || api.Deployment.Method != "bluegreen" || api.ActiveDeploymentTag() != GreenService {
+		t.Errorf("Unexpected api deployment %+v", api.Deployment)
+	}
+
+	base, err := LoadEnvironment("../../test/assets/environments.bitesize", "environment31-base")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+	if svc := base.Services.FindByName("api"); svc.Version != "1.0.0" || svc.ActiveDeploymentTag() != BlueService {
+		t.Errorf("Expected base environment to be unchanged, got %+v", svc)
+	}
+}
+
+func TestEnvironmentExtendsErrors(t *testing.T) {
+	var tests = []struct {
+		Environments string
+		Error        string
+	}{
+		{
+			"[{name: dev, extends: base}]",
+			"environment dev: extends unknown environment base",
+		},
+		{
+			"[{name: a, extends: b}, {name: b, extends: a}]",
+			"environment a: extends cycle a -> b -> a",
+		},
+		{
+			"[{name: base, services: [{name: api, port: 80}]}, {name: dev, extends: base, services: [{name: web, remove: true}]}]",
+			"environment dev: service web: remove: not inherited from base",
+		},
+		{
+			"[{name: dev, services: [{name: api, remove: true}]}]",
+			"remove is only valid in environments with extends",
+		},
+		{
+			"[{name: base, services: [{name: api, port: 80, replicas: 2}]}, {name: dev, extends: base, services: [{name: api, replicas: many}]}]",
+			"environment dev (extends base): ",
+		},
+		{
+			"[{name: base, services: [{name: api, port: 80, replicas: 2}]}, {name: dev, extends: base, services: [{name: api, replicas: many}]}]",
+			"(service api: name, replicas from dev; port from base)",
+		},
+		{
+			"[{name: base, services: [{name: api, port: 80}]}, {extends: base}]",
+			"environment <nil>: name is required",
+		},
+	}
+
+	for _, tst := range tests {
+		_, err := LoadFromString("project: test\nenvironments: " + tst.Environments)
+		if err == nil || !strings.Contains(err.Error(), tst.Error) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Environments, err)
+		}
+	}
+}
+
+func TestEnvironmentExtendsNumericName(t *testing.T) {
+	b, err := LoadFromString("project: test\nenvironments: [{name: base, namespace: base, services: [{name: api, port: 80}]}, {name: 123, namespace: dev, extends: base}]")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	e := b.Environments[1]
+	if e.Name != "123" || e.Namespace != "dev" || e.Services.FindByName("api") == nil {
+		t.Errorf("Unexpected environment %+v", e)
+	}
+}
+
+func TestEnvironmentVars(t *testing.T) {
+	str := `
+project: test
+vars:
+  domain: example.com
+  replicas: 2
+environments:
+  - name: base
+    namespace: base
+    services:
+      - name: api
+        port: 80
+        replicas: ${replicas}
+        external_url: api.${namespace}.${domain}
+        env:
+          - name: SHELL_VALUE
+            value: $${HOME}
+          - name: PROJECT
+            value: ${project}-${environment}
+  - name: dev
+    namespace: dev
+    extends: base
+    vars:
+      replicas: 3
+      version: "1.10"
+    services:
+      - name: api
+        version: ${version}
+`
+	cfg, err := LoadFromString(str)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	var tests = []struct {
+		Environment string
+		Replicas    int
+		URL         string
+		Project     string
+		Version     string
+	}{
+		{"base", 2, "api.base.example.com", "test-base", ""},
+		{"dev", 3, "api.dev.example.com", "test-dev", "1.10"},
+	}
+
+	for _, tst := range tests {
+		var env *Environment
+		for i := range cfg.Environments {
+			if cfg.Environments[i].Name == tst.Environment {
+				env = &cfg.Environments[i]
+			}
+		}
+		svc := env.Services.FindByName("api")
+		if svc.Replicas != tst.Replicas || svc.ExternalURL[0] != tst.URL || svc.Version != tst.Version {
+			t.Errorf("Unexpected %s api: replicas %d, external_url %v, version %s", tst.Environment, svc.Replicas, svc.ExternalURL, svc.Version)
+		}
+		expected := []EnvVar{{Name: "SHELL_VALUE", Value: "${HOME}"}, {Name: "PROJECT", Value: tst.Project}}
+		if !reflect.DeepEqual(svc.EnvVars, expected) {
+			t.Errorf("Unexpected %s api env: %+v", tst.Environment, svc.EnvVars)
+		}
+	}
+}
+
+func TestEnvironmentVarsErrors(t *testing.T) {
+	var tests = []struct {
+		Environment string
+		Error       string
+	}{
+		{
+			"{name: dev, vars: {}, services: [{name: api, port: 80, env: [{name: HOST, value: '${host}'}]}]}",
+			"environment dev: services[api].env[HOST].value: undefined variable ${host}",
+		},
+		{
+			"{name: dev, vars: {}, services: [{name: api, port: 80, external_url: ['${namespace}.example.com']}]}",
+			"environment dev: services[api].external_url[0]: undefined variable ${namespace}",
+		},
+		{
+			"{name: dev, vars: {namespace: dev}, services: [{name: api, port: 80}]}",
+			"environment dev: vars.namespace: built-in variable can't be redefined",
+		},
+		{
+			"{name: dev, vars: {host: '${domain}'}, services: [{name: api, port: 80}]}",
+			"environment dev: vars.host: undefined variable ${domain}",
+		},
+		{
+			"{name: dev, vars: {}, services: [{name: api, port: 80, env: [{name: HOST, value: '${my-host}'}]}]}",
+			"invalid variable reference ${my-host}",
+		},
+	}
+
+	for _, tst := range tests {
+		_, err := LoadFromString("project: test\nenvironments:\n  - " + tst.Environment)
+		if err == nil || !strings.Contains(err.Error(), tst.Error) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Environment, err)
+		}
+	}
+}
+
+func TestEnvironmentWithoutVars(t *testing.T) {
+	// manifests written before vars existed pass ${...} through to services
+	str := `
+project: test
+environments:
+  - name: base
+    namespace: base
+    services:
+      - name: api
+        port: 80
+        env:
+          - name: GREETING
+            value: hello ${FOO}
+        command: ["sh", "-c", "echo ${HOME}"]
+  - name: dev
+    namespace: dev
+    extends: base
+`
+	cfg, err := LoadFromString(str)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	for _, env := range cfg.Environments {
+		svc := env.Services.FindByName("api")
+		if len(svc.EnvVars) != 1 || svc.EnvVars[0].Value != "hello ${FOO}" {
+			t.Errorf("Unexpected %s api env: %+v", env.Name, svc.EnvVars)
+		}
+		if len(svc.Commands) != 3 || svc.Commands[2] != "echo ${HOME}" {
+			t.Errorf("Unexpected %s api commands: %v", env.Name, svc.Commands)
+		}
+	}
+}
+
+func TestEnvironmentInclude(t *testing.T) {
+	e, err := LoadEnvironment("../../test/assets/include", "dev")
+	if err != nil {
+		t.Fatalf("Unexpected error when loading environment: %s", err.Error())
+	}
+
+	var names []string
+	for _, svc := range e.Services {
+		names = append(names, svc.Name)
+	}
+	expected := []string{"api", "indexer", "mailer", "orders-db", "web"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected services %v, got %v", expected, names)
+	}
+	if svc := e.Services.FindByName("api"); svc.Replicas != 2 {
+		t.Errorf("Unexpected api replicas %d", svc.Replicas)
+	}
+	if svc := e.Services.FindByName("orders-db"); svc.Type != "mysql" {
+		t.Errorf("Unexpected orders-db type %s", svc.Type)
+	}
+}
+
+func TestEnvironmentIncludeErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"services/api.yaml":  "name: api\nport: 80\n",
+		"duplicate/api.yaml": "name: api\nport: 8080\n",
+		"invalid/api.yaml":   "name: api\nreplicas: many\n",
+		"unnamed/api.yaml":   "port: 80\n",
+		"scalar/api.yaml":    "api\n",
+		"list/services.yaml": "- name: a\n  port: 80\n- name: a\n  port: 81\n",
+		"vars/api.yaml":      "name: api\nreplicas: ${replicas}\n",
+		"vars/resolved.yaml": "name: worker\nexternal_url: ${environment}.example.com\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		Environment string
+		Error       string
+	}{
+		{"{name: dev, include: [services], services: [{name: api, port: 80}]}", "environment dev: include.services/api.yaml: service api is already defined in services"},
+		{"{name: dev, include: [services, duplicate]}", "environment dev: include.duplicate/api.yaml: service api is already defined in services/api.yaml"},
+		{"{name: dev, include: [list/*.yaml]}", "service a is already defined in list/services.yaml"},
+		{"{name: dev, include: [invalid/api.yaml]}", "include.invalid/api.yaml: service.yaml: unmarshal errors:\n  line 2: cannot unmarshal !!str `many` into int"},
+		{"{name: dev, include: [unnamed]}", "include.unnamed/api.yaml: service.Name: zero value"},
+		{"{name: dev, include: [scalar]}", "include.scalar/api.yaml: expected a service or a list of services"},
+		{"{name: dev, include: [missing/*.yaml]}", "include.missing/*.yaml: no files found"},
+		{"{name: dev, include: [../*.yaml]}", "include.../*.yaml: outside of the manifest directory"},
+		{"{name: dev, vars: {}, include: [vars/api.yaml]}", "environment dev: services[api].replicas: undefined variable ${replicas}"},
+		{"{name: dev, vars: {}, include: [vars/resolved.yaml]}", ""},
+	}
+
+	for _, tst := range tests {
+		path := filepath.Join(dir, "environments.bitesize")
+		if err := ioutil.WriteFile(path, []byte("project: test\nenvironments:\n  - "+tst.Environment), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadFromFile(dir)
+		if tst.Error == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.Environment, err.Error())
+		}
+		if tst.Error != "" && (err == nil || !strings.Contains(err.Error(), tst.Error)) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Environment, err)
+		}
+	}
+}
+
+func TestEnvironmentStrict(t *testing.T) {
+	var tests = []struct {
+		Strict string
+		Error  string
+	}{
+		{"true", "unknown keys: environments[dev].services[api].health_chek (did you mean health_check?), environments[dev].services[api].replica (did you mean replicas?), environments[dev].services[api].volumes[data].sise (did you mean size?), environments[dev].tsl (did you mean tls?)"},
+		{"warn", ""},
+		{"false", ""},
+		{"always", "strict: must be true, false or warn"},
+	}
+
+	for _, tst := range tests {
+		str := `
+project: test
+strict: ` + tst.Strict + `
+environments:
+  - name: dev
+    tsl:
+      provider: secret
+    services:
+      - name: api
+        port: 80
+        replica: 3
+        health_chek:
+          command: ["/bin/true"]
+        volumes:
+          - name: data
+            path: /data
+            sise: 1G
+`
+		cfg, err := LoadFromString(str)
+		if tst.Error != "" {
+			if err == nil || err.Error() != tst.Error {
+				t.Errorf("Expected error %q for strict %s, got %v", tst.Error, tst.Strict, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for strict %s: %s", tst.Strict, err.Error())
+			continue
+		}
+		if svc := cfg.Environments[0].Services.FindByName("api"); svc.Replicas != 1 {
+			t.Errorf("Expected unknown replica key to be ignored, got %d replicas", svc.Replicas)
+		}
+	}
+}
+
+func TestEnvironmentStrictAcceptsValidKeys(t *testing.T) {
+	contents, err := ioutil.ReadFile("../../test/assets/environments2.bitesize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadFromString("strict: true\n" + string(contents)); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+}
+
+const legacyEnvironment = `
+project: test
+environments:
+  - name: dev
+    tests:
+      - name: smoke
+        repository: git@github.com:example/tests.git
+    services:
+      - name: api
+        port: 80
+        ssl: "true"
+        httpsOnly: "false"
+        external_url: api.example.com
+        health_check:
+          command: ["/bin/check"]
+          initial_delay: 5
+        env:
+          - secret: DB_PASSWORD
+            value: db
+          - secret: TOKEN
+            value: tokens/api
+`
+
+func TestEnvironmentMigration(t *testing.T) {
+	cfg, err := LoadFromString(legacyEnvironment)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if cfg.Version != LatestVersion {
+		t.Errorf("Expected version %d, got %d", LatestVersion, cfg.Version)
+	}
+
+	env := cfg.Environments[0]
+	if len(env.Tests) != 0 {
+		t.Errorf("Expected tests to be removed, got %v", env.Tests)
+	}
+	api := env.Services.FindByName("api")
+	if api.HealthCheck != nil {
+		t.Errorf("Expected health_check to be removed, got %+v", api.HealthCheck)
+	}
+	// health_check is only replaced by environment-validator -migrate
+	if api.LivenessProbe != nil {
+		t.Errorf("Expected no liveness_probe on load, got %+v", api.LivenessProbe)
+	}
+	if api.Ssl != "true" || api.HTTPSOnly != "false" {
+		t.Errorf("Unexpected ssl %q, httpsOnly %q", api.Ssl, api.HTTPSOnly)
+	}
+	values := map[string]string{}
+	for _, e := range api.EnvVars {
+		values[e.Secret] = e.Value
+	}
+	if values["DB_PASSWORD"] != "db/db" || values["TOKEN"] != "tokens/api" {
+		t.Errorf("Unexpected secret env values %v", values)
+	}
+}
+
+func TestEnvironmentVersionErrors(t *testing.T) {
+	var tests = []struct {
+		Version string
+		Service string
+		Error   string
+	}{
+		{"2", `{name: api, ssl: "true"}`, `environment dev: services[api].ssl: not valid in version 2 ("true" -> true), run environment-validator -migrate`},
+		{"2", `{name: api, health_check: {command: [/bin/check]}}`, "environment dev: services[api].health_check: not valid in version 2 (obsolete, ignored"},
+		{"2", `{name: api, env: [{secret: DB, value: db}]}`, `environment dev: services[api].env[DB].value: not valid in version 2 ("db" -> "db/db")`},
+		{"2", `{name: api, ssl: true, env: [{secret: DB, value: db/password}]}`, ""},
+		{"1", `{name: api, ssl: "true"}`, ""},
+		{"3", `{name: api}`, "version: must be between 1 and 2"},
+		{"latest", `{name: api}`, "version: must be between 1 and 2"},
+	}
+
+	for _, tst := range tests {
+		str := "version: " + tst.Version + "\nproject: test\nenvironments:\n  - name: dev\n    services:\n      - " + tst.Service
+		_, err := LoadFromString(str)
+		if tst.Error == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.Service, err.Error())
+		}
+		if tst.Error != "" && (err == nil || !strings.Contains(err.Error(), tst.Error)) {
+			t.Errorf("Expected error %q for version %s %s, got %v", tst.Error, tst.Version, tst.Service, err)
+		}
+	}
+
+	if _, err := LoadFromString("version: 2\n" + legacyEnvironment); err == nil || !strings.Contains(err.Error(), "environment dev: tests: not valid in version 2") {
+		t.Errorf("Expected tests to be rejected in version 2, got %v", err)
+	}
+}
+
+func TestMigrateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"environments.bitesize": legacyEnvironment + "    include: [services]\n",
+		"services/worker.yaml":  "name: worker\nreplicas: 2\nhttp2: \"true\"\n",
+		"services/web.yaml":     "name: web\nport: 80\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before, err := LoadFromFile(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	changes, err := MigrateFile(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expected := []string{
+		"environments[dev].tests: obsolete, removed",
+		"environments[dev].services[api].health_check: replaced with liveness_probe",
+		`environments[dev].services[api].ssl: "true" -> true`,
+		`environments[dev].services[api].httpsOnly: "false" -> false`,
+		`environments[dev].services[api].env[DB_PASSWORD].value: "db" -> "db/db"`,
+		`include.services/worker.yaml: services[worker].http2: "true" -> true`,
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Unexpected changes:\n%s", strings.Join(changes, "\n"))
+	}
+
+	contents, _ := ioutil.ReadFile(filepath.Join(dir, "environments.bitesize"))
+	if !strings.HasPrefix(string(contents), "version: 2\nproject: test\nenvironments:\n- name: dev\n  services:\n  - name: api\n    port: 80\n    ssl: true\n") {
+		t.Errorf("Unexpected migrated environments.bitesize:\n%s", contents)
+	}
+	worker, _ := ioutil.ReadFile(filepath.Join(dir, "services/worker.yaml"))
+	if string(worker) != "name: worker\nreplicas: 2\nhttp2: true\n" {
+		t.Errorf("Unexpected migrated services/worker.yaml:\n%s", worker)
+	}
+	web, _ := ioutil.ReadFile(filepath.Join(dir, "services/web.yaml"))
+	if string(web) != files["services/web.yaml"] {
+		t.Errorf("Expected unchanged services/web.yaml, got:\n%s", web)
+	}
+
+	for name, contents := range map[string]string{
+		"environments.bitesize.bak": files["environments.bitesize"],
+		"services/worker.yaml.bak":  files["services/worker.yaml"],
+	} {
+		if backup, _ := ioutil.ReadFile(filepath.Join(dir, name)); string(backup) != contents {
+			t.Errorf("Unexpected %s:\n%s", name, backup)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "services/web.yaml.bak")); err == nil {
+		t.Error("Expected no backup of unchanged services/web.yaml")
+	}
+
+	after, err := LoadFromFile(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error loading migrated file: %s", err.Error())
+	}
+	probe := after.Environments[0].Services.FindByName("api").LivenessProbe
+	if probe == nil || probe.Exec == nil || !reflect.DeepEqual(probe.Exec.Command, []string{"/bin/check"}) || probe.InitialDelaySeconds != 5 {
+		t.Errorf("Unexpected liveness_probe %+v", probe)
+	}
+	for i := range after.Environments[0].Services {
+		after.Environments[0].Services[i].LivenessProbe = nil
+	}
+	if !reflect.DeepEqual(before.Environments, after.Environments) {
+		t.Errorf("Expected migrated file to load as before migration, but for liveness_probe")
+	}
+
+	if changes, err = MigrateFile(dir); err != nil || len(changes) != 0 {
+		t.Errorf("Expected migrated file to be left as is, got %v, %v", changes, err)
+	}
+}
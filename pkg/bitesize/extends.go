@@ -0,0 +1,403 @@
+package bitesize
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	validator "gopkg.in/validator.v2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// environmentNode keeps environment as decoded from environments.bitesize,
+// so it can be merged with the environment it extends before it's parsed
+type environmentNode struct {
+	raw       map[interface{}]interface{}
+	unmarshal func(interface{}) error
+	rewritten bool // services were included from other files or the environment was migrated
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface for environmentNode.
+func (n *environmentNode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	n.unmarshal = unmarshal
+	return unmarshal(&n.raw)
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface for
+// EnvironmentsBitesize. Services of included files are added to their
+// environments, environments of older versions are migrated to
+// LatestVersion, environments with "extends" are deep merged over the
+// environment they extend and ${var} references are interpolated before
+// environments are parsed.
+func (b *EnvironmentsBitesize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var doc struct {
+		Version      interface{}            `yaml:"version"`
+		Project      string                 `yaml:"project"`
+		Vars         map[string]interface{} `yaml:"vars"`
+		Strict       string                 `yaml:"strict"`
+		Environments []environmentNode      `yaml:"environments"`
+	}
+	if err := unmarshal(&doc); err != nil {
+		return err
+	}
+	switch doc.Strict {
+	case "", StrictOff, StrictOn, StrictWarn:
+	default:
+		return fmt.Errorf("strict: must be %s, %s or %s", StrictOn, StrictOff, StrictWarn)
+	}
+	version, err := documentVersion(doc.Version)
+	if err != nil {
+		return err
+	}
+
+	raw := map[string]map[interface{}]interface{}{}
+	var rawEnvs []interface{}
+	for i, node := range doc.Environments {
+		included, err := expandIncludes(node.raw, b.dir)
+		if err != nil {
+			return fmt.Errorf("environment %v: %s", node.raw["name"], err.Error())
+		}
+		changes, err := migrateEnvironment(node.raw, version)
+		if err != nil {
+			return fmt.Errorf("environment %v: %s", node.raw["name"], err.Error())
+		}
+		for _, c := range changes {
+			if c.lossy {
+				log.Warnf("environments.bitesize: environment %v: migrated %s", node.raw["name"], c)
+				continue
+			}
+			log.Debugf("environments.bitesize: environment %v: migrated %s", node.raw["name"], c)
+		}
+		doc.Environments[i].rewritten = included || len(changes) > 0
+
+		if name, ok := node.raw["name"]; ok && name != nil {
+			raw[fmt.Sprintf("%v", name)] = node.raw
+		}
+		rawEnvs = append(rawEnvs, node.raw)
+	}
+
+	// unknown keys are checked with included services in place
+	if doc.Strict != "" && doc.Strict != StrictOff {
+		var rawDoc map[interface{}]interface{}
+		if err := unmarshal(&rawDoc); err != nil {
+			return err
+		}
+		rawDoc["environments"] = rawEnvs
+		if err := checkUnknownKeys(doc.Strict, rawDoc); err != nil {
+			return err
+		}
+	}
+
+	builtins := map[string]interface{}{VarProject: doc.Project}
+	for k, v := range b.builtins {
+		builtins[k] = v
+	}
+
+	b.Version = LatestVersion
+	b.Project = doc.Project
+	b.Vars = doc.Vars
+	b.Strict = doc.Strict
+	b.Environments = nil
+	for _, node := range doc.Environments {
+		env, err := node.environment(raw, builtins, doc.Vars)
+		if err != nil {
+			return err
+		}
+		b.Environments = append(b.Environments, env)
+	}
+	return nil
+}
+
+// environment parses environment node, merged with the environment it
+// extends and with ${var} references interpolated
+func (n *environmentNode) environment(envs map[string]map[interface{}]interface{}, builtins, vars map[string]interface{}) (Environment, error) {
+	var env Environment
+	name := fmt.Sprintf("%v", n.raw["name"])
+
+	var merged *mergedEnvironment
+	if _, ok := n.raw["extends"]; ok {
+		var err error
+		if merged, err = mergeEnvironment(name, envs, nil); err != nil {
+			return env, err
+		}
+	} else {
+		if err := validateNoRemove(n.raw); err != nil {
+			return env, err
+		}
+		merged = &mergedEnvironment{raw: n.raw, chain: []string{name}}
+	}
+
+	// ${var} references are only interpolated in documents declaring vars,
+	// so ${...} in values of existing manifests is kept as is
+	interpolated, changed := merged.raw, false
+	if _, ok := merged.raw["vars"]; ok || vars != nil {
+		envVars, err := environmentVars(builtins, vars, merged.raw)
+		if err != nil {
+			return env, fmt.Errorf("environment %s: %s", name, err.Error())
+		}
+		interpolated, changed, err = interpolateEnvironment(merged.raw, envVars)
+		if err != nil {
+			return env, fmt.Errorf("environment %s: %s", name, err.Error())
+		}
+	}
+
+	// environments without extends, includes, migrations or variables are
+	// parsed from the original document, keeping line numbers in errors
+	if len(merged.chain) == 1 && !changed && !n.rewritten {
+		err := n.unmarshal(&env)
+		return env, err
+	}
+	merged.raw = interpolated
+	return merged.environment()
+}
+
+// mergedEnvironment is raw environment merged over the environments it
+// extends, with the environment each service key was last set in
+type mergedEnvironment struct {
+	raw     map[interface{}]interface{}
+	chain   []string                     // environment names, extending one first
+	origins map[string]map[string]string // service name -> key -> environment name
+}
+
+// mergeEnvironment resolves "extends" of named environment. seen holds
+// environments already in the chain, to detect cycles.
+func mergeEnvironment(name string, envs map[string]map[interface{}]interface{}, seen []string) (*mergedEnvironment, error) {
+	for _, s := range seen {
+		if s == name {
+			return nil, fmt.Errorf("environment %s: extends cycle %s -> %s", seen[0], strings.Join(seen, " -> "), name)
+		}
+	}
+	env, ok := envs[name]
+	if !ok && len(seen) == 0 {
+		return nil, fmt.Errorf("environment %s: name is required", name)
+	}
+	if !ok {
+		return nil, fmt.Errorf("environment %s: extends unknown environment %s", seen[len(seen)-1], name)
+	}
+	seen = append(seen, name)
+
+	base, _ := env["extends"].(string)
+	if base == "" {
+		if err := validateNoRemove(env); err != nil {
+			return nil, err
+		}
+		retval := &mergedEnvironment{
+			raw:     deepCopy(env).(map[interface{}]interface{}),
+			chain:   []string{name},
+			origins: map[string]map[string]string{},
+		}
+		for _, svc := range namedItems(env["services"]) {
+			retval.setOrigins(svc, name)
+		}
+		return retval, nil
+	}
+
+	retval, err := mergeEnvironment(base, envs, seen)
+	if err != nil {
+		return nil, err
+	}
+	if err = retval.merge(name, env); err != nil {
+		return nil, err
+	}
+	return retval, nil
+}
+
+// merge applies environment overlay over m. Namespace is not inherited,
+// services and gists are merged by name (and type, for gists), other keys
+// are deep merged. Lists other than these are replaced.
+func (m *mergedEnvironment) merge(name string, overlay map[interface{}]interface{}) error {
+	delete(m.raw, "namespace")
+	m.chain = append([]string{name}, m.chain...)
+
+	for k, v := range overlay {
+		var err error
+		switch k {
+		case "services":
+			m.raw[k], err = m.mergeNamed(name, "service", m.raw[k], v, func(item map[interface{}]interface{}) string {
+				return fmt.Sprintf("%v", item["name"])
+			})
+		case "gists":
+			m.raw[k], err = m.mergeNamed(name, "gist", m.raw[k], v, func(item map[interface{}]interface{}) string {
+				return fmt.Sprintf("%v/%v", item["type"], item["name"])
+			})
+		default:
+			m.raw[k] = deepMerge(m.raw[k], v)
+		}
+		if err != nil {
+			return fmt.Errorf("environment %s: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+// mergeNamed merges overlay list items over base items with the same key.
+// Overlay items with "remove: true" remove the base item.
+func (m *mergedEnvironment) mergeNamed(env, kind string, base, overlay interface{}, key func(map[interface{}]interface{}) string) ([]interface{}, error) {
+	var retval []interface{}
+	index := map[string]int{}
+	for _, item := range namedItems(base) {
+		index[key(item)] = len(retval)
+		retval = append(retval, deepCopy(item))
+	}
+
+	removed := map[int]bool{}
+	for _, item := range namedItems(overlay) {
+		k := key(item)
+		i, inherited := index[k]
+
+		if remove, _ := item["remove"].(bool); remove {
+			if !inherited {
+				return nil, fmt.Errorf("%s %s: remove: not inherited from %s", kind, item["name"], strings.Join(m.chain[1:], ", "))
+			}
+			removed[i] = true
+			continue
+		}
+		item = deepCopy(item).(map[interface{}]interface{})
+		delete(item, "remove")
+
+		if kind == "service" {
+			m.setOrigins(item, env)
+		}
+		if inherited {
+			retval[i] = deepMerge(retval[i], item)
+			removed[i] = false
+			continue
+		}
+		index[k] = len(retval)
+		retval = append(retval, deepCopy(item))
+	}
+
+	var kept []interface{}
+	for i, item := range retval {
+		if !removed[i] {
+			kept = append(kept, item)
+		}
+	}
+	return kept, nil
+}
+
+func (m *mergedEnvironment) setOrigins(svc map[interface{}]interface{}, env string) {
+	name := fmt.Sprintf("%v", svc["name"])
+	if m.origins[name] == nil {
+		m.origins[name] = map[string]string{}
+	}
+	for k := range svc {
+		m.origins[name][fmt.Sprintf("%v", k)] = env
+	}
+}
+
+// environment parses merged environment. Errors name the environments
+// values of the failing service were set in.
+func (m *mergedEnvironment) environment() (Environment, error) {
+	var env Environment
+
+	byt, err := yaml.Marshal(m.raw)
+	if err != nil {
+		return env, err
+	}
+	if err = yaml.Unmarshal(byt, &env); err == nil {
+		return env, nil
+	}
+	if len(m.chain) == 1 {
+		return env, fmt.Errorf("environment %s: %s", m.chain[0], err.Error())
+	}
+	return env, fmt.Errorf("environment %s (extends %s): %s%s", m.chain[0], strings.Join(m.chain[1:], " -> "), err.Error(), m.describeOrigins())
+}
+
+// describeOrigins returns environments the keys of services failing to
+// parse on their own were set in
+func (m *mergedEnvironment) describeOrigins() string {
+	var retval []string
+	for _, item := range namedItems(m.raw["services"]) {
+		byt, err := yaml.Marshal(item)
+		if err != nil {
+			continue
+		}
+		var svc Service
+		if err = yaml.Unmarshal(byt, &svc); err == nil {
+			err = validator.Validate(svc)
+		}
+		if err == nil {
+			continue
+		}
+
+		name := fmt.Sprintf("%v", item["name"])
+		keys := map[string][]string{}
+		for k, env := range m.origins[name] {
+			keys[env] = append(keys[env], k)
+		}
+		var sources []string
+		for _, env := range m.chain {
+			if len(keys[env]) == 0 {
+				continue
+			}
+			sort.Strings(keys[env])
+			sources = append(sources, fmt.Sprintf("%s from %s", strings.Join(keys[env], ", "), env))
+		}
+		retval = append(retval, fmt.Sprintf("service %s: %s", name, strings.Join(sources, "; ")))
+	}
+	if len(retval) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", strings.Join(retval, ", "))
+}
+
+// validateNoRemove returns an error if environment without "extends"
+// removes services or gists
+func validateNoRemove(env map[interface{}]interface{}) error {
+	for _, k := range []string{"services", "gists"} {
+		for _, item := range namedItems(env[k]) {
+			if _, ok := item["remove"]; ok {
+				return fmt.Errorf("environment %v: %s.%v: remove is only valid in environments with extends", env["name"], k, item["name"])
+			}
+		}
+	}
+	return nil
+}
+
+func namedItems(list interface{}) []map[interface{}]interface{} {
+	var retval []map[interface{}]interface{}
+	items, _ := list.([]interface{})
+	for _, item := range items {
+		if m, ok := item.(map[interface{}]interface{}); ok {
+			retval = append(retval, m)
+		}
+	}
+	return retval
+}
+
+// deepMerge returns overlay merged over base. Maps are merged key by key,
+// other values are replaced.
+func deepMerge(base, overlay interface{}) interface{} {
+	baseMap, baseIsMap := base.(map[interface{}]interface{})
+	overlayMap, overlayIsMap := overlay.(map[interface{}]interface{})
+	if !baseIsMap || !overlayIsMap {
+		return deepCopy(overlay)
+	}
+
+	retval := deepCopy(baseMap).(map[interface{}]interface{})
+	for k, v := range overlayMap {
+		retval[k] = deepMerge(retval[k], v)
+	}
+	return retval
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		retval := map[interface{}]interface{}{}
+		for k, val := range v {
+			retval[k] = deepCopy(val)
+		}
+		return retval
+	case []interface{}:
+		retval := make([]interface{}, len(v))
+		for i, val := range v {
+			retval[i] = deepCopy(val)
+		}
+		return retval
+	default:
+		return v
+	}
+}
@@ -0,0 +1,150 @@
+package bitesize
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+)
+
+// ExternalURLAnnotation lists service external_url hosts on ingresses that
+// also carry external_routes, so the two can be told apart when scraped
+const ExternalURLAnnotation = "external_url"
+
+const (
+	// PathTypePrefix matches request path by '/' separated prefix
+	PathTypePrefix = "Prefix"
+	// PathTypeExact matches request path exactly
+	PathTypeExact = "Exact"
+)
+
+// ExternalRoute represents a single entry in service's external_routes
+// list. Requests for host and path are routed to port of the target
+// service, which defaults to the declaring service.
+type ExternalRoute struct {
+	Host     string `yaml:"host"`
+	Path     string `yaml:"path,omitempty"`
+	PathType string `yaml:"path_type,omitempty"`
+	Service  string `yaml:"service,omitempty"`
+	Port     int    `yaml:"port,omitempty"`
+}
+
+// HasExternalRoutes returns true if service declares external_routes
+func (e Service) HasExternalRoutes() bool {
+	return len(e.ExternalRoutes) != 0
+}
+
+// Routes returns all external routes of the service. Each external_url
+// routes "/" to the service backend, followed by declared external_routes.
+func (e Service) Routes() []ExternalRoute {
+	var routes []ExternalRoute
+
+	backend := e.Name
+	if e.Backend != "" {
+		backend = e.Backend
+	}
+	port := e.BackendPort
+	if port == 0 {
+		port = e.HTTPPort()
+	}
+
+	for _, url := range e.ExternalURL {
+		routes = append(routes, ExternalRoute{
+			Host:     url,
+			Path:     "/",
+			PathType: PathTypePrefix,
+			Service:  backend,
+			Port:     port,
+		})
+	}
+	return append(routes, e.ExternalRoutes...)
+}
+
+// ExternalHosts returns unique hosts of service external_url and external_routes
+// in the order of declaration
+func (e Service) ExternalHosts() []string {
+	var hosts []string
+	seen := map[string]bool{}
+	for _, r := range e.Routes() {
+		if !seen[r.Host] {
+			seen[r.Host] = true
+			hosts = append(hosts, r.Host)
+		}
+	}
+	return hosts
+}
+
+// setExternalRouteDefaults fills in route fields omitted in the manifest
+func (e *Service) setExternalRouteDefaults() error {
+	for i := range e.ExternalRoutes {
+		r := &e.ExternalRoutes[i]
+		if r.Path == "" {
+			r.Path = "/"
+		}
+		if r.PathType == "" {
+			r.PathType = PathTypePrefix
+		}
+		if r.Service == "" {
+			r.Service = e.Name
+		}
+		if r.Port == 0 && r.Service == e.Name {
+			r.Port = e.HTTPPort()
+		}
+		if r.Port == 0 {
+			return fmt.Errorf("external route %s%s requires port for service %s", r.Host, r.Path, r.Service)
+		}
+	}
+	return nil
+}
+
+// SortedRoutes returns routes ordered by path length, longest first, for
+// routers that pick the first matching route
+func SortedRoutes(routes []ExternalRoute) []ExternalRoute {
+	sorted := append([]ExternalRoute(nil), routes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Path) > len(sorted[j].Path)
+	})
+	return sorted
+}
+
+// validateRouteClaims returns an error if more than one route across
+// services claims the same host and path
+func validateRouteClaims(services Services) error {
+	claims := map[string]string{}
+	for _, svc := range services {
+		for _, r := range svc.Routes() {
+			key := r.Host + r.Path
+			if owner, ok := claims[key]; ok {
+				if owner == svc.Name {
+					return fmt.Errorf("host %s path %s is routed more than once by %s", r.Host, r.Path, svc.Name)
+				}
+				return fmt.Errorf("host %s path %s is claimed by both %s and %s", r.Host, r.Path, owner, svc.Name)
+			}
+			claims[key] = svc.Name
+		}
+	}
+	return nil
+}
+
+func validExternalRoutes(v interface{}, param string) error {
+	routes, ok := v.([]ExternalRoute)
+	if !ok {
+		return fmt.Errorf("invalid external routes: %v", reflect.ValueOf(v))
+	}
+
+	for _, r := range routes {
+		if ok, _ := regexp.MatchString("^[a-zA-Z0-9\\.\\-]+$", r.Host); !ok {
+			return fmt.Errorf("external route host %q is invalid", r.Host)
+		}
+		if r.Path != "" && r.Path[0] != '/' {
+			return fmt.Errorf("external route %s path %s must start with /", r.Host, r.Path)
+		}
+		if r.PathType != "" && r.PathType != PathTypePrefix && r.PathType != PathTypeExact {
+			return fmt.Errorf("external route %s%s path_type %s is invalid. Valid types: Prefix,Exact", r.Host, r.Path, r.PathType)
+		}
+		if r.Port < 0 || r.Port > 65535 {
+			return fmt.Errorf("external route %s%s port %d out of range", r.Host, r.Path, r.Port)
+		}
+	}
+	return nil
+}
@@ -1,14 +1,20 @@
 package bitesize
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/pearsontechnology/environment-operator/pkg/config"
+	"github.com/pearsontechnology/environment-operator/pkg/sops"
 	"github.com/pearsontechnology/environment-operator/pkg/util"
 	v1batch "k8s.io/api/batch/v1"
 	"k8s.io/api/batch/v1beta1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8Yaml "k8s.io/apimachinery/pkg/util/yaml"
 )
 
@@ -21,6 +27,10 @@ const (
 	TypeCronJob string = "cronjob"
 	// TypeSecret k8s secret type
 	TypeSecret string = "secret"
+
+	// GistHashAnnotation holds content hash of job, cronjob and secret gists.
+	// Jobs are re-run when the hash changes.
+	GistHashAnnotation string = "gist_hash"
 )
 
 // Gist represent a resource
@@ -33,6 +43,16 @@ type Gist struct {
 	Job       v1batch.Job     `yaml:"-"`
 	CronJob   v1beta1.CronJob `yaml:"-"`
 	Secret    v1.Secret       `yaml:"-"`
+	Status    JobStatus       `yaml:"-"`
+}
+
+// JobStatus represents job gist's completion status in the cluster
+type JobStatus struct {
+	Active      int
+	Succeeded   int
+	Failed      int
+	StartedAt   string
+	CompletedAt string
 }
 
 // GistsRepository contains the repository info all the imports per env
@@ -58,6 +78,27 @@ func (slice Gists) Swap(i, j int) {
 
 // LoadResource loads named resource from a filename with a given path
 func LoadResource(res *Gist, namespace, localPath string) error {
+	return (&gistLoader{}).load(res, namespace, localPath)
+}
+
+// gistLoader loads gists of an environment. sops keys are read once, when
+// the first secret gist is loaded.
+type gistLoader struct {
+	keys *sops.Keys
+}
+
+func (l *gistLoader) sopsKeys() (*sops.Keys, error) {
+	if l.keys == nil {
+		keys, err := sops.LoadKeys(config.Env.SopsAgeKeyFile, config.Env.SopsPGPKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		l.keys = keys
+	}
+	return l.keys, nil
+}
+
+func (l *gistLoader) load(res *Gist, namespace, localPath string) error {
 	if len(res.Path) > 0 {
 		resPath := path.Join(localPath, res.Path)
 
@@ -102,10 +143,12 @@ func LoadResource(res *Gist, namespace, localPath string) error {
 					labels["creator"] = "pipeline"
 				}
 				res.Job.SetLabels(labels)
-				res.ConfigMap.ObjectMeta.SetLabels(labels)
-				res.ConfigMap.ObjectMeta.SetName(res.Name)
+				res.Job.SetName(res.Name)
 				// override metadata namespace to current environment namespace
-				res.ConfigMap.ObjectMeta.SetNamespace(namespace)
+				res.Job.SetNamespace(namespace)
+				if err := setGistHash(&res.Job.ObjectMeta, res.Job); err != nil {
+					return err
+				}
 			}
 		case TypeCronJob:
 			{
@@ -120,10 +163,32 @@ func LoadResource(res *Gist, namespace, localPath string) error {
 				} else {
 					labels["creator"] = "pipeline"
 				}
-				res.ConfigMap.ObjectMeta.SetLabels(labels)
-				res.ConfigMap.ObjectMeta.SetName(res.Name)
+				res.CronJob.SetLabels(labels)
+				res.CronJob.SetName(res.Name)
 				// override metadata namespace to current environment namespace
-				res.ConfigMap.ObjectMeta.SetNamespace(namespace)
+				res.CronJob.SetNamespace(namespace)
+				if err := setGistHash(&res.CronJob.ObjectMeta, res.CronJob); err != nil {
+					return err
+				}
+			}
+		case TypeSecret:
+			{
+				log.Debugf("adding Secret %s from encrypted path %s", res.Name, res.Path)
+				if err := l.loadSecret(res, resPath); err != nil {
+					return err
+				}
+				labels := res.Secret.GetLabels()
+				if labels == nil {
+					labels = map[string]string{
+						"creator": "pipeline",
+					}
+				} else {
+					labels["creator"] = "pipeline"
+				}
+				res.Secret.SetLabels(labels)
+				res.Secret.SetName(res.Name)
+				// override metadata namespace to current environment namespace
+				res.Secret.SetNamespace(namespace)
 			}
 		}
 	}
@@ -165,6 +230,67 @@ func LoadResource(res *Gist, namespace, localPath string) error {
 	return nil
 }
 
+// loadSecret decrypts sops encrypted secret manifest at resPath into
+// res.Secret. Plaintext files are rejected, and errors never include
+// decrypted content. Gist hash is computed from the encrypted file so that
+// it can't be used to guess secret values.
+func (l *gistLoader) loadSecret(res *Gist, resPath string) error {
+	data, err := ioutil.ReadFile(resPath)
+	if err != nil {
+		return err
+	}
+	if !sops.IsEncrypted(data) {
+		return fmt.Errorf("secret %s: %s is not encrypted with sops", res.Name, res.Path)
+	}
+
+	keys, err := l.sopsKeys()
+	if err != nil {
+		return err
+	}
+	plain, err := sops.Decrypt(data, keys)
+	if err != nil {
+		return fmt.Errorf("secret %s: %s", res.Name, err.Error())
+	}
+
+	decoder := k8Yaml.NewYAMLOrJSONDecoder(bytes.NewReader(plain), 1000)
+	if err := decoder.Decode(&res.Secret); err != nil {
+		return fmt.Errorf("secret %s: invalid secret manifest in %s", res.Name, res.Path)
+	}
+	return setGistHash(&res.Secret.ObjectMeta, data)
+}
+
+// setGistHash annotates metadata with the content hash of obj
+func setGistHash(metadata *metav1.ObjectMeta, obj interface{}) error {
+	h, err := util.ContentHash(obj)
+	if err != nil {
+		return err
+	}
+	if metadata.Annotations == nil {
+		metadata.Annotations = map[string]string{}
+	}
+	metadata.Annotations[GistHashAnnotation] = h
+	return nil
+}
+
+// Hash returns content hash of job, cronjob or secret gist, as recorded
+// when the gist was loaded
+func (g Gist) Hash() string {
+	switch g.Type {
+	case TypeJob:
+		return g.Job.Annotations[GistHashAnnotation]
+	case TypeCronJob:
+		return g.CronJob.Annotations[GistHashAnnotation]
+	case TypeSecret:
+		return g.Secret.Annotations[GistHashAnnotation]
+	}
+	return ""
+}
+
+// IsComplete returns true if job gist has finished successfully
+func (g Gist) IsComplete() bool {
+	return g.Status.CompletedAt != ""
+}
+
 // Find returns service with a name match
 // path is a UNC path relative to the configured repository root
 // rstype is the resource type of the imported resource
@@ -172,6 +298,7 @@ func LoadResource(res *Gist, namespace, localPath string) error {
 //      - configmap
 //      - job
 //      - cronjob
+//      - secret
 // if resource found returns the resource else returns nil
 func (slice Gists) Find(path string, rstype string) *Gist {
 	for _, s := range slice {
@@ -188,6 +315,7 @@ func (slice Gists) Find(path string, rstype string) *Gist {
 //      - bitesize.TypeConfigMap
 //      - bitesize.TypeJob
 //      - bitesize.TypeCronJob
+//      - bitesize.TypeSecret
 // if resource found returns the resource else returns nil
 func (slice Gists) FindByName(name string, rstype string) *Gist {
 	for _, s := range slice {
@@ -204,6 +332,7 @@ func (slice Gists) FindByName(name string, rstype string) *Gist {
 //      - bitesize.TypeConfigMap
 //      - bitesize.TypeJob
 //      - bitesize.TypeCronJob
+//      - bitesize.TypeSecret
 // if resource found returns the resource else returns nil
 func (slice Gists) FindByType(rstype string) Gists {
 	res := Gists{}
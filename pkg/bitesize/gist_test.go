@@ -4,10 +4,16 @@ import (
 	"os"
 	"reflect"
 	"testing"
+
+	"github.com/pearsontechnology/environment-operator/pkg/config"
 )
 
 func TestUnmarshal(t *testing.T) {
 	t.Run("configmap parsed correctly", testUmarshalConfigMap)
+	t.Run("job parsed correctly", testUmarshalJob)
+	t.Run("cronjob parsed correctly", testUmarshalCronJob)
+	t.Run("encrypted secret parsed correctly", testUmarshalSecret)
+	t.Run("plaintext secret rejected", testUmarshalPlaintextSecret)
 }
 
 func TestFind(t *testing.T) {
@@ -189,3 +195,89 @@ func testUmarshalConfigMap(t *testing.T) {
 		}, r.ConfigMap.Data)
 	}
 }
+
+func testUmarshalJob(t *testing.T) {
+	r := &Gist{
+		Name: "db-migrate",
+		Path: "../../test/assets/k8s/migrate-job.bitesize",
+		Type: TypeJob,
+	}
+	pwd, _ := os.Getwd()
+	if err := LoadResource(r, "dev", pwd); err != nil {
+		t.Errorf("Errors expected nil, got %v", err)
+	}
+	if r.Job.GetName() != "db-migrate" || r.Job.GetNamespace() != "dev" {
+		t.Errorf("Expected dev/db-migrate, got %s/%s", r.Job.GetNamespace(), r.Job.GetName())
+	}
+	if r.Job.Labels["creator"] != "pipeline" {
+		t.Errorf("Expected creator label, got %v", r.Job.Labels)
+	}
+	if r.Hash() == "" {
+		t.Error("Expected job content hash to be set")
+	}
+
+	// same content gives the same hash
+	again := &Gist{Name: "db-migrate", Path: r.Path, Type: TypeJob}
+	LoadResource(again, "dev", pwd)
+	if again.Hash() != r.Hash() {
+		t.Errorf("Expected stable hash, got %s and %s", r.Hash(), again.Hash())
+	}
+}
+
+func testUmarshalCronJob(t *testing.T) {
+	r := &Gist{
+		Name: "cleanup",
+		Path: "../../test/assets/k8s/cleanup-cronjob.bitesize",
+		Type: TypeCronJob,
+	}
+	pwd, _ := os.Getwd()
+	if err := LoadResource(r, "dev", pwd); err != nil {
+		t.Errorf("Errors expected nil, got %v", err)
+	}
+	if r.CronJob.GetName() != "cleanup" || r.CronJob.GetNamespace() != "dev" {
+		t.Errorf("Expected dev/cleanup, got %s/%s", r.CronJob.GetNamespace(), r.CronJob.GetName())
+	}
+	if r.CronJob.Spec.Schedule != "0 * * * *" {
+		t.Errorf("Unexpected schedule %q", r.CronJob.Spec.Schedule)
+	}
+	if r.Hash() == "" {
+		t.Error("Expected cronjob content hash to be set")
+	}
+}
+
+func testUmarshalSecret(t *testing.T) {
+	config.Env.SopsAgeKeyFile = "../../test/assets/sops/age-key.txt"
+	r := &Gist{
+		Name: "db",
+		Path: "../../test/assets/sops/secret.age.yaml",
+		Type: TypeSecret,
+	}
+	pwd, _ := os.Getwd()
+	if err := LoadResource(r, "dev", pwd); err != nil {
+		t.Fatalf("Errors expected nil, got %v", err)
+	}
+	if r.Secret.GetName() != "db" || r.Secret.GetNamespace() != "dev" {
+		t.Errorf("Expected dev/db, got %s/%s", r.Secret.GetNamespace(), r.Secret.GetName())
+	}
+	if r.Secret.Labels["creator"] != "pipeline" {
+		t.Errorf("Expected creator label, got %v", r.Secret.Labels)
+	}
+	if r.Secret.StringData["password"] != "s3cr3t-p4ss" {
+		t.Errorf("Expected decrypted password, got %q", r.Secret.StringData["password"])
+	}
+	if r.Hash() == "" {
+		t.Error("Expected secret content hash to be set")
+	}
+}
+
+func testUmarshalPlaintextSecret(t *testing.T) {
+	r := &Gist{
+		Name: "plain",
+		Path: "../../test/assets/k8s/migrate-job.bitesize",
+		Type: TypeSecret,
+	}
+	pwd, _ := os.Getwd()
+	if err := LoadResource(r, "dev", pwd); err == nil {
+		t.Error("Expected error loading unencrypted secret, got nil")
+	}
+}
@@ -0,0 +1,208 @@
+package bitesize
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// HPA metric types
+const (
+	MetricTypeResource = "resource"
+	MetricTypePods     = "pods"
+	MetricTypeObject   = "object"
+	MetricTypeExternal = "external"
+)
+
+// ScalingBehavior represents "behavior" block of hpa: scale up and scale
+// down policies. JSON tags match HorizontalPodAutoscaler spec.behavior.
+type ScalingBehavior struct {
+	ScaleUp   *ScalingRules `yaml:"scale_up,omitempty" json:"scaleUp,omitempty"`
+	ScaleDown *ScalingRules `yaml:"scale_down,omitempty" json:"scaleDown,omitempty"`
+}
+
+// ScalingRules limit the rate of scaling in one direction. Changes are
+// held back for the stabilization window to avoid flapping.
+type ScalingRules struct {
+	StabilizationWindowSeconds *int32          `yaml:"stabilization_window_seconds,omitempty" json:"stabilizationWindowSeconds,omitempty"`
+	SelectPolicy               string          `yaml:"select_policy,omitempty" json:"selectPolicy,omitempty" validate:"regexp=^(Max|Min|Disabled)*$"`
+	Policies                   []ScalingPolicy `yaml:"policies,omitempty" json:"policies,omitempty"`
+}
+
+// ScalingPolicy allows Value pods, or Value percent of pods, to be added
+// or removed within PeriodSeconds
+type ScalingPolicy struct {
+	Type          string `yaml:"type" json:"type" validate:"regexp=^(Pods|Percent)*$"`
+	Value         int32  `yaml:"value" json:"value"`
+	PeriodSeconds int32  `yaml:"period_seconds" json:"periodSeconds"`
+}
+
+// ScalingBehaviorJSON returns behavior serialized as HorizontalPodAutoscaler
+// spec.behavior, or empty string if it's not set
+func ScalingBehaviorJSON(behavior *ScalingBehavior) string {
+	if behavior == nil {
+		return ""
+	}
+	byt, err := json.Marshal(behavior)
+	if err != nil {
+		return ""
+	}
+	return string(byt)
+}
+
+// ScalingBehaviorFromJSON reads behavior written by ScalingBehaviorJSON,
+// returning nil if it's not set
+func ScalingBehaviorFromJSON(s string) *ScalingBehavior {
+	if s == "" {
+		return nil
+	}
+	retval := &ScalingBehavior{}
+	if err := json.Unmarshal([]byte(s), retval); err != nil {
+		return nil
+	}
+	return retval
+}
+
+// MetricType returns type of the metric
+func (m Metric) MetricType() string {
+	switch {
+	case m.Type != "":
+		return m.Type
+	case m.Name == "cpu" || m.Name == "memory":
+		return MetricTypeResource
+	default:
+		return MetricTypePods
+	}
+}
+
+// MetricList returns metrics the hpa scales on: metrics, or the single
+// metric
+func (h HorizontalPodAutoscaler) MetricList() []Metric {
+	if len(h.Metrics) > 0 {
+		return h.Metrics
+	}
+	if h.Metric.Name != "" {
+		return []Metric{h.Metric}
+	}
+	return nil
+}
+
+// setHPADefaults moves the single metric to metrics, scaling on 80% cpu
+// utilization if none is set, and writes targets in canonical quantity
+// format as the cluster returns them
+func (e *Service) setHPADefaults() error {
+	if e.HPA.MinReplicas == 0 {
+		return nil
+	}
+	if e.HPA.Metric.Name != "" && len(e.HPA.Metrics) > 0 {
+		return fmt.Errorf("hpa.metric: not valid with hpa.metrics")
+	}
+
+	metrics := e.HPA.MetricList()
+	if len(metrics) == 0 {
+		metrics = []Metric{{Name: "cpu", TargetAverageUtilization: int32(80)}}
+	}
+	e.HPA.Metric = Metric{}
+	e.HPA.Metrics = nil
+
+	for i, m := range metrics {
+		var err error
+		m.Type = m.MetricType()
+		if m.TargetValue, err = canonicalQuantity(m.TargetValue); err != nil {
+			return fmt.Errorf("hpa.metrics[%d].target_value: %s", i, err.Error())
+		}
+		if m.TargetAverageValue, err = canonicalQuantity(m.TargetAverageValue); err != nil {
+			return fmt.Errorf("hpa.metrics[%d].target_average_value: %s", i, err.Error())
+		}
+		e.HPA.Metrics = append(e.HPA.Metrics, m)
+	}
+	return nil
+}
+
+func canonicalQuantity(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid quantity %s", s)
+	}
+	return q.String(), nil
+}
+
+// validMetric returns an error if metric targets and selectors don't apply
+// to its type
+func validMetric(m Metric) error {
+	if m.Name == "" {
+		return fmt.Errorf("metric name is required")
+	}
+	switch m.MetricType() {
+	case MetricTypeResource:
+		if m.Name != "cpu" && m.Name != "memory" {
+			return fmt.Errorf("resource metric %s invalid; only cpu and memory are supported", m.Name)
+		}
+		if (m.TargetAverageUtilization != 0) == (m.TargetAverageValue != "") {
+			return fmt.Errorf("resource metric %s requires one of target_average_utilization or target_average_value", m.Name)
+		}
+		if m.TargetAverageUtilization != 0 && (m.TargetAverageUtilization < 75 || m.TargetAverageUtilization > 100) {
+			return fmt.Errorf("resource metric %s utilization invalid; thresholds between 75%% and 100%% allowed", m.Name)
+		}
+		if m.TargetValue != "" || m.Selector != nil || m.Object != nil {
+			return fmt.Errorf("resource metric %s only takes target_average_utilization or target_average_value", m.Name)
+		}
+	case MetricTypePods:
+		if m.TargetAverageValue == "" {
+			return fmt.Errorf("pods metric %s requires target_average_value", m.Name)
+		}
+		if m.TargetAverageUtilization != 0 || m.TargetValue != "" || m.Object != nil {
+			return fmt.Errorf("pods metric %s only takes target_average_value and selector", m.Name)
+		}
+	case MetricTypeObject, MetricTypeExternal:
+		if (m.TargetValue != "") == (m.TargetAverageValue != "") {
+			return fmt.Errorf("%s metric %s requires one of target_value or target_average_value", m.MetricType(), m.Name)
+		}
+		if m.TargetAverageUtilization != 0 {
+			return fmt.Errorf("%s metric %s target_average_utilization does not exist", m.MetricType(), m.Name)
+		}
+		if m.MetricType() == MetricTypeObject && m.Object == nil {
+			return fmt.Errorf("object metric %s requires object", m.Name)
+		}
+		if m.MetricType() == MetricTypeExternal && m.Object != nil {
+			return fmt.Errorf("external metric %s doesn't take object", m.Name)
+		}
+	}
+	return nil
+}
+
+// validScalingBehavior returns an error if scaling rules are out of range
+func validScalingBehavior(behavior *ScalingBehavior) error {
+	if behavior == nil {
+		return nil
+	}
+	if err := validScalingRules("scale_up", behavior.ScaleUp); err != nil {
+		return err
+	}
+	return validScalingRules("scale_down", behavior.ScaleDown)
+}
+
+func validScalingRules(direction string, rules *ScalingRules) error {
+	if rules == nil {
+		return nil
+	}
+	if w := rules.StabilizationWindowSeconds; w != nil && (*w < 0 || *w > 3600) {
+		return fmt.Errorf("%s.stabilization_window_seconds invalid; values between 0 and 3600 allowed", direction)
+	}
+	for i, p := range rules.Policies {
+		if p.Type != "Pods" && p.Type != "Percent" {
+			return fmt.Errorf("%s.policies[%d].type invalid; must be Pods or Percent", direction, i)
+		}
+		if p.Value < 1 {
+			return fmt.Errorf("%s.policies[%d].value invalid; must be greater than 0", direction, i)
+		}
+		if p.PeriodSeconds < 1 || p.PeriodSeconds > 1800 {
+			return fmt.Errorf("%s.policies[%d].period_seconds invalid; values between 1 and 1800 allowed", direction, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package bitesize
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// includeExtensions are extensions of files included from a directory
+var includeExtensions = []string{".bitesize", ".yaml", ".yml"}
+
+// expandIncludes appends services from files matched by "include" patterns
+// of raw environment to its services. Patterns are globs or directories
+// relative to dir; a directory includes every .bitesize, .yaml and .yml
+// file in it. Every file holds a single service or a list of services.
+// Returns whether any file was included.
+func expandIncludes(env map[interface{}]interface{}, dir string) (bool, error) {
+	patterns, _ := env["include"].([]interface{})
+	if len(patterns) == 0 {
+		return false, nil
+	}
+
+	sources := map[string]string{}
+	for _, svc := range namedItems(env["services"]) {
+		sources[fmt.Sprintf("%v", svc["name"])] = "services"
+	}
+
+	services, _ := env["services"].([]interface{})
+	for _, pattern := range patterns {
+		files, err := includeFiles(fmt.Sprintf("%v", pattern), dir)
+		if err != nil {
+			return false, fmt.Errorf("include.%s", err.Error())
+		}
+
+		for _, file := range files {
+			rel, _ := filepath.Rel(dir, file)
+			items, err := loadServiceFile(file)
+			if err != nil {
+				return false, fmt.Errorf("include.%s: %s", rel, err.Error())
+			}
+
+			for _, item := range items {
+				name := fmt.Sprintf("%v", item["name"])
+				if source, ok := sources[name]; ok {
+					return false, fmt.Errorf("include.%s: service %s is already defined in %s", rel, name, source)
+				}
+				sources[name] = rel
+				services = append(services, item)
+			}
+		}
+	}
+
+	env["services"] = services
+	return true, nil
+}
+
+// includeFiles returns files matching pattern, sorted by path. Files
+// outside of dir can't be included.
+func includeFiles(pattern, dir string) ([]string, error) {
+	path := filepath.Join(dir, pattern)
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s: outside of the manifest directory", pattern)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		var retval []string
+		for _, ext := range includeExtensions {
+			matches, _ := filepath.Glob(filepath.Join(path, "*"+ext))
+			retval = append(retval, matches...)
+		}
+		sort.Strings(retval)
+		return retval, nil
+	}
+
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", pattern, err.Error())
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%s: no files found", pattern)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// loadServiceFile returns raw services in file. Files without ${var}
+// references are also parsed as services here, so errors refer to lines of
+// the file they're in.
+func loadServiceFile(file string) ([]map[interface{}]interface{}, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw interface{}
+	if err = yaml.Unmarshal(contents, &raw); err != nil {
+		return nil, err
+	}
+
+	var items []interface{}
+	switch raw := raw.(type) {
+	case map[interface{}]interface{}:
+		items = []interface{}{raw}
+		if !varReference.Match(contents) {
+			err = yaml.Unmarshal(contents, &Service{})
+		}
+	case []interface{}:
+		items = raw
+		if !varReference.Match(contents) {
+			err = yaml.Unmarshal(contents, &Services{})
+		}
+	default:
+		return nil, fmt.Errorf("expected a service or a list of services")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	retval := namedItems(items)
+	if len(retval) != len(items) {
+		return nil, fmt.Errorf("expected a service or a list of services")
+	}
+	for _, item := range retval {
+		if item["name"] == nil {
+			return nil, fmt.Errorf("service name is required")
+		}
+	}
+	return retval, nil
+}
@@ -0,0 +1,247 @@
+package bitesize
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IngressProfileLabel records the ingress profile on generated ingresses
+const IngressProfileLabel = "ingressProfile"
+
+// IngressAnnotationsAnnotation lists annotations of ingress set by
+// ingress_annotations, so they're read back into ingress_annotations
+const IngressAnnotationsAnnotation = "ingress_annotations"
+
+// IngressProfile translates service ingress settings (ssl, httpsOnly,
+// httpsBackend, http2, ingress_timeout, ingress_max_body_size and
+// ingress_whitelist) to annotations understood by an ingress controller
+type IngressProfile interface {
+	// Annotations returns controller annotations for the service
+	Annotations(svc Service) map[string]string
+	// Scrape reads service settings back from annotations
+	Scrape(annotations map[string]string, svc *Service)
+	// Manages returns true if annotation is generated by the profile
+	Manages(annotation string) bool
+	// Unsupported returns service settings the controller can't express
+	Unsupported(svc Service) []string
+}
+
+// IngressProfiles lists available ingress profiles by name
+var IngressProfiles = map[string]IngressProfile{
+	"nginx":   nginxProfile{},
+	"traefik": traefikProfile{},
+	"alb":     albProfile{},
+}
+
+// IngressAnnotationsFor returns ingress annotations for the service: the
+// profile annotations overlaid with service ingress_annotations
+func IngressAnnotationsFor(svc Service) map[string]string {
+	annotations := map[string]string{}
+	if profile, ok := IngressProfiles[svc.IngressProfile]; ok {
+		for k, v := range profile.Annotations(svc) {
+			annotations[k] = v
+		}
+	}
+	for k, v := range svc.IngressAnnotations {
+		annotations[k] = v
+	}
+	return annotations
+}
+
+// IngressAnnotationKeys returns the annotation recording which ingress
+// annotations are set by ingress_annotations, or empty string if there are
+// none
+func IngressAnnotationKeys(svc Service) string {
+	var keys []string
+	for k := range svc.IngressAnnotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// validateIngressProfile returns an error if service sets ingress settings
+// its profile can't express, or overrides annotations the profile manages
+func validateIngressProfile(svc Service) error {
+	if svc.IngressProfile == "" {
+		if svc.IngressTimeout != 0 || svc.IngressMaxBodySize != "" || len(svc.IngressWhitelist) != 0 {
+			return fmt.Errorf("%s: ingress settings require ingress_profile", svc.Name)
+		}
+		return nil
+	}
+
+	profile, ok := IngressProfiles[svc.IngressProfile]
+	if !ok {
+		return fmt.Errorf("%s: unknown ingress_profile %s", svc.Name, svc.IngressProfile)
+	}
+	if unsupported := profile.Unsupported(svc); len(unsupported) != 0 {
+		return fmt.Errorf("%s: %s not supported by ingress_profile %s",
+			svc.Name, strings.Join(unsupported, ","), svc.IngressProfile)
+	}
+
+	var keys []string
+	for k := range svc.IngressAnnotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if profile.Manages(k) {
+			return fmt.Errorf("%s: ingress_annotations %s is managed by ingress_profile %s", svc.Name, k, svc.IngressProfile)
+		}
+	}
+	return nil
+}
+
+func managedBy(prefix string, keys ...string) func(string) bool {
+	return func(annotation string) bool {
+		for _, k := range keys {
+			if annotation == prefix+k {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// nginxProfile targets kubernetes/ingress-nginx
+type nginxProfile struct{}
+
+const nginxPrefix = "nginx.ingress.kubernetes.io/"
+
+func (nginxProfile) Annotations(svc Service) map[string]string {
+	a := map[string]string{}
+	if svc.Ssl == "true" {
+		a[nginxPrefix+"ssl-redirect"] = strconv.FormatBool(svc.HTTPSOnly == "true")
+	}
+	switch {
+	case svc.HTTP2 == "true" && svc.HTTPSBackend == "true":
+		a[nginxPrefix+"backend-protocol"] = "GRPCS"
+	case svc.HTTP2 == "true":
+		a[nginxPrefix+"backend-protocol"] = "GRPC"
+	case svc.HTTPSBackend == "true":
+		a[nginxPrefix+"backend-protocol"] = "HTTPS"
+	}
+	if svc.IngressTimeout != 0 {
+		timeout := strconv.Itoa(svc.IngressTimeout)
+		a[nginxPrefix+"proxy-read-timeout"] = timeout
+		a[nginxPrefix+"proxy-send-timeout"] = timeout
+	}
+	if svc.IngressMaxBodySize != "" {
+		a[nginxPrefix+"proxy-body-size"] = svc.IngressMaxBodySize
+	}
+	if len(svc.IngressWhitelist) != 0 {
+		a[nginxPrefix+"whitelist-source-range"] = strings.Join(svc.IngressWhitelist, ",")
+	}
+	return a
+}
+
+func (nginxProfile) Scrape(annotations map[string]string, svc *Service) {
+	svc.IngressTimeout, _ = strconv.Atoi(annotations[nginxPrefix+"proxy-read-timeout"])
+	svc.IngressMaxBodySize = annotations[nginxPrefix+"proxy-body-size"]
+	svc.IngressWhitelist = splitList(annotations[nginxPrefix+"whitelist-source-range"])
+}
+
+func (nginxProfile) Manages(annotation string) bool {
+	return managedBy(nginxPrefix, "ssl-redirect", "backend-protocol", "proxy-read-timeout",
+		"proxy-send-timeout", "proxy-body-size", "whitelist-source-range")(annotation)
+}
+
+func (nginxProfile) Unsupported(svc Service) []string {
+	return nil
+}
+
+// traefikProfile targets traefik v2. Timeouts, body size and whitelists
+// need traefik middlewares and can't be set with ingress annotations.
+type traefikProfile struct{}
+
+const traefikPrefix = "traefik.ingress.kubernetes.io/"
+
+func (traefikProfile) Annotations(svc Service) map[string]string {
+	a := map[string]string{}
+	if svc.Ssl == "true" {
+		a[traefikPrefix+"router.tls"] = "true"
+		if svc.HTTPSOnly == "true" {
+			a[traefikPrefix+"router.entrypoints"] = "websecure"
+		}
+	}
+	return a
+}
+
+func (traefikProfile) Scrape(annotations map[string]string, svc *Service) {}
+
+func (traefikProfile) Manages(annotation string) bool {
+	return managedBy(traefikPrefix, "router.tls", "router.entrypoints")(annotation)
+}
+
+func (traefikProfile) Unsupported(svc Service) []string {
+	var unsupported []string
+	if svc.IngressTimeout != 0 {
+		unsupported = append(unsupported, "ingress_timeout")
+	}
+	if svc.IngressMaxBodySize != "" {
+		unsupported = append(unsupported, "ingress_max_body_size")
+	}
+	if len(svc.IngressWhitelist) != 0 {
+		unsupported = append(unsupported, "ingress_whitelist")
+	}
+	return unsupported
+}
+
+// albProfile targets aws-load-balancer-controller. ALB has no request body
+// size limit.
+type albProfile struct{}
+
+const (
+	albPrefix          = "alb.ingress.kubernetes.io/"
+	albIdleTimeoutAttr = "idle_timeout.timeout_seconds="
+)
+
+func (albProfile) Annotations(svc Service) map[string]string {
+	a := map[string]string{}
+	if svc.Ssl == "true" {
+		a[albPrefix+"listen-ports"] = `[{"HTTP": 80}, {"HTTPS": 443}]`
+		if svc.HTTPSOnly == "true" {
+			a[albPrefix+"ssl-redirect"] = "443"
+		}
+	}
+	if svc.HTTPSBackend == "true" {
+		a[albPrefix+"backend-protocol"] = "HTTPS"
+	}
+	if svc.HTTP2 == "true" {
+		a[albPrefix+"backend-protocol-version"] = "HTTP2"
+	}
+	if svc.IngressTimeout != 0 {
+		a[albPrefix+"load-balancer-attributes"] = albIdleTimeoutAttr + strconv.Itoa(svc.IngressTimeout)
+	}
+	if len(svc.IngressWhitelist) != 0 {
+		a[albPrefix+"inbound-cidrs"] = strings.Join(svc.IngressWhitelist, ",")
+	}
+	return a
+}
+
+func (albProfile) Scrape(annotations map[string]string, svc *Service) {
+	attrs := annotations[albPrefix+"load-balancer-attributes"]
+	svc.IngressTimeout, _ = strconv.Atoi(strings.TrimPrefix(attrs, albIdleTimeoutAttr))
+	svc.IngressWhitelist = splitList(annotations[albPrefix+"inbound-cidrs"])
+}
+
+func (albProfile) Manages(annotation string) bool {
+	return managedBy(albPrefix, "listen-ports", "ssl-redirect", "backend-protocol",
+		"backend-protocol-version", "load-balancer-attributes", "inbound-cidrs")(annotation)
+}
+
+func (albProfile) Unsupported(svc Service) []string {
+	if svc.IngressMaxBodySize != "" {
+		return []string{"ingress_max_body_size"}
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
@@ -0,0 +1,90 @@
+package bitesize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIngressProfileAnnotations(t *testing.T) {
+	svc := Service{
+		Name:               "front",
+		Ssl:                "true",
+		HTTPSOnly:          "true",
+		HTTPSBackend:       "true",
+		IngressTimeout:     120,
+		IngressMaxBodySize: "10m",
+		IngressWhitelist:   []string{"10.0.0.0/8", "192.168.0.0/16"},
+		IngressAnnotations: map[string]string{"nginx.ingress.kubernetes.io/rewrite-target": "/"},
+	}
+
+	var testCases = []struct {
+		Profile  string
+		Expected map[string]string
+	}{
+		{"nginx", map[string]string{
+			"nginx.ingress.kubernetes.io/ssl-redirect":           "true",
+			"nginx.ingress.kubernetes.io/backend-protocol":       "HTTPS",
+			"nginx.ingress.kubernetes.io/proxy-read-timeout":     "120",
+			"nginx.ingress.kubernetes.io/proxy-send-timeout":     "120",
+			"nginx.ingress.kubernetes.io/proxy-body-size":        "10m",
+			"nginx.ingress.kubernetes.io/whitelist-source-range": "10.0.0.0/8,192.168.0.0/16",
+			"nginx.ingress.kubernetes.io/rewrite-target":         "/",
+		}},
+		{"alb", map[string]string{
+			"alb.ingress.kubernetes.io/listen-ports":             `[{"HTTP": 80}, {"HTTPS": 443}]`,
+			"alb.ingress.kubernetes.io/ssl-redirect":             "443",
+			"alb.ingress.kubernetes.io/backend-protocol":         "HTTPS",
+			"alb.ingress.kubernetes.io/load-balancer-attributes": "idle_timeout.timeout_seconds=120",
+			"alb.ingress.kubernetes.io/inbound-cidrs":            "10.0.0.0/8,192.168.0.0/16",
+			"nginx.ingress.kubernetes.io/rewrite-target":         "/",
+		}},
+		{"traefik", map[string]string{
+			"traefik.ingress.kubernetes.io/router.tls":         "true",
+			"traefik.ingress.kubernetes.io/router.entrypoints": "websecure",
+			"nginx.ingress.kubernetes.io/rewrite-target":       "/",
+		}},
+	}
+
+	for _, tCase := range testCases {
+		s := svc
+		s.IngressProfile = tCase.Profile
+		annotations := IngressAnnotationsFor(s)
+		if !reflect.DeepEqual(annotations, tCase.Expected) {
+			t.Errorf("%s: unexpected annotations %v", tCase.Profile, annotations)
+		}
+
+		// settings the profile supports are scraped back
+		scraped := Service{}
+		IngressProfiles[tCase.Profile].Scrape(annotations, &scraped)
+		if tCase.Profile != "traefik" && (scraped.IngressTimeout != 120 || !reflect.DeepEqual(scraped.IngressWhitelist, svc.IngressWhitelist)) {
+			t.Errorf("%s: unexpected scraped settings %+v", tCase.Profile, scraped)
+		}
+	}
+}
+
+func TestValidateIngressProfile(t *testing.T) {
+	var testCases = []struct {
+		Service Service
+		Valid   bool
+	}{
+		{Service{Name: "a"}, true},
+		{Service{Name: "a", IngressProfile: "nginx", IngressMaxBodySize: "1m"}, true},
+		{Service{Name: "a", IngressTimeout: 30}, false},
+		{Service{Name: "a", IngressProfile: "haproxy"}, false},
+		{Service{Name: "a", IngressProfile: "alb", IngressMaxBodySize: "1m"}, false},
+		{Service{Name: "a", IngressProfile: "traefik", IngressWhitelist: []string{"10.0.0.0/8"}}, false},
+		{Service{Name: "a", IngressProfile: "nginx", IngressAnnotations: map[string]string{
+			"nginx.ingress.kubernetes.io/proxy-body-size": "1m",
+		}}, false},
+	}
+
+	for _, tCase := range testCases {
+		err := validateIngressProfile(tCase.Service)
+		if tCase.Valid && err != nil {
+			t.Errorf("Unexpected error for %+v: %s", tCase.Service, err.Error())
+		}
+		if !tCase.Valid && err == nil {
+			t.Errorf("Expected error for %+v, got nil", tCase.Service)
+		}
+	}
+}
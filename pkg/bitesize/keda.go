@@ -0,0 +1,100 @@
+package bitesize
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pearsontechnology/environment-operator/pkg/config"
+)
+
+// KEDA defaults of ScaledObject polling interval and cooldown period, set
+// explicitly so they're compared with what's scraped back
+const (
+	KedaDefaultPollingInterval = 30
+	KedaDefaultCooldownPeriod  = 300
+)
+
+var kedaTriggerType = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// KedaScaling represents "keda" block of a service in environments.bitesize:
+// event driven autoscaling by a KEDA ScaledObject, in place of hpa. Unlike
+// hpa it can scale the workload to zero replicas.
+type KedaScaling struct {
+	MinReplicas     int32         `yaml:"min_replicas"`
+	MaxReplicas     int32         `yaml:"max_replicas"`
+	PollingInterval int32         `yaml:"polling_interval,omitempty"`
+	CooldownPeriod  int32         `yaml:"cooldown_period,omitempty"`
+	Triggers        []KedaTrigger `yaml:"triggers"`
+}
+
+// KedaTrigger is an event source the service is scaled on, e.g.
+// aws-sqs-queue or kafka. Metadata is passed to the KEDA scaler as is.
+type KedaTrigger struct {
+	Type              string            `yaml:"type"`
+	Metadata          map[string]string `yaml:"metadata"`
+	AuthenticationRef string            `yaml:"authentication_ref,omitempty"`
+}
+
+// HasKeda returns true if the service is scaled by a KEDA ScaledObject
+func (e Service) HasKeda() bool {
+	return e.Keda != nil
+}
+
+// setKedaDefaults fills in keda fields omitted in the manifest. Services
+// start with min_replicas replicas, or the replicas set if it's zero.
+func (e *Service) setKedaDefaults() {
+	if e.Keda == nil {
+		return
+	}
+	if e.Keda.PollingInterval == 0 {
+		e.Keda.PollingInterval = KedaDefaultPollingInterval
+	}
+	if e.Keda.CooldownPeriod == 0 {
+		e.Keda.CooldownPeriod = KedaDefaultCooldownPeriod
+	}
+	if e.Keda.MinReplicas != 0 {
+		e.Replicas = int(e.Keda.MinReplicas)
+	}
+}
+
+// validateKeda returns an error if keda settings are out of range or the
+// service can't be scaled by KEDA
+func validateKeda(svc Service) error {
+	k := svc.Keda
+	if k == nil {
+		return nil
+	}
+	if svc.Type != "" || svc.IsCustomResource() {
+		return fmt.Errorf("%s: keda is not supported for custom resources", svc.Name)
+	}
+	if svc.HPA.MinReplicas != 0 {
+		return fmt.Errorf("%s: keda not valid with hpa, only one of them scales the service", svc.Name)
+	}
+	if svc.IsBlueGreenParentDeployment() {
+		return fmt.Errorf("%s: keda is not supported for bluegreen deployment method", svc.Name)
+	}
+	if k.MinReplicas < 0 {
+		return fmt.Errorf("%s: keda.min_replicas invalid; must not be negative", svc.Name)
+	}
+	if k.MaxReplicas < 1 || k.MaxReplicas > int32(config.Env.HPAMaxReplicas) {
+		return fmt.Errorf("%s: keda.max_replicas invalid; values between 1 and %d allowed", svc.Name, config.Env.HPAMaxReplicas)
+	}
+	if k.MinReplicas > k.MaxReplicas {
+		return fmt.Errorf("%s: keda.min_replicas invalid; must not be greater than max_replicas", svc.Name)
+	}
+	if k.PollingInterval < 0 || k.CooldownPeriod < 0 {
+		return fmt.Errorf("%s: keda polling_interval and cooldown_period must not be negative", svc.Name)
+	}
+	if len(k.Triggers) == 0 {
+		return fmt.Errorf("%s: keda.triggers: at least one trigger is required", svc.Name)
+	}
+	for i, trigger := range k.Triggers {
+		if !kedaTriggerType.MatchString(trigger.Type) {
+			return fmt.Errorf("%s: keda.triggers[%d].type invalid; KEDA scaler type, e.g. aws-sqs-queue or kafka, required", svc.Name, i)
+		}
+		if len(trigger.Metadata) == 0 {
+			return fmt.Errorf("%s: keda.triggers[%d].metadata is required", svc.Name, i)
+		}
+	}
+	return nil
+}
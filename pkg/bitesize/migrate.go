@@ -0,0 +1,406 @@
+package bitesize
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// LatestVersion is the environments.bitesize format version written by
+// environment-validator -migrate. Documents without "version" are version 1.
+const LatestVersion = 2
+
+// migration upgrades raw environments and services to version. Functions
+// return the changes they made. rewrite is only run by
+// environment-validator -migrate, for changes that should be reviewed in
+// the rewritten file rather than made silently on every load.
+type migration struct {
+	version     int
+	environment func(env map[interface{}]interface{}) []migrationChange
+	service     func(svc map[interface{}]interface{}) []migrationChange
+	rewrite     func(svc map[interface{}]interface{}) []migrationChange
+}
+
+// migrationChange is a single key rewritten by a migration. Lossy changes
+// drop settings and are logged as warnings on load.
+type migrationChange struct {
+	path        string
+	description string
+	lossy       bool
+}
+
+func (c migrationChange) String() string {
+	return fmt.Sprintf("%s: %s", c.path, c.description)
+}
+
+var migrations = []migration{
+	{version: 2, environment: migrateEnvironmentV2, service: migrateServiceV2, rewrite: rewriteServiceV2},
+}
+
+// documentVersion returns format version of raw environments.bitesize
+func documentVersion(v interface{}) (int, error) {
+	if v == nil {
+		return 1, nil
+	}
+	version, ok := v.(int)
+	if !ok || version < 1 || version > LatestVersion {
+		return 0, fmt.Errorf("version: must be between 1 and %d", LatestVersion)
+	}
+	return version, nil
+}
+
+// apply runs migration over raw environment and its services, including
+// the rewrite when files are rewritten
+func (m migration) apply(env map[interface{}]interface{}, rewrite bool) []migrationChange {
+	return append(m.environment(env), m.services(namedItems(env["services"]), rewrite)...)
+}
+
+func (m migration) services(services []map[interface{}]interface{}, rewrite bool) []migrationChange {
+	var retval []migrationChange
+	for _, svc := range services {
+		var changes []migrationChange
+		if rewrite && m.rewrite != nil {
+			changes = m.rewrite(svc)
+		}
+		for _, c := range append(changes, m.service(svc)...) {
+			c.path = fmt.Sprintf("services[%v].%s", svc["name"], c.path)
+			retval = append(retval, c)
+		}
+	}
+	return retval
+}
+
+// migrateEnvironment upgrades raw environment of the given version to
+// LatestVersion in place. Legacy shapes in environments already at a
+// version that dropped them are errors.
+func migrateEnvironment(env map[interface{}]interface{}, version int) ([]migrationChange, error) {
+	var retval []migrationChange
+	for _, m := range migrations {
+		changes := m.apply(env, false)
+		if len(changes) > 0 && m.version <= version {
+			return nil, fmt.Errorf("%s: not valid in version %d (%s), run environment-validator -migrate", changes[0].path, version, changes[0].description)
+		}
+		retval = append(retval, changes...)
+	}
+	return retval, nil
+}
+
+// migrateEnvironmentV2 removes obsolete "tests"
+func migrateEnvironmentV2(env map[interface{}]interface{}) []migrationChange {
+	if _, ok := env["tests"]; !ok {
+		return nil
+	}
+	delete(env, "tests")
+	return []migrationChange{{path: "tests", description: "obsolete, removed"}}
+}
+
+// rewriteServiceV2 replaces health_check with an exec liveness_probe
+func rewriteServiceV2(svc map[interface{}]interface{}) []migrationChange {
+	// health_check that doesn't parse is left for the parser to report
+	hc, ok := svc["health_check"]
+	if !ok || !isHealthCheck(hc) {
+		return nil
+	}
+	check, _ := hc.(map[interface{}]interface{})
+	if svc["liveness_probe"] != nil || check == nil {
+		return nil
+	}
+
+	delete(svc, "health_check")
+	probe := map[interface{}]interface{}{
+		"handler": map[interface{}]interface{}{
+			"exec": map[interface{}]interface{}{"command": check["command"]},
+		},
+	}
+	if check["initial_delay"] != nil {
+		probe["initial_delay_seconds"] = check["initial_delay"]
+	}
+	if check["timeout"] != nil {
+		probe["timeout_seconds"] = check["timeout"]
+	}
+	svc["liveness_probe"] = probe
+	return []migrationChange{{path: "health_check", description: "replaced with liveness_probe"}}
+}
+
+// migrateServiceV2 removes health_check, replaces "true" and "false"
+// strings of boolean keys with booleans and secret env values without a
+// key with <secret>/<secret>
+func migrateServiceV2(svc map[interface{}]interface{}) []migrationChange {
+	var retval []migrationChange
+
+	// health_check that doesn't parse is left for the parser to report
+	if hc, ok := svc["health_check"]; ok && isHealthCheck(hc) {
+		delete(svc, "health_check")
+		check, _ := hc.(map[interface{}]interface{})
+		switch {
+		case svc["liveness_probe"] != nil:
+			retval = append(retval, migrationChange{path: "health_check", description: "removed, liveness_probe is set"})
+		case check == nil:
+			retval = append(retval, migrationChange{path: "health_check", description: "empty, removed"})
+		default:
+			retval = append(retval, migrationChange{
+				path:        "health_check",
+				description: "obsolete, ignored; environment-validator -migrate replaces it with liveness_probe",
+				lossy:       true,
+			})
+		}
+	}
+
+	for _, key := range []string{"ssl", "http2", "httpsOnly", "httpsBackend"} {
+		if s, ok := svc[key].(string); ok && (s == "true" || s == "false") {
+			svc[key] = s == "true"
+			retval = append(retval, migrationChange{path: key, description: fmt.Sprintf("%q -> %s", s, s)})
+		}
+	}
+
+	for _, env := range namedItems(svc["env"]) {
+		value, ok := env["value"].(string)
+		if env["secret"] == nil || !ok || strings.Contains(value, "/") {
+			continue
+		}
+		env["value"] = value + "/" + value
+		retval = append(retval, migrationChange{
+			path:        fmt.Sprintf("env[%v].value", env["secret"]),
+			description: fmt.Sprintf("%q -> %q", value, env["value"]),
+		})
+	}
+	return retval
+}
+
+func isHealthCheck(v interface{}) bool {
+	byt, err := yaml.Marshal(v)
+	if err == nil {
+		err = yaml.Unmarshal(byt, &HealthCheck{})
+	}
+	return err == nil
+}
+
+// MigrateFile upgrades environments.bitesize at path, and the files it
+// includes services from, to LatestVersion, rewriting them in place. If
+// path is a directory, environments.bitesize in it is migrated. Returns
+// the changes made. Key order is kept, comments are not: every rewritten
+// file is kept as it was in <file>.bak.
+func MigrateFile(path string) ([]string, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "environments.bitesize")
+	}
+	dir := filepath.Dir(path)
+
+	var doc map[interface{}]interface{}
+	original, err := readOrdered(path, &doc)
+	if err != nil {
+		return nil, err
+	}
+	version, err := documentVersion(doc["version"])
+	if err != nil {
+		return nil, err
+	}
+	if version == LatestVersion {
+		return nil, nil
+	}
+
+	var retval []string
+	migrated := map[string]bool{}
+	for _, env := range namedItems(doc["environments"]) {
+		for _, m := range migrations {
+			if m.version <= version {
+				continue
+			}
+			for _, c := range m.apply(env, true) {
+				retval = append(retval, fmt.Sprintf("environments[%v].%s", env["name"], c))
+			}
+		}
+
+		patterns, _ := env["include"].([]interface{})
+		for _, pattern := range patterns {
+			files, err := includeFiles(fmt.Sprintf("%v", pattern), dir)
+			if err != nil {
+				return nil, fmt.Errorf("include.%s", err.Error())
+			}
+			for _, file := range files {
+				if migrated[file] {
+					continue
+				}
+				migrated[file] = true
+
+				changes, err := migrateServiceFile(file, version)
+				rel, _ := filepath.Rel(dir, file)
+				if err != nil {
+					return nil, fmt.Errorf("include.%s: %s", rel, err.Error())
+				}
+				for _, c := range changes {
+					retval = append(retval, fmt.Sprintf("include.%s: %s", rel, c))
+				}
+			}
+		}
+	}
+
+	doc["version"] = LatestVersion
+	if keys, ok := original.(yaml.MapSlice); ok && !hasKey(keys, "version") {
+		// version goes first, where it's seen
+		original = append(yaml.MapSlice{{Key: "version"}}, keys...)
+	}
+	if err = writeYAML(path, orderedLike(doc, original)); err != nil {
+		return nil, err
+	}
+	return retval, nil
+}
+
+// migrateServiceFile migrates services of an included file, rewriting it
+// if anything changed
+func migrateServiceFile(file string, version int) ([]migrationChange, error) {
+	var raw interface{}
+	original, err := readOrdered(file, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []map[interface{}]interface{}
+	switch raw := raw.(type) {
+	case map[interface{}]interface{}:
+		services = []map[interface{}]interface{}{raw}
+	case []interface{}:
+		services = namedItems(raw)
+	}
+
+	var changes []migrationChange
+	for _, m := range migrations {
+		if m.version > version {
+			changes = append(changes, m.services(services, true)...)
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return changes, writeYAML(file, orderedLike(raw, original))
+}
+
+// readOrdered unmarshals file into v, returning its contents with mappings
+// decoded as yaml.MapSlice, to keep key order when it's written back
+func readOrdered(file string, v interface{}) (interface{}, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(contents, v); err != nil {
+		return nil, err
+	}
+	var original orderedNode
+	if err = yaml.Unmarshal(contents, &original); err != nil {
+		return nil, err
+	}
+	return original.value, nil
+}
+
+// writeYAML replaces file with v, keeping the file as it was in
+// <file>.bak, as comments are lost
+func writeYAML(file string, v interface{}) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	original, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	byt, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(file+".bak", original, info.Mode()); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, byt, info.Mode())
+}
+
+// orderedNode decodes YAML with mappings, at any depth, as yaml.MapSlice
+type orderedNode struct {
+	value interface{}
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface for orderedNode.
+func (n *orderedNode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch v.(type) {
+	case map[interface{}]interface{}:
+		var keys yaml.MapSlice
+		var values map[interface{}]orderedNode
+		if err := unmarshal(&keys); err != nil {
+			return err
+		}
+		if err := unmarshal(&values); err != nil {
+			return err
+		}
+		retval := yaml.MapSlice{}
+		for _, item := range keys {
+			retval = append(retval, yaml.MapItem{Key: item.Key, Value: values[item.Key].value})
+		}
+		n.value = retval
+	case []interface{}:
+		var items []orderedNode
+		if err := unmarshal(&items); err != nil {
+			return err
+		}
+		retval := make([]interface{}, len(items))
+		for i, item := range items {
+			retval[i] = item.value
+		}
+		n.value = retval
+	default:
+		n.value = v
+	}
+	return nil
+}
+
+// orderedLike returns v with mappings converted to yaml.MapSlice, keys
+// ordered as in original. Keys not in original follow, sorted.
+func orderedLike(v, original interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		originalMap, _ := original.(yaml.MapSlice)
+		retval := yaml.MapSlice{}
+		seen := map[interface{}]bool{}
+		for _, item := range originalMap {
+			if value, ok := v[item.Key]; ok {
+				retval = append(retval, yaml.MapItem{Key: item.Key, Value: orderedLike(value, item.Value)})
+				seen[item.Key] = true
+			}
+		}
+		for _, k := range sortedKeys(v) {
+			if !seen[k] {
+				retval = append(retval, yaml.MapItem{Key: k, Value: orderedLike(v[k], nil)})
+			}
+		}
+		return retval
+	case []interface{}:
+		originalList, _ := original.([]interface{})
+		retval := make([]interface{}, len(v))
+		for i, item := range v {
+			var o interface{}
+			if i < len(originalList) {
+				o = originalList[i]
+			}
+			retval[i] = orderedLike(item, o)
+		}
+		return retval
+	default:
+		return v
+	}
+}
+
+func hasKey(m yaml.MapSlice, key string) bool {
+	for _, item := range m {
+		if item.Key == key {
+			return true
+		}
+	}
+	return false
+}
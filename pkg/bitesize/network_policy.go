@@ -0,0 +1,57 @@
+package bitesize
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+)
+
+// NetworkPeer represents a single entry in service's allow_from or allow_to
+// lists. A peer is either another service (optionally in a different
+// namespace), a whole namespace or an IP block.
+type NetworkPeer struct {
+	Service   string `yaml:"service,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+	CIDR      string `yaml:"cidr,omitempty"`
+	Ports     []int  `yaml:"ports,omitempty"`
+}
+
+// NetworkPolicySettings represents environment-wide "network_policy" block
+// in environments.bitesize
+type NetworkPolicySettings struct {
+	DefaultDeny bool `yaml:"default_deny,omitempty"`
+}
+
+// HasNetworkPolicy returns true if service declares any allowed dependencies
+func (e Service) HasNetworkPolicy() bool {
+	return len(e.AllowFrom) != 0 || len(e.AllowTo) != 0
+}
+
+func validNetworkPeers(v interface{}, param string) error {
+	peers, ok := v.([]NetworkPeer)
+	if !ok {
+		return fmt.Errorf("invalid network peers: %v", reflect.ValueOf(v))
+	}
+
+	for _, p := range peers {
+		if p.Service == "" && p.Namespace == "" && p.CIDR == "" {
+			return fmt.Errorf("network peer %+v must specify one of service, namespace or cidr", p)
+		}
+
+		if p.CIDR != "" {
+			if p.Service != "" || p.Namespace != "" {
+				return fmt.Errorf("network peer %+v invalid; cidr can't be combined with service or namespace", p)
+			}
+			if _, _, err := net.ParseCIDR(p.CIDR); err != nil {
+				return fmt.Errorf("network peer cidr %s is invalid", p.CIDR)
+			}
+		}
+
+		for _, port := range p.Ports {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("network peer %+v port %d out of range", p, port)
+			}
+		}
+	}
+	return nil
+}
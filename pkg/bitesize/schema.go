@@ -0,0 +1,373 @@
+package bitesize
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pearsontechnology/environment-operator/version"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// schemaEnum matches validator regexps allowing a fixed list of values,
+// e.g. ^(true|false)*$
+var schemaEnum = regexp.MustCompile(`^\^\(([a-zA-Z0-9_|-]+)\)\*\$$`)
+
+// schemaScalar is the schema of string fields. YAML scalars of any type are
+// read as strings, so numbers and booleans are accepted too.
+func schemaScalar() map[string]interface{} {
+	return map[string]interface{}{"type": []string{"string", "number", "boolean"}}
+}
+
+// schemaTypes are schemas of types read by custom unmarshalers in a different
+// shape than their Go type
+var schemaTypes = map[reflect.Type]func() map[string]interface{}{
+	reflect.TypeOf(BlueGreenServiceSet(0)): func() map[string]interface{} {
+		return map[string]interface{}{"type": "string", "enum": []string{"blue", "green"}}
+	},
+	reflect.TypeOf(intstr.IntOrString{}): func() map[string]interface{} {
+		return map[string]interface{}{"type": []string{"integer", "string"}}
+	},
+}
+
+// schemaAnnotations is the schema of annotations lists, read into maps
+func schemaAnnotations() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":                 "object",
+			"properties":           map[string]interface{}{"name": schemaScalar(), "value": schemaScalar()},
+			"required":             []string{"name"},
+			"additionalProperties": false,
+		},
+	}
+}
+
+// schemaPorts is the schema of port and ports, a single port, a comma
+// separated list of ports or a list of port numbers and ServicePorts
+func schemaPorts() map[string]interface{} {
+	g := &schemaGenerator{definitions: map[string]interface{}{}}
+	item := g.structSchema(reflect.TypeOf(ServicePort{}))
+	item["type"] = []string{"integer", "string", "object"}
+	item["pattern"] = `^[0-9]+$`
+
+	return map[string]interface{}{
+		"type":    []string{"integer", "string", "array"},
+		"pattern": `^[0-9]+( *, *[0-9]+)*$`,
+		"items":   item,
+	}
+}
+
+// schemaExtraProperties are properties of types that are read by custom
+// unmarshalers, and so have no yaml tag
+var schemaExtraProperties = map[string]map[string]func() map[string]interface{}{
+	"Service": {
+		"annotations":         schemaAnnotations,
+		"ingress_annotations": schemaAnnotations,
+		"external_url": func() map[string]interface{} {
+			return map[string]interface{}{
+				"type":  []string{"string", "array"},
+				"items": map[string]interface{}{"type": "string"},
+			}
+		},
+		"options": func() map[string]interface{} { return map[string]interface{}{"type": "object"} },
+		"spec":    func() map[string]interface{} { return map[string]interface{}{"type": "object"} },
+		"remove":  func() map[string]interface{} { return map[string]interface{}{"type": "boolean"} },
+	},
+	"Gist": {
+		"remove": func() map[string]interface{} { return map[string]interface{}{"type": "boolean"} },
+	},
+	"ServiceType": {
+		"annotations": schemaAnnotations,
+	},
+	"DeploymentSettings": {
+		"active": schemaTypes[reflect.TypeOf(BlueGreenServiceSet(0))],
+	},
+	"Volume": {
+		"provisioning": func() map[string]interface{} {
+			return map[string]interface{}{"type": "string", "enum": []string{"dynamic", "manual"}}
+		},
+	},
+}
+
+// port and ports are generated from ServicePort, which would otherwise
+// make schemaExtraProperties refer to itself
+func init() {
+	schemaExtraProperties["Service"]["port"] = schemaPorts
+	schemaExtraProperties["Service"]["ports"] = schemaPorts
+}
+
+// schemaSkipped are properties set by environment operator, not in
+// environments.bitesize
+var schemaSkipped = map[string]bool{
+	"Service.status":          true,
+	"Service.resourceVersion": true,
+}
+
+// schemaOverrides are keywords added to property schemas: descriptions and
+// enums not expressed by validator tags
+var schemaOverrides = map[string]map[string]interface{}{
+	"EnvironmentsBitesize.version":      {"description": "Format version, 1 if not set", "minimum": 1, "maximum": LatestVersion},
+	"EnvironmentsBitesize.project":      {"description": "Project name"},
+	"EnvironmentsBitesize.vars":         {"description": "Variables available to ${var} interpolation in every environment"},
+	"EnvironmentsBitesize.strict":       {"description": "Reject (true) or log (warn) unknown keys"},
+	"EnvironmentsBitesize.environments": {"description": "Environments managed by environment operator"},
+
+	"Environment.name":             {"description": "Environment name, matched against ENVIRONMENT_NAME of the operator"},
+	"Environment.namespace":        {"description": "Kubernetes namespace the environment is deployed to"},
+	"Environment.extends":          {"description": "Name of the environment this environment inherits services, gists and settings from"},
+	"Environment.vars":             {"description": "Variables available to ${var} interpolation, overriding project vars"},
+	"Environment.include":          {"description": "Glob patterns or directories of files with services of the environment"},
+	"Environment.deployment":       {"description": "Default deployment settings of services"},
+	"Environment.network_policy":   {"description": "Environment-wide NetworkPolicy settings"},
+	"Environment.ingress_class":    {"description": "Ingress class of services without one"},
+	"Environment.ingress_profile":  {"description": "Ingress controller of services without one"},
+	"Environment.tls":              {"description": "Provider of TLS secrets of services with ssl enabled"},
+	"Environment.services":         {"description": "Services of the environment"},
+	"Environment.tests":            {"description": "Obsolete, removed by version 2"},
+	"Environment.gists":            {"description": "Kubernetes resources imported from files in the repository"},
+	"Environment.gists_repository": {"description": "Repository gists are read from, if not the manifest repository"},
+
+	"Service.name":                  {"description": "Name of the kubernetes service, deployment and ingress"},
+	"Service.port":                  {"description": "Service port, a comma separated list of ports or a list of ports"},
+	"Service.ports":                 {"description": "Service ports: a comma separated list or a list of port numbers and named ports"},
+	"Service.application":           {"description": "Docker image name of the service container"},
+	"Service.version":               {"description": "Docker image tag; services without a version are deployed via /deploy"},
+	"Service.replicas":              {"description": "Number of pods"},
+	"Service.external_url":          {"description": "Hosts the service is exposed on with an ingress"},
+	"Service.ssl":                   {"description": "Whether the ingress serves TLS"},
+	"Service.health_check":          {"description": "Obsolete, replaced with liveness_probe by version 2"},
+	"Service.annotations":           {"description": "Annotations of the pods"},
+	"Service.ingress_annotations":   {"description": "Annotations of the ingress"},
+	"Service.options":               {"description": "Options of custom resource services"},
+	"Service.spec":                  {"description": "Spec of the custom resource of api_version and kind"},
+	"Service.remove":                {"description": "Remove the service inherited from the environment extended"},
+	"Service.type":                  {"description": "Type of custom resource service, e.g. mysql, sns"},
+	"Service.api_version":           {"description": "API version of the custom resource declared by the service"},
+	"Service.kind":                  {"description": "Kind of the custom resource declared by the service"},
+	"Service.env":                   {"description": "Environment variables of the service container"},
+	"Service.deployment":            {"description": "Deployment settings of the service"},
+	"Service.hpa":                   {"description": "Horizontal pod autoscaler"},
+	"Service.keda":                  {"description": "KEDA event driven autoscaling, in place of hpa"},
+	"Service.workload":              {"description": "Kubernetes workload of the service"},
+	"Service.deletion_protection":   {"description": "Keep the custom resource when the service is removed"},
+	"Service.service_mesh":          {"description": "Whether the service is exposed via Istio"},
+	"Service.traffic_policy":        {"description": "Istio traffic policy of service mesh enabled services"},
+	"Service.allow_from":            {"description": "Peers allowed to connect to the service"},
+	"Service.allow_to":              {"description": "Peers the service is allowed to connect to"},
+	"Service.external_routes":       {"description": "Path based routes of hosts to services"},
+	"Service.ingress_class":         {"description": "Ingress class of the service ingress"},
+	"Service.ingress_profile":       {"description": "Ingress controller the ingress settings are translated for"},
+	"Service.ingress_timeout":       {"description": "Ingress read timeout in seconds"},
+	"Service.ingress_max_body_size": {"description": "Maximum request body size, e.g. 8m"},
+	"Service.ingress_whitelist":     {"description": "CIDRs allowed to reach the ingress"},
+	"Service.service_type":          {"description": "Type of the kubernetes service, ClusterIP if not set"},
+
+	"ServiceType.external_name":               {"description": "Host the ExternalName service is an alias of"},
+	"ServiceType.load_balancer_source_ranges": {"description": "CIDRs allowed to reach the LoadBalancer service"},
+	"ServiceType.external_traffic_policy":     {"description": "Whether external traffic is routed to node-local pods only; Cluster if not set"},
+	"ServiceType.annotations":                 {"description": "Annotations of the kubernetes service, e.g. cloud load balancer settings"},
+
+	"ServicePort.name":           {"description": "Port name, prefixed with app_protocol; <app_protocol>-<port> if not set"},
+	"ServicePort.port":           {"description": "Service port", "minimum": 1, "maximum": 65535},
+	"ServicePort.container_port": {"description": "Container port traffic is sent to; port if not set", "minimum": 1, "maximum": 65535},
+	"ServicePort.protocol":       {"description": "Transport protocol, TCP if not set", "enum": []string{ProtocolTCP, ProtocolUDP, ProtocolGRPC, "tcp", "udp", "grpc"}},
+	"ServicePort.app_protocol":   {"description": "Application protocol, e.g. http, http2, grpc, tcp; http if not set"},
+
+	"HorizontalPodAutoscaler.metric":   {"description": "Single metric to scale on; cpu utilization of 80% if no metric is set"},
+	"HorizontalPodAutoscaler.metrics":  {"description": "Metrics to scale on, the one proposing most replicas wins"},
+	"HorizontalPodAutoscaler.behavior": {"description": "Scale up and scale down policies and stabilization windows"},
+
+	"Metric.type":                       {"description": "Metric type; resource for cpu and memory, pods otherwise, if not set"},
+	"Metric.selector":                   {"description": "Labels selecting the series of pods, object and external metrics"},
+	"Metric.object":                     {"description": "Kubernetes object described by an object metric"},
+	"Metric.target_value":               {"description": "Target value of object and external metrics"},
+	"Metric.target_average_value":       {"description": "Target value per pod"},
+	"Metric.target_average_utilization": {"description": "Target utilization of resource metrics in percent of requests", "minimum": 75, "maximum": 100},
+
+	"ScalingRules.stabilization_window_seconds": {"description": "Seconds of past recommendations considered before scaling", "minimum": 0, "maximum": 3600},
+	"ScalingRules.select_policy":                {"description": "Policy used when several apply; Max if not set"},
+	"ScalingPolicy.value":                       {"description": "Number of pods or percent of pods", "minimum": 1},
+	"ScalingPolicy.period_seconds":              {"description": "Window the policy applies to", "minimum": 1, "maximum": 1800},
+
+	"KedaScaling.min_replicas":       {"description": "Minimum number of replicas, 0 scales the service to zero when idle", "minimum": 0},
+	"KedaScaling.max_replicas":       {"description": "Maximum number of replicas", "minimum": 1},
+	"KedaScaling.polling_interval":   {"description": "Seconds between trigger checks; 30 if not set", "minimum": 1},
+	"KedaScaling.cooldown_period":    {"description": "Seconds after the last active trigger before scaling to min_replicas; 300 if not set", "minimum": 1},
+	"KedaScaling.triggers":           {"description": "Event sources the service is scaled on", "minItems": 1},
+	"KedaTrigger.type":               {"description": "KEDA scaler type, e.g. aws-sqs-queue or kafka"},
+	"KedaTrigger.metadata":           {"description": "Scaler settings, e.g. queueURL and queueLength of aws-sqs-queue"},
+	"KedaTrigger.authentication_ref": {"description": "TriggerAuthentication providing scaler credentials"},
+
+	"DeploymentSettings.method": {"description": "Deployment method"},
+	"DeploymentSettings.mode":   {"description": "Whether deployments are triggered automatically or via /deploy"},
+	"DeploymentSettings.active": {"description": "Active service set of bluegreen deployments"},
+
+	"EnvVar.value_from":       {"description": "Output of another service in the environment"},
+	"Gist.type":               {"enum": []string{TypeConfigMap, TypeJob, TypeCronJob, TypeSecret}},
+	"Gist.remove":             {"description": "Remove the gist inherited from the environment extended"},
+	"ExternalRoute.path_type": {"enum": []string{PathTypePrefix, PathTypeExact}},
+}
+
+// Schema returns JSON Schema of environments.bitesize, generated from the
+// types and validators in this package
+func Schema() map[string]interface{} {
+	g := &schemaGenerator{definitions: map[string]interface{}{}}
+	root := g.typeSchema(reflect.TypeOf(EnvironmentsBitesize{}))
+
+	return map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         "environments.bitesize-" + version.Version + ".schema.json",
+		"title":       "environments.bitesize",
+		"description": "environment-operator " + version.Version + " manifest",
+		"$ref":        root["$ref"],
+		"definitions": g.definitions,
+	}
+}
+
+// SchemaJSON returns Schema as indented JSON
+func SchemaJSON() ([]byte, error) {
+	return json.MarshalIndent(Schema(), "", "  ")
+}
+
+type schemaGenerator struct {
+	definitions map[string]interface{}
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	if schema, ok := schemaTypes[t]; ok {
+		return schema()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.typeSchema(t.Elem())
+	case reflect.Struct:
+		if _, ok := g.definitions[t.Name()]; !ok {
+			g.definitions[t.Name()] = nil // placeholder for recursive types
+			g.definitions[t.Name()] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.String:
+		return schemaScalar()
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		name, inline := schemaFieldName(field)
+		if inline {
+			embedded := g.structSchema(field.Type)
+			for k, v := range embedded["properties"].(map[string]interface{}) {
+				properties[k] = v
+			}
+			continue
+		}
+		if name == "" || schemaSkipped[t.Name()+"."+name] {
+			continue
+		}
+
+		schema := g.typeSchema(field.Type)
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			switch {
+			case rule == "nonzero":
+				required = append(required, name)
+			case strings.HasPrefix(rule, "regexp="):
+				expr := strings.TrimPrefix(rule, "regexp=")
+				if m := schemaEnum.FindStringSubmatch(expr); m != nil {
+					schema["enum"] = schemaEnumValues(strings.Split(m[1], "|"))
+				} else {
+					schema["pattern"] = expr
+				}
+			case strings.HasPrefix(rule, "min="):
+				schema["minimum"], _ = strconv.Atoi(strings.TrimPrefix(rule, "min="))
+			case strings.HasPrefix(rule, "max="):
+				schema["maximum"], _ = strconv.Atoi(strings.TrimPrefix(rule, "max="))
+			}
+		}
+		properties[name] = schema
+	}
+
+	for name, schema := range schemaExtraProperties[t.Name()] {
+		properties[name] = schema()
+	}
+	for name, schema := range properties {
+		overrides, ok := schemaOverrides[t.Name()+"."+name]
+		if !ok {
+			continue
+		}
+		// keywords next to $ref are ignored, so references are wrapped
+		if ref, ok := schema.(map[string]interface{})["$ref"]; ok {
+			schema = map[string]interface{}{"allOf": []interface{}{map[string]interface{}{"$ref": ref}}}
+			properties[name] = schema
+		}
+		for k, v := range overrides {
+			schema.(map[string]interface{})[k] = v
+		}
+	}
+
+	retval := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		retval["required"] = required
+	}
+	return retval
+}
+
+// schemaFieldName returns YAML key of struct field as yaml.v2 reads it, or
+// whether the field is inlined. Fields not read from YAML have no name.
+func schemaFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("yaml")
+	if tag == "-" {
+		return "", false
+	}
+	parts := strings.Split(tag, ",")
+	for _, flag := range parts[1:] {
+		if flag == "inline" {
+			return "", true
+		}
+	}
+	if parts[0] != "" {
+		return parts[0], false
+	}
+	return strings.ToLower(field.Name), false
+}
+
+// schemaEnumValues returns enum values; "true" and "false" are accepted as
+// YAML booleans too
+func schemaEnumValues(values []string) []interface{} {
+	var retval []interface{}
+	for _, v := range values {
+		retval = append(retval, v)
+		if b, err := strconv.ParseBool(v); err == nil && (v == "true" || v == "false") {
+			retval = append(retval, b)
+		}
+	}
+	return retval
+}
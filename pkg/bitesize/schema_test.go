@@ -0,0 +1,187 @@
+package bitesize
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// schemaErrors checks value against the subset of JSON Schema keywords
+// generated by Schema
+func schemaErrors(defs map[string]interface{}, schema map[string]interface{}, value interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		return schemaErrors(defs, defs[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{}), value, path)
+	}
+	var errs []string
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range all {
+			errs = append(errs, schemaErrors(defs, s.(map[string]interface{}), value, path)...)
+		}
+	}
+	if t, ok := schema["type"]; ok && !schemaTypeMatches(t, value) {
+		return append(errs, fmt.Sprintf("%s: unexpected type %T", path, value))
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, v := range enum {
+			found = found || v == value
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: %v not in %v", path, value, enum))
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range schemaStrings(schema["required"]) {
+			if _, ok := value[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: %s is required", path, name))
+			}
+		}
+		for k, v := range value {
+			if s, ok := properties[k].(map[string]interface{}); ok {
+				errs = append(errs, schemaErrors(defs, s, v, path+"."+k)...)
+			} else if s, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				errs = append(errs, schemaErrors(defs, s, v, path+"."+k)...)
+			} else if schema["additionalProperties"] == false {
+				errs = append(errs, fmt.Sprintf("%s: unknown property %s", path, k))
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, v := range value {
+				errs = append(errs, schemaErrors(defs, items, v, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+func schemaTypeMatches(t interface{}, value interface{}) bool {
+	for _, name := range schemaStrings(t) {
+		switch v := value.(type) {
+		case nil:
+			return true
+		case string:
+			if name == "string" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case float64:
+			if name == "number" || (name == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case map[string]interface{}:
+			if name == "object" {
+				return true
+			}
+		case []interface{}:
+			if name == "array" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func schemaStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var retval []string
+		for _, s := range v {
+			retval = append(retval, s.(string))
+		}
+		return retval
+	}
+	return nil
+}
+
+// validateAgainstSchema returns schema errors of environments.bitesize
+// contents
+func validateAgainstSchema(t *testing.T, contents []byte) []string {
+	var schema map[string]interface{}
+	byt, err := SchemaJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(byt, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var raw interface{}
+	if err = yaml.Unmarshal(contents, &raw); err != nil {
+		t.Fatal(err)
+	}
+	// values are compared as decoded from JSON
+	byt, _ = json.Marshal(jsonValue(raw))
+	var value interface{}
+	json.Unmarshal(byt, &value)
+
+	return schemaErrors(schema["definitions"].(map[string]interface{}), schema, value, "")
+}
+
+func TestSchemaAcceptsAssets(t *testing.T) {
+	var tests = []struct {
+		Path     string
+		Expected []string
+	}{
+		// keys ignored by the parser
+		{"../../test/assets/environments.bitesize", []string{
+			".environments[0].services[0]: unknown property namespace",
+			".environments[15].services[0]: unknown property allow_destroy",
+			".environments[15].services[0]: unknown property allow_modify",
+			".environments[2].services[0]: unknown property namespace",
+			".environments[3].services[0]: unknown property namespace",
+		}},
+		{"../../test/assets/environments2.bitesize", nil},
+		{"../../test/assets/environments3.bitesize", []string{
+			".environments[0].gists[2]: unknown property Files",
+		}},
+		{"../../test/assets/include/environments.bitesize", nil},
+	}
+
+	for _, tst := range tests {
+		contents, err := ioutil.ReadFile(tst.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errs := validateAgainstSchema(t, contents); strings.Join(errs, "\n") != strings.Join(tst.Expected, "\n") {
+			t.Errorf("Unexpected schema errors for %s:\n%s", tst.Path, strings.Join(errs, "\n"))
+		}
+	}
+}
+
+func TestSchemaRejectsInvalid(t *testing.T) {
+	var tests = []struct {
+		Service string
+		Error   string
+	}{
+		{"{name: api, ssl: maybe}", ".environments[0].services[0].ssl: maybe not in"},
+		{"{name: api, deployment: {method: recreate}}", ".environments[0].services[0].deployment.method: recreate not in"},
+		{"{name: api, deployment: {active: red}}", ".environments[0].services[0].deployment.active: red not in"},
+		{"{name: api, replicas: two}", ".environments[0].services[0].replicas: unexpected type string"},
+		{"{name: api, replicass: 2}", ".environments[0].services[0]: unknown property replicass"},
+		{"{port: 80}", ".environments[0].services[0]: name is required"},
+		{"{name: api, annotations: [{value: x}]}", ".environments[0].services[0].annotations[0]: name is required"},
+	}
+
+	for _, tst := range tests {
+		errs := validateAgainstSchema(t, []byte("project: test\nenvironments:\n  - name: dev\n    services:\n      - "+tst.Service))
+		if len(errs) != 1 || !strings.HasPrefix(errs[0], tst.Error) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Service, errs)
+		}
+	}
+}
@@ -3,65 +3,81 @@ package bitesize
 import (
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/pearsontechnology/environment-operator/pkg/config"
-	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
 	validator "gopkg.in/validator.v2"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // Service represents a single service and it's configuration,
 // running in environment
 type Service struct {
-	Name              string                        `yaml:"name" validate:"nonzero"`
-	ExternalURL       []string                      `yaml:"-"`
-	ServiceMesh       string                        `yaml:"service_mesh,omitempty" validate:"regexp=^(enable|disable)*$"`
-	Backend           string                        `yaml:"backend"`
-	BackendPort       int                           `yaml:"backend_port"`
-	Ports             []int                         `yaml:"-"` // Ports have custom unmarshaler
-	Ssl               string                        `yaml:"ssl" validate:"regexp=^(true|false)*$"`
-	Version           string                        `yaml:"version,omitempty"`
-	Application       string                        `yaml:"application,omitempty"`
-	Replicas          int                           `yaml:"replicas,omitempty"`
-	Deployment        *DeploymentSettings           `yaml:"deployment,omitempty"`
-	HPA               HorizontalPodAutoscaler       `yaml:"hpa" validate:"hpa"`
-	Requests          ContainerRequests             `yaml:"requests" validate:"requests"`
-	Limits            ContainerLimits               `yaml:"limits" validate:"limits"`
-	HealthCheck       *HealthCheck                  `yaml:"health_check,omitempty"`
-	LivenessProbe     *Probe                        `yaml:"liveness_probe,omitempty"`
-	ReadinessProbe    *Probe                        `yaml:"readiness_probe,omitempty"`
-	EnvVars           []EnvVar                      `yaml:"env,omitempty"`
-	Commands          []string                      `yaml:"command,omitempty"`
-	InitContainers    *[]Container                  `yaml:"init_containers,omitempty"`
-	Annotations       map[string]string             `yaml:"-"` // Annotations have custom unmarshaler
-	Volumes           []Volume                      `yaml:"volumes,omitempty"`
-	Options           map[string]interface{}        `yaml:"-"` // Options have custom unmarshaler
-	HTTP2             string                        `yaml:"http2,omitempty" validate:"regexp=^(true|false)*$"`
-	HTTPSOnly         string                        `yaml:"httpsOnly" validate:"regexp=^(true|false)*$"`
-	HTTPSBackend      string                        `yaml:"httpsBackend,omitempty" validate:"regexp=^(true|false)*$"`
-	Type              string                        `yaml:"type,omitempty"`
-	Status            ServiceStatus                 `yaml:"status"`
-	DatabaseType      string                        `yaml:"database_type,omitempty" validate:"regexp=^(mongo)*$"`
-	GracePeriod       *int64                        `yaml:"graceperiod,omitempty"`
-	ResourceVersion   string                        `yaml:"resourceVersion,omitempty"`
-	TargetNamespace   string                        `yaml:"target_namespace,omitempty"`
-	Chart             string                        `yaml:"chart,omitempty"`
-	Repo              string                        `yaml:"repo,omitempty"`
-	Set               map[string]intstr.IntOrString `yaml:"set,omitempty"`
-	ValuesContent     string                        `yaml:"values_content,omitempty"`
-	Ignore            bool                          `yaml:"ignore,omitempty"`
-	Hosts             []string                      `yaml:"hosts,omitempty"`
-	Addresses         []string                      `yaml:"addresses,omitempty"`
-	ServiceEntryPorts []Port                        `yaml:"service_entry_ports,omitempty"`
-	Location          string                        `yaml:"location,omitempty"`
-	Resolution        string                        `yaml:"resolution,omitempty"`
-	Endpoints         []ServiceEntry_Endpoint       `yaml:"endpoints,omitempty"`
-	ExportTo          []string                      `yaml:"export_to,omitempty"`
-	Protocol          string                        `yaml:"protocol,omitempty"`
+	Name               string                        `yaml:"name" validate:"nonzero"`
+	ExternalURL        []string                      `yaml:"-"`
+	ServiceMesh        string                        `yaml:"service_mesh,omitempty" validate:"regexp=^(enable|disable)*$"`
+	Backend            string                        `yaml:"backend"`
+	BackendPort        int                           `yaml:"backend_port"`
+	Ports              ServicePorts                  `yaml:"-"` // Ports have custom unmarshaler
+	Ssl                string                        `yaml:"ssl" validate:"regexp=^(true|false)*$"`
+	Version            string                        `yaml:"version,omitempty"`
+	Application        string                        `yaml:"application,omitempty"`
+	Replicas           int                           `yaml:"replicas,omitempty"`
+	Deployment         *DeploymentSettings           `yaml:"deployment,omitempty"`
+	HPA                HorizontalPodAutoscaler       `yaml:"hpa" validate:"hpa"`
+	Keda               *KedaScaling                  `yaml:"keda,omitempty"`
+	Requests           ContainerRequests             `yaml:"requests" validate:"requests"`
+	Limits             ContainerLimits               `yaml:"limits" validate:"limits"`
+	HealthCheck        *HealthCheck                  `yaml:"health_check,omitempty"`
+	LivenessProbe      *Probe                        `yaml:"liveness_probe,omitempty"`
+	ReadinessProbe     *Probe                        `yaml:"readiness_probe,omitempty"`
+	EnvVars            []EnvVar                      `yaml:"env,omitempty"`
+	Commands           []string                      `yaml:"command,omitempty"`
+	InitContainers     *[]Container                  `yaml:"init_containers,omitempty"`
+	Annotations        map[string]string             `yaml:"-"` // Annotations have custom unmarshaler
+	Volumes            []Volume                      `yaml:"volumes,omitempty"`
+	Options            map[string]interface{}        `yaml:"-"` // Options have custom unmarshaler
+	HTTP2              string                        `yaml:"http2,omitempty" validate:"regexp=^(true|false)*$"`
+	HTTPSOnly          string                        `yaml:"httpsOnly" validate:"regexp=^(true|false)*$"`
+	HTTPSBackend       string                        `yaml:"httpsBackend,omitempty" validate:"regexp=^(true|false)*$"`
+	Type               string                        `yaml:"type,omitempty"`
+	Status             ServiceStatus                 `yaml:"status"`
+	DatabaseType       string                        `yaml:"database_type,omitempty" validate:"regexp=^(mongo)*$"`
+	GracePeriod        *int64                        `yaml:"graceperiod,omitempty"`
+	ResourceVersion    string                        `yaml:"resourceVersion,omitempty"`
+	TargetNamespace    string                        `yaml:"target_namespace,omitempty"`
+	Chart              string                        `yaml:"chart,omitempty"`
+	Repo               string                        `yaml:"repo,omitempty"`
+	Set                map[string]intstr.IntOrString `yaml:"set,omitempty"`
+	ValuesContent      string                        `yaml:"values_content,omitempty"`
+	Ignore             bool                          `yaml:"ignore,omitempty"`
+	Hosts              []string                      `yaml:"hosts,omitempty"`
+	Addresses          []string                      `yaml:"addresses,omitempty"`
+	ServiceEntryPorts  []Port                        `yaml:"service_entry_ports,omitempty"`
+	Location           string                        `yaml:"location,omitempty"`
+	Resolution         string                        `yaml:"resolution,omitempty"`
+	Endpoints          []ServiceEntry_Endpoint       `yaml:"endpoints,omitempty"`
+	ExportTo           []string                      `yaml:"export_to,omitempty"`
+	Protocol           string                        `yaml:"protocol,omitempty"`
+	AllowFrom          []NetworkPeer                 `yaml:"allow_from,omitempty" validate:"network_peers"`
+	AllowTo            []NetworkPeer                 `yaml:"allow_to,omitempty" validate:"network_peers"`
+	Workload           string                        `yaml:"workload,omitempty" validate:"regexp=^(deployment|statefulset)*$"`
+	IngressClass       string                        `yaml:"ingress_class,omitempty"`
+	ExternalRoutes     []ExternalRoute               `yaml:"external_routes,omitempty" validate:"external_routes"`
+	IngressAnnotations map[string]string             `yaml:"-"` // IngressAnnotations have custom unmarshaler
+	IngressProfile     string                        `yaml:"ingress_profile,omitempty" validate:"regexp=^(nginx|traefik|alb)*$"`
+	IngressTimeout     int                           `yaml:"ingress_timeout,omitempty" validate:"min=0"`
+	IngressMaxBodySize string                        `yaml:"ingress_max_body_size,omitempty" validate:"regexp=^([0-9]+[kKmMgG]?)*$"`
+	IngressWhitelist   []string                      `yaml:"ingress_whitelist,omitempty" validate:"cidrs"`
+	TrafficPolicy      *TrafficPolicy                `yaml:"traffic_policy,omitempty"`
+	ServiceType        *ServiceType                  `yaml:"service_type,omitempty"`
+	APIVersion         string                        `yaml:"api_version,omitempty"`
+	Kind               string                        `yaml:"kind,omitempty"`
+	Spec               map[string]interface{}        `yaml:"-"` // Spec has custom unmarshaler
+	DeletionProtection *bool                         `yaml:"deletion_protection,omitempty"`
+	TLS                *TLSSettings                  `yaml:"-"` // TLS is set from environment tls settings
+	TLSProviders       []string                      `yaml:"-"` // TLSProviders lists providers of TLS resources found in the cluster
+	ConfigHash         string                        `yaml:"-"` // ConfigHash is set by the cluster from referenced configmaps and secrets
 }
 
 // ServiceStatus represents cluster service's status metrics
@@ -96,7 +112,7 @@ type Services []Service
 // default values should match those in bitesize.AddDeployment
 func ServiceWithDefaults() *Service {
 	return &Service{
-		Ports:    []int{80},
+		Ports:    ServicePorts{{Port: 80}},
 		Replicas: 1,
 		Limits: ContainerLimits{
 			Memory: config.Env.LimitDefaultMemory,
@@ -119,7 +135,7 @@ func (e *Service) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 	ports, err := unmarshalPorts(unmarshal)
 	if err != nil {
-		return fmt.Errorf("service.ports.%s", err.Error())
+		return fmt.Errorf("service.%s", err.Error())
 	}
 
 	annotations, err := unmarshalAnnotations(unmarshal)
@@ -127,6 +143,11 @@ func (e *Service) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return fmt.Errorf("service.annotations.%s", err.Error())
 	}
 
+	ingressAnnotations, err := unmarshalIngressAnnotations(unmarshal)
+	if err != nil {
+		return fmt.Errorf("service.ingress_annotations.%s", err.Error())
+	}
+
 	externalURL, err := unmarshalExternalURL(unmarshal)
 	if err != nil {
 		return fmt.Errorf("service.external_url.%s", err.Error())
@@ -137,6 +158,11 @@ func (e *Service) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return fmt.Errorf("service.options.%s", err.Error())
 	}
 
+	spec, err := unmarshalSpec(unmarshal)
+	if err != nil {
+		return fmt.Errorf("service.spec.%s", err.Error())
+	}
+
 	type plain Service
 	if err = unmarshal((*plain)(ee)); err != nil {
 		return fmt.Errorf("service.%s", err.Error())
@@ -145,11 +171,20 @@ func (e *Service) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	*e = *ee
 	e.Ports = ports
 	e.Annotations = annotations
+	e.IngressAnnotations = ingressAnnotations
 	e.ExternalURL = externalURL
 	e.Options = unmarshalOptions
+	e.Spec = spec
+	if err = e.validateCustomResource(); err != nil {
+		return err
+	}
 	if e.Type != "" {
 		e.Ports = nil
 	}
+	if err = e.setPortDefaults(); err != nil {
+		return fmt.Errorf("service.%s", err.Error())
+	}
+	e.setServiceTypeDefaults()
 	// annotation := Annotation{Name: "Name", Value: e.Name}
 	// e.Annotations = append(e.Annotations, annotation)
 
@@ -157,20 +192,47 @@ func (e *Service) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		e.Replicas = int(e.HPA.MinReplicas)
 	}
 
-	if e.HPA.MinReplicas != 0 && e.HPA.Metric.Name == "" {
-		e.HPA.Metric = Metric{Name: "cpu", TargetAverageUtilization: int32(80)}
+	if err = e.setHPADefaults(); err != nil {
+		return fmt.Errorf("service.%s", err.Error())
 	}
+	e.setKedaDefaults()
 
 	if err = validator.Validate(e); err != nil {
 		return fmt.Errorf("service.%s", err.Error())
 	}
 
+	// deployment is the default workload, scraped services leave it empty
+	if e.Workload == WorkloadDeployment {
+		e.Workload = ""
+	}
+
+	if e.HasExternalRoutes() {
+		if e.Type != "" {
+			return fmt.Errorf("service.external_routes: not supported for %s service %s", e.Type, e.Name)
+		}
+		if e.IsBlueGreenParentDeployment() {
+			return fmt.Errorf("service.external_routes: bluegreen deployment method is not supported for %s", e.Name)
+		}
+		if err = e.setExternalRouteDefaults(); err != nil {
+			return fmt.Errorf("service.external_routes: %s", err.Error())
+		}
+	}
+
+	if e.IngressClass != "" && !e.HasExternalURL() {
+		return fmt.Errorf("service.ingress_class: requires external_url for %s", e.Name)
+	}
+
+	if e.IsStatefulSet() && e.IsBlueGreenParentDeployment() {
+		return fmt.Errorf("service.workload: bluegreen deployment method is not supported for statefulset %s", e.Name)
+	}
+
 	return nil
 }
 
-// HasExternalURL checks if the service has an external_url defined
+// HasExternalURL checks if the service has an external_url or
+// external_routes defined
 func (e Service) HasExternalURL() bool {
-	return len(e.ExternalURL) != 0
+	return len(e.ExternalURL) != 0 || e.HasExternalRoutes()
 }
 
 // IsServiceMeshEnabled checks if the service_mesh is enabled
@@ -254,29 +316,6 @@ func (e Service) IsTLSEnabled() bool {
 	return e.Ssl == "true" && e.HasExternalURL()
 }
 
-func (e Service) ExternalSecretExist(namespace, name string) bool {
-
-	var err error
-	client := k8s.Client{}
-
-	client.Namespace = namespace
-	client.CRDClient, err = k8s.CRDClient(&schema.GroupVersion{
-		Group:   "kubernetes-client.io",
-		Version: "v1",
-	})
-
-	if err != nil {
-		log.Errorf("Error creating kubernetes client for External Secrets use: %s", err.Error())
-		return false
-	}
-
-	if client.ExternalSecret().Exist(name) {
-		return true
-	}
-
-	return false
-}
-
 func (slice Services) Len() int {
 	return len(slice)
 }
@@ -319,6 +358,29 @@ func unmarshalAnnotations(unmarshal func(interface{}) error) (map[string]string,
 	return annotations, nil
 }
 
+func unmarshalIngressAnnotations(unmarshal func(interface{}) error) (map[string]string, error) {
+	// ingress_annotations use the same representation as annotations
+	var bz struct {
+		Annotations []struct {
+			Name  string
+			Value string
+		} `yaml:"ingress_annotations,omitempty"`
+	}
+
+	if err := unmarshal(&bz); err != nil {
+		return nil, err
+	}
+	if len(bz.Annotations) == 0 {
+		return nil, nil
+	}
+
+	annotations := map[string]string{}
+	for _, ann := range bz.Annotations {
+		annotations[ann.Name] = ann.Value
+	}
+	return annotations, nil
+}
+
 func cleanupInterfaceArray(in []interface{}) []interface{} {
 	res := make([]interface{}, len(in))
 	for i, v := range in {
@@ -370,41 +432,6 @@ func unmarshalOptions(unmarshal func(interface{}) error) (map[string]interface{}
 	return options, nil
 }
 
-func unmarshalPorts(unmarshal func(interface{}) error) ([]int, error) {
-	var portYAML struct {
-		Port  string `yaml:"port,omitempty"`
-		Ports string `yaml:"ports,omitempty"`
-	}
-
-	var ports []int
-
-	if err := unmarshal(&portYAML); err != nil {
-		return ports, err
-	}
-
-	if portYAML.Ports != "" {
-		ports = stringToIntArray(portYAML.Ports)
-	} else if portYAML.Port != "" {
-		ports = stringToIntArray(portYAML.Port)
-	} else {
-		ports = []int{80}
-	}
-	return ports, nil
-}
-
-func stringToIntArray(str string) []int {
-	var retval []int
-
-	pstr := strings.Split(str, ",")
-	for _, p := range pstr {
-		j, err := strconv.Atoi(p)
-		if err == nil {
-			retval = append(retval, j)
-		}
-	}
-	return retval
-}
-
 func unmarshalExternalURL(unmarshal func(interface{}) error) ([]string, error) {
 
 	var u struct {
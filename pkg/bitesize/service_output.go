@@ -0,0 +1,129 @@
+package bitesize
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ServiceOutputsAnnotation keeps env vars set from service outputs on the
+// deployment, so they can be told apart from plain values and secrets
+const ServiceOutputsAnnotation = "env_value_from"
+
+// ServiceOutput represents env "value_from" block, referencing an output of
+// another service in the environment. Outputs are read from the
+// "<service>-outputs" secret key named after the field or, if there's no
+// such secret, from the field of the service custom resource status.
+type ServiceOutput struct {
+	Service string `yaml:"service" json:"service"`
+	Field   string `yaml:"field" json:"field"`
+
+	// Secret or Value is set once the output is found in the cluster
+	Secret string `yaml:"-" json:"-"`
+	Value  string `yaml:"-" json:"-"`
+}
+
+// Resolved returns true if output was found in the cluster
+func (o *ServiceOutput) Resolved() bool {
+	return o.Secret != "" || o.Value != ""
+}
+
+// OutputsSecretName returns name of the secret holding service outputs
+func OutputsSecretName(service string) string {
+	return service + "-outputs"
+}
+
+// UnresolvedOutputs returns service outputs env vars reference, that are
+// not yet available in the cluster
+func (e Service) UnresolvedOutputs() []string {
+	var retval []string
+	for _, env := range e.EnvVars {
+		if env.ValueFrom != nil && !env.ValueFrom.Resolved() {
+			retval = append(retval, fmt.Sprintf("%s.%s", env.ValueFrom.Service, env.ValueFrom.Field))
+		}
+	}
+	return retval
+}
+
+// ServiceOutputsAnnotationValue returns outputs referenced by env vars,
+// serialized for ServiceOutputsAnnotation, or empty string if there are none
+func ServiceOutputsAnnotationValue(envVars []EnvVar) string {
+	outputs := map[string]ServiceOutput{}
+	for _, env := range envVars {
+		if env.ValueFrom != nil {
+			outputs[env.Name] = *env.ValueFrom
+		}
+	}
+	if len(outputs) == 0 {
+		return ""
+	}
+	byt, err := json.Marshal(outputs)
+	if err != nil {
+		return ""
+	}
+	return string(byt)
+}
+
+// EnvVarsWithOutputs restores env vars set from service outputs, as listed
+// in ServiceOutputsAnnotation
+func EnvVarsWithOutputs(envVars []EnvVar, annotation string) []EnvVar {
+	outputs := map[string]ServiceOutput{}
+	if annotation == "" || json.Unmarshal([]byte(annotation), &outputs) != nil {
+		return envVars
+	}
+
+	for i, env := range envVars {
+		name := env.Name
+		if env.Secret != "" {
+			name = env.Secret
+		}
+		output, ok := outputs[name]
+		if !ok {
+			continue
+		}
+		if env.Secret != "" {
+			output.Secret = strings.Split(env.Value, "/")[0]
+		} else {
+			output.Value = env.Value
+		}
+		envVars[i] = EnvVar{Name: name, ValueFrom: &output}
+	}
+	return envVars
+}
+
+// validateServiceOutputs returns an error if env value_from references
+// service not in the environment, or a service without custom resource
+func validateServiceOutputs(services Services) error {
+	for _, svc := range services {
+		if svc.InitContainers != nil {
+			for _, c := range *svc.InitContainers {
+				for _, env := range c.EnvVars {
+					if env.ValueFrom != nil {
+						return fmt.Errorf("%s: env.value_from is not supported in init_containers", svc.Name)
+					}
+				}
+			}
+		}
+
+		for _, env := range svc.EnvVars {
+			output := env.ValueFrom
+			if output == nil {
+				continue
+			}
+			if env.Name == "" || env.Value != "" || env.Secret != "" || env.PodField != "" {
+				return fmt.Errorf("%s: env.value_from requires name and can't be combined with value, secret or pod_field", svc.Name)
+			}
+			if output.Service == "" || output.Field == "" {
+				return fmt.Errorf("%s: env.value_from requires service and field", svc.Name)
+			}
+			ref := services.FindByName(output.Service)
+			if ref == nil {
+				return fmt.Errorf("%s: env.value_from: service %s not found", svc.Name, output.Service)
+			}
+			if ref.Type == "" {
+				return fmt.Errorf("%s: env.value_from: service %s has no outputs, only services with type or kind do", svc.Name, output.Service)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package bitesize
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Protocols of service ports
+const (
+	ProtocolTCP  = "TCP"
+	ProtocolUDP  = "UDP"
+	ProtocolGRPC = "GRPC"
+)
+
+var (
+	portName        = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	portAppProtocol = regexp.MustCompile(`^[a-z0-9]+$`)
+)
+
+// ServicePort is a port exposed by the service's kubernetes service and
+// container. Name is prefixed with AppProtocol, as Istio selects the
+// protocol of a port by its name.
+type ServicePort struct {
+	Name          string `yaml:"name,omitempty"`
+	Port          int    `yaml:"port" validate:"nonzero"`
+	ContainerPort int    `yaml:"container_port,omitempty"`
+	Protocol      string `yaml:"protocol,omitempty"`
+	AppProtocol   string `yaml:"app_protocol,omitempty"`
+}
+
+// ServicePorts are ports of a service, in the order of declaration
+type ServicePorts []ServicePort
+
+// Numbers returns service port numbers
+func (ports ServicePorts) Numbers() []int {
+	var retval []int
+	for _, p := range ports {
+		retval = append(retval, p.Port)
+	}
+	return retval
+}
+
+// KubernetesProtocol returns transport protocol of the port in kubernetes
+// services and containers. gRPC is served over TCP.
+func (p ServicePort) KubernetesProtocol() v1.Protocol {
+	if p.Protocol == ProtocolUDP {
+		return v1.ProtocolUDP
+	}
+	return v1.ProtocolTCP
+}
+
+// portProtocol returns protocol of a port from its transport protocol and
+// app protocol, as kubernetes services keep only these: gRPC ports are TCP
+// ports with the grpc app protocol
+func portProtocol(protocol, appProtocol string) string {
+	switch {
+	case protocol == ProtocolUDP:
+		return ProtocolUDP
+	case appProtocol == "grpc":
+		return ProtocolGRPC
+	default:
+		return ProtocolTCP
+	}
+}
+
+// IsHTTP returns true if the port serves HTTP based protocols
+func (p ServicePort) IsHTTP() bool {
+	switch p.AppProtocol {
+	case "http", "http2", "https", "grpc":
+		return true
+	}
+	return false
+}
+
+// HTTPPort returns the port ingresses and Istio routes send traffic to:
+// the first port serving HTTP, or the first port if none does
+func (e Service) HTTPPort() int {
+	for _, p := range e.Ports {
+		if p.IsHTTP() {
+			return p.Port
+		}
+	}
+	if len(e.Ports) > 0 {
+		return e.Ports[0].Port
+	}
+	return 0
+}
+
+// NewServicePort returns port as scraped from kubernetes service port name,
+// number, target port and protocol
+func NewServicePort(name string, port, containerPort int, protocol v1.Protocol) ServicePort {
+	retval := ServicePort{Name: name, Port: port, ContainerPort: containerPort}
+	if containerPort == 0 {
+		retval.ContainerPort = port
+	}
+	retval.AppProtocol = strings.SplitN(name, "-", 2)[0]
+	retval.Protocol = portProtocol(string(protocol), retval.AppProtocol)
+	return retval
+}
+
+// unmarshalPorts reads "ports", or "port" if ports are not set. Both take a
+// port, a comma separated list of ports or a list of ports, where every
+// port is a number or a ServicePort.
+func unmarshalPorts(unmarshal func(interface{}) error) (ServicePorts, error) {
+	var portYAML struct {
+		Port  *portsValue `yaml:"port,omitempty"`
+		Ports *portsValue `yaml:"ports,omitempty"`
+	}
+	if err := unmarshal(&portYAML); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case portYAML.Ports != nil:
+		return portYAML.Ports.parse("ports")
+	case portYAML.Port != nil:
+		return portYAML.Port.parse("port")
+	default:
+		return ServicePorts{{Port: 80}}, nil
+	}
+}
+
+// portsValue is the value of port or ports. Items of lists are decoded
+// from the document, so errors refer to its lines.
+type portsValue struct {
+	value interface{}
+	items []portItem
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface for portsValue.
+func (v *portsValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&v.value); err != nil {
+		return err
+	}
+	if _, ok := v.value.([]interface{}); ok {
+		return unmarshal(&v.items)
+	}
+	return nil
+}
+
+func (v *portsValue) parse(key string) (ServicePorts, error) {
+	if v.items == nil {
+		return parsePorts(key, v.value)
+	}
+
+	var retval ServicePorts
+	for i, item := range v.items {
+		if item.err != nil {
+			return nil, fmt.Errorf("%s[%d]: %s", key, i, item.err.Error())
+		}
+		if item.port != nil {
+			retval = append(retval, *item.port)
+			continue
+		}
+		ports, err := parsePorts(fmt.Sprintf("%s[%d]", key, i), item.value)
+		if err != nil {
+			return nil, err
+		}
+		retval = append(retval, ports...)
+	}
+	return retval, nil
+}
+
+// portItem is an item of ports list, a port number or a ServicePort
+type portItem struct {
+	value interface{}
+	port  *ServicePort
+	err   error
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface for portItem.
+func (p *portItem) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&p.value); err != nil {
+		return err
+	}
+	if _, ok := p.value.(map[interface{}]interface{}); ok {
+		type plain ServicePort
+		p.port = &ServicePort{}
+		p.err = unmarshal((*plain)(p.port))
+	}
+	return nil
+}
+
+// parsePorts parses a port number or a comma separated list of ports
+func parsePorts(key string, v interface{}) (ServicePorts, error) {
+	var retval ServicePorts
+	switch v := v.(type) {
+	case int:
+		retval = append(retval, ServicePort{Port: v})
+	case string:
+		for _, s := range strings.Split(v, ",") {
+			port, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid port %q", key, strings.TrimSpace(s))
+			}
+			retval = append(retval, ServicePort{Port: port})
+		}
+	default:
+		return nil, fmt.Errorf("%s: must be a port, a comma separated list of ports or a list of ports", key)
+	}
+	return retval, nil
+}
+
+// setPortDefaults validates ports and fills in the fields omitted in the
+// manifest. Services with "protocol: tcp" keep tcp-port-<port> names.
+func (e *Service) setPortDefaults() error {
+	names := map[string]bool{}
+	numbers := map[string]bool{}
+
+	for i := range e.Ports {
+		p := &e.Ports[i]
+		path := fmt.Sprintf("ports[%d]", i)
+
+		if p.Port < 1 || p.Port > 65535 {
+			return fmt.Errorf("%s.port: must be between 1 and 65535", path)
+		}
+		if p.ContainerPort == 0 {
+			p.ContainerPort = p.Port
+		}
+		if p.ContainerPort < 1 || p.ContainerPort > 65535 {
+			return fmt.Errorf("%s.container_port: must be between 1 and 65535", path)
+		}
+
+		switch strings.ToUpper(p.Protocol) {
+		case "", ProtocolTCP:
+			p.Protocol = ProtocolTCP
+		case ProtocolUDP, ProtocolGRPC:
+			p.Protocol = strings.ToUpper(p.Protocol)
+		default:
+			return fmt.Errorf("%s.protocol: must be %s, %s or %s", path, ProtocolTCP, ProtocolUDP, ProtocolGRPC)
+		}
+
+		p.AppProtocol = strings.ToLower(p.AppProtocol)
+		switch {
+		case p.AppProtocol != "":
+		case p.Protocol == ProtocolGRPC:
+			p.AppProtocol = "grpc"
+		case p.Protocol == ProtocolUDP:
+			p.AppProtocol = "udp"
+		case strings.EqualFold(e.Protocol, "tcp"):
+			p.AppProtocol = "tcp"
+		default:
+			p.AppProtocol = "http"
+		}
+		if !portAppProtocol.MatchString(p.AppProtocol) {
+			return fmt.Errorf("%s.app_protocol: invalid app protocol %s", path, p.AppProtocol)
+		}
+		p.Protocol = portProtocol(p.Protocol, p.AppProtocol)
+
+		switch {
+		case p.Name == "" && p.AppProtocol == "tcp":
+			p.Name = fmt.Sprintf("tcp-port-%d", p.Port)
+		case p.Name == "":
+			p.Name = fmt.Sprintf("%s-%d", p.AppProtocol, p.Port)
+		case p.Name != p.AppProtocol && !strings.HasPrefix(p.Name, p.AppProtocol+"-"):
+			p.Name = p.AppProtocol + "-" + p.Name
+		}
+		if len(p.Name) > 63 || !portName.MatchString(p.Name) {
+			return fmt.Errorf("%s.name: invalid port name %s", path, p.Name)
+		}
+
+		number := fmt.Sprintf("%d/%s", p.Port, p.KubernetesProtocol())
+		if numbers[number] {
+			return fmt.Errorf("%s.port: duplicate port %s", path, number)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("%s.name: duplicate port name %s", path, p.Name)
+		}
+		numbers[number] = true
+		names[p.Name] = true
+	}
+	return nil
+}
@@ -16,6 +16,9 @@ func TestUnmarshalPorts(t *testing.T) {
 	t.Run("ports preferred over port", testPortsOverPort)
 	t.Run("ports with invalid value", testPortsWithInvalidValue)
 	t.Run("empty ports return default", testPortsEmpty)
+	t.Run("ports list with named ports", testPortsList)
+	t.Run("invalid ports list", testPortsListInvalid)
+	t.Run("scraped ports match defaults", testPortsScraped)
 }
 
 func TestFindByName(t *testing.T) {
@@ -38,7 +41,7 @@ func testPortsString(t *testing.T) {
 		t.Errorf("could not unmarshal yaml: %s", err.Error())
 	}
 
-	if !util.EqualArrays(svc.Ports, []int{81, 88, 89}) {
+	if !util.EqualArrays(svc.Ports.Numbers(), []int{81, 88, 89}) {
 		t.Errorf("Ports not equal. Expected: [81 88 89], got: %v", svc.Ports)
 	}
 
@@ -91,29 +94,109 @@ func testPortsWithInvalidValue(t *testing.T) {
 	str := `
   name: something
   ports: 81,invalid,82
+  `
+	err := yaml.Unmarshal([]byte(str), svc)
+	if err == nil || err.Error() != `service.ports: invalid port "invalid"` {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func testPortsEmpty(t *testing.T) {
+	svc := &Service{}
+	str := `
+  name: something
   `
 	if err := yaml.Unmarshal([]byte(str), svc); err != nil {
 		t.Errorf("could not unmarshal yaml: %s", err.Error())
 	}
 
-	if !util.EqualArrays(svc.Ports, []int{81, 82}) {
+	if !util.EqualArrays(svc.Ports.Numbers(), []int{80}) {
 		t.Errorf("Unexpected ports: %v", svc.Ports)
 	}
 }
+func testPortsList(t *testing.T) {
+	svc := &Service{}
+	str := `
+  name: something
+  protocol: tcp
+  ports:
+    - 8080
+    - "9090"
+    - name: api
+      port: 443
+      container_port: 8443
+      app_protocol: https
+    - name: grpc-api
+      port: 9000
+      protocol: grpc
+    - port: 53
+      protocol: udp
+  `
+	if err := yaml.Unmarshal([]byte(str), svc); err != nil {
+		t.Fatalf("could not unmarshal yaml: %s", err.Error())
+	}
 
-func testPortsEmpty(t *testing.T) {
+	expected := ServicePorts{
+		{Name: "tcp-port-8080", Port: 8080, ContainerPort: 8080, Protocol: ProtocolTCP, AppProtocol: "tcp"},
+		{Name: "tcp-port-9090", Port: 9090, ContainerPort: 9090, Protocol: ProtocolTCP, AppProtocol: "tcp"},
+		{Name: "https-api", Port: 443, ContainerPort: 8443, Protocol: ProtocolTCP, AppProtocol: "https"},
+		{Name: "grpc-api", Port: 9000, ContainerPort: 9000, Protocol: ProtocolGRPC, AppProtocol: "grpc"},
+		{Name: "udp-53", Port: 53, ContainerPort: 53, Protocol: ProtocolUDP, AppProtocol: "udp"},
+	}
+	if !reflect.DeepEqual(svc.Ports, expected) {
+		t.Errorf("Unexpected ports: %+v", svc.Ports)
+	}
+	if svc.HTTPPort() != 443 {
+		t.Errorf("Unexpected HTTP port %d", svc.HTTPPort())
+	}
+}
+
+func testPortsListInvalid(t *testing.T) {
+	var tests = []struct {
+		Ports string
+		Error string
+	}{
+		{"[{port: 80, protocol: sctp}]", "service.ports[0].protocol: must be TCP, UDP or GRPC"},
+		{"[{port: 70000}]", "service.ports[0].port: must be between 1 and 65535"},
+		{"[{name: api}]", "service.ports[0].port: must be between 1 and 65535"},
+		{"[80, {port: http}]", "service.ports[1]: yaml: unmarshal errors:\n  line 2: cannot unmarshal !!str `http` into int"},
+		{"[{name: Api, port: 80}]", "service.ports[0].name: invalid port name http-Api"},
+		{"[80, {port: 80}]", "service.ports[1].port: duplicate port 80/TCP"},
+		{"[{name: web, port: 80}, {name: web, port: 81}]", "service.ports[1].name: duplicate port name http-web"},
+		{"[80, web]", `service.ports[1]: invalid port "web"`},
+		{"{port: 80}", "service.ports: must be a port, a comma separated list of ports or a list of ports"},
+		{"[80, {port: 53, protocol: udp}]", ""},
+	}
+
+	for _, tst := range tests {
+		err := yaml.Unmarshal([]byte("name: something\nports: "+tst.Ports), &Service{})
+		if tst.Error == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %s", tst.Ports, err.Error())
+		}
+		if tst.Error != "" && (err == nil || err.Error() != tst.Error) {
+			t.Errorf("Expected error %q for %s, got %v", tst.Error, tst.Ports, err)
+		}
+	}
+}
+
+func testPortsScraped(t *testing.T) {
 	svc := &Service{}
 	str := `
   name: something
+  ports: [80, {name: api, port: 9000, container_port: 9090, protocol: grpc}, {port: 9001, protocol: grpc, app_protocol: http2}, {port: 53, protocol: udp}]
   `
 	if err := yaml.Unmarshal([]byte(str), svc); err != nil {
-		t.Errorf("could not unmarshal yaml: %s", err.Error())
+		t.Fatalf("could not unmarshal yaml: %s", err.Error())
 	}
 
-	if !util.EqualArrays(svc.Ports, []int{80}) {
-		t.Errorf("Unexpected ports: %v", svc.Ports)
+	for _, p := range svc.Ports {
+		scraped := NewServicePort(p.Name, p.Port, p.ContainerPort, p.KubernetesProtocol())
+		if scraped != p {
+			t.Errorf("Expected scraped port %+v to equal %+v", scraped, p)
+		}
 	}
 }
+
 func TestPod(t *testing.T) {
 	t.Run("test Pods exist in Service", testPodsEqual)
 }
@@ -233,3 +316,125 @@ func TestServiceSortInterface(t *testing.T) {
 		t.Errorf("Service sort invalid, got %v", s)
 	}
 }
+
+func TestWorkload(t *testing.T) {
+	t.Run("statefulset workload", testStatefulSetWorkload)
+	t.Run("invalid workload", testInvalidWorkload)
+	t.Run("statefulset with bluegreen", testStatefulSetBlueGreen)
+}
+
+func testStatefulSetWorkload(t *testing.T) {
+	svc := &Service{}
+	str := `
+  name: kafka
+  workload: statefulset
+  `
+	if err := yaml.Unmarshal([]byte(str), svc); err != nil {
+		t.Errorf("could not unmarshal yaml: %s", err.Error())
+	}
+
+	if !svc.IsStatefulSet() {
+		t.Errorf("expected service to be a statefulset, got workload %q", svc.Workload)
+	}
+}
+
+func testInvalidWorkload(t *testing.T) {
+	svc := &Service{}
+	str := `
+  name: kafka
+  workload: daemonset
+  `
+	if err := yaml.Unmarshal([]byte(str), svc); err == nil {
+		t.Error("expected error for invalid workload, got nil")
+	}
+}
+
+func testStatefulSetBlueGreen(t *testing.T) {
+	svc := &Service{}
+	str := `
+  name: kafka
+  workload: statefulset
+  deployment:
+    method: bluegreen
+    active: blue
+  `
+	if err := yaml.Unmarshal([]byte(str), svc); err == nil {
+		t.Error("expected error for bluegreen statefulset, got nil")
+	}
+}
+
+func TestIngressClassRequiresExternalURL(t *testing.T) {
+	svc := &Service{}
+	str := `
+  name: worker
+  port: 8080
+  ingress_class: nginx
+  `
+	if err := yaml.Unmarshal([]byte(str), svc); err == nil {
+		t.Error("expected error for ingress_class without external_url, got nil")
+	}
+}
+
+func TestExternalRoutes(t *testing.T) {
+	t.Run("defaults", testExternalRouteDefaults)
+	t.Run("port required for other services", testExternalRoutePortRequired)
+	t.Run("bluegreen", testExternalRoutesBlueGreen)
+}
+
+func testExternalRouteDefaults(t *testing.T) {
+	svc := &Service{}
+	str := `
+  name: front
+  port: 8080
+  external_url: www.example.com
+  external_routes:
+    - host: www.example.com
+      path: /api
+      service: api
+      port: 80
+    - host: static.example.com
+  `
+	if err := yaml.Unmarshal([]byte(str), svc); err != nil {
+		t.Fatalf("could not unmarshal yaml: %s", err.Error())
+	}
+
+	expected := []ExternalRoute{
+		{Host: "www.example.com", Path: "/", PathType: "Prefix", Service: "front", Port: 8080},
+		{Host: "www.example.com", Path: "/api", PathType: "Prefix", Service: "api", Port: 80},
+		{Host: "static.example.com", Path: "/", PathType: "Prefix", Service: "front", Port: 8080},
+	}
+	if !reflect.DeepEqual(svc.Routes(), expected) {
+		t.Errorf("Unexpected routes: %+v", svc.Routes())
+	}
+	if hosts := svc.ExternalHosts(); !reflect.DeepEqual(hosts, []string{"www.example.com", "static.example.com"}) {
+		t.Errorf("Unexpected hosts: %v", hosts)
+	}
+}
+
+func testExternalRoutePortRequired(t *testing.T) {
+	svc := &Service{}
+	str := `
+  name: front
+  external_routes:
+    - host: www.example.com
+      service: api
+  `
+	if err := yaml.Unmarshal([]byte(str), svc); err == nil {
+		t.Error("expected error for route without port, got nil")
+	}
+}
+
+func testExternalRoutesBlueGreen(t *testing.T) {
+	svc := &Service{}
+	str := `
+  name: front
+  external_routes:
+    - host: www.example.com
+  deployment:
+    method: bluegreen
+    active: blue
+  `
+	if err := yaml.Unmarshal([]byte(str), svc); err == nil {
+		t.Error("expected error for bluegreen external_routes, got nil")
+	}
+}
@@ -0,0 +1,158 @@
+package bitesize
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kubernetes service types
+const (
+	ServiceTypeClusterIP    = "ClusterIP"
+	ServiceTypeNodePort     = "NodePort"
+	ServiceTypeLoadBalancer = "LoadBalancer"
+	ServiceTypeExternalName = "ExternalName"
+)
+
+// ServiceTypeAnnotationsAnnotation lists annotations of kubernetes service
+// set by service_type, so they're read back into service_type annotations
+const ServiceTypeAnnotationsAnnotation = "service_type_annotations"
+
+// ServiceType represents "service_type" block of a service in
+// environments.bitesize: the type of its kubernetes service, ClusterIP if
+// not set
+type ServiceType struct {
+	Type                     string            `yaml:"type,omitempty" validate:"regexp=^(ClusterIP|NodePort|LoadBalancer|ExternalName)*$"`
+	ExternalName             string            `yaml:"external_name,omitempty"`
+	LoadBalancerSourceRanges []string          `yaml:"load_balancer_source_ranges,omitempty" validate:"cidrs"`
+	ExternalTrafficPolicy    string            `yaml:"external_traffic_policy,omitempty" validate:"regexp=^(Cluster|Local)*$"`
+	Annotations              map[string]string `yaml:"-"` // Annotations have custom unmarshaler
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface for ServiceType.
+// Annotations use the same representation as service annotations.
+func (t *ServiceType) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	annotations, err := unmarshalAnnotations(unmarshal)
+	if err != nil {
+		return fmt.Errorf("annotations.%s", err.Error())
+	}
+
+	type plain ServiceType
+	if err = unmarshal((*plain)(t)); err != nil {
+		return err
+	}
+	t.Annotations = nil
+	if len(annotations) > 0 {
+		t.Annotations = annotations
+	}
+	return nil
+}
+
+// KubernetesServiceType returns type of the service's kubernetes service
+func (e Service) KubernetesServiceType() string {
+	if e.ServiceType == nil || e.ServiceType.Type == "" {
+		return ServiceTypeClusterIP
+	}
+	return e.ServiceType.Type
+}
+
+// IsExternalName returns true if the service is an alias of external_name,
+// deployed as an ExternalName kubernetes service without a workload
+func (e Service) IsExternalName() bool {
+	return e.KubernetesServiceType() == ServiceTypeExternalName
+}
+
+// ServiceTypeAnnotationKeys returns the annotation recording which
+// kubernetes service annotations are set by service_type, or empty string
+// if there are none
+func ServiceTypeAnnotationKeys(t *ServiceType) string {
+	if t == nil {
+		return ""
+	}
+	var keys []string
+	for k := range t.Annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// setServiceTypeDefaults fills in service_type fields omitted in the
+// manifest. A ClusterIP service_type without settings is dropped, as it's
+// the default.
+func (e *Service) setServiceTypeDefaults() {
+	t := e.ServiceType
+	if t == nil {
+		return
+	}
+	if t.Type == "" {
+		t.Type = ServiceTypeClusterIP
+	}
+	if t.ExternalTrafficPolicy == "" && (t.Type == ServiceTypeNodePort || t.Type == ServiceTypeLoadBalancer) {
+		t.ExternalTrafficPolicy = "Cluster"
+	}
+	if t.Type == ServiceTypeClusterIP && t.ExternalName == "" && len(t.LoadBalancerSourceRanges) == 0 &&
+		t.ExternalTrafficPolicy == "" && len(t.Annotations) == 0 {
+		e.ServiceType = nil
+	}
+}
+
+// validateServiceType returns an error if service_type settings don't apply
+// to its type, or the service has settings an ExternalName alias can't have
+func validateServiceType(svc Service) error {
+	t := svc.ServiceType
+	if t == nil {
+		return nil
+	}
+	if svc.Type != "" || svc.IsCustomResource() {
+		return fmt.Errorf("%s: service_type is not supported for custom resources", svc.Name)
+	}
+	if t.ExternalName != "" && t.Type != ServiceTypeExternalName {
+		return fmt.Errorf("%s: service_type.external_name requires type %s", svc.Name, ServiceTypeExternalName)
+	}
+	if len(t.LoadBalancerSourceRanges) != 0 && t.Type != ServiceTypeLoadBalancer {
+		return fmt.Errorf("%s: service_type.load_balancer_source_ranges requires type %s", svc.Name, ServiceTypeLoadBalancer)
+	}
+	if t.ExternalTrafficPolicy != "" && t.Type != ServiceTypeNodePort && t.Type != ServiceTypeLoadBalancer {
+		return fmt.Errorf("%s: service_type.external_traffic_policy requires type %s or %s", svc.Name, ServiceTypeNodePort, ServiceTypeLoadBalancer)
+	}
+	if svc.IsStatefulSet() && t.Type != ServiceTypeClusterIP {
+		return fmt.Errorf("%s: service_type %s is not supported for statefulset, its service is headless", svc.Name, t.Type)
+	}
+	for k := range t.Annotations {
+		if k == ServiceTypeAnnotationsAnnotation || k == "deployment_method" || k == "traffic_policy" {
+			return fmt.Errorf("%s: service_type.annotations %s is set by environment operator", svc.Name, k)
+		}
+	}
+
+	if t.Type != ServiceTypeExternalName {
+		return nil
+	}
+	if t.ExternalName == "" {
+		return fmt.Errorf("%s: service_type %s requires external_name", svc.Name, ServiceTypeExternalName)
+	}
+	var invalid []string
+	if svc.HasExternalURL() {
+		invalid = append(invalid, "external_url")
+	}
+	if svc.IsServiceMeshEnabled() {
+		invalid = append(invalid, "service_mesh")
+	}
+	if svc.Application != "" || svc.Version != "" {
+		invalid = append(invalid, "application")
+	}
+	if svc.DeploymentMethod() == "bluegreen" || svc.DeploymentMethod() == "canary" {
+		invalid = append(invalid, "deployment.method "+svc.DeploymentMethod())
+	}
+	if svc.HPA.MinReplicas != 0 {
+		invalid = append(invalid, "hpa")
+	}
+	if svc.HasKeda() {
+		invalid = append(invalid, "keda")
+	}
+	if len(invalid) != 0 {
+		return fmt.Errorf("%s: %s not valid with service_type %s, which has no workload",
+			svc.Name, strings.Join(invalid, ","), ServiceTypeExternalName)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package bitesize
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Strict parsing modes, set by project-wide "strict" key
+const (
+	StrictOff  = "false"
+	StrictOn   = "true"
+	StrictWarn = "warn"
+)
+
+// checkUnknownKeys reports keys of raw environments.bitesize unknown to the
+// schema. In StrictOn mode they're returned as an error, in StrictWarn mode
+// they're logged.
+func checkUnknownKeys(mode string, raw map[interface{}]interface{}) error {
+	schema := Schema()
+	defs := schema["definitions"].(map[string]interface{})
+	unknown := unknownKeys(defs, schema, raw, "")
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	if mode == StrictWarn {
+		for _, key := range unknown {
+			log.Warnf("environments.bitesize: unknown key %s", key)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))
+}
+
+// unknownKeys returns paths of keys in value that schema doesn't declare,
+// with the closest known key suggested
+func unknownKeys(defs map[string]interface{}, schema map[string]interface{}, value interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, _ := defs[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{})
+		return unknownKeys(defs, def, value, path)
+	}
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		var retval []string
+		for _, s := range all {
+			retval = append(retval, unknownKeys(defs, s.(map[string]interface{}), value, path)...)
+		}
+		return retval
+	}
+
+	var retval []string
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+
+		for _, k := range sortedKeys(value) {
+			key := fmt.Sprintf("%v", k)
+			keyPath := strings.TrimPrefix(path+"."+key, ".")
+			if s, ok := properties[key].(map[string]interface{}); ok {
+				retval = append(retval, unknownKeys(defs, s, value[k], keyPath)...)
+			} else if additional != nil {
+				retval = append(retval, unknownKeys(defs, additional, value[k], keyPath)...)
+			} else if schema["additionalProperties"] == false {
+				retval = append(retval, keyPath+suggestKey(key, properties))
+			}
+		}
+	case []interface{}:
+		items, _ := schema["items"].(map[string]interface{})
+		if items == nil {
+			return nil
+		}
+		for i, item := range value {
+			elem := fmt.Sprintf("%s[%d]", path, i)
+			if m, ok := item.(map[interface{}]interface{}); ok && m["name"] != nil {
+				elem = fmt.Sprintf("%s[%v]", path, m["name"])
+			}
+			retval = append(retval, unknownKeys(defs, items, item, elem)...)
+		}
+	}
+	return retval
+}
+
+// suggestKey returns " (did you mean <key>?)" for the known key closest to
+// key, if it's close enough to be a misspelling
+func suggestKey(key string, properties map[string]interface{}) string {
+	var known []string
+	for k := range properties {
+		known = append(known, k)
+	}
+	sort.Strings(known)
+
+	best, bestDistance := "", len(key)/3+2
+	for _, k := range known {
+		if d := editDistance(strings.ToLower(key), k); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %s?)", best)
+}
+
+// editDistance returns Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,92 @@
+package bitesize
+
+import (
+	"fmt"
+
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
+)
+
+const (
+	// TLSProviderExternalSecrets syncs service certificates from AWS
+	// Secrets Manager with ExternalSecret resources
+	TLSProviderExternalSecrets = "external-secrets"
+	// TLSProviderCertManager issues service certificates with cert-manager
+	// Certificate resources
+	TLSProviderCertManager = "cert-manager"
+	// TLSProviderSecret uses a pre-existing TLS secret
+	TLSProviderSecret = "secret"
+)
+
+// TLSSettings represents environment-wide "tls" block in
+// environments.bitesize. It selects how TLS secrets of services with ssl
+// enabled are provided.
+type TLSSettings struct {
+	Provider   string `yaml:"provider,omitempty" validate:"regexp=^(external-secrets|cert-manager|secret)*$"`
+	Issuer     string `yaml:"issuer,omitempty"`
+	IssuerKind string `yaml:"issuer_kind,omitempty" validate:"regexp=^(Issuer|ClusterIssuer)*$"`
+	SecretName string `yaml:"secret_name,omitempty"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface for TLSSettings
+func (t *TLSSettings) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain TLSSettings
+	if err := unmarshal((*plain)(t)); err != nil {
+		return err
+	}
+
+	switch t.Provider {
+	case TLSProviderCertManager:
+		if t.Issuer == "" {
+			return fmt.Errorf("tls.issuer: required for provider %s", t.Provider)
+		}
+		if t.IssuerKind == "" {
+			t.IssuerKind = "Issuer"
+		}
+	case TLSProviderSecret:
+		if t.SecretName == "" {
+			return fmt.Errorf("tls.secret_name: required for provider %s", t.Provider)
+		}
+	}
+	return nil
+}
+
+// serviceTLS returns TLS settings for a service in the environment, keeping
+// only the settings used by the provider. Without a provider, ExternalSecrets
+// are used when EXTERNAL_CRD_EXTERNAL_SECRETS_ENABLED is set.
+func (e Environment) serviceTLS(svc Service) *TLSSettings {
+	if !svc.IsTLSEnabled() {
+		return nil
+	}
+	tls := e.TLS
+	if tls.Provider == "" && k8s.ExternalSecretsEnabled {
+		tls.Provider = TLSProviderExternalSecrets
+	}
+	switch tls.Provider {
+	case "":
+		return nil
+	case TLSProviderExternalSecrets:
+		return &TLSSettings{Provider: tls.Provider}
+	case TLSProviderCertManager:
+		tls.SecretName = ""
+	case TLSProviderSecret:
+		tls.Issuer, tls.IssuerKind = "", ""
+	}
+	return &tls
+}
+
+// TLSProvider returns the provider of service TLS secret, empty if the
+// secret is managed outside of environment operator
+func (e Service) TLSProvider() string {
+	if e.TLS == nil {
+		return ""
+	}
+	return e.TLS.Provider
+}
+
+// TLSSecretName returns the name of the secret holding service certificate
+func (e Service) TLSSecretName() string {
+	if e.TLS != nil && e.TLS.Provider == TLSProviderSecret {
+		return e.TLS.SecretName
+	}
+	return e.Name
+}
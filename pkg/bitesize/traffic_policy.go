@@ -0,0 +1,102 @@
+package bitesize
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TrafficPolicy represents "traffic_policy" block of service mesh enabled
+// services in environments.bitesize. Connection pool, outlier detection and
+// mTLS settings are applied to the service DestinationRule, retries and
+// timeout to its VirtualService routes.
+type TrafficPolicy struct {
+	ConnectionPool   *ConnectionPool   `yaml:"connection_pool,omitempty" json:"connection_pool,omitempty"`
+	OutlierDetection *OutlierDetection `yaml:"outlier_detection,omitempty" json:"outlier_detection,omitempty"`
+	Retries          *Retries          `yaml:"retries,omitempty" json:"retries,omitempty"`
+	Timeout          string            `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	TLSMode          string            `yaml:"tls_mode,omitempty" json:"tls_mode,omitempty" validate:"regexp=^(DISABLE|SIMPLE|MUTUAL|ISTIO_MUTUAL)*$"`
+}
+
+// ConnectionPool limits connections and requests to the service
+type ConnectionPool struct {
+	MaxConnections           int32  `yaml:"max_connections,omitempty" json:"max_connections,omitempty" validate:"min=0"`
+	ConnectTimeout           string `yaml:"connect_timeout,omitempty" json:"connect_timeout,omitempty"`
+	HTTP1MaxPendingRequests  int32  `yaml:"http1_max_pending_requests,omitempty" json:"http1_max_pending_requests,omitempty" validate:"min=0"`
+	HTTP2MaxRequests         int32  `yaml:"http2_max_requests,omitempty" json:"http2_max_requests,omitempty" validate:"min=0"`
+	MaxRequestsPerConnection int32  `yaml:"max_requests_per_connection,omitempty" json:"max_requests_per_connection,omitempty" validate:"min=0"`
+}
+
+// OutlierDetection ejects service pods failing requests from load balancing
+type OutlierDetection struct {
+	ConsecutiveErrors  int32  `yaml:"consecutive_errors,omitempty" json:"consecutive_errors,omitempty" validate:"min=0"`
+	Interval           string `yaml:"interval,omitempty" json:"interval,omitempty"`
+	BaseEjectionTime   string `yaml:"base_ejection_time,omitempty" json:"base_ejection_time,omitempty"`
+	MaxEjectionPercent int32  `yaml:"max_ejection_percent,omitempty" json:"max_ejection_percent,omitempty" validate:"min=0,max=100"`
+}
+
+// Retries sets retry policy of requests routed to the service
+type Retries struct {
+	Attempts      int32  `yaml:"attempts" json:"attempts" validate:"min=0"`
+	PerTryTimeout string `yaml:"per_try_timeout,omitempty" json:"per_try_timeout,omitempty"`
+	RetryOn       string `yaml:"retry_on,omitempty" json:"retry_on,omitempty"`
+}
+
+// TrafficPolicyAnnotation returns traffic policy serialized for kubernetes
+// service annotation, or empty string if policy is not set
+func TrafficPolicyAnnotation(policy *TrafficPolicy) string {
+	if policy == nil {
+		return ""
+	}
+	byt, err := json.Marshal(policy)
+	if err != nil {
+		return ""
+	}
+	return string(byt)
+}
+
+// TrafficPolicyFromAnnotation reads traffic policy written by
+// TrafficPolicyAnnotation, returning nil if it's not set
+func TrafficPolicyFromAnnotation(annotation string) *TrafficPolicy {
+	if annotation == "" {
+		return nil
+	}
+	retval := &TrafficPolicy{}
+	if err := json.Unmarshal([]byte(annotation), retval); err != nil {
+		return nil
+	}
+	return retval
+}
+
+// validateTrafficPolicy returns an error if traffic policy is set without
+// service mesh or has invalid durations
+func validateTrafficPolicy(svc Service) error {
+	policy := svc.TrafficPolicy
+	if policy == nil {
+		return nil
+	}
+	if !svc.IsServiceMeshEnabled() {
+		return fmt.Errorf("%s: traffic_policy requires service_mesh", svc.Name)
+	}
+
+	durations := map[string]string{"timeout": policy.Timeout}
+	if policy.ConnectionPool != nil {
+		durations["connection_pool.connect_timeout"] = policy.ConnectionPool.ConnectTimeout
+	}
+	if policy.OutlierDetection != nil {
+		durations["outlier_detection.interval"] = policy.OutlierDetection.Interval
+		durations["outlier_detection.base_ejection_time"] = policy.OutlierDetection.BaseEjectionTime
+	}
+	if policy.Retries != nil {
+		durations["retries.per_try_timeout"] = policy.Retries.PerTryTimeout
+	}
+	for field, value := range durations {
+		if value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%s: traffic_policy.%s: invalid duration %s", svc.Name, field, value)
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package bitesize
 
 import (
 	"fmt"
+	"net"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -19,6 +20,9 @@ func addCustomValidators() {
 	validator.SetValidationFunc("requests", validRequests)
 	validator.SetValidationFunc("limits", validLimits)
 	validator.SetValidationFunc("external_url", validExternalURL)
+	validator.SetValidationFunc("network_peers", validNetworkPeers)
+	validator.SetValidationFunc("external_routes", validExternalRoutes)
+	validator.SetValidationFunc("cidrs", validCIDRs)
 }
 
 func validVolumeModes(v interface{}, param string) error {
@@ -93,23 +97,17 @@ func validHPA(hpa interface{}, param string) error {
 				return fmt.Errorf("hpa %+v number of replicas invalid; values greater than %v not allowed", hpa, config.Env.HPAMaxReplicas)
 			}
 
-		case "Metric":
-			fieldValue := val.Field(i).Interface()
-
-			metric, ok := fieldValue.(Metric)
-			if ok {
-				if metric.TargetAverageUtilization != 0 && metric.TargetAverageUtilization < 75 {
-					return fmt.Errorf("hpa %+v CPU Utilization invalid; thresholds lower than 75%% not allowed", hpa)
-				}
-				if metric.TargetAverageUtilization != 0 && metric.TargetAverageUtilization > 100 {
-					return fmt.Errorf("hpa %+v memory Utilization invalid; thresholds greater than 100%% not allowed", hpa)
-				}
-				if metric.Name != "cpu" && metric.Name != "memory" {
-					if metric.TargetAverageUtilization != 0 {
-						return fmt.Errorf("hpa %+v target Average Utilization does not exist for custom metrics", hpa)
-					}
+		case "Metrics":
+			for j, metric := range val.Field(i).Interface().([]Metric) {
+				if err := validMetric(metric); err != nil {
+					return fmt.Errorf("hpa.metrics[%d]: %s", j, err.Error())
 				}
 			}
+
+		case "Behavior":
+			if err := validScalingBehavior(val.Field(i).Interface().(*ScalingBehavior)); err != nil {
+				return fmt.Errorf("hpa.behavior.%s", err.Error())
+			}
 		}
 	}
 
@@ -223,3 +221,16 @@ func validExternalURL(urls interface{}, param string) error {
 	}
 	return nil
 }
+
+func validCIDRs(v interface{}, param string) error {
+	cidrs, ok := v.([]string)
+	if !ok {
+		return fmt.Errorf("invalid cidrs: %v", reflect.ValueOf(v))
+	}
+	for _, cidr := range cidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("cidr %s is invalid", cidr)
+		}
+	}
+	return nil
+}
@@ -73,23 +73,23 @@ func TestValidHPA(t *testing.T) {
 		Error error
 	}{
 		{
-			HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 51, Metric: Metric{Name: "cpu", TargetAverageUtilization: 75}},
-			fmt.Errorf("hpa %+v number of replicas invalid; values greater than %v not allowed", HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 51, Metric: Metric{Name: "cpu", TargetAverageUtilization: 75}}, config.Env.HPAMaxReplicas),
+			HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 51, Metrics: []Metric{{Name: "cpu", TargetAverageUtilization: 75}}},
+			fmt.Errorf("hpa %+v number of replicas invalid; values greater than %v not allowed", HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 51, Metrics: []Metric{{Name: "cpu", TargetAverageUtilization: 75}}}, config.Env.HPAMaxReplicas),
 		},
 		{
-			HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 2, Metric: Metric{Name: "cpu", TargetAverageUtilization: 74}},
-			fmt.Errorf("hpa %+v CPU Utilization invalid; thresholds lower than 75%% not allowed", HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 2, Metric: Metric{Name: "cpu", TargetAverageUtilization: 74}}),
+			HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 2, Metrics: []Metric{{Name: "cpu", TargetAverageUtilization: 74}}},
+			fmt.Errorf("hpa.metrics[0]: resource metric cpu utilization invalid; thresholds between 75%% and 100%% allowed"),
 		},
 		{
-			HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 2, Metric: Metric{Name: "memory", TargetAverageUtilization: 120}},
-			fmt.Errorf("hpa %+v memory Utilization invalid; thresholds greater than 100%% not allowed", HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 2, Metric: Metric{Name: "memory", TargetAverageUtilization: 120}}),
+			HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 2, Metrics: []Metric{{Name: "cpu", TargetAverageUtilization: 75}, {Name: "memory", TargetAverageUtilization: 120}}},
+			fmt.Errorf("hpa.metrics[1]: resource metric memory utilization invalid; thresholds between 75%% and 100%% allowed"),
 		},
 		{
-			HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 2, Metric: Metric{Name: "custom_metric", TargetAverageUtilization: 80}},
-			fmt.Errorf("hpa %+v target Average Utilization does not exist for custom metrics", HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 2, Metric: Metric{Name: "custom_metric", TargetAverageUtilization: 80}}),
+			HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 2, Metrics: []Metric{{Type: MetricTypePods, Name: "custom_metric", TargetAverageUtilization: 80}}},
+			fmt.Errorf("hpa.metrics[0]: pods metric custom_metric requires target_average_value"),
 		},
 		{
-			HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 2, Metric: Metric{Name: "cpu", TargetAverageUtilization: 75}},
+			HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 2, Metrics: []Metric{{Name: "cpu", TargetAverageUtilization: 75}}},
 			nil,
 		},
 	}
@@ -97,7 +97,7 @@ func TestValidHPA(t *testing.T) {
 	for _, tCase := range testCases {
 		err := validHPA(tCase.Value, "")
 		if err != tCase.Error {
-			if err.Error() != tCase.Error.Error() {
+			if err == nil || tCase.Error == nil || err.Error() != tCase.Error.Error() {
 				t.Errorf("HPA validation error: %v", err)
 			}
 		}
@@ -214,3 +214,55 @@ func TestValidExternalURL(t *testing.T) {
 	}
 
 }
+
+func TestValidNetworkPeers(t *testing.T) {
+	var testCases = []struct {
+		Value interface{}
+		Valid bool
+	}{
+		{[]NetworkPeer{{Service: "front"}}, true},
+		{[]NetworkPeer{{Service: "db", Namespace: "data", Ports: []int{5432}}}, true},
+		{[]NetworkPeer{{CIDR: "10.0.0.0/8"}}, true},
+		{[]NetworkPeer{{}}, false},
+		{[]NetworkPeer{{CIDR: "10.0.0.0"}}, false},
+		{[]NetworkPeer{{CIDR: "10.0.0.0/8", Service: "front"}}, false},
+		{[]NetworkPeer{{Service: "front", Ports: []int{70000}}}, false},
+		{"front", false},
+	}
+
+	for _, tCase := range testCases {
+		err := validNetworkPeers(tCase.Value, "")
+		if tCase.Valid && err != nil {
+			t.Errorf("Unexpected error for %+v: %s", tCase.Value, err.Error())
+		}
+		if !tCase.Valid && err == nil {
+			t.Errorf("Expected error for %+v, got nil", tCase.Value)
+		}
+	}
+}
+
+func TestValidExternalRoutes(t *testing.T) {
+	var testCases = []struct {
+		Value interface{}
+		Valid bool
+	}{
+		{[]ExternalRoute{{Host: "www.example.com"}}, true},
+		{[]ExternalRoute{{Host: "www.example.com", Path: "/api", PathType: "Exact", Service: "api", Port: 8080}}, true},
+		{[]ExternalRoute{{}}, false},
+		{[]ExternalRoute{{Host: "www.example.com/api"}}, false},
+		{[]ExternalRoute{{Host: "www.example.com", Path: "api"}}, false},
+		{[]ExternalRoute{{Host: "www.example.com", PathType: "ImplementationSpecific"}}, false},
+		{[]ExternalRoute{{Host: "www.example.com", Port: 70000}}, false},
+		{"www.example.com", false},
+	}
+
+	for _, tCase := range testCases {
+		err := validExternalRoutes(tCase.Value, "")
+		if tCase.Valid && err != nil {
+			t.Errorf("Unexpected error for %+v: %s", tCase.Value, err.Error())
+		}
+		if !tCase.Valid && err == nil {
+			t.Errorf("Expected error for %+v, got nil", tCase.Value)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package bitesize
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Built-in variables available to ${var} interpolation in every environment
+const (
+	VarProject     = "project"
+	VarEnvironment = "environment"
+	VarNamespace   = "namespace"
+	VarGitCommit   = "git_commit"
+)
+
+var (
+	varReference = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+	varName      = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+func isBuiltinVar(name string) bool {
+	switch name {
+	case VarProject, VarEnvironment, VarNamespace, VarGitCommit:
+		return true
+	}
+	return false
+}
+
+// environmentVars returns variables available to raw environment: built-ins,
+// project vars and environment vars, the latter overriding project ones.
+// Values of vars may reference built-in variables only.
+func environmentVars(builtins map[string]interface{}, project map[string]interface{}, env map[interface{}]interface{}) (map[string]interface{}, error) {
+	retval := map[string]interface{}{}
+	for k, v := range builtins {
+		retval[k] = v
+	}
+	if name, ok := env["name"].(string); ok {
+		retval[VarEnvironment] = name
+	}
+	if ns, ok := env["namespace"].(string); ok && ns != "" {
+		retval[VarNamespace] = ns
+	}
+	builtinVars := map[string]interface{}{}
+	for k, v := range retval {
+		builtinVars[k] = v
+	}
+
+	envVars := map[string]interface{}{}
+	if vars, ok := env["vars"]; ok && vars != nil {
+		m, ok := vars.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("vars: must be a map of variable names to values")
+		}
+		for k, v := range m {
+			envVars[fmt.Sprintf("%v", k)] = v
+		}
+	}
+
+	for _, vars := range []map[string]interface{}{project, envVars} {
+		names := make([]string, 0, len(vars))
+		for k := range vars {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		for _, k := range names {
+			if !varName.MatchString(k) {
+				return nil, fmt.Errorf("vars.%s: invalid variable name", k)
+			}
+			if isBuiltinVar(k) {
+				return nil, fmt.Errorf("vars.%s: built-in variable can't be redefined", k)
+			}
+			switch vars[k].(type) {
+			case map[interface{}]interface{}, []interface{}:
+				return nil, fmt.Errorf("vars.%s: value must be a scalar", k)
+			}
+			v, err := interpolate(vars[k], builtinVars, "vars."+k)
+			if err != nil {
+				return nil, err
+			}
+			retval[k] = v
+		}
+	}
+	return retval, nil
+}
+
+// interpolateEnvironment returns a copy of raw environment with ${var}
+// references replaced by their values, and whether any were found. Vars
+// block itself is left as is.
+func interpolateEnvironment(env map[interface{}]interface{}, vars map[string]interface{}) (map[interface{}]interface{}, bool, error) {
+	retval := map[interface{}]interface{}{}
+	changed := false
+	for _, k := range sortedKeys(env) {
+		v := env[k]
+		if k == "vars" {
+			retval[k] = deepCopy(v)
+			continue
+		}
+		if byt, _ := yaml.Marshal(v); varReference.Match(byt) {
+			changed = true
+		}
+		value, err := interpolate(v, vars, fmt.Sprintf("%v", k))
+		if err != nil {
+			return nil, false, err
+		}
+		retval[k] = value
+	}
+	return retval, changed, nil
+}
+
+// interpolate replaces ${var} references in string values found in v.
+// A value consisting of a single reference takes the variable's value as
+// is, keeping its type (e.g. replicas: ${replicas}). $${ escapes ${.
+// path is the location of v, used in errors.
+func interpolate(v interface{}, vars map[string]interface{}, path string) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		retval := map[interface{}]interface{}{}
+		for _, k := range sortedKeys(v) {
+			value, err := interpolate(v[k], vars, fmt.Sprintf("%s.%v", path, k))
+			if err != nil {
+				return nil, err
+			}
+			retval[k] = value
+		}
+		return retval, nil
+	case []interface{}:
+		retval := make([]interface{}, len(v))
+		for i, val := range v {
+			elem := fmt.Sprintf("%s[%d]", path, i)
+			if m, ok := val.(map[interface{}]interface{}); ok && m["name"] != nil {
+				elem = fmt.Sprintf("%s[%v]", path, m["name"])
+			}
+			value, err := interpolate(val, vars, elem)
+			if err != nil {
+				return nil, err
+			}
+			retval[i] = value
+		}
+		return retval, nil
+	case string:
+		return interpolateString(v, vars, path)
+	default:
+		return v, nil
+	}
+}
+
+func interpolateString(s string, vars map[string]interface{}, path string) (interface{}, error) {
+	if m := varReference.FindStringSubmatchIndex(s); m != nil && m[0] == 0 && m[1] == len(s) && m[2] >= 0 {
+		name := s[m[2]:m[3]]
+		value, err := lookupVar(name, vars, path)
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+
+	var err error
+	retval := varReference.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(ref, "${"), "}")
+		value, e := lookupVar(name, vars, path)
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			return ref
+		}
+		return fmt.Sprintf("%v", value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return retval, nil
+}
+
+func lookupVar(name string, vars map[string]interface{}, path string) (interface{}, error) {
+	if !varName.MatchString(name) {
+		return nil, fmt.Errorf("%s: invalid variable reference ${%s}", path, name)
+	}
+	value, ok := vars[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: undefined variable ${%s}", path, name)
+	}
+	if value == nil {
+		return "", nil
+	}
+	return value, nil
+}
+
+func sortedKeys(m map[interface{}]interface{}) []interface{} {
+	keys := make([]interface{}, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprintf("%v", keys[i]) < fmt.Sprintf("%v", keys[j])
+	})
+	return keys
+}
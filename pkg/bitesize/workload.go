@@ -0,0 +1,15 @@
+package bitesize
+
+const (
+	// WorkloadDeployment is the default workload kind; service pods are
+	// managed by a k8s Deployment
+	WorkloadDeployment string = "deployment"
+	// WorkloadStatefulSet makes service pods managed by a k8s StatefulSet,
+	// giving each replica a stable identity and its own volumes
+	WorkloadStatefulSet string = "statefulset"
+)
+
+// IsStatefulSet returns true if service should be deployed as a StatefulSet
+func (e Service) IsStatefulSet() bool {
+	return e.Workload == WorkloadStatefulSet
+}
@@ -0,0 +1,158 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+)
+
+// smokeCheckGet returns the response status of HTTP GET request to url.
+// Replaced in unit tests.
+var smokeCheckGet = httpStatus
+
+// ResolveActiveDeployments sets bluegreen services in cfg to serve live
+// traffic from the service sets promoted via API
+func (cluster *Cluster) ResolveActiveDeployments(cfg *bitesize.Environment) error {
+	current, err := cluster.ScrapeResourcesForNamespace(cfg.Namespace)
+	if err != nil {
+		return err
+	}
+	resolveActiveDeployments(cfg, current)
+	return nil
+}
+
+// resolveActiveDeployments keeps the active service set of bluegreen services
+// switched via API, unless deployment.active in cfg changed since the switch
+func resolveActiveDeployments(cfg, current *bitesize.Environment) {
+	for _, svc := range cfg.Services {
+		if !svc.IsBlueGreenParentDeployment() {
+			continue
+		}
+		deployed := current.Services.FindByName(svc.Name)
+		if deployed == nil || deployed.ActiveDeploymentTag() == 0 {
+			continue
+		}
+		if deployed.ConfiguredDeploymentTag() != svc.ActiveDeploymentTag() ||
+			deployed.ActiveDeploymentTag() == svc.ActiveDeploymentTag() {
+			continue
+		}
+		if err := cfg.SetActiveDeployment(svc.Name, deployed.ActiveDeploymentTag()); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// PromoteBlueGreen switches live traffic of bluegreen service name to its
+// inactive service set, after the inactive set passes smoke checks
+func (cluster *Cluster) PromoteBlueGreen(cfg *bitesize.Environment, name string) (*bitesize.Service, error) {
+	if err := cluster.ResolveActiveDeployments(cfg); err != nil {
+		return nil, err
+	}
+	svc := cfg.Services.FindByName(name)
+	if svc == nil || !svc.IsBlueGreenParentDeployment() {
+		return nil, fmt.Errorf("service %s deployment method is not bluegreen", name)
+	}
+	return cluster.switchBlueGreen(cfg, *svc, svc.InactiveDeploymentTag())
+}
+
+// RollbackBlueGreen switches live traffic of bluegreen service name back to
+// the service set configured in environments.bitesize, after it passes smoke
+// checks
+func (cluster *Cluster) RollbackBlueGreen(cfg *bitesize.Environment, name string) (*bitesize.Service, error) {
+	if err := cluster.ResolveActiveDeployments(cfg); err != nil {
+		return nil, err
+	}
+	svc := cfg.Services.FindByName(name)
+	if svc == nil || !svc.IsBlueGreenParentDeployment() {
+		return nil, fmt.Errorf("service %s deployment method is not bluegreen", name)
+	}
+	if svc.ConfiguredDeploymentTag() == svc.ActiveDeploymentTag() {
+		return nil, errors.New("no promotion to roll back")
+	}
+	return cluster.switchBlueGreen(cfg, *svc, svc.ConfiguredDeploymentTag())
+}
+
+// switchBlueGreen runs smoke checks against active service set of svc and
+// re-applies "parent" service routing live traffic to it
+func (cluster *Cluster) switchBlueGreen(cfg *bitesize.Environment, svc bitesize.Service, active bitesize.BlueGreenServiceSet) (*bitesize.Service, error) {
+	target := cfg.Services.FindByName(fmt.Sprintf("%s-%s", svc.Name, active))
+	if target == nil {
+		return nil, fmt.Errorf("%s-%s not found", svc.Name, active)
+	}
+	if err := runSmokeChecks(svc, *target, cfg.Namespace); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.SetActiveDeployment(svc.Name, active); err != nil {
+		return nil, err
+	}
+	parent := cfg.Services.FindByName(svc.Name)
+	log.Infof("switching %s live traffic to %s", svc.Name, target.Name)
+	if err := cluster.ApplyService(parent, &bitesize.Gists{}, cfg.Namespace); err != nil {
+		return nil, err
+	}
+	return parent, nil
+}
+
+// runSmokeChecks probes target service set with smoke checks of bluegreen
+// "parent" service svc, returning the first failure
+func runSmokeChecks(svc, target bitesize.Service, namespace string) error {
+	if svc.Deployment == nil {
+		return nil
+	}
+
+	for _, check := range svc.Deployment.SmokeChecks {
+		for _, url := range smokeCheckURLs(check, target, namespace) {
+			timeout := 5 * time.Second
+			if check.Timeout > 0 {
+				timeout = time.Duration(check.Timeout) * time.Second
+			}
+			expected := http.StatusOK
+			if check.Status != 0 {
+				expected = check.Status
+			}
+
+			status, err := smokeCheckGet(url, timeout)
+			if err != nil {
+				return fmt.Errorf("smoke check %s failed: %s", url, err.Error())
+			}
+			if status != expected {
+				return fmt.Errorf("smoke check %s returned %d, expected %d", url, status, expected)
+			}
+			log.Debugf("smoke check %s passed", url)
+		}
+	}
+	return nil
+}
+
+// smokeCheckURLs returns URLs probed by check: external urls of the target
+// service set, or its cluster service address if it has none
+func smokeCheckURLs(check bitesize.SmokeCheck, target bitesize.Service, namespace string) []string {
+	scheme := check.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	var retval []string
+	for _, u := range target.ExternalURL {
+		retval = append(retval, fmt.Sprintf("%s://%s%s", scheme, u, check.Path))
+	}
+	if len(retval) == 0 && len(target.Ports) > 0 {
+		retval = append(retval, fmt.Sprintf("%s://%s.%s.svc:%d%s", scheme, target.Name, namespace, target.HTTPPort(), check.Path))
+	}
+	return retval
+}
+
+func httpStatus(url string, timeout time.Duration) (int, error) {
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
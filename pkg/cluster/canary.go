@@ -0,0 +1,288 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	"github.com/pearsontechnology/environment-operator/pkg/config"
+	"github.com/pearsontechnology/environment-operator/pkg/translator"
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
+)
+
+// canaryQuery returns the result of canary analysis query. Replaced in
+// unit tests.
+var canaryQuery = queryPrometheus
+
+// now returns current time. Replaced in unit tests.
+var now = time.Now
+
+// DeployCanary deploys canary service and starts routing the first canary
+// step of traffic to it. Services without a deployed version have nothing
+// to compare the canary to and are deployed directly.
+func (cluster *Cluster) DeployCanary(svc, canary bitesize.Service, gists *bitesize.Gists, namespace string) error {
+	current, err := cluster.ScrapeResourcesForNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	return cluster.deployCanary(svc, canary, gists, current)
+}
+
+// deployCanary deploys canary service next to the stable service deployed
+// in current environment
+func (cluster *Cluster) deployCanary(svc, canary bitesize.Service, gists *bitesize.Gists, current *bitesize.Environment) error {
+	namespace := current.Namespace
+	stable := current.Services.FindByName(svc.Name)
+	if stable == nil || stable.Version == "" {
+		svc.Version = canary.Version
+		svc.Application = canary.Application
+		return cluster.ApplyService(&svc, gists, namespace)
+	}
+
+	if err := cluster.ApplyService(&canary, gists, namespace); err != nil {
+		return err
+	}
+
+	svc.Version = stable.Version
+	svc.ConfigHash = stable.ConfigHash
+	if stable.Application != "" {
+		svc.Application = stable.Application
+	}
+	return cluster.applyCanaryState(svc, bitesize.CanaryState{
+		Step:      0,
+		StartedAt: now(),
+		Status:    bitesize.CanaryProgressing,
+		Version:   canary.Version,
+	}, gists, namespace)
+}
+
+// ProgressCanaries advances canary rollouts of services in cfg whose step
+// interval elapsed, and aborts canaries failing readiness or analysis checks.
+// current is the environment scraped from the namespace.
+func (cluster *Cluster) ProgressCanaries(cfg, current *bitesize.Environment) error {
+	if current == nil {
+		return errors.New("could not progress canaries of environment (nil)")
+	}
+
+	for _, svc := range cfg.Services {
+		if !svc.IsCanaryParentDeployment() {
+			continue
+		}
+		if _, err := cluster.progressCanary(svc, current, false); err != nil {
+			log.Errorf("canary %s: %s", svc.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// AdvanceCanary moves canary of svc to the next step, or promotes it after
+// the last step
+func (cluster *Cluster) AdvanceCanary(svc bitesize.Service, namespace string) (*bitesize.CanaryState, error) {
+	if !svc.IsCanaryParentDeployment() {
+		return nil, fmt.Errorf("service %s deployment method is not canary", svc.Name)
+	}
+
+	current, err := cluster.ScrapeResourcesForNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.progressCanary(svc, current, true)
+}
+
+// progressCanary applies the next canary state of svc: aborted if the
+// canary failed a check, the next step when due or requested, and promoted
+// after the last step. Canaries are held at their step while the analysis
+// can't be run.
+func (cluster *Cluster) progressCanary(svc bitesize.Service, current *bitesize.Environment, advance bool) (*bitesize.CanaryState, error) {
+	stable := current.Services.FindByName(svc.Name)
+	canary := current.Services.FindByName(svc.CanaryDeploymentName())
+	if stable == nil || canary == nil || stable.CanaryState() == nil ||
+		stable.CanaryState().Status != bitesize.CanaryProgressing {
+		if advance {
+			return nil, errors.New("no canary in progress")
+		}
+		return nil, nil
+	}
+
+	state := *stable.CanaryState()
+	svc.Version = stable.Version
+	svc.Application = stable.Application
+	svc.ConfigHash = stable.ConfigHash
+	namespace := current.Namespace
+	cluster.resolveOutputs(svc, current.Services, namespace)
+
+	passed, err := canaryAnalysis(svc)
+	if err != nil {
+		return &state, fmt.Errorf("holding canary at step %d: %s", state.Step, err.Error())
+	}
+	if !passed {
+		state.Status = bitesize.CanaryAborted
+		return &state, cluster.finishCanary(svc, state, namespace)
+	}
+
+	interval := canaryInterval(svc)
+	due := advance || (interval > 0 && now().Sub(state.StartedAt) >= interval)
+	if !due {
+		return &state, nil
+	}
+
+	if canary.Status.DesiredReplicas == 0 || canary.Status.AvailableReplicas < canary.Status.DesiredReplicas {
+		log.Infof("aborting canary %s: %d of %d canary pods ready", svc.Name,
+			canary.Status.AvailableReplicas, canary.Status.DesiredReplicas)
+		state.Status = bitesize.CanaryAborted
+		return &state, cluster.finishCanary(svc, state, namespace)
+	}
+
+	state.StartedAt = now()
+	if state.Step+1 < len(svc.CanarySteps()) {
+		state.Step++
+		log.Infof("canary %s advanced to %d%% of traffic", svc.Name, svc.CanarySteps()[state.Step])
+		return &state, cluster.applyCanaryState(svc, state, nil, namespace)
+	}
+
+	log.Infof("promoting canary %s version %s", svc.Name, canary.Version)
+	state.Status = bitesize.CanaryPromoted
+	svc.Version = canary.Version
+	svc.Application = canary.Application
+	return &state, cluster.finishCanary(svc, state, namespace)
+}
+
+// finishCanary applies promoted or aborted canary state, moving all traffic
+// to the "parent" service, and deletes the canary deployment and service
+func (cluster *Cluster) finishCanary(svc bitesize.Service, state bitesize.CanaryState, namespace string) error {
+	if err := cluster.applyCanaryState(svc, state, nil, namespace); err != nil {
+		return err
+	}
+
+	client := &k8s.Client{
+		Interface: cluster.Interface,
+		Namespace: namespace,
+	}
+	name := svc.CanaryDeploymentName()
+	if client.Deployment().Exist(name) {
+		if err := client.Deployment().Destroy(name); err != nil {
+			return err
+		}
+	}
+	if client.Service().Exist(name) {
+		return client.Service().Destroy(name)
+	}
+	return nil
+}
+
+// applyCanaryState re-applies "parent" service with canary state, updating
+// traffic weights
+func (cluster *Cluster) applyCanaryState(svc bitesize.Service, state bitesize.CanaryState, gists *bitesize.Gists, namespace string) error {
+	deployment := bitesize.DeploymentSettings{}
+	if svc.Deployment != nil {
+		deployment = *svc.Deployment
+	}
+	deployment.CanaryState = &state
+	svc.Deployment = &deployment
+
+	if gists == nil {
+		gists = &bitesize.Gists{}
+	}
+	return cluster.ApplyService(&svc, gists, namespace)
+}
+
+// applyCanaryIngress creates nginx canary ingress while the canary receives
+// traffic and removes it otherwise
+func (cluster *Cluster) applyCanaryIngress(mapper *translator.KubeMapper, client k8s.Client) error {
+	name := mapper.BiteService.CanaryDeploymentName()
+	if mapper.BiteService.CanaryWeight() == 0 {
+		if client.Ingress().Exist(name) {
+			return client.Ingress().Destroy(name)
+		}
+		return nil
+	}
+
+	ingress, err := mapper.CanaryIngress()
+	if err != nil {
+		return err
+	}
+	return client.Ingress().Apply(ingress)
+}
+
+// withCanaryState returns deployment settings with canary state of the
+// deployed service
+func withCanaryState(deployment *bitesize.DeploymentSettings, current *bitesize.Service) *bitesize.DeploymentSettings {
+	if current == nil || current.CanaryState() == nil {
+		return deployment
+	}
+	retval := *deployment
+	retval.CanaryState = current.CanaryState()
+	return &retval
+}
+
+func canaryInterval(svc bitesize.Service) time.Duration {
+	if svc.Deployment == nil || svc.Deployment.Canary == nil {
+		return 0
+	}
+	return time.Duration(svc.Deployment.Canary.Interval) * time.Second
+}
+
+// canaryAnalysis returns false if the canary analysis query result is above
+// the threshold, and an error if the query failed
+func canaryAnalysis(svc bitesize.Service) (bool, error) {
+	if svc.Deployment == nil || svc.Deployment.Canary == nil || svc.Deployment.Canary.Analysis == nil {
+		return true, nil
+	}
+	analysis := svc.Deployment.Canary.Analysis
+
+	value, err := canaryQuery(config.Env.PrometheusURL, analysis.Query)
+	if err != nil {
+		return false, fmt.Errorf("analysis query failed: %s", err.Error())
+	}
+	if value > analysis.Threshold {
+		log.Infof("aborting canary %s: analysis result %g above threshold %g", svc.Name, value, analysis.Threshold)
+		return false, nil
+	}
+	return true, nil
+}
+
+// queryPrometheus returns the first value of instant query result from
+// Prometheus compatible API at endpoint
+func queryPrometheus(endpoint, query string) (float64, error) {
+	if endpoint == "" {
+		return 0, errors.New("PROMETHEUS_URL is not set")
+	}
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("%s/api/v1/query?query=%s", endpoint, url.QueryEscape(query)))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Result []struct {
+				Value []interface{} `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	if result.Status != "success" {
+		return 0, fmt.Errorf("query failed: %s", result.Error)
+	}
+	if len(result.Data.Result) == 0 || len(result.Data.Result[0].Value) != 2 {
+		return 0, errors.New("query returned no data")
+	}
+
+	value, ok := result.Data.Result[0].Value[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected query value %v", result.Data.Result[0].Value[1])
+	}
+	return strconv.ParseFloat(value, 64)
+}
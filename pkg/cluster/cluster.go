@@ -14,6 +14,7 @@ import (
 	"github.com/pearsontechnology/environment-operator/pkg/util"
 	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -34,7 +35,17 @@ func Client() (*Cluster, error) {
 		return nil, err
 	}
 
-	return &Cluster{Interface: clientset, CRDClient: crdcli}, nil
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cluster{
+		Interface:      clientset,
+		CRDClient:      crdcli,
+		Dynamic:        dynamicClient,
+		IngressVersion: &k8s.IngressVersion{},
+	}, nil
 }
 
 // ApplyIfChanged compares bitesize Environment passed as an argument to
@@ -53,6 +64,9 @@ func (cluster *Cluster) ApplyIfChanged(newConfig *bitesize.Environment) error {
 		log.Errorf("error while loading environment: %s", err.Error())
 		return err
 	}
+	cluster.resolveServiceOutputs(newConfig)
+	cluster.setConfigHashes(newConfig)
+	resolveActiveDeployments(newConfig, currentConfig)
 	if diff.Compare(*newConfig, *currentConfig) {
 		util.LogTraceAsYaml("ApplyIfChanged newConfig", newConfig)
 		util.LogTraceAsYaml("ApplyIfChanged currentConfig", currentConfig)
@@ -67,6 +81,27 @@ func (cluster *Cluster) ApplyIfChanged(newConfig *bitesize.Environment) error {
 func (cluster *Cluster) ApplyEnvironment(currentEnvironment, newEnvironment *bitesize.Environment) error {
 	var err error
 
+	if newEnvironment.NetworkPolicy.DefaultDeny {
+		client := &k8s.Client{
+			Interface: cluster.Interface,
+			Namespace: newEnvironment.Namespace,
+		}
+		if err = client.NetworkPolicy().Apply(translator.DefaultDenyNetworkPolicy(newEnvironment.Namespace)); err != nil {
+			log.Error(err)
+		}
+	}
+
+	// secrets are applied before services, so that pods rolled out on
+	// secret change read the new values
+	for _, gist := range newEnvironment.Gists.FindByType(bitesize.TypeSecret) {
+		if !diff.GistChanged(gist.Name) {
+			continue
+		}
+		if gistErr := cluster.ApplyGist(&gist, newEnvironment.Namespace); gistErr != nil {
+			log.Error(gistErr)
+		}
+	}
+
 	for _, service := range newEnvironment.Services {
 		if !shouldDeployOnChange(currentEnvironment, newEnvironment, service.Name) {
 			continue
@@ -100,19 +135,75 @@ func (cluster *Cluster) ApplyEnvironment(currentEnvironment, newEnvironment *bit
 				}
 			}
 		}
-		// TODO: load jobs and cronjobs
-		if service.Version == "" {
-			service.Version = currentEnvironment.Services.FindByName(service.Name).Version
+		current := currentEnvironment.Services.FindByName(service.Name)
+		if service.Version == "" && current != nil {
+			service.Version = current.Version
+		}
+		if service.IsCanaryParentDeployment() {
+			// new versions are released to the canary, versions released
+			// by canary in progress or aborted keep the stable version
+			if canary := newEnvironment.Services.FindByName(service.CanaryDeploymentName()); canary != nil &&
+				current != nil && current.Version != "" && service.Version != current.Version {
+				if state := current.CanaryState(); state == nil || state.Version != service.Version {
+					err = cluster.deployCanary(service, *canary, &gists, currentEnvironment)
+					continue
+				}
+				service.Version = current.Version
+			}
+			service.Deployment = withCanaryState(service.Deployment, current)
 		}
 
 		err = cluster.ApplyService(&service, &gists, newEnvironment.Namespace)
 	}
+
+	for _, gist := range newEnvironment.Gists {
+		if gist.Type == bitesize.TypeSecret || !diff.GistChanged(gist.Name) {
+			continue
+		}
+		if gistErr := cluster.ApplyGist(&gist, newEnvironment.Namespace); gistErr != nil {
+			log.Error(gistErr)
+		}
+	}
 	return err
 }
 
+// ApplyGist applies a single job, cronjob or secret gist to the namespace. Jobs
+// that already exist are replaced and run again. ConfigMap gists are applied
+// together with the services mounting them.
+func (cluster *Cluster) ApplyGist(gist *bitesize.Gist, namespace string) error {
+	client := &k8s.Client{
+		Interface: cluster.Interface,
+		Namespace: namespace,
+	}
+
+	switch gist.Type {
+	case bitesize.TypeJob:
+		log.Infof("running job %s", gist.Name)
+		return client.Job().Apply(&gist.Job)
+	case bitesize.TypeCronJob:
+		log.Infof("applying cronjob %s", gist.Name)
+		return client.CronJob().Apply(&gist.CronJob)
+	case bitesize.TypeSecret:
+		log.Infof("applying secret %s", gist.Name)
+		return client.Secret().Apply(&gist.Secret)
+	}
+	return nil
+}
+
 // ApplyService applies a single service to the namespace
 func (cluster *Cluster) ApplyService(service *bitesize.Service, gists *bitesize.Gists, namespace string) error {
 	var err error
+
+	// outputs are resolved by ApplyIfChanged, but not for services deployed
+	// through the API or copied to blue/green and canary deployments
+	if len(service.UnresolvedOutputs()) > 0 {
+		cluster.resolveOutputs(*service, nil, namespace)
+	}
+	if outputs := service.UnresolvedOutputs(); len(outputs) > 0 {
+		log.Infof("holding back service %s until outputs %s exist", service.Name, strings.Join(outputs, ", "))
+		return nil
+	}
+
 	mapper := &translator.KubeMapper{
 		BiteService: service,
 		Namespace:   namespace,
@@ -120,27 +211,50 @@ func (cluster *Cluster) ApplyService(service *bitesize.Service, gists *bitesize.
 	}
 
 	client := &k8s.Client{
-		Interface: cluster.Interface,
-		Namespace: namespace,
-		CRDClient: cluster.CRDClient,
+		Interface:      cluster.Interface,
+		Namespace:      namespace,
+		CRDClient:      cluster.CRDClient,
+		IngressVersion: cluster.IngressVersion,
 	}
 
 	// if no type specified, deploy:
 	//  - PersistentVolumeClaims()
 	//  - ConfigMaps()
-	//  - Deployment()
+	//  - Deployment() or StatefulSet()
 	//  - Service()
-	//  - HPA()
+	//  - NetworkPolicy()
+	//  - HPA(), or ScaledObject() if keda is set
+	//  - if Istio enabled:
+	//     - DestinationRule
 	//
 	// if ExternalURL is set, also deploy:
 	//  - Ingress()
 	//  - if ExternalSecretsEnabled
 	//     - ExternalSecrets
+	//  - if canary deployment method without Istio:
+	//     - CanaryIngress()
 	//  - If Istio enabled:
 	//     - ExternalSecret
 	//     - Gateway
 	//     - VirtualService
-	if service.Type == "" {
+	//
+	// if kind is set, deploy the custom resource as declared
+	//
+	// if service_type is ExternalName, deploy only:
+	//  - Service()
+	if service.IsCustomResource() {
+		if err = cluster.applyCustomResource(mapper); err != nil {
+			log.Error(err)
+		} else {
+			log.Infof("successfully updated %s resource: %s", service.Kind, service.Name)
+		}
+	} else if service.IsExternalName() {
+		svc, _ := mapper.Service()
+		if err = client.Service().Apply(svc); err != nil {
+			log.Error(err)
+			log.Debugf("service +%v", svc)
+		}
+	} else if service.Type == "" {
 		log.Debugf("applying pvcs for service %s", service.Name)
 		pvc, _ := mapper.PersistentVolumeClaims()
 		for _, claim := range pvc {
@@ -159,7 +273,7 @@ func (cluster *Cluster) ApplyService(service *bitesize.Service, gists *bitesize.
 			}
 		}
 
-		log.Debugf("applying deployment for service %s", service.Name)
+		log.Debugf("applying workload for service %s", service.Name)
 		deployment, err := mapper.Deployment()
 		if err != nil {
 			log.Error(err)
@@ -170,17 +284,44 @@ func (cluster *Cluster) ApplyService(service *bitesize.Service, gists *bitesize.
 			log.Error(err)
 		}
 
+		statefulset, err := mapper.StatefulSet()
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+
+		if err = client.StatefulSet().Apply(statefulset); err != nil {
+			log.Error(err)
+		}
+
 		svc, _ := mapper.Service()
 		if err = client.Service().Apply(svc); err != nil {
 			log.Error(err)
 			log.Debugf("service +%v", svc)
 		}
 
+		netpol, _ := mapper.NetworkPolicy()
+		if err = client.NetworkPolicy().Apply(netpol); err != nil {
+			log.Error(err)
+		}
+
 		hpa, _ := mapper.HPA()
 		if err = client.HorizontalPodAutoscaler().Apply(hpa); err != nil {
 			log.Error(err)
 		}
 
+		if service.HasKeda() {
+			if err := cluster.applyScaledObject(mapper, *client); err != nil {
+				log.Error(err)
+			}
+		}
+
+		if service.IsServiceMeshEnabled() {
+			if err := cluster.applyDestinationRule(mapper, *client); err != nil {
+				log.Error(err)
+			}
+		}
+
 		if service.HasExternalURL() {
 
 			log.Debugf("applying ingress for service %s", service.Name)
@@ -189,19 +330,20 @@ func (cluster *Cluster) ApplyService(service *bitesize.Service, gists *bitesize.
 				log.Error(err)
 			}
 
-			if k8s.ExternalSecretsEnabled {
-				log.Debugf("applying external secret for ingress %s", service.Name)
-				if err := createExternalSecret(mapper, *client, ""); err != nil {
-					log.Error("Failed to create ExternalSecret")
+			if err := cluster.applyTLS(mapper, *client, ""); err != nil {
+				log.Error(err)
+			}
+
+			if service.IsCanaryParentDeployment() && !service.IsServiceMeshEnabled() {
+				if err := cluster.applyCanaryIngress(mapper, *client); err != nil {
+					log.Error(err)
 				}
 			}
 
 			if service.IsServiceMeshEnabled() {
 
-				if k8s.ExternalSecretsEnabled {
-					if err := createExternalSecret(mapper, *client, "istio-system"); err != nil {
-						log.Error("Failed to create ExternalSecret")
-					}
+				if err := cluster.applyTLS(mapper, *client, "istio-system"); err != nil {
+					log.Error(err)
 				}
 
 				client.CRDClient, err = k8s.CRDClient(&schema.GroupVersion{
@@ -214,14 +356,14 @@ func (cluster *Cluster) ApplyService(service *bitesize.Service, gists *bitesize.
 				}
 
 				gateway, _ := mapper.ServiceMeshGateway()
-				if err = client.CustomResourceDefinition("Gateway").Apply(gateway); err != nil {
+				if err = client.CustomResourceDefinition(k8_extensions.GatewayKind).Apply(gateway); err != nil {
 					log.Error(err)
 				} else {
 					log.Infof("Successfully updated Gateway CRD resource: %s", gateway.Name)
 				}
 
 				virtualService, _ := mapper.ServiceMeshVirtualService()
-				if err = client.CustomResourceDefinition("VirtualService").Apply(virtualService); err != nil {
+				if err = client.CustomResourceDefinition(k8_extensions.VirtualServiceKind).Apply(virtualService); err != nil {
 					log.Error(err)
 				} else {
 					log.Infof("Successfully updated VirtualService CRD resource: %s", gateway.Name)
@@ -232,7 +374,7 @@ func (cluster *Cluster) ApplyService(service *bitesize.Service, gists *bitesize.
 	} else {
 		crd, _ := mapper.CustomResourceDefinition()
 
-		client.CRDClient, err = k8s.CRDClient(&schema.GroupVersion{
+		client.CRDClient, err = cluster.CRDClientFor(schema.GroupVersion{
 			Group:   strings.Split(crd.TypeMeta.APIVersion, "/")[0],
 			Version: strings.Split(crd.TypeMeta.APIVersion, "/")[1],
 		})
@@ -288,9 +430,10 @@ func (cluster *Cluster) ScrapeResourcesForNamespace(namespace string) (*bitesize
 	serviceMap := make(ServiceMap)
 
 	client := &k8s.Client{
-		Namespace: namespace,
-		Interface: cluster.Interface,
-		CRDClient: cluster.CRDClient,
+		Namespace:      namespace,
+		Interface:      cluster.Interface,
+		CRDClient:      cluster.CRDClient,
+		IngressVersion: cluster.IngressVersion,
 	}
 
 	ns, err := client.Ns().Get()
@@ -315,6 +458,14 @@ func (cluster *Cluster) ScrapeResourcesForNamespace(namespace string) (*bitesize
 		serviceMap.AddDeployment(deployment)
 	}
 
+	statefulsets, err := client.StatefulSet().List()
+	if err != nil {
+		log.Errorf("error loading kubernetes statefulsets: %s", err.Error())
+	}
+	for _, statefulset := range statefulsets {
+		serviceMap.AddStatefulSet(statefulset)
+	}
+
 	hpas, err := client.HorizontalPodAutoscaler().List()
 	if err != nil {
 		log.Errorf("error loading kubernetes hpas: %s", err.Error())
@@ -323,6 +474,8 @@ func (cluster *Cluster) ScrapeResourcesForNamespace(namespace string) (*bitesize
 		serviceMap.AddHPA(hpa)
 	}
 
+	cluster.scrapeScaledObjects(*client, serviceMap)
+
 	ingresses, err := client.Ingress().List()
 	if err != nil {
 		log.Errorf("error loading kubernetes ingresses: %s", err.Error())
@@ -332,6 +485,21 @@ func (cluster *Cluster) ScrapeResourcesForNamespace(namespace string) (*bitesize
 		serviceMap.AddIngress(ingress)
 	}
 
+	cluster.scrapeTLS(*client, serviceMap)
+
+	environmentNetworkPolicy := bitesize.NetworkPolicySettings{}
+	policies, err := client.NetworkPolicy().List()
+	if err != nil {
+		log.Errorf("error loading kubernetes network policies: %s", err.Error())
+	}
+	for _, policy := range policies {
+		if policy.Name == translator.DefaultDenyPolicyName {
+			environmentNetworkPolicy.DefaultDeny = true
+			continue
+		}
+		serviceMap.AddNetworkPolicy(policy)
+	}
+
 	// we'll need the same for tprs
 	claims, _ := client.PVC().List()
 	for _, claim := range claims {
@@ -360,6 +528,8 @@ func (cluster *Cluster) ScrapeResourcesForNamespace(namespace string) (*bitesize
 		}
 	}
 
+	cluster.scrapeCustomResources(serviceMap, namespace)
+
 	// Handle imported resources
 	gistMap := GistMap{}
 
@@ -368,11 +538,36 @@ func (cluster *Cluster) ScrapeResourcesForNamespace(namespace string) (*bitesize
 		gistMap.AddConfigMap(config)
 	}
 
+	jobs, err := client.Job().List()
+	if err != nil {
+		log.Errorf("error loading kubernetes jobs: %s", err.Error())
+	}
+	for _, job := range jobs {
+		gistMap.AddJob(job)
+	}
+
+	cronjobs, err := client.CronJob().List()
+	if err != nil {
+		log.Errorf("error loading kubernetes cronjobs: %s", err.Error())
+	}
+	for _, cronjob := range cronjobs {
+		gistMap.AddCronJob(cronjob)
+	}
+
+	secrets, err := client.Secret().List()
+	if err != nil {
+		log.Errorf("error loading kubernetes secrets: %s", err.Error())
+	}
+	for _, secret := range secrets {
+		gistMap.AddSecret(secret)
+	}
+
 	bitesizeConfig := bitesize.Environment{
-		Name:      environmentName,
-		Namespace: namespace,
-		Services:  serviceMap.Services(),
-		Gists:     gistMap.Gists(),
+		Name:          environmentName,
+		Namespace:     namespace,
+		NetworkPolicy: environmentNetworkPolicy,
+		Services:      serviceMap.Services(),
+		Gists:         gistMap.Gists(),
 	}
 
 	return &bitesizeConfig, nil
@@ -399,6 +594,16 @@ func shouldDeployOnChange(currentEnvironment, newEnvironment *bitesize.Environme
 		return true
 	}
 
+	if updatedService.IsCustomResource() {
+		log.Debugf("should deploy custom resource %s", serviceName)
+		return true
+	}
+
+	if updatedService.IsExternalName() {
+		log.Debugf("should deploy ExternalName service %s", serviceName)
+		return true
+	}
+
 	if currentService != nil && currentService.Status.DeployedAt != "" {
 		log.Tracef("Detected existing deployment")
 		return true
@@ -410,36 +615,3 @@ func shouldDeployOnChange(currentEnvironment, newEnvironment *bitesize.Environme
 	}
 	return false
 }
-
-func createExternalSecret(mapper *translator.KubeMapper, client k8s.Client, ns string) error {
-
-	es, err := mapper.ExternalSecretTLS()
-	if err != nil {
-		log.Errorf("Error creating external secret CRD for ingress: %s", err.Error())
-		return err
-	}
-
-	if ns != "" {
-		es.Namespace = ns
-		client.Namespace = ns
-	}
-
-	client.CRDClient, err = k8s.CRDClient(&schema.GroupVersion{
-		Group:   "kubernetes-client.io",
-		Version: "v1",
-	})
-
-	if err != nil {
-		log.Errorf("Error creating kubernetes client for External Secrets use: %s", err.Error())
-		return err
-	}
-
-	if err = client.ExternalSecret().Apply(es); err != nil {
-		log.Fatalf("Error creating external secret CRD for ingress: %s", err.Error())
-		return err
-	} else {
-		log.Infof("Successfully updated ExternalSecret CRD resource: %s", es.Name)
-	}
-
-	return nil
-}
@@ -1,14 +1,20 @@
 package cluster
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
 	"github.com/pearsontechnology/environment-operator/pkg/config"
 	"github.com/pearsontechnology/environment-operator/pkg/diff"
 	ext "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
 	"github.com/pearsontechnology/environment-operator/pkg/util"
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
 	fakecrd "github.com/pearsontechnology/environment-operator/pkg/util/k8s/fake"
 	apps_v1 "k8s.io/api/apps/v1"
 	autoscale_v2beta2 "k8s.io/api/autoscaling/v2beta2"
@@ -16,6 +22,10 @@ import (
 	netwk_v1beta1 "k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
 	fakerest "k8s.io/client-go/rest/fake"
 )
@@ -558,7 +568,7 @@ func testServicePorts(t *testing.T) {
 	}
 
 	svc := environment.Services.FindByName("test")
-	if !util.EqualArrays(svc.Ports, []int{80, 8081}) {
+	if !util.EqualArrays(svc.Ports.Numbers(), []int{80, 8081}) {
 		t.Errorf("Ports not equal. Expected: [80 8081], got: %v", svc.Ports)
 	}
 }
@@ -808,6 +818,1118 @@ func TestApplyExistingHPA(t *testing.T) {
 		t.Errorf("Expected loaded environments to be equal, yet diff is: %s", diff.Changes())
 	}
 }
+
+func TestApplyHPAMetrics(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-hpa",
+				Labels: map[string]string{
+					"environment": "environment-hpa",
+				},
+			},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: loadEmptyCRDs(),
+	}
+
+	e1, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment33")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	hpa, err := client.AutoscalingV2beta2().HorizontalPodAutoscalers("environment-hpa").Get("worker", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if len(hpa.Spec.Metrics) != 5 {
+		t.Errorf("Expected 5 hpa metrics, got %+v", hpa.Spec.Metrics)
+	}
+
+	e2, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment33")
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-hpa")
+	if diff.Compare(*e2, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+}
+
+func TestApplyScaledObject(t *testing.T) {
+	minReplicas := int32(1)
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-keda",
+				Labels: map[string]string{
+					"environment": "environment-keda",
+				},
+			},
+		},
+		// hpa managed by KEDA, labelled as its ScaledObject
+		&autoscale_v2beta2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "keda-hpa-sqs-consumer",
+				Namespace: "environment-keda",
+				Labels: map[string]string{
+					"creator": "pipeline",
+					"name":    "sqs-consumer",
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: ext.ScaledObjectAPIVersion, Kind: "ScaledObject", Name: "sqs-consumer"},
+				},
+			},
+			Spec: autoscale_v2beta2.HorizontalPodAutoscalerSpec{MinReplicas: &minReplicas, MaxReplicas: 20},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: fakecrd.ScaledObjectClient(),
+	}
+
+	e1, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment34")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	scaledObjects, err := (&k8s.ScaledObject{Interface: cluster.CRDClient, Namespace: "environment-keda"}).List()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if len(scaledObjects) != 2 {
+		t.Fatalf("Expected 2 scaled objects, got: %+v", scaledObjects)
+	}
+	for _, so := range scaledObjects {
+		if so.Name == "kafka-consumer" && so.Spec.ScaleTargetRef.Kind != "StatefulSet" {
+			t.Errorf("Unexpected scale target: %+v", so.Spec.ScaleTargetRef)
+		}
+	}
+	if _, err := client.AutoscalingV2beta2().HorizontalPodAutoscalers("environment-keda").Get("sqs-consumer", metav1.GetOptions{}); err == nil {
+		t.Error("Expected no hpa for keda scaled service")
+	}
+
+	e2, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment34")
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-keda")
+	if diff.Compare(*e2, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+	if svc := current.Services.FindByName("keda-hpa-sqs-consumer"); svc != nil {
+		t.Errorf("Expected hpa managed by KEDA not to be scraped as a service, got: %+v", svc)
+	}
+}
+
 func loadEmptyCRDs() *fakerest.RESTClient {
 	return fakecrd.CRDClient("prsn.io", "v1")
 }
+
+func TestApplyStatefulSet(t *testing.T) {
+	crdcli := loadEmptyCRDs()
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-stateful",
+				Labels: map[string]string{
+					"environment": "environment-stateful",
+				},
+			},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: crdcli,
+	}
+
+	e1, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment20")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	if err = cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	if _, err := client.AppsV1().Deployments("environment-stateful").Get("kafka-consumer", metav1.GetOptions{}); err == nil {
+		t.Error("Expected no deployment for statefulset service")
+	}
+
+	svc, err := client.CoreV1().Services("environment-stateful").Get("kafka-consumer", metav1.GetOptions{})
+	if err != nil || svc.Spec.ClusterIP != v1.ClusterIPNone {
+		t.Errorf("Expected headless service, got: %+v, err: %v", svc, err)
+	}
+
+	if claims, _ := client.CoreV1().PersistentVolumeClaims("environment-stateful").List(metav1.ListOptions{}); len(claims.Items) != 0 {
+		t.Errorf("Expected no standalone pvcs, got: %+v", claims.Items)
+	}
+
+	e2, err := cluster.ScrapeResourcesForNamespace("environment-stateful")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	if diff.Compare(*e1, *e2) {
+		t.Errorf("Expected loaded environments to be equal, yet diff is: %s", diff.Changes())
+	}
+
+	// volume claim templates are immutable, so the statefulset is recreated
+	e3, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment20")
+	e3.Services[0].Volumes[0].Size = "20G"
+	if err = cluster.ApplyIfChanged(e3); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	statefulset, err := client.AppsV1().StatefulSets("environment-stateful").Get("kafka-consumer", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if size := statefulset.Spec.VolumeClaimTemplates[0].Labels["size"]; size != "20G" {
+		t.Errorf("Expected claim template size 20G, got %s", size)
+	}
+
+	e4, err := cluster.ScrapeResourcesForNamespace("environment-stateful")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if diff.Compare(*e3, *e4) {
+		t.Errorf("Expected no changes after volume change, got: %s", diff.Changes())
+	}
+}
+
+func TestApplyJobGist(t *testing.T) {
+	crdcli := loadEmptyCRDs()
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-jobs",
+				Labels: map[string]string{
+					"environment": "environment-jobs",
+				},
+			},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: crdcli,
+	}
+
+	job := bitesize.Gist{Name: "migrate", Path: "../../test/assets/k8s/migrate-job.bitesize", Type: bitesize.TypeJob}
+	cron := bitesize.Gist{Name: "cleanup", Path: "../../test/assets/k8s/cleanup-cronjob.bitesize", Type: bitesize.TypeCronJob}
+	for _, g := range []*bitesize.Gist{&job, &cron} {
+		if err := bitesize.LoadResource(g, "environment-jobs", ""); err != nil {
+			t.Fatalf("Unexpected err: %s", err.Error())
+		}
+	}
+
+	e1 := &bitesize.Environment{
+		Name:      "environment-jobs",
+		Namespace: "environment-jobs",
+		Gists:     bitesize.Gists{cron, job},
+	}
+
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	e2, err := cluster.ScrapeResourcesForNamespace("environment-jobs")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	if len(e2.Gists) != 2 {
+		t.Fatalf("Expected job and cronjob gists, got: %+v", e2.Gists)
+	}
+
+	if diff.Compare(*e1, *e2) {
+		t.Errorf("Expected loaded environments to be equal, yet diff is: %s", diff.Changes())
+	}
+
+	// changed job content is re-run
+	job.Job.Spec.Template.Spec.Containers[0].Command = []string{"migrate", "--again"}
+	job.Job.Annotations[bitesize.GistHashAnnotation] = "changed"
+	e1.Gists = bitesize.Gists{cron, job}
+
+	if !diff.Compare(*e1, *e2) || !diff.GistChanged("migrate") {
+		t.Fatalf("Expected job change to be detected")
+	}
+
+	if err := cluster.ApplyEnvironment(e2, e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	j, err := client.BatchV1().Jobs("environment-jobs").Get("migrate", metav1.GetOptions{})
+	if err != nil || j.Annotations[bitesize.GistHashAnnotation] != "changed" {
+		t.Errorf("Expected job to be replaced, got: %+v, err: %v", j, err)
+	}
+}
+
+func TestApplySecretGist(t *testing.T) {
+	config.Env.SopsAgeKeyFile = "../../test/assets/sops/age-key.txt"
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-secrets",
+				Labels: map[string]string{
+					"environment": "environment-secrets",
+				},
+			},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: loadEmptyCRDs(),
+	}
+
+	secret := bitesize.Gist{Name: "db", Path: "../../test/assets/sops/secret.age.yaml", Type: bitesize.TypeSecret}
+	if err := bitesize.LoadResource(&secret, "environment-secrets", ""); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	e1 := &bitesize.Environment{
+		Name:      "environment-secrets",
+		Namespace: "environment-secrets",
+		Gists:     bitesize.Gists{secret},
+	}
+
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	s, err := client.CoreV1().Secrets("environment-secrets").Get("db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected secret to be created, got: %s", err.Error())
+	}
+	if s.StringData["password"] != "s3cr3t-p4ss" {
+		t.Errorf("Expected decrypted secret data, got: %v", s.StringData)
+	}
+
+	e2, err := cluster.ScrapeResourcesForNamespace("environment-secrets")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	if diff.Compare(*e1, *e2) {
+		t.Errorf("Expected loaded environments to be equal, yet diff is: %s", diff.Changes())
+	}
+}
+
+func TestApplyConfigHash(t *testing.T) {
+	localPath := config.Env.GitLocalPath
+	config.Env.GitLocalPath = "../.."
+	defer func() { config.Env.GitLocalPath = localPath }()
+
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-config",
+				Labels: map[string]string{
+					"environment": "environment-config",
+				},
+			},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "api-credentials",
+				Namespace: "environment-config",
+			},
+			Data: map[string][]byte{"token": []byte("abc")},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: loadEmptyCRDs(),
+	}
+
+	configHash := func() string {
+		d, err := client.AppsV1().Deployments("environment-config").Get("api", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err.Error())
+		}
+		return d.Spec.Template.Annotations[bitesize.ConfigHashAnnotation]
+	}
+
+	e1, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment21")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	initial := configHash()
+	if initial == "" {
+		t.Fatal("Expected config hash annotation on pod template")
+	}
+
+	// unchanged config is not re-applied
+	e2, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment21")
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-config")
+	cluster.setConfigHashes(e2)
+	if diff.Compare(*e2, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+
+	// configmap gist change rolls the deployment
+	e3, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment21")
+	e3.Gists[0].ConfigMap.Data["data-2"] = "changed"
+	if err := cluster.ApplyIfChanged(e3); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	afterConfigMap := configHash()
+	if afterConfigMap == initial {
+		t.Error("Expected config hash to change with configmap content")
+	}
+
+	// secret change rolls the deployment
+	client.CoreV1().Secrets("environment-config").Update(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "api-credentials",
+			Namespace: "environment-config",
+		},
+		Data: map[string][]byte{"token": []byte("def")},
+	})
+	if err := cluster.ApplyIfChanged(e3); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if configHash() == afterConfigMap {
+		t.Error("Expected config hash to change with secret content")
+	}
+}
+
+func TestApplyIngressClass(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-ingress",
+				Labels: map[string]string{
+					"environment": "environment-ingress",
+				},
+			},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: loadEmptyCRDs(),
+	}
+
+	e1, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment22")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	// fake clientset does not serve networking/v1, class is set with annotation
+	ingress, err := client.NetworkingV1beta1().Ingresses("environment-ingress").Get("admin", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if ingress.Annotations["kubernetes.io/ingress.class"] != "nginx-internal" {
+		t.Errorf("Expected ingress class nginx-internal, got %v", ingress.Annotations)
+	}
+
+	e2, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment22")
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-ingress")
+	if svc := current.Services.FindByName("frontend"); svc == nil || svc.IngressClass != "nginx" {
+		t.Errorf("Expected scraped ingress class nginx, got %+v", svc)
+	}
+	if diff.Compare(*e2, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+}
+
+func TestApplyExternalRoutes(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-routes",
+				Labels: map[string]string{
+					"environment": "environment-routes",
+				},
+			},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: loadEmptyCRDs(),
+	}
+
+	e1, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment23")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	ingress, err := client.NetworkingV1beta1().Ingresses("environment-routes").Get("frontend", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if paths := ingress.Spec.Rules[0].HTTP.Paths; len(paths) != 3 || paths[1].Backend.ServiceName != "api" {
+		t.Errorf("Unexpected ingress paths: %+v", paths)
+	}
+
+	e2, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment23")
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-routes")
+	if diff.Compare(*e2, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+}
+
+func TestApplyIngressProfile(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-profile",
+				Labels: map[string]string{
+					"environment": "environment-profile",
+				},
+			},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: loadEmptyCRDs(),
+	}
+
+	e1, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment24")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	ingress, err := client.NetworkingV1beta1().Ingresses("environment-profile").Get("frontend", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	expected := map[string]string{
+		"nginx.ingress.kubernetes.io/ssl-redirect":           "true",
+		"nginx.ingress.kubernetes.io/proxy-read-timeout":     "120",
+		"nginx.ingress.kubernetes.io/proxy-send-timeout":     "120",
+		"nginx.ingress.kubernetes.io/proxy-body-size":        "10m",
+		"nginx.ingress.kubernetes.io/whitelist-source-range": "10.0.0.0/8",
+		"nginx.ingress.kubernetes.io/rewrite-target":         "/",
+		bitesize.IngressAnnotationsAnnotation:                "nginx.ingress.kubernetes.io/rewrite-target",
+	}
+	if !reflect.DeepEqual(ingress.Annotations, expected) {
+		t.Errorf("Unexpected ingress annotations: %v", ingress.Annotations)
+	}
+
+	// annotations added outside of the environment config are not changes
+	ingress.Annotations["meta.helm.sh/release-name"] = "frontend"
+	ingress.Annotations["field.cattle.io/publicEndpoints"] = "[]"
+	if _, err := client.NetworkingV1beta1().Ingresses("environment-profile").Update(ingress); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	e2, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment24")
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-profile")
+	if diff.Compare(*e2, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+}
+
+func TestApplyServiceTypes(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-service-types",
+				Labels: map[string]string{
+					"environment": "environment-service-types",
+				},
+			},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: loadEmptyCRDs(),
+	}
+
+	e1, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment32")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	mqtt, err := client.CoreV1().Services("environment-service-types").Get("mqtt", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if mqtt.Spec.Type != v1.ServiceTypeLoadBalancer || mqtt.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyTypeLocal ||
+		!reflect.DeepEqual(mqtt.Spec.LoadBalancerSourceRanges, []string{"10.0.0.0/8"}) {
+		t.Errorf("Unexpected mqtt service spec: %+v", mqtt.Spec)
+	}
+	if mqtt.Annotations["service.beta.kubernetes.io/aws-load-balancer-type"] != "nlb" {
+		t.Errorf("Unexpected mqtt service annotations: %v", mqtt.Annotations)
+	}
+
+	alias, err := client.CoreV1().Services("environment-service-types").Get("legacy-db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if alias.Spec.Type != v1.ServiceTypeExternalName || alias.Spec.ExternalName != "db.legacy.example.com" || alias.Spec.Selector != nil {
+		t.Errorf("Unexpected legacy-db service spec: %+v", alias.Spec)
+	}
+	if _, err := client.AppsV1().Deployments("environment-service-types").Get("legacy-db", metav1.GetOptions{}); err == nil {
+		t.Error("Expected no deployment for ExternalName service legacy-db")
+	}
+
+	e2, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment32")
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-service-types")
+	if diff.Compare(*e2, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+}
+
+func TestApplyCertificate(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-tls",
+				Labels: map[string]string{
+					"environment": "environment-tls",
+				},
+			},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: fakecrd.CertificateClient(),
+	}
+
+	e1, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment25")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	certs, err := (&k8s.Certificate{Interface: cluster.CRDClient, Namespace: "environment-tls"}).List()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if len(certs) != 1 || certs[0].Name != "frontend" {
+		t.Fatalf("Expected frontend certificate, got: %+v", certs)
+	}
+	if certs[0].Spec.IssuerRef.Name != "letsencrypt" || certs[0].Spec.IssuerRef.Kind != "ClusterIssuer" {
+		t.Errorf("Unexpected certificate issuer: %+v", certs[0].Spec.IssuerRef)
+	}
+
+	e2, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment25")
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-tls")
+	if diff.Compare(*e2, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+}
+
+func TestScrapeTLSSecret(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-tls",
+				Labels: map[string]string{
+					"environment": "environment-tls",
+				},
+			},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: loadEmptyCRDs(),
+	}
+
+	e1, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment25")
+	for i := range e1.Services {
+		if e1.Services[i].TLS != nil {
+			e1.Services[i].TLS = &bitesize.TLSSettings{Provider: bitesize.TLSProviderSecret, SecretName: "wildcard-tls"}
+		}
+	}
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-tls")
+	frontend := current.Services.FindByName("frontend")
+	if frontend.TLSProvider() != bitesize.TLSProviderSecret || frontend.TLSSecretName() != "wildcard-tls" {
+		t.Errorf("Unexpected frontend tls settings: %+v", frontend.TLS)
+	}
+}
+
+func TestCanaryLifecycle(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-canary",
+				Labels: map[string]string{
+					"environment": "environment-canary",
+				},
+			},
+		},
+	)
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: loadEmptyCRDs(),
+	}
+
+	started := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	analysis := 0.0
+	defer func(n func() time.Time, q func(string, string) (float64, error)) {
+		now, canaryQuery = n, q
+	}(now, canaryQuery)
+	now = func() time.Time { return started }
+	var queryErr error
+	canaryQuery = func(endpoint, query string) (float64, error) { return analysis, queryErr }
+
+	e, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment26")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	frontend := *e.Services.FindByName("frontend")
+	canary := *e.Services.FindByName("frontend-canary")
+	deploy := func(version string) {
+		frontend.Version, canary.Version = version, version
+		if err := cluster.DeployCanary(frontend, canary, &bitesize.Gists{}, "environment-canary"); err != nil {
+			t.Fatalf("Unexpected err: %s", err.Error())
+		}
+		// fake clientset doesn't roll out pods
+		if d, err := client.AppsV1().Deployments("environment-canary").Get("frontend-canary", metav1.GetOptions{}); err == nil {
+			d.Status = apps_v1.DeploymentStatus{Replicas: 1, AvailableReplicas: 1, UpdatedReplicas: 1}
+			client.AppsV1().Deployments("environment-canary").Update(d)
+		}
+	}
+	canaryWeight := func() string {
+		ingress, err := client.NetworkingV1beta1().Ingresses("environment-canary").Get("frontend-canary", metav1.GetOptions{})
+		if err != nil {
+			return ""
+		}
+		return ingress.Annotations["nginx.ingress.kubernetes.io/canary-weight"]
+	}
+	stableVersion := func() string {
+		d, _ := client.AppsV1().Deployments("environment-canary").Get("frontend", metav1.GetOptions{})
+		return d.Labels["version"]
+	}
+	canaryExists := func() bool {
+		_, errD := client.AppsV1().Deployments("environment-canary").Get("frontend-canary", metav1.GetOptions{})
+		_, errS := client.CoreV1().Services("environment-canary").Get("frontend-canary", metav1.GetOptions{})
+		return errD == nil || errS == nil
+	}
+	progress := func(e *bitesize.Environment) {
+		current, err := cluster.ScrapeResourcesForNamespace("environment-canary")
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err.Error())
+		}
+		cluster.ProgressCanaries(e, current)
+	}
+
+	// first version is deployed without canary
+	deploy("1.0")
+	if stableVersion() != "1.0" || canaryWeight() != "" {
+		t.Fatalf("Expected version 1.0 without canary, got %s with weight %q", stableVersion(), canaryWeight())
+	}
+
+	deploy("2.0")
+	if stableVersion() != "1.0" || canaryWeight() != "20" {
+		t.Fatalf("Expected 20%% of traffic to canary, got %q", canaryWeight())
+	}
+
+	// canary state survives environment changes
+	cluster.ApplyIfChanged(e)
+	e2, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment26")
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-canary")
+	if diff.Compare(*e2, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+	if canaryWeight() != "20" {
+		t.Errorf("Expected 20%% of traffic to canary after apply, got %q", canaryWeight())
+	}
+
+	// step interval not elapsed
+	progress(e)
+	if canaryWeight() != "20" {
+		t.Errorf("Expected 20%% of traffic to canary, got %q", canaryWeight())
+	}
+
+	// failing analysis query holds the canary at its step
+	now = func() time.Time { return started.Add(time.Minute) }
+	queryErr = errors.New("connection refused")
+	progress(e)
+	if canaryWeight() != "20" || !canaryExists() {
+		t.Errorf("Expected canary held at 20%% of traffic, got %q", canaryWeight())
+	}
+	if _, err := cluster.AdvanceCanary(frontend, "environment-canary"); err == nil {
+		t.Error("Expected error advancing canary without analysis")
+	}
+
+	queryErr = nil
+	progress(e)
+	if canaryWeight() != "50" {
+		t.Errorf("Expected 50%% of traffic to canary, got %q", canaryWeight())
+	}
+
+	state, err := cluster.AdvanceCanary(frontend, "environment-canary")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if state.Status != bitesize.CanaryPromoted || stableVersion() != "2.0" || canaryWeight() != "" {
+		t.Errorf("Expected canary 2.0 promoted, got %s stable version %s", state.Status, stableVersion())
+	}
+	if canaryExists() {
+		t.Error("Expected canary deployment and service to be deleted after promotion")
+	}
+
+	// failed analysis aborts canary
+	deploy("3.0")
+	analysis = 1
+	progress(e)
+	current, _ = cluster.ScrapeResourcesForNamespace("environment-canary")
+	if s := current.Services.FindByName("frontend").CanaryState(); s == nil || s.Status != bitesize.CanaryAborted {
+		t.Errorf("Expected aborted canary, got %+v", s)
+	}
+	if stableVersion() != "2.0" || canaryWeight() != "" {
+		t.Errorf("Expected stable version 2.0 without canary, got %s with weight %q", stableVersion(), canaryWeight())
+	}
+	if canaryExists() {
+		t.Error("Expected canary deployment and service to be deleted after abort")
+	}
+
+	// aborted version set in the config is not released again
+	analysis = 0
+	withVersion := func(version string) *bitesize.Environment {
+		e, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment26")
+		for i := range e.Services {
+			e.Services[i].Version = version
+		}
+		return e
+	}
+	if err := cluster.ApplyIfChanged(withVersion("3.0")); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if stableVersion() != "2.0" || canaryExists() {
+		t.Errorf("Expected aborted version 3.0 to be kept back, got stable version %s", stableVersion())
+	}
+
+	// versions changed in the config are released to the canary
+	if err := cluster.ApplyIfChanged(withVersion("4.0")); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if stableVersion() != "2.0" || canaryWeight() != "20" {
+		t.Errorf("Expected 4.0 released to 20%% of traffic, got stable version %s with weight %q", stableVersion(), canaryWeight())
+	}
+	current, _ = cluster.ScrapeResourcesForNamespace("environment-canary")
+	if diff.Compare(*withVersion("4.0"), *current) {
+		t.Errorf("Expected no changes while canary is in progress, got: %s", diff.Changes())
+	}
+}
+
+func TestBlueGreenPromote(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-bluegreen",
+				Labels: map[string]string{
+					"environment": "environment-bluegreen",
+				},
+			},
+		},
+	)
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: loadEmptyCRDs(),
+	}
+
+	var probed []string
+	healthy := true
+	defer func(get func(string, time.Duration) (int, error)) { smokeCheckGet = get }(smokeCheckGet)
+	smokeCheckGet = func(url string, timeout time.Duration) (int, error) {
+		probed = append(probed, url)
+		if !healthy {
+			return http.StatusServiceUnavailable, nil
+		}
+		if strings.HasSuffix(url, "/ready") {
+			return http.StatusNoContent, nil
+		}
+		return http.StatusOK, nil
+	}
+
+	load := func() *bitesize.Environment {
+		e, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment27")
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err.Error())
+		}
+		return e
+	}
+	selector := func() string {
+		svc, _ := client.CoreV1().Services("environment-bluegreen").Get("frontend", metav1.GetOptions{})
+		return svc.Spec.Selector["name"]
+	}
+
+	cluster.ApplyIfChanged(load())
+	if selector() != "frontend-blue" {
+		t.Fatalf("Expected frontend-blue to be live, got %s", selector())
+	}
+
+	if _, err := cluster.RollbackBlueGreen(load(), "frontend"); err == nil {
+		t.Error("Expected error rolling back without promotion")
+	}
+
+	healthy = false
+	if _, err := cluster.PromoteBlueGreen(load(), "frontend"); err == nil {
+		t.Error("Expected failed smoke check error")
+	}
+	if selector() != "frontend-blue" {
+		t.Errorf("Expected frontend-blue to stay live, got %s", selector())
+	}
+
+	healthy = true
+	probed = nil
+	svc, err := cluster.PromoteBlueGreen(load(), "frontend")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	expectedProbes := []string{"http://www-green.example.com/healthz", "https://www-green.example.com/ready"}
+	if !reflect.DeepEqual(probed, expectedProbes) {
+		t.Errorf("Expected smoke checks %v, got %v", expectedProbes, probed)
+	}
+	if svc.ActiveDeploymentTag() != bitesize.GreenService || selector() != "frontend-green" {
+		t.Errorf("Expected frontend-green to be live, got %s", selector())
+	}
+
+	// promoted colour survives applying config from git
+	e := load()
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-bluegreen")
+	resolveActiveDeployments(e, current)
+	if diff.Compare(*e, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+	if !e.Services.FindByName("frontend-green").IsActiveBlueGreenDeployment() ||
+		e.Services.FindByName("frontend-blue").IsActiveBlueGreenDeployment() {
+		t.Error("Expected frontend-green to be the active service set")
+	}
+	cluster.ApplyIfChanged(load())
+	if selector() != "frontend-green" {
+		t.Errorf("Expected frontend-green to stay live, got %s", selector())
+	}
+
+	if _, err = cluster.RollbackBlueGreen(load(), "frontend"); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if selector() != "frontend-blue" {
+		t.Errorf("Expected frontend-blue to be live after rollback, got %s", selector())
+	}
+	k8svc, _ := client.CoreV1().Services("environment-bluegreen").Get("frontend", metav1.GetOptions{})
+	if _, ok := k8svc.Annotations["deployment_active_configured"]; ok {
+		t.Errorf("Expected no configured colour annotation after rollback, got %v", k8svc.Annotations)
+	}
+}
+
+func TestCustomResource(t *testing.T) {
+	groups := config.Env.CustomResourceGroups
+	config.Env.CustomResourceGroups = []string{"monitoring.coreos.com"}
+	defer func() { config.Env.CustomResourceGroups = groups }()
+
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-custom",
+				Labels: map[string]string{
+					"environment": "environment-custom",
+				},
+			},
+		},
+	)
+	client.Fake.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "monitoring.coreos.com/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "servicemonitors", Kind: "ServiceMonitor", Namespaced: true, Verbs: []string{"get", "list", "create", "update", "delete"}},
+				{Name: "servicemonitors/status", Kind: "ServiceMonitor", Namespaced: true},
+			},
+		},
+	}
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: fakecrd.UnstructuredClient("monitoring.coreos.com", "v1"),
+		Dynamic:   fakedynamic.NewSimpleDynamicClient(runtime.NewScheme()),
+	}
+
+	e1, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment29")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	monitors := cluster.Dynamic.Resource(schema.GroupVersionResource{
+		Group: "monitoring.coreos.com", Version: "v1", Resource: "servicemonitors",
+	}).Namespace("environment-custom")
+	monitor, err := monitors.Get("api-monitor", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if monitor.GetKind() != "ServiceMonitor" || monitor.GetLabels()["creator"] != "pipeline" {
+		t.Errorf("Unexpected custom resource: %+v", monitor.Object)
+	}
+
+	// fields defaulted by the API server are not changes
+	spec := monitor.Object["spec"].(map[string]interface{})
+	spec["namespaceSelector"] = map[string]interface{}{"any": false}
+	spec["endpoints"].([]interface{})[0].(map[string]interface{})["scheme"] = "http"
+	if _, err := monitors.Update(monitor, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	// resources named after other services, or owned by other resources,
+	// are not custom resource services
+	for _, rsc := range []struct {
+		name   string
+		owners []metav1.OwnerReference
+	}{
+		{"api", nil},
+		{"api-monitor-copy", []metav1.OwnerReference{{APIVersion: "v1", Kind: "Service", Name: "api"}}},
+	} {
+		other := &unstructured.Unstructured{}
+		other.SetAPIVersion("monitoring.coreos.com/v1")
+		other.SetKind("ServiceMonitor")
+		other.SetName(rsc.name)
+		other.SetNamespace("environment-custom")
+		other.SetLabels(map[string]string{"creator": "pipeline"})
+		other.SetOwnerReferences(rsc.owners)
+		if _, err := monitors.Create(other, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Unexpected err: %s", err.Error())
+		}
+	}
+
+	e2, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment29")
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-custom")
+	if diff.Compare(*e2, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+	if svc := current.Services.FindByName("api"); svc == nil || svc.IsCustomResource() {
+		t.Errorf("Expected api not to be scraped as custom resource, got: %+v", svc)
+	}
+	if svc := current.Services.FindByName("api-monitor-copy"); svc != nil {
+		t.Errorf("Expected owned api-monitor-copy not to be scraped, got: %+v", svc)
+	}
+
+	for i := range e2.Services {
+		if e2.Services[i].Name == "api-monitor" {
+			e2.Services[i].Spec["endpoints"] = []interface{}{
+				map[string]interface{}{"port": "tcp-80", "interval": "10s"},
+			}
+		}
+	}
+	if !diff.Compare(*e2, *current) {
+		t.Error("Expected spec change to be detected")
+	}
+
+	config.Env.CustomResourceGroups = nil
+	if err := cluster.DestroyCustomResource(*e2.Services.FindByName("api-monitor"), "environment-custom"); err == nil {
+		t.Error("Expected error for API group not in CUSTOM_RESOURCE_GROUPS")
+	}
+}
+
+func TestServiceOutputs(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "environment-outputs",
+				Labels: map[string]string{
+					"environment": "environment-outputs",
+				},
+			},
+		},
+	)
+
+	cluster := Cluster{
+		Interface: client,
+		CRDClient: fakecrd.UnstructuredClient("prsn.io", "v1"),
+	}
+	databases := k8s.CustomResourceDefinition{Interface: cluster.CRDClient, Namespace: "environment-outputs", Type: "mysql"}
+	deployments := k8s.Deployment{Interface: client, Namespace: "environment-outputs"}
+
+	e1, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment30")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := cluster.ApplyIfChanged(e1); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if !databases.Exist("orders-db") {
+		t.Fatal("Expected orders-db to be created")
+	}
+	if deployments.Exist("api") {
+		t.Fatal("Expected api to be held back until orders-db outputs exist")
+	}
+
+	// outputs written by the controller provisioning the database
+	db, _ := databases.Get("orders-db")
+	db.Kind = "Mysql"
+	db.Status = map[string]interface{}{"endpoint": "orders.db.example.com"}
+	if err := databases.Apply(db); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	client.CoreV1().Secrets("environment-outputs").Create(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-db-outputs", Namespace: "environment-outputs"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	})
+
+	e2, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment30")
+	if err := cluster.ApplyIfChanged(e2); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	deployment, err := deployments.Get("api")
+	if err != nil {
+		t.Fatalf("Expected api to be deployed, got: %s", err.Error())
+	}
+	expected := []v1.EnvVar{
+		{Name: "DB_HOST", Value: "orders.db.example.com"},
+		{Name: "DB_PASSWORD", ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "orders-db-outputs"},
+				Key:                  "password",
+			},
+		}},
+	}
+	if env := deployment.Spec.Template.Spec.Containers[0].Env; !reflect.DeepEqual(env, expected) {
+		t.Errorf("Unexpected api env: %+v", env)
+	}
+
+	e3, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment30")
+	cluster.resolveServiceOutputs(e3)
+	cluster.setConfigHashes(e3)
+	current, _ := cluster.ScrapeResourcesForNamespace("environment-outputs")
+	if diff.Compare(*e3, *current) {
+		t.Errorf("Expected no changes, got: %s", diff.Changes())
+	}
+
+	db.Status = map[string]interface{}{"endpoint": "orders-replica.db.example.com"}
+	databases.Apply(db)
+	cluster.resolveServiceOutputs(e3)
+	if !diff.Compare(*e3, *current) {
+		t.Error("Expected output change to be detected")
+	}
+}
@@ -0,0 +1,75 @@
+package cluster
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	"github.com/pearsontechnology/environment-operator/pkg/util"
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
+)
+
+// setConfigHashes sets ConfigHash of every service in env that runs pods.
+// The hash ends up in the pod template, so a change in any referenced
+// configmap or secret rolls the service.
+func (cluster *Cluster) setConfigHashes(env *bitesize.Environment) {
+	client := &k8s.Client{
+		Interface: cluster.Interface,
+		Namespace: env.Namespace,
+	}
+
+	for i, service := range env.Services {
+		if service.Type != "" || service.IsBlueGreenParentDeployment() {
+			continue
+		}
+		h, err := configHash(client, service, env.Gists)
+		if err != nil {
+			log.Errorf("unable to compute config hash for service %s: %s", service.Name, err.Error())
+			continue
+		}
+		env.Services[i].ConfigHash = h
+	}
+}
+
+// configHash combines content hashes of configmaps and secrets referenced
+// by service. Gists are hashed as loaded from the repository, other objects
+// as they are in the cluster. Secret gists use the hash of their encrypted
+// file. Missing objects are skipped.
+func configHash(client *k8s.Client, service bitesize.Service, gists bitesize.Gists) (string, error) {
+	hashes := map[string]string{}
+
+	for _, name := range service.ConfigMapReferences() {
+		var data interface{}
+		if gist := gists.FindByName(name, bitesize.TypeConfigMap); gist != nil {
+			data = []interface{}{gist.ConfigMap.Data, gist.ConfigMap.BinaryData}
+		} else if cm, err := client.ConfigMap().Get(name); err == nil {
+			data = []interface{}{cm.Data, cm.BinaryData}
+		} else {
+			continue
+		}
+		h, err := util.ContentHash(data)
+		if err != nil {
+			return "", err
+		}
+		hashes["configmap/"+name] = h
+	}
+
+	for _, name := range service.SecretReferences() {
+		if gist := gists.FindByName(name, bitesize.TypeSecret); gist != nil {
+			hashes["secret/"+name] = gist.Hash()
+			continue
+		}
+		secret, err := client.Secret().Get(name)
+		if err != nil {
+			continue
+		}
+		h, err := util.ContentHash(secret.Data)
+		if err != nil {
+			return "", err
+		}
+		hashes["secret/"+name] = h
+	}
+
+	if len(hashes) == 0 {
+		return "", nil
+	}
+	return util.ContentHash(hashes)
+}
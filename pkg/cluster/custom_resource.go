@@ -0,0 +1,139 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	"github.com/pearsontechnology/environment-operator/pkg/config"
+	"github.com/pearsontechnology/environment-operator/pkg/translator"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// customResourceAllowed returns an error unless API group is listed in
+// CUSTOM_RESOURCE_GROUPS
+func customResourceAllowed(group string) error {
+	for _, allowed := range config.Env.CustomResourceGroups {
+		if group != "" && strings.EqualFold(group, strings.TrimSpace(allowed)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("API group %q is not allowed for custom resources, see CUSTOM_RESOURCE_GROUPS", group)
+}
+
+// customResourceClient returns client for resources of kind in apiVersion,
+// with resource name resolved via API discovery
+func (cluster *Cluster) customResourceClient(apiVersion, kind, namespace string) (dynamic.ResourceInterface, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	if err = customResourceAllowed(gv.Group); err != nil {
+		return nil, err
+	}
+
+	resources, err := cluster.Interface.Discovery().ServerResourcesForGroupVersion(apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Kind != kind || strings.Contains(resource.Name, "/") {
+			continue
+		}
+		if !resource.Namespaced {
+			return nil, fmt.Errorf("%s %s is cluster scoped, only namespaced custom resources are supported", apiVersion, kind)
+		}
+		return cluster.Dynamic.Resource(gv.WithResource(resource.Name)).Namespace(namespace), nil
+	}
+	return nil, fmt.Errorf("kind %s not found in %s", kind, apiVersion)
+}
+
+// applyCustomResource applies custom resource declared by the service
+func (cluster *Cluster) applyCustomResource(mapper *translator.KubeMapper) error {
+	svc := mapper.BiteService
+	client, err := cluster.customResourceClient(svc.APIVersion, svc.Kind, mapper.Namespace)
+	if err != nil {
+		return err
+	}
+
+	resource, err := mapper.CustomResource()
+	if err != nil {
+		return err
+	}
+
+	current, err := client.Get(resource.GetName(), metav1.GetOptions{})
+	if err == nil {
+		log.Debugf("Updating %s %s", resource.GetKind(), resource.GetName())
+		resource.SetResourceVersion(current.GetResourceVersion())
+		_, err = client.Update(resource, metav1.UpdateOptions{})
+		return err
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+	log.Debugf("Creating %s %s", resource.GetKind(), resource.GetName())
+	_, err = client.Create(resource, metav1.CreateOptions{})
+	return err
+}
+
+// DestroyCustomResource deletes custom resource declared by the service
+func (cluster *Cluster) DestroyCustomResource(svc bitesize.Service, namespace string) error {
+	client, err := cluster.customResourceClient(svc.APIVersion, svc.Kind, namespace)
+	if err != nil {
+		return err
+	}
+	err = client.Delete(svc.Name, &metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// scrapeCustomResources adds custom resources created by environment
+// operator in allowed API groups to serviceMap. Resources are read in the
+// version preferred by the API server.
+func (cluster *Cluster) scrapeCustomResources(serviceMap ServiceMap, namespace string) {
+	if len(config.Env.CustomResourceGroups) == 0 {
+		return
+	}
+
+	groups, err := cluster.Interface.Discovery().ServerGroups()
+	if err != nil {
+		log.Errorf("error discovering API groups: %s", err.Error())
+		return
+	}
+
+	for _, group := range groups.Groups {
+		if customResourceAllowed(group.Name) != nil {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(group.PreferredVersion.GroupVersion)
+		if err != nil {
+			continue
+		}
+		resources, err := cluster.Interface.Discovery().ServerResourcesForGroupVersion(gv.String())
+		if err != nil {
+			log.Errorf("error discovering %s resources: %s", gv, err.Error())
+			continue
+		}
+
+		for _, resource := range resources.APIResources {
+			if !resource.Namespaced || strings.Contains(resource.Name, "/") {
+				continue
+			}
+			client := cluster.Dynamic.Resource(gv.WithResource(resource.Name)).Namespace(namespace)
+			items, err := client.List(metav1.ListOptions{LabelSelector: "creator=pipeline"})
+			if err != nil {
+				log.Debugf("error listing %s: %s", resource.Name, err.Error())
+				continue
+			}
+			for _, item := range items.Items {
+				serviceMap.AddCustomResource(item)
+			}
+		}
+	}
+}
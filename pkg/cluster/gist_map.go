@@ -27,13 +27,21 @@ func (m GistMap) AddConfigMap(gist v1.ConfigMap) *bitesize.Gist {
 	return m[name]
 }
 
-// AddJob adds imported v1batch.Job Gist to GistMap
+// AddJob adds imported v1batch.Job Gist to GistMap. Jobs spawned by
+// cronjobs are not gists themselves and are skipped (nil is returned).
 func (m GistMap) AddJob(gist v1batch.Job) *bitesize.Gist {
+	for _, owner := range gist.OwnerReferences {
+		if owner.Kind == "CronJob" {
+			return nil
+		}
+	}
+
 	if m[gist.Name] == nil {
 		m[gist.Name] = &bitesize.Gist{
-			Name: gist.Name,
-			Type: bitesize.TypeJob,
-			Job:  gist,
+			Name:   gist.Name,
+			Type:   bitesize.TypeJob,
+			Job:    gist,
+			Status: jobStatus(gist.Status),
 		}
 	}
 	return m[gist.Name]
@@ -51,6 +59,41 @@ func (m GistMap) AddCronJob(gist v1beta1.CronJob) *bitesize.Gist {
 	return m[gist.Name]
 }
 
+// AddSecret adds secret created from an encrypted gist to GistMap. Other
+// secrets in the namespace are skipped (nil is returned). Secret data is
+// not kept, secret gists are compared by their content hash.
+func (m GistMap) AddSecret(gist v1.Secret) *bitesize.Gist {
+	if gist.Labels["creator"] != "pipeline" || gist.Annotations[bitesize.GistHashAnnotation] == "" {
+		return nil
+	}
+
+	gist.Data = nil
+	gist.StringData = nil
+	if m[gist.Name] == nil {
+		m[gist.Name] = &bitesize.Gist{
+			Name:   gist.Name,
+			Type:   bitesize.TypeSecret,
+			Secret: gist,
+		}
+	}
+	return m[gist.Name]
+}
+
+func jobStatus(status v1batch.JobStatus) bitesize.JobStatus {
+	retval := bitesize.JobStatus{
+		Active:    int(status.Active),
+		Succeeded: int(status.Succeeded),
+		Failed:    int(status.Failed),
+	}
+	if status.StartTime != nil {
+		retval.StartedAt = status.StartTime.String()
+	}
+	if status.CompletionTime != nil {
+		retval.CompletedAt = status.CompletionTime.String()
+	}
+	return retval
+}
+
 // Gists extracts a sorted list of bitesize.Gist type out from
 // ImportMap type
 func (m GistMap) Gists() bitesize.Gists {
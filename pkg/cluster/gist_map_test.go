@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	v1batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddJob(t *testing.T) {
+	completed := metav1.Now()
+	gistMap := GistMap{}
+
+	gist := gistMap.AddJob(v1batch.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "migrate"},
+		Status: v1batch.JobStatus{
+			Succeeded:      1,
+			StartTime:      &completed,
+			CompletionTime: &completed,
+		},
+	})
+
+	if gist == nil || !gist.IsComplete() || gist.Status.Succeeded != 1 {
+		t.Errorf("Unexpected job gist: %+v", gist)
+	}
+}
+
+func TestAddJobFromCronJob(t *testing.T) {
+	gistMap := GistMap{}
+
+	gist := gistMap.AddJob(v1batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cleanup-1571234",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "CronJob", Name: "cleanup"},
+			},
+		},
+	})
+
+	if gist != nil || len(gistMap) != 0 {
+		t.Errorf("Expected cronjob spawned job to be skipped, got: %+v", gist)
+	}
+}
+
+func TestAddSecret(t *testing.T) {
+	gistMap := GistMap{}
+
+	gist := gistMap.AddSecret(v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "db",
+			Labels:      map[string]string{"creator": "pipeline"},
+			Annotations: map[string]string{bitesize.GistHashAnnotation: "abc"},
+		},
+		Data: map[string][]byte{"password": []byte("secret")},
+	})
+
+	if gist == nil || gist.Hash() != "abc" {
+		t.Fatalf("Unexpected secret gist: %+v", gist)
+	}
+	if gist.Secret.Data != nil {
+		t.Errorf("Expected secret data to be dropped, got %v", gist.Secret.Data)
+	}
+
+	// secrets not created from gists are skipped
+	other := gistMap.AddSecret(v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "registry",
+			Labels: map[string]string{"creator": "pipeline"},
+		},
+	})
+	if other != nil || len(gistMap) != 1 {
+		t.Errorf("Expected secret without gist hash to be skipped, got: %+v", other)
+	}
+}
@@ -5,14 +5,15 @@ import (
 	"strings"
 
 	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
-	apps_v1 "k8s.io/api/apps/v1"
+	"github.com/pearsontechnology/environment-operator/pkg/translator"
 	v1 "k8s.io/api/core/v1"
+	netwk_v1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func envVars(deployment apps_v1.Deployment) []bitesize.EnvVar {
+func envVars(template v1.PodTemplateSpec) []bitesize.EnvVar {
 	var retval []bitesize.EnvVar
-	for _, e := range deployment.Spec.Template.Spec.Containers[0].Env {
+	for _, e := range template.Spec.Containers[0].Env {
 		var v bitesize.EnvVar
 		// Reserved vars
 		if isReservedEnvVar(e) {
@@ -57,10 +58,10 @@ func isReservedEnvVar(e v1.EnvVar) bool {
 	return false
 }
 
-func healthCheck(deployment apps_v1.Deployment) *bitesize.HealthCheck {
+func healthCheck(template v1.PodTemplateSpec) *bitesize.HealthCheck {
 	var retval *bitesize.HealthCheck
 
-	probe := deployment.Spec.Template.Spec.Containers[0].LivenessProbe
+	probe := template.Spec.Containers[0].LivenessProbe
 	if probe != nil && probe.Exec != nil {
 
 		retval = &bitesize.HealthCheck{
@@ -128,14 +129,14 @@ func convertProbeType(probe *v1.Probe) *bitesize.Probe {
 	return retval
 }
 
-func livenessProbe(deployment apps_v1.Deployment) *bitesize.Probe {
-	probe := deployment.Spec.Template.Spec.Containers[0].LivenessProbe
+func livenessProbe(template v1.PodTemplateSpec) *bitesize.Probe {
+	probe := template.Spec.Containers[0].LivenessProbe
 
 	return convertProbeType(probe)
 }
 
-func readinessProbe(deployment apps_v1.Deployment) *bitesize.Probe {
-	probe := deployment.Spec.Template.Spec.Containers[0].ReadinessProbe
+func readinessProbe(template v1.PodTemplateSpec) *bitesize.Probe {
+	probe := template.Spec.Containers[0].ReadinessProbe
 
 	return convertProbeType(probe)
 }
@@ -174,13 +175,23 @@ func containsAccessMode(modes []v1.PersistentVolumeAccessMode, mode v1.Persisten
 	return false
 }
 
-func volumes(deployment apps_v1.Deployment) []bitesize.Volume {
+func volumeFromClaim(claim v1.PersistentVolumeClaim) bitesize.Volume {
+	return bitesize.Volume{
+		Path:  strings.Replace(claim.ObjectMeta.Labels["mount_path"], "2F", "/", -1),
+		Modes: getAccessModesAsString(claim.Spec.AccessModes),
+		Size:  claim.ObjectMeta.Labels["size"],
+		Name:  claim.ObjectMeta.Name,
+		Type:  claim.ObjectMeta.Labels["type"],
+	}
+}
+
+func volumes(template v1.PodTemplateSpec) []bitesize.Volume {
 	//TODO: implement other volume types
 	var volumes []bitesize.Volume
 
-	volumeMounts := deployment.Spec.Template.Spec.Containers[0].VolumeMounts
+	volumeMounts := template.Spec.Containers[0].VolumeMounts
 	// add ConfigMap volumes to diff
-	for _, v := range deployment.Spec.Template.Spec.Volumes {
+	for _, v := range template.Spec.Volumes {
 		// if ConfigMap volume
 		if v.VolumeSource.ConfigMap != nil {
 			vol := bitesize.Volume{
@@ -229,3 +240,39 @@ func volumes(deployment apps_v1.Deployment) []bitesize.Volume {
 	}
 	return volumes
 }
+
+// networkPeers converts network policy rule peers back to bitesize
+// representation. Rules without peers (e.g. DNS egress) are generated by
+// the operator and are not part of the service config.
+func networkPeers(peers []netwk_v1.NetworkPolicyPeer, ports []netwk_v1.NetworkPolicyPort) []bitesize.NetworkPeer {
+	var retval []bitesize.NetworkPeer
+	var peerPorts []int
+
+	for _, p := range ports {
+		if p.Port != nil {
+			peerPorts = append(peerPorts, p.Port.IntValue())
+		}
+	}
+
+	for _, p := range peers {
+		peer := bitesize.NetworkPeer{Ports: peerPorts}
+
+		if p.IPBlock != nil {
+			peer.CIDR = p.IPBlock.CIDR
+		}
+
+		if p.PodSelector != nil {
+			for _, expr := range p.PodSelector.MatchExpressions {
+				if expr.Key == "name" && len(expr.Values) > 0 {
+					peer.Service = expr.Values[0]
+				}
+			}
+		}
+
+		if p.NamespaceSelector != nil {
+			peer.Namespace = p.NamespaceSelector.MatchLabels[translator.NamespaceNameLabel]
+		}
+		retval = append(retval, peer)
+	}
+	return retval
+}
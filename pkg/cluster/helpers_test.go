@@ -29,7 +29,7 @@ func TestHealthCheck(t *testing.T) {
 			},
 		},
 	}
-	r := healthCheck(deployment)
+	r := healthCheck(deployment.Spec.Template)
 	if r.Command[0] != "ls" {
 		t.Errorf("Unexpected command in healthcehck. Expected ls, got: %s", r.Command[0])
 	}
@@ -72,7 +72,7 @@ func TestLivenessProbe(t *testing.T) {
 		},
 	}
 
-	r := livenessProbe(deployment)
+	r := livenessProbe(deployment.Spec.Template)
 	if r.HTTPGet.Path != "/healthz" {
 		t.Errorf("Unexpected path in healthcehck. Expected /healthz, got: %s", r.HTTPGet.Path)
 	}
@@ -115,7 +115,7 @@ func TestReadinessProbe(t *testing.T) {
 		},
 	}
 
-	r := readinessProbe(deployment)
+	r := readinessProbe(deployment.Spec.Template)
 
 	if r.TCPSocket.Port != 8080 {
 		t.Errorf("Unexpected value for port %d", r.TCPSocket.Port)
@@ -0,0 +1,47 @@
+package cluster
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+	"github.com/pearsontechnology/environment-operator/pkg/translator"
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
+)
+
+func (cluster *Cluster) applyScaledObject(mapper *translator.KubeMapper, client k8s.Client) error {
+	so, err := mapper.ScaledObject()
+	if err != nil {
+		return err
+	}
+	so.Namespace = client.Namespace
+
+	client.CRDClient, err = cluster.CRDClientFor(k8_extensions.ScaledObjectGroupVersion)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client for KEDA use: %s", err.Error())
+	}
+
+	if err = client.ScaledObject().Apply(so); err != nil {
+		return fmt.Errorf("error applying scaled object %s: %s", so.Name, err.Error())
+	}
+	log.Infof("Successfully updated ScaledObject resource: %s", so.Name)
+	return nil
+}
+
+// scrapeScaledObjects adds KEDA ScaledObjects created by the pipeline to
+// services. Errors are expected on clusters without KEDA installed.
+func (cluster *Cluster) scrapeScaledObjects(client k8s.Client, serviceMap ServiceMap) {
+	var err error
+
+	client.CRDClient, err = cluster.CRDClientFor(k8_extensions.ScaledObjectGroupVersion)
+	if err != nil {
+		return
+	}
+	scaledObjects, err := client.ScaledObject().List()
+	if err != nil {
+		log.Debugf("error loading scaled objects: %s", err.Error())
+	}
+	for _, so := range scaledObjects {
+		serviceMap.AddScaledObject(so)
+	}
+}
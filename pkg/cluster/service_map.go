@@ -8,12 +8,15 @@ import (
 
 	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
 	"github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+	"github.com/pearsontechnology/environment-operator/pkg/translator"
 	"github.com/pearsontechnology/environment-operator/pkg/util"
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
 	apps_v1 "k8s.io/api/apps/v1"
 	autoscale_v2beta2 "k8s.io/api/autoscaling/v2beta2"
 	v1 "k8s.io/api/core/v1"
-	netwk_v1beta1 "k8s.io/api/networking/v1beta1"
+	netwk_v1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // ServiceMap holds a list of bitesize.Service objects, representing the
@@ -60,17 +63,43 @@ func (s ServiceMap) AddService(svc v1.Service) {
 	biteservice := s.CreateOrGet(name)
 	biteservice.Application = getLabel(svc.ObjectMeta, "application")
 	biteservice.Deployment = s.addDeploymentSettings(svc.ObjectMeta)
+	biteservice.TrafficPolicy = bitesize.TrafficPolicyFromAnnotation(getAnnotation(svc.ObjectMeta, "traffic_policy"))
 
 	if len(svc.Spec.Ports) > 0 {
-		biteservice.Ports = []int{}
+		biteservice.Ports = bitesize.ServicePorts{}
 	}
 
 	for _, port := range svc.Spec.Ports {
-		biteservice.Ports = append(biteservice.Ports, int(port.Port))
+		biteservice.Ports = append(biteservice.Ports, bitesize.NewServicePort(port.Name, int(port.Port), port.TargetPort.IntValue(), port.Protocol))
 	}
+	biteservice.ServiceType = serviceType(svc)
 	util.LogTraceAsYaml("AddService biteservice", biteservice)
 }
 
+// serviceType reads service_type back from kubernetes service, returning
+// nil for ClusterIP services without service_type annotations
+func serviceType(svc v1.Service) *bitesize.ServiceType {
+	retval := &bitesize.ServiceType{
+		Type:                     string(svc.Spec.Type),
+		ExternalName:             svc.Spec.ExternalName,
+		LoadBalancerSourceRanges: svc.Spec.LoadBalancerSourceRanges,
+		ExternalTrafficPolicy:    string(svc.Spec.ExternalTrafficPolicy),
+	}
+	if retval.Type == "" {
+		retval.Type = bitesize.ServiceTypeClusterIP
+	}
+	if keys := getAnnotation(svc.ObjectMeta, bitesize.ServiceTypeAnnotationsAnnotation); keys != "" {
+		retval.Annotations = map[string]string{}
+		for _, k := range strings.Split(keys, ",") {
+			retval.Annotations[k] = getAnnotation(svc.ObjectMeta, k)
+		}
+	}
+	if retval.Type == bitesize.ServiceTypeClusterIP && retval.Annotations == nil {
+		return nil
+	}
+	return retval
+}
+
 func (s ServiceMap) addDeploymentSettings(metadata metav1.ObjectMeta) *bitesize.DeploymentSettings {
 	retval := &bitesize.DeploymentSettings{}
 	retval.Method = getAnnotation(metadata, "deployment_method")
@@ -79,8 +108,15 @@ func (s ServiceMap) addDeploymentSettings(metadata metav1.ObjectMeta) *bitesize.
 		id := bitesize.BlueGreenDeploymentID(active)
 		if id != 0 {
 			retval.BlueGreen = &bitesize.BlueGreenSettings{Active: &id}
+			configured := bitesize.BlueGreenDeploymentID(getAnnotation(metadata, "deployment_active_configured"))
+			if configured != 0 && configured != id {
+				retval.BlueGreen.Configured = &configured
+			}
 		}
 	}
+	if retval.Method == "canary" {
+		retval.CanaryState = bitesize.CanaryStateFromAnnotations(metadata.GetAnnotations())
+	}
 	return retval
 }
 
@@ -93,7 +129,48 @@ func (s ServiceMap) AddDeployment(deployment apps_v1.Deployment) {
 		biteservice.Replicas = int(*deployment.Spec.Replicas)
 	}
 
-	resources := deployment.Spec.Template.Spec.Containers[0].Resources
+	s.addPodTemplate(biteservice, deployment.ObjectMeta, deployment.Spec.Template)
+
+	biteservice.Status = bitesize.ServiceStatus{
+
+		AvailableReplicas: int(deployment.Status.AvailableReplicas),
+		DesiredReplicas:   int(deployment.Status.Replicas),
+		CurrentReplicas:   int(deployment.Status.UpdatedReplicas),
+		DeployedAt:        deployment.CreationTimestamp.String(),
+	}
+
+	util.LogTraceAsYaml("AddDeployment biteservice", biteservice)
+}
+
+// AddStatefulSet adds kubernetes statefulset object to biteservice
+func (s ServiceMap) AddStatefulSet(statefulset apps_v1.StatefulSet) {
+	name := statefulset.Name
+
+	biteservice := s.CreateOrGet(name)
+	biteservice.Workload = bitesize.WorkloadStatefulSet
+	if statefulset.Spec.Replicas != nil {
+		biteservice.Replicas = int(*statefulset.Spec.Replicas)
+	}
+
+	for _, claim := range statefulset.Spec.VolumeClaimTemplates {
+		biteservice.Volumes = append(biteservice.Volumes, volumeFromClaim(claim))
+	}
+
+	s.addPodTemplate(biteservice, statefulset.ObjectMeta, statefulset.Spec.Template)
+
+	biteservice.Status = bitesize.ServiceStatus{
+		AvailableReplicas: int(statefulset.Status.ReadyReplicas),
+		DesiredReplicas:   int(statefulset.Status.Replicas),
+		CurrentReplicas:   int(statefulset.Status.UpdatedReplicas),
+		DeployedAt:        statefulset.CreationTimestamp.String(),
+	}
+
+	util.LogTraceAsYaml("AddStatefulSet biteservice", biteservice)
+}
+
+// addPodTemplate fills in biteservice fields common to all workload kinds
+func (s ServiceMap) addPodTemplate(biteservice *bitesize.Service, metadata metav1.ObjectMeta, template v1.PodTemplateSpec) {
+	resources := template.Spec.Containers[0].Resources
 
 	if len(resources.Requests) != 0 {
 		cpuQuantity := resources.Requests["cpu"]
@@ -108,23 +185,23 @@ func (s ServiceMap) AddDeployment(deployment apps_v1.Deployment) {
 		biteservice.Limits.CPU = cpuQuantity.String()
 		biteservice.Limits.Memory = memQuantity.String()
 	}
-	sslEnabled := getLabel(deployment.ObjectMeta, "ssl") // kubeDeployment.Labels["ssl"]
+	sslEnabled := getLabel(metadata, "ssl") // kubeDeployment.Labels["ssl"]
 	if sslEnabled == "true" {
 		biteservice.Ssl = "true"
 	}
-	HTTPSOnly := getLabel(deployment.ObjectMeta, "httpsOnly")
+	HTTPSOnly := getLabel(metadata, "httpsOnly")
 	if HTTPSOnly == "true" {
 		biteservice.HTTPSOnly = "true"
 	}
 
-	biteservice.Version = getLabel(deployment.ObjectMeta, "version")
-	biteservice.Application = getLabel(deployment.ObjectMeta, "application")
-	biteservice.HTTPSBackend = getLabel(deployment.ObjectMeta, "httpsBackend")
-	biteservice.EnvVars = envVars(deployment)
-	biteservice.HealthCheck = healthCheck(deployment)
-	biteservice.LivenessProbe = livenessProbe(deployment)
-	biteservice.ReadinessProbe = readinessProbe(deployment)
-	vols := append(biteservice.Volumes, volumes(deployment)...)
+	biteservice.Version = getLabel(metadata, "version")
+	biteservice.Application = getLabel(metadata, "application")
+	biteservice.HTTPSBackend = getLabel(metadata, "httpsBackend")
+	biteservice.EnvVars = bitesize.EnvVarsWithOutputs(envVars(template), getAnnotation(metadata, bitesize.ServiceOutputsAnnotation))
+	biteservice.HealthCheck = healthCheck(template)
+	biteservice.LivenessProbe = livenessProbe(template)
+	biteservice.ReadinessProbe = readinessProbe(template)
+	vols := append(biteservice.Volumes, volumes(template)...)
 	sortedVols, err := bitesize.SortVolumesByVolName(vols)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "sortVolumesByVolName error: %v\n", err)
@@ -135,29 +212,27 @@ func (s ServiceMap) AddDeployment(deployment apps_v1.Deployment) {
 		biteservice.Volumes = sortedVols
 	}
 
-	for _, cmd := range deployment.Spec.Template.Spec.Containers[0].Command {
+	for _, cmd := range template.Spec.Containers[0].Command {
 		biteservice.Commands = append(biteservice.Commands, string(cmd))
 	}
 
-	if deployment.Spec.Template.ObjectMeta.Annotations != nil {
-		biteservice.Annotations = deployment.Spec.Template.ObjectMeta.Annotations
+	if template.ObjectMeta.Annotations != nil {
+		biteservice.Annotations = template.ObjectMeta.Annotations
+		biteservice.ConfigHash = biteservice.Annotations[bitesize.ConfigHashAnnotation]
+		delete(biteservice.Annotations, bitesize.ConfigHashAnnotation)
 	} else {
 		biteservice.Annotations = map[string]string{}
 	}
-
-	biteservice.Status = bitesize.ServiceStatus{
-
-		AvailableReplicas: int(deployment.Status.AvailableReplicas),
-		DesiredReplicas:   int(deployment.Status.Replicas),
-		CurrentReplicas:   int(deployment.Status.UpdatedReplicas),
-		DeployedAt:        deployment.CreationTimestamp.String(),
-	}
-
-	util.LogTraceAsYaml("AddDeployment biteservice", biteservice)
 }
 
 // AddHPA adds Kubernetes HPA to biteservice
 func (s ServiceMap) AddHPA(hpa autoscale_v2beta2.HorizontalPodAutoscaler) {
+	// KEDA copies ScaledObject labels to the hpa it manages
+	for _, owner := range hpa.OwnerReferences {
+		if owner.Kind == "ScaledObject" {
+			return
+		}
+	}
 	name := hpa.Name
 
 	biteservice := s.CreateOrGet(name)
@@ -166,25 +241,65 @@ func (s ServiceMap) AddHPA(hpa autoscale_v2beta2.HorizontalPodAutoscaler) {
 	biteservice.HPA.MaxReplicas = hpa.Spec.MaxReplicas
 	biteservice.Replicas = int(biteservice.HPA.MinReplicas)
 
-	if hpa.Spec.Metrics[0].Type == "Resource" {
-		if hpa.Spec.Metrics[0].Resource.Name == "cpu" {
-			biteservice.HPA.Metric.Name = "cpu"
+	biteservice.HPA.Metric = bitesize.Metric{}
+	biteservice.HPA.Metrics = nil
+	for _, spec := range hpa.Spec.Metrics {
+		if metric := metricFromSpec(spec); metric != nil {
+			biteservice.HPA.Metrics = append(biteservice.HPA.Metrics, *metric)
 		}
+	}
+	biteservice.HPA.Behavior = bitesize.ScalingBehaviorFromJSON(hpa.Annotations[k8s.HPABehaviorAnnotation])
+	util.LogTraceAsYaml("AddHPA biteservice", biteservice)
 
-		if hpa.Spec.Metrics[0].Resource.Name == "memory" {
-			biteservice.HPA.Metric.Name = "memory"
-		}
+}
 
-		biteservice.HPA.Metric.TargetAverageUtilization = *hpa.Spec.Metrics[0].Resource.Target.AverageUtilization
+// metricFromSpec reads hpa metric back from kubernetes metric spec,
+// returning nil for metric types environment operator doesn't generate
+func metricFromSpec(spec autoscale_v2beta2.MetricSpec) *bitesize.Metric {
+	var retval bitesize.Metric
+	var target autoscale_v2beta2.MetricTarget
+	var identifier autoscale_v2beta2.MetricIdentifier
+
+	switch {
+	case spec.Type == autoscale_v2beta2.ResourceMetricSourceType && spec.Resource != nil:
+		retval = bitesize.Metric{Type: bitesize.MetricTypeResource, Name: string(spec.Resource.Name)}
+		target = spec.Resource.Target
+	case spec.Type == autoscale_v2beta2.PodsMetricSourceType && spec.Pods != nil:
+		retval = bitesize.Metric{Type: bitesize.MetricTypePods}
+		target, identifier = spec.Pods.Target, spec.Pods.Metric
+	case spec.Type == autoscale_v2beta2.ObjectMetricSourceType && spec.Object != nil:
+		retval = bitesize.Metric{
+			Type: bitesize.MetricTypeObject,
+			Object: &bitesize.MetricObject{
+				APIVersion: spec.Object.DescribedObject.APIVersion,
+				Kind:       spec.Object.DescribedObject.Kind,
+				Name:       spec.Object.DescribedObject.Name,
+			},
+		}
+		target, identifier = spec.Object.Target, spec.Object.Metric
+	case spec.Type == autoscale_v2beta2.ExternalMetricSourceType && spec.External != nil:
+		retval = bitesize.Metric{Type: bitesize.MetricTypeExternal}
+		target, identifier = spec.External.Target, spec.External.Metric
+	default:
+		return nil
 	}
 
-	if hpa.Spec.Metrics[0].Type == "Pods" {
-		targetAverageValueQuantity := hpa.Spec.Metrics[0].Pods.Target.AverageValue
-		biteservice.HPA.Metric.Name = hpa.Spec.Metrics[0].Pods.Metric.Name
-		biteservice.HPA.Metric.TargetAverageValue = targetAverageValueQuantity.String()
+	if identifier.Name != "" {
+		retval.Name = identifier.Name
 	}
-	util.LogTraceAsYaml("AddHPA biteservice", biteservice)
-
+	if identifier.Selector != nil && len(identifier.Selector.MatchLabels) > 0 {
+		retval.Selector = identifier.Selector.MatchLabels
+	}
+	if target.AverageUtilization != nil {
+		retval.TargetAverageUtilization = *target.AverageUtilization
+	}
+	if target.AverageValue != nil {
+		retval.TargetAverageValue = target.AverageValue.String()
+	}
+	if target.Value != nil {
+		retval.TargetValue = target.Value.String()
+	}
+	return &retval
 }
 
 // AddVolumeClaim adds Kubernetes PVC to biteservice
@@ -197,15 +312,7 @@ func (s ServiceMap) AddVolumeClaim(claim v1.PersistentVolumeClaim) {
 
 	biteservice := s.CreateOrGet(name)
 
-	vol := bitesize.Volume{
-		Path:  strings.Replace(claim.ObjectMeta.Labels["mount_path"], "2F", "/", -1),
-		Modes: getAccessModesAsString(claim.Spec.AccessModes),
-		Size:  claim.ObjectMeta.Labels["size"],
-		Name:  claim.ObjectMeta.Name,
-		Type:  claim.ObjectMeta.Labels["type"],
-	}
-
-	vols := append(biteservice.Volumes, vol)
+	vols := append(biteservice.Volumes, volumeFromClaim(claim))
 	sortedVols, err := bitesize.SortVolumesByVolName(vols)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "sortVolumesByVolName error: %v\n", err)
@@ -232,6 +339,7 @@ func (s ServiceMap) AddCustomResourceDefinition(crd k8_extensions.PrsnExternalRe
 	biteservice.Set = crd.Spec.Set
 	biteservice.ValuesContent = crd.Spec.ValuesContent
 	biteservice.Ignore = crd.Spec.Ignore
+	biteservice.DeletionProtection = bitesize.DeletionProtectionFromAnnotation(crd.Annotations)
 
 	if crd.Spec.Replicas != 0 {
 		biteservice.Replicas = crd.Spec.Replicas
@@ -239,8 +347,35 @@ func (s ServiceMap) AddCustomResourceDefinition(crd k8_extensions.PrsnExternalRe
 	util.LogTraceAsYaml("AddCustomResourceDefinition biteservice", biteservice)
 }
 
+// AddCustomResource adds custom resource of any kind to biteservice.
+// Resources owned by another resource, or named after a service already
+// scraped from its other resources (e.g. Istio VirtualService or
+// cert-manager Certificate the operator creates for it), are skipped.
+func (s ServiceMap) AddCustomResource(resource unstructured.Unstructured) {
+	if len(resource.GetOwnerReferences()) > 0 {
+		return
+	}
+	if svc, ok := s[resource.GetName()]; ok && !svc.IsCustomResource() {
+		return
+	}
+
+	biteservice := s.CreateOrGet(resource.GetName())
+	biteservice.Type = strings.ToLower(resource.GetKind())
+	biteservice.Kind = resource.GetKind()
+	biteservice.APIVersion = resource.GetAPIVersion()
+	biteservice.Spec, _ = resource.Object["spec"].(map[string]interface{})
+	biteservice.DeletionProtection = bitesize.DeletionProtectionFromAnnotation(resource.GetAnnotations())
+
+	util.LogTraceAsYaml("AddCustomResource biteservice", biteservice)
+}
+
 // AddIngress adds Kubernetes ingress fields to biteservice
-func (s ServiceMap) AddIngress(ingress netwk_v1beta1.Ingress) {
+func (s ServiceMap) AddIngress(ingress k8_extensions.Ingress) {
+	// canary ingresses are part of the "parent" service traffic routing
+	if ingress.Labels[translator.CanaryLabel] == "true" {
+		return
+	}
+
 	name := ingress.Name
 	biteservice := s.CreateOrGet(name)
 
@@ -256,25 +391,183 @@ func (s ServiceMap) AddIngress(ingress netwk_v1beta1.Ingress) {
 
 	httpsBackend := ingress.Labels["httpsBackend"]
 
+	biteservice.HTTPSBackend = httpsBackend
+	biteservice.HTTP2 = ingress.Labels["http2"]
+
+	if ingress.Spec.IngressClassName != nil {
+		biteservice.IngressClass = *ingress.Spec.IngressClassName
+	}
+
+	// secrets not named after the service are provided by "secret" tls
+	// provider, others are overridden by their ExternalSecret or Certificate
+	biteservice.TLS = nil
+	for _, tls := range ingress.Spec.TLS {
+		if tls.SecretName != "" && tls.SecretName != name {
+			biteservice.TLS = &bitesize.TLSSettings{
+				Provider:   bitesize.TLSProviderSecret,
+				SecretName: tls.SecretName,
+			}
+		}
+	}
+
+	// annotations generated by the ingress profile are scraped back into
+	// settings, ones recorded as set by ingress_annotations into
+	// ingress_annotations. Annotations added by anything else are ignored.
+	biteservice.IngressProfile = ingress.Labels[bitesize.IngressProfileLabel]
+	if profile, ok := bitesize.IngressProfiles[biteservice.IngressProfile]; ok {
+		profile.Scrape(ingress.Annotations, biteservice)
+	}
+	biteservice.IngressAnnotations = nil
+	if keys := getAnnotation(ingress.ObjectMeta, bitesize.IngressAnnotationsAnnotation); keys != "" {
+		biteservice.IngressAnnotations = map[string]string{}
+		for _, k := range strings.Split(keys, ",") {
+			biteservice.IngressAnnotations[k] = getAnnotation(ingress.ObjectMeta, k)
+		}
+	}
+
+	// ingresses without external_routes have a single path per external_url
+	urls, hasRoutes := ingress.Annotations[bitesize.ExternalURLAnnotation]
+	urlHosts := map[string]bool{}
+	if hasRoutes && urls != "" {
+		for _, url := range strings.Split(urls, ",") {
+			urlHosts[url] = true
+		}
+	}
+
+	var backend *k8_extensions.IngressServiceBackend
 	biteservice.ExternalURL = []string{}
-	if len(ingress.Spec.Rules) > 0 {
-		for _, rule := range ingress.Spec.Rules {
+	biteservice.ExternalRoutes = nil
+	for _, rule := range ingress.Spec.Rules {
+		var paths []k8_extensions.HTTPIngressPath
+		if rule.HTTP != nil {
+			paths = rule.HTTP.Paths
+		}
+
+		if !hasRoutes || urlHosts[rule.Host] {
 			biteservice.ExternalURL = append(biteservice.ExternalURL, rule.Host)
+			if len(paths) > 0 {
+				if backend == nil {
+					backend = paths[0].Backend.Service
+				}
+				paths = paths[1:]
+			}
+		}
+		if !hasRoutes {
+			continue
+		}
+
+		for _, path := range paths {
+			route := bitesize.ExternalRoute{
+				Host: rule.Host,
+				Path: path.Path,
+			}
+			// path types are not served by networking/v1beta1 clusters
+			if path.PathType != nil {
+				route.PathType = string(*path.PathType)
+			}
+			if path.Backend.Service != nil {
+				route.Service = path.Backend.Service.Name
+				route.Port = int(path.Backend.Service.Port.Number)
+			}
+			biteservice.ExternalRoutes = append(biteservice.ExternalRoutes, route)
 		}
 	}
 
-	biteservice.HTTPSBackend = httpsBackend
-	biteservice.HTTP2 = ingress.Labels["http2"]
+	if backend == nil {
+		util.LogTraceAsYaml("AddIngress biteservice", biteservice)
+		return
+	}
 
 	// backend service has been overridden
-	backendService := ingress.Spec.Rules[0].IngressRuleValue.HTTP.Paths[0].Backend.ServiceName
-	if backendService != biteservice.Name {
-		biteservice.Backend = backendService
+	if backend.Name != biteservice.Name {
+		biteservice.Backend = backend.Name
 	}
 	// backend port has been overriden
-	backendPort := int(ingress.Spec.Rules[0].IngressRuleValue.HTTP.Paths[0].Backend.ServicePort.IntVal)
-	if len(biteservice.Ports) > 0 && backendPort != biteservice.Ports[0] {
+	backendPort := int(backend.Port.Number)
+	if len(biteservice.Ports) > 0 && backendPort != biteservice.HTTPPort() {
 		biteservice.BackendPort = backendPort
 	}
 	util.LogTraceAsYaml("AddIngress biteservice", biteservice)
 }
+
+// AddExternalSecret adds TLS settings of services with certificates synced
+// by ExternalSecret
+func (s ServiceMap) AddExternalSecret(es k8_extensions.ExternalSecret) {
+	biteservice, ok := s[es.Labels["name"]]
+	if !ok || es.SecretDescriptor.Type != "kubernetes.io/tls" {
+		return
+	}
+
+	biteservice.TLS = &bitesize.TLSSettings{
+		Provider: bitesize.TLSProviderExternalSecrets,
+	}
+	biteservice.TLSProviders = append(biteservice.TLSProviders, bitesize.TLSProviderExternalSecrets)
+}
+
+// AddCertificate adds TLS settings of services with certificates issued by
+// cert-manager
+func (s ServiceMap) AddCertificate(cert k8_extensions.Certificate) {
+	biteservice, ok := s[cert.Labels["name"]]
+	if !ok {
+		return
+	}
+
+	kind := cert.Spec.IssuerRef.Kind
+	if kind == "" {
+		kind = "Issuer"
+	}
+	biteservice.TLS = &bitesize.TLSSettings{
+		Provider:   bitesize.TLSProviderCertManager,
+		Issuer:     cert.Spec.IssuerRef.Name,
+		IssuerKind: kind,
+	}
+	biteservice.TLSProviders = append(biteservice.TLSProviders, bitesize.TLSProviderCertManager)
+}
+
+// AddScaledObject adds keda settings of services scaled by KEDA
+func (s ServiceMap) AddScaledObject(so k8_extensions.ScaledObject) {
+	biteservice, ok := s[so.Labels["name"]]
+	if !ok {
+		return
+	}
+
+	keda := &bitesize.KedaScaling{}
+	if v := so.Spec.MinReplicaCount; v != nil {
+		keda.MinReplicas = *v
+	}
+	if v := so.Spec.MaxReplicaCount; v != nil {
+		keda.MaxReplicas = *v
+	}
+	if v := so.Spec.PollingInterval; v != nil {
+		keda.PollingInterval = *v
+	}
+	if v := so.Spec.CooldownPeriod; v != nil {
+		keda.CooldownPeriod = *v
+	}
+	for _, t := range so.Spec.Triggers {
+		trigger := bitesize.KedaTrigger{Type: t.Type, Metadata: t.Metadata}
+		if t.AuthenticationRef != nil {
+			trigger.AuthenticationRef = t.AuthenticationRef.Name
+		}
+		keda.Triggers = append(keda.Triggers, trigger)
+	}
+	biteservice.Keda = keda
+	util.LogTraceAsYaml("AddScaledObject biteservice", biteservice)
+}
+
+// AddNetworkPolicy adds Kubernetes network policy to biteservice
+func (s ServiceMap) AddNetworkPolicy(np netwk_v1.NetworkPolicy) {
+	name := np.Name
+	biteservice := s.CreateOrGet(name)
+
+	biteservice.AllowFrom = nil
+	for _, rule := range np.Spec.Ingress {
+		biteservice.AllowFrom = append(biteservice.AllowFrom, networkPeers(rule.From, rule.Ports)...)
+	}
+
+	biteservice.AllowTo = nil
+	for _, rule := range np.Spec.Egress {
+		biteservice.AllowTo = append(biteservice.AllowTo, networkPeers(rule.To, rule.Ports)...)
+	}
+	util.LogTraceAsYaml("AddNetworkPolicy biteservice", biteservice)
+}
@@ -1,8 +1,11 @@
 package cluster
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	"github.com/pearsontechnology/environment-operator/pkg/translator"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -31,3 +34,30 @@ func TestAddDeploymentSetting(t *testing.T) {
 		t.Errorf("unexpected active deployment name. expected test-blue, got: %+v", biteservice.ActiveDeploymentName())
 	}
 }
+
+func TestAddNetworkPolicy(t *testing.T) {
+	svc := &bitesize.Service{
+		Name: "test",
+		AllowFrom: []bitesize.NetworkPeer{
+			{Service: "front", Ports: []int{8080}},
+			{Namespace: "ingress-nginx"},
+		},
+		AllowTo: []bitesize.NetworkPeer{
+			{CIDR: "10.0.0.0/8"},
+		},
+	}
+	mapper := &translator.KubeMapper{BiteService: svc, Namespace: "sample"}
+	np, _ := mapper.NetworkPolicy()
+
+	serviceMap := &ServiceMap{}
+	serviceMap.AddNetworkPolicy(*np)
+
+	biteservice := serviceMap.CreateOrGet("test")
+	if !reflect.DeepEqual(biteservice.AllowFrom, svc.AllowFrom) {
+		t.Errorf("unexpected allow_from. expected %+v, got %+v", svc.AllowFrom, biteservice.AllowFrom)
+	}
+
+	if !reflect.DeepEqual(biteservice.AllowTo, svc.AllowTo) {
+		t.Errorf("unexpected allow_to. expected %+v, got %+v", svc.AllowTo, biteservice.AllowTo)
+	}
+}
@@ -0,0 +1,33 @@
+package cluster
+
+import (
+	"github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+	"github.com/pearsontechnology/environment-operator/pkg/translator"
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// applyDestinationRule applies Istio DestinationRule of service mesh enabled
+// service, removing it once service has no traffic policy or subsets
+func (cluster *Cluster) applyDestinationRule(mapper *translator.KubeMapper, client k8s.Client) error {
+	crdClient, err := cluster.CRDClientFor(schema.GroupVersion{
+		Group:   "networking.istio.io",
+		Version: "v1alpha3",
+	})
+	if err != nil {
+		return err
+	}
+	client.CRDClient = crdClient
+
+	rule, err := mapper.ServiceMeshDestinationRule()
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		if client.CustomResourceDefinition(k8_extensions.DestinationRuleKind).Exist(mapper.BiteService.Name) {
+			return client.CustomResourceDefinition(k8_extensions.DestinationRuleKind).Destroy(mapper.BiteService.Name)
+		}
+		return nil
+	}
+	return client.CustomResourceDefinition(k8_extensions.DestinationRuleKind).Apply(rule)
+}
@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	"github.com/pearsontechnology/environment-operator/pkg/translator"
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// resolveServiceOutputs finds outputs referenced by env value_from of every
+// service in env, so the diff sees their current values
+func (cluster *Cluster) resolveServiceOutputs(env *bitesize.Environment) {
+	for _, service := range env.Services {
+		cluster.resolveOutputs(service, env.Services, env.Namespace)
+	}
+}
+
+// resolveOutputs finds outputs referenced by env value_from of service.
+// Services with outputs missing are held back by ApplyService until they
+// exist. Referenced services are looked up in services or, if nil, scraped
+// from the namespace.
+func (cluster *Cluster) resolveOutputs(service bitesize.Service, services bitesize.Services, namespace string) {
+	client := &k8s.Client{
+		Interface: cluster.Interface,
+		Namespace: namespace,
+	}
+
+	for _, e := range service.EnvVars {
+		output := e.ValueFrom
+		if output == nil {
+			continue
+		}
+		output.Secret, output.Value = "", ""
+
+		if secret, err := client.Secret().Get(bitesize.OutputsSecretName(output.Service)); err == nil {
+			if _, ok := secret.Data[output.Field]; ok {
+				output.Secret = secret.Name
+				continue
+			}
+		}
+
+		if services == nil {
+			current, err := cluster.ScrapeResourcesForNamespace(namespace)
+			if err != nil {
+				log.Debugf("error loading services for outputs of %s: %s", service.Name, err.Error())
+				return
+			}
+			services = current.Services
+		}
+		ref := services.FindByName(output.Service)
+		if ref == nil {
+			continue
+		}
+		value, err := cluster.statusField(*ref, namespace, output.Field)
+		if err != nil {
+			log.Debugf("output %s.%s for service %s not found: %s", output.Service, output.Field, service.Name, err.Error())
+			continue
+		}
+		output.Value = value
+	}
+}
+
+// statusField reads field (dot separated path) from the status of service
+// custom resource
+func (cluster *Cluster) statusField(svc bitesize.Service, namespace, field string) (string, error) {
+	var object map[string]interface{}
+
+	if svc.IsCustomResource() {
+		client, err := cluster.customResourceClient(svc.APIVersion, svc.Kind, namespace)
+		if err != nil {
+			return "", err
+		}
+		rsc, err := client.Get(svc.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		object = rsc.Object
+	} else {
+		mapper := &translator.KubeMapper{BiteService: &svc, Namespace: namespace}
+		crd, err := mapper.CustomResourceDefinition()
+		if err != nil {
+			return "", err
+		}
+		gv, err := schema.ParseGroupVersion(crd.APIVersion)
+		if err != nil {
+			return "", err
+		}
+		crdClient, err := cluster.CRDClientFor(gv)
+		if err != nil {
+			return "", err
+		}
+		client := k8s.Client{CRDClient: crdClient, Namespace: namespace}
+		rsc, err := client.CustomResourceDefinition(svc.Type).Get(svc.Name)
+		if err != nil {
+			return "", err
+		}
+		object = map[string]interface{}{"status": rsc.Status}
+	}
+
+	path := append([]string{"status"}, strings.Split(field, ".")...)
+	value, found, err := unstructured.NestedFieldNoCopy(object, path...)
+	if err != nil {
+		return "", err
+	}
+	if !found || value == nil {
+		return "", fmt.Errorf("status.%s is not set", field)
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case map[string]interface{}, []interface{}:
+		byt, err := json.Marshal(value)
+		return string(byt), err
+	default:
+		return fmt.Sprintf("%v", value), nil
+	}
+}
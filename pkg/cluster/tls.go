@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	"github.com/pearsontechnology/environment-operator/pkg/translator"
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	externalSecretGroupVersion = schema.GroupVersion{Group: "kubernetes-client.io", Version: "v1"}
+	certificateGroupVersion    = schema.GroupVersion{Group: "cert-manager.io", Version: "v1"}
+)
+
+// CRDClientFor returns REST client for custom resources in group version.
+// Outside of the cluster (e.g. in unit tests) cluster.CRDClient is used.
+func (cluster *Cluster) CRDClientFor(gv schema.GroupVersion) (rest.Interface, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if len(host) == 0 || len(port) == 0 {
+		return cluster.CRDClient, nil
+	}
+	return k8s.CRDClient(&gv)
+}
+
+// applyTLS creates objects providing service TLS secret in namespace ns,
+// or in the service namespace if ns is empty
+func (cluster *Cluster) applyTLS(mapper *translator.KubeMapper, client k8s.Client, ns string) error {
+	if ns != "" {
+		client.Namespace = ns
+	}
+
+	switch mapper.BiteService.TLSProvider() {
+	case bitesize.TLSProviderExternalSecrets:
+		return cluster.applyExternalSecret(mapper, client)
+	case bitesize.TLSProviderCertManager:
+		return cluster.applyCertificate(mapper, client)
+	}
+	return nil
+}
+
+func (cluster *Cluster) applyExternalSecret(mapper *translator.KubeMapper, client k8s.Client) error {
+	es, err := mapper.ExternalSecretTLS()
+	if err != nil {
+		return fmt.Errorf("error creating external secret for ingress: %s", err.Error())
+	}
+	es.Namespace = client.Namespace
+
+	client.CRDClient, err = cluster.CRDClientFor(externalSecretGroupVersion)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client for External Secrets use: %s", err.Error())
+	}
+
+	if err = client.ExternalSecret().Apply(es); err != nil {
+		return fmt.Errorf("error applying external secret %s: %s", es.Name, err.Error())
+	}
+	log.Infof("Successfully updated ExternalSecret CRD resource: %s", es.Name)
+	return nil
+}
+
+func (cluster *Cluster) applyCertificate(mapper *translator.KubeMapper, client k8s.Client) error {
+	cert, err := mapper.Certificate()
+	if err != nil {
+		return err
+	}
+	cert.Namespace = client.Namespace
+
+	client.CRDClient, err = cluster.CRDClientFor(certificateGroupVersion)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client for cert-manager use: %s", err.Error())
+	}
+
+	if err = client.Certificate().Apply(cert); err != nil {
+		return fmt.Errorf("error applying certificate %s: %s", cert.Name, err.Error())
+	}
+	log.Infof("Successfully updated Certificate resource: %s", cert.Name)
+	return nil
+}
+
+// scrapeTLS adds ExternalSecrets and Certificates created by the pipeline
+// to services. Errors are expected on clusters without the CRDs installed.
+func (cluster *Cluster) scrapeTLS(client k8s.Client, serviceMap ServiceMap) {
+	var err error
+
+	client.CRDClient, err = cluster.CRDClientFor(externalSecretGroupVersion)
+	if err == nil {
+		secrets, err := client.ExternalSecret().List()
+		if err != nil {
+			log.Debugf("error loading external secrets: %s", err.Error())
+		}
+		for _, es := range secrets {
+			serviceMap.AddExternalSecret(es)
+		}
+	}
+
+	client.CRDClient, err = cluster.CRDClientFor(certificateGroupVersion)
+	if err == nil {
+		certs, err := client.Certificate().List()
+		if err != nil {
+			log.Debugf("error loading certificates: %s", err.Error())
+		}
+		for _, cert := range certs {
+			serviceMap.AddCertificate(cert)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package cluster
 
 import (
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -10,4 +12,8 @@ import (
 type Cluster struct {
 	kubernetes.Interface
 	CRDClient rest.Interface
+	Dynamic   dynamic.Interface
+	// IngressVersion caches discovery of ingress API versions for the
+	// lifetime of the cluster client
+	IngressVersion *k8s.IngressVersion
 }
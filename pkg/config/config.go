@@ -23,6 +23,10 @@ type Config struct {
 	GistsToken string `envconfig:"GISTS_TOKEN"`
 	GistsKey   string `envconfig:"GISTS_PRIVATE_KEY"`
 
+	// Keys decrypting sops encrypted secret gists
+	SopsAgeKeyFile string `envconfig:"SOPS_AGE_KEY_FILE" default:"/etc/sops/age/keys.txt"`
+	SopsPGPKeyFile string `envconfig:"SOPS_PGP_KEY_FILE" default:"/etc/sops/pgp/key.asc"`
+
 	EnvName           string `envconfig:"ENVIRONMENT_NAME"`
 	EnvFile           string `envconfig:"BITESIZE_FILE"`
 	Namespace         string `envconfig:"NAMESPACE"`
@@ -43,6 +47,18 @@ type Config struct {
 
 	TokenFile string `envconfig:"AUTH_TOKEN_FILE"`
 
+	// Prometheus compatible query endpoint used in canary analysis
+	PrometheusURL string `envconfig:"PROMETHEUS_URL"`
+
+	// API groups of custom resources services may declare with kind and
+	// api_version, e.g. "example.com,monitoring.coreos.com"
+	CustomResourceGroups []string `envconfig:"CUSTOM_RESOURCE_GROUPS"`
+
+	// Keep custom resources of data bearing types (and services with
+	// deletion_protection: true) when removed from the manifest, unless
+	// deletion_protection: false was applied first
+	DeletionProtection bool `envconfig:"DELETION_PROTECTION" default:"false"`
+
 	Debug string `envconfig:"DEBUG"`
 }
 
@@ -8,7 +8,6 @@ import (
 	"github.com/kylelemons/godebug/pretty"
 	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
 	"github.com/pearsontechnology/environment-operator/pkg/util"
-	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
@@ -33,7 +32,20 @@ func Compare(desiredCfg, existingCfg bitesize.Environment) bool {
 		IncludeUnexported: false,
 	}
 
+	// default deny policy removed from the config is deleted by the reaper
+	if desired, existing := desiredCfg.NetworkPolicy.DefaultDeny, existingCfg.NetworkPolicy.DefaultDeny; desired != existing {
+		log.Debugf("change detected for environment default deny network policy")
+		addEnvironmentChange("network_policy", fmt.Sprintf("DefaultDeny: -%t +%t", existing, desired))
+	}
+
+	compareGists(desiredCfg.Gists, existingCfg.Gists)
+
 	for _, desiredCfgSvc := range desiredCfg.Services {
+		// canary copies are deployed by canary releases and removed after
+		// them, not synced from the config
+		if desiredCfgSvc.IsCanaryChildDeployment() {
+			continue
+		}
 		util.LogTraceAsYaml("Desired Service Config", desiredCfgSvc)
 		serviceName := desiredCfgSvc.Name
 		log.Debugf("Checking desired configuration against running service %s", serviceName)
@@ -88,17 +100,28 @@ func Compare(desiredCfg, existingCfg bitesize.Environment) bool {
 				addServiceChange(serviceName, serviceDiff)
 				continue
 			}
+
+			// Compare ConfiguredDeploymentTag(), kept after live traffic was switched via API
+			if serviceDiff := compareConfig.Compare(existingCfgSvc.ConfiguredDeploymentTag().String(), desiredCfgSvc.ConfiguredDeploymentTag().String()); serviceDiff != "" {
+				log.Debugf("change detected for blue/green service configured active deployment %s", serviceName)
+				addServiceChange(serviceName, serviceDiff)
+				continue
+			}
 		}
 
 		// Changes are only applied if:
 		//  - config in git for service has version set
 		// OR
 		//  - deployed config for service has version set
+		// OR
+		//  - service is a custom resource or an ExternalName alias, which
+		//    have no image to version
 		gitConfigHasVersionSetForService := desiredCfgSvc.Version != ""
 		deployedConfigHasVersionSetForService := (existingCfgSvc != nil &&
 			existingCfgSvc.Version != "")
 
-		if gitConfigHasVersionSetForService || deployedConfigHasVersionSetForService {
+		if gitConfigHasVersionSetForService || deployedConfigHasVersionSetForService ||
+			desiredCfgSvc.IsCustomResource() || desiredCfgSvc.IsExternalName() {
 
 			// if service is already deployed
 			if existingCfgSvc != nil {
@@ -116,12 +139,6 @@ func Compare(desiredCfg, existingCfg bitesize.Environment) bool {
 		} else {
 			log.Debugf("\"version\" field not set for Service %s. Skipping deployment.", serviceName)
 		}
-
-		if k8s.ExternalSecretsEnabled && desiredCfgSvc.IsTLSEnabled() &&
-			!desiredCfgSvc.ExternalSecretExist(desiredCfg.Namespace, serviceName) {
-			log.Debugf("changes detected for externalsecrets for %s", serviceName)
-			addServiceChange(serviceName, fmt.Sprintf("ExternalSecrets: +%s", serviceName))
-		}
 	}
 
 	cmCount := len(changeMap)
@@ -134,6 +151,28 @@ func Compare(desiredCfg, existingCfg bitesize.Environment) bool {
 	return cmCount > 0
 }
 
+// compareGists detects job, cronjob and secret gists whose content hash
+// differs from the one deployed. ConfigMap gists are applied with the services
+// mounting them and are not compared here.
+func compareGists(desired, existing bitesize.Gists) {
+	for _, gist := range desired {
+		switch gist.Type {
+		case bitesize.TypeJob, bitesize.TypeCronJob, bitesize.TypeSecret:
+		default:
+			continue
+		}
+
+		current := existing.FindByName(gist.Name, gist.Type)
+		if current == nil {
+			log.Debugf("change detected for new %s %s", gist.Type, gist.Name)
+			addGistChange(gist.Name, fmt.Sprintf("%s: +%s", gist.Type, gist.Hash()))
+		} else if current.Hash() != gist.Hash() {
+			log.Debugf("change detected for %s %s", gist.Type, gist.Name)
+			addGistChange(gist.Name, fmt.Sprintf("%s: -%s +%s", gist.Type, current.Hash(), gist.Hash()))
+		}
+	}
+}
+
 // Can't think of a better word
 func alignServices(desiredCfg, currentCfg *bitesize.Service) {
 	util.LogTraceAsYaml("alignServices: Desired Service Config", desiredCfg)
@@ -150,15 +189,38 @@ func alignServices(desiredCfg, currentCfg *bitesize.Service) {
 
 	// Copy status from currentCfg (status is only stored in the cluster)
 	desiredCfg.Status = currentCfg.Status
+	desiredCfg.TLSProviders = currentCfg.TLSProviders
 
 	// Ignore changes to internal info
+	// (copied, deployment settings are shared with the environment being applied)
 	if desiredCfg.Deployment != nil {
-		desiredCfg.Deployment.BlueGreen = nil
+		deployment := *desiredCfg.Deployment
+		deployment.BlueGreen = nil
+		desiredCfg.Deployment = &deployment
 	}
 	if currentCfg.Deployment != nil {
 		currentCfg.Deployment.BlueGreen = nil
 	}
 
+	// Version released by canary is not a change until the canary is
+	// promoted, and isn't released again after the canary was aborted
+	if state := currentCfg.CanaryState(); state != nil && desiredCfg.IsCanaryParentDeployment() &&
+		state.Version == desiredCfg.Version {
+		desiredCfg.Version = currentCfg.Version
+	}
+
+	// Canary settings and smoke checks are only kept in the config and canary
+	// state only in the cluster
+	if currentCfg.Deployment != nil {
+		deployment := *currentCfg.Deployment
+		deployment.CanaryState = nil
+		if desiredCfg.Deployment != nil {
+			deployment.Canary = desiredCfg.Deployment.Canary
+			deployment.SmokeChecks = desiredCfg.Deployment.SmokeChecks
+		}
+		currentCfg.Deployment = &deployment
+	}
+
 	// If its a TPR type service, sync up the Limits since they aren't appied to the k8s resource
 	if desiredCfg.Type != "" {
 		desiredCfg.Limits.Memory = currentCfg.Limits.Memory
@@ -168,6 +230,26 @@ func alignServices(desiredCfg, currentCfg *bitesize.Service) {
 		}
 	}
 
+	// Custom resources are applied verbatim, without version. Fields
+	// defaulted by the API server and versions of the same API group are
+	// not changes.
+	if desiredCfg.IsCustomResource() {
+		desiredCfg.Version = currentCfg.Version
+		if desiredCfg.APIGroup() == currentCfg.APIGroup() {
+			currentCfg.APIVersion = desiredCfg.APIVersion
+		}
+		if currentCfg.Spec != nil {
+			currentCfg.Spec = pruneSpec(currentCfg.Spec, desiredCfg.Spec)
+		}
+	}
+
+	// ExternalName services have no workload to compare
+	if desiredCfg.IsExternalName() {
+		desiredCfg.Replicas = currentCfg.Replicas
+		desiredCfg.Limits = currentCfg.Limits
+		desiredCfg.Requests = currentCfg.Requests
+	}
+
 	// Sync up Requests in the case where different units are present, but they represent equivalent quantities
 	destmemreq, _ := resource.ParseQuantity(currentCfg.Requests.Memory)
 	srcmemreq, _ := resource.ParseQuantity(desiredCfg.Requests.Memory)
@@ -192,11 +274,33 @@ func alignServices(desiredCfg, currentCfg *bitesize.Service) {
 		desiredCfg.Limits.CPU = currentCfg.Limits.CPU
 	}
 
-	// Override source replicas with currentCfg replicas if HPA is active
-	if currentCfg.HPA.MinReplicas != 0 {
+	// Path types are unknown for ingresses on networking/v1beta1 clusters
+	for i, route := range currentCfg.ExternalRoutes {
+		if route.PathType != "" {
+			continue
+		}
+		for _, desired := range desiredCfg.ExternalRoutes {
+			if desired.Host == route.Host && desired.Path == route.Path {
+				currentCfg.ExternalRoutes[i].PathType = desired.PathType
+			}
+		}
+	}
+
+	// Pre-existing secrets named after the service can't be told apart
+	// from secrets managed outside of environment operator
+	if currentCfg.TLS == nil && desiredCfg.TLSProvider() == bitesize.TLSProviderSecret &&
+		desiredCfg.TLSSecretName() == desiredCfg.Name {
+		currentCfg.TLS = desiredCfg.TLS
+	}
+
+	// Override source replicas with currentCfg replicas if HPA or KEDA is active
+	if currentCfg.HPA.MinReplicas != 0 || currentCfg.HasKeda() {
 		desiredCfg.Replicas = currentCfg.Replicas
 	}
 
+	// health_check is migrated to liveness_probe, which is compared instead
+	currentCfg.HealthCheck = desiredCfg.HealthCheck
+
 	if desiredCfg.LivenessProbe != nil && currentCfg.LivenessProbe != nil {
 		if desiredCfg.LivenessProbe.InitialDelaySeconds == 0 {
 			desiredCfg.LivenessProbe.InitialDelaySeconds = currentCfg.LivenessProbe.InitialDelaySeconds
@@ -290,3 +394,39 @@ func alignServices(desiredCfg, currentCfg *bitesize.Service) {
 		}
 	}
 }
+
+// pruneSpec returns a copy of current custom resource spec with only the
+// keys set in desired spec
+func pruneSpec(current, desired map[string]interface{}) map[string]interface{} {
+	retval := map[string]interface{}{}
+	for k, v := range current {
+		d, ok := desired[k]
+		if !ok {
+			continue
+		}
+		retval[k] = pruneValue(v, d)
+	}
+	return retval
+}
+
+// pruneValue prunes maps, and maps in lists of the same length, in current
+// value to the keys set in desired value
+func pruneValue(current, desired interface{}) interface{} {
+	switch c := current.(type) {
+	case map[string]interface{}:
+		if d, ok := desired.(map[string]interface{}); ok {
+			return pruneSpec(c, d)
+		}
+	case []interface{}:
+		d, ok := desired.([]interface{})
+		if !ok || len(c) != len(d) {
+			return current
+		}
+		retval := make([]interface{}, len(c))
+		for i := range c {
+			retval[i] = pruneValue(c[i], d[i])
+		}
+		return retval
+	}
+	return current
+}
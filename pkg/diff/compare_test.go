@@ -66,6 +66,25 @@ func TestIgnoreStatusFields(t *testing.T) {
 	}
 }
 
+func TestIgnoreHealthCheckFields(t *testing.T) {
+	probe := &bitesize.Probe{Handler: bitesize.Handler{Exec: &bitesize.ExecAction{Command: []string{"check"}}}}
+	a := bitesize.Environment{
+		Services: bitesize.Services{{Name: "a", Version: "1", LivenessProbe: probe}},
+	}
+	b := bitesize.Environment{
+		Services: bitesize.Services{{
+			Name:          "a",
+			Version:       "1",
+			LivenessProbe: probe,
+			HealthCheck:   &bitesize.HealthCheck{Command: []string{"check"}},
+		}},
+	}
+
+	if Compare(a, b) {
+		t.Errorf("Expected diff to be empty, got: %s", Changes())
+	}
+}
+
 func TestDiffNames(t *testing.T) {
 	a := bitesize.Environment{Name: "asd"}
 	b := bitesize.Environment{Name: "asdf"}
@@ -214,3 +233,112 @@ func TestDiffVersionsSame(t *testing.T) {
 		}
 	}
 }
+
+func TestDefaultDenyNetworkPolicy(t *testing.T) {
+	a := bitesize.Environment{NetworkPolicy: bitesize.NetworkPolicySettings{DefaultDeny: true}}
+	b := bitesize.Environment{}
+
+	if !Compare(a, b) {
+		t.Errorf("Expected default deny network policy change to be detected")
+	}
+
+	if ServiceChanged("network_policy") {
+		t.Errorf("Expected environment change not to be reported as service change")
+	}
+
+	if Compare(a, a) {
+		t.Errorf("Expected diff to be empty, got: %s", Changes())
+	}
+
+	if !Compare(b, a) {
+		t.Errorf("Expected default deny network policy removal to be detected")
+	}
+	if change := Changes()["environment.network_policy"]; change != "DefaultDeny: -true +false" {
+		t.Errorf("Unexpected default deny change: %s", change)
+	}
+}
+
+func TestWorkloadChange(t *testing.T) {
+	a := bitesize.Environment{
+		Services: []bitesize.Service{{Name: "a", Version: "1", Workload: bitesize.WorkloadStatefulSet}},
+	}
+	b := bitesize.Environment{
+		Services: []bitesize.Service{{Name: "a", Version: "1"}},
+	}
+
+	if !Compare(a, b) {
+		t.Errorf("Expected workload change to be detected")
+	}
+
+	if !ServiceChanged("a") {
+		t.Errorf("Expected service a to be changed")
+	}
+}
+
+func TestJobGistChange(t *testing.T) {
+	job := func(hash string) bitesize.Gist {
+		g := bitesize.Gist{Name: "migrate", Type: bitesize.TypeJob}
+		g.Job.Annotations = map[string]string{bitesize.GistHashAnnotation: hash}
+		return g
+	}
+
+	a := bitesize.Environment{Gists: bitesize.Gists{job("abc")}}
+	b := bitesize.Environment{}
+
+	if !Compare(a, b) || !GistChanged("migrate") {
+		t.Errorf("Expected new job gist to be detected")
+	}
+
+	if ServiceChanged("migrate") {
+		t.Errorf("Expected gist change not to be reported as service change")
+	}
+
+	if Compare(a, a) {
+		t.Errorf("Expected diff to be empty, got: %s", Changes())
+	}
+
+	c := bitesize.Environment{Gists: bitesize.Gists{job("def")}}
+	if !Compare(c, a) || !GistChanged("migrate") {
+		t.Errorf("Expected job gist hash change to be detected")
+	}
+}
+
+func TestSecretGistChange(t *testing.T) {
+	secret := func(hash string) bitesize.Gist {
+		g := bitesize.Gist{Name: "db", Type: bitesize.TypeSecret}
+		g.Secret.Annotations = map[string]string{bitesize.GistHashAnnotation: hash}
+		return g
+	}
+
+	a := bitesize.Environment{Gists: bitesize.Gists{secret("abc")}}
+	b := bitesize.Environment{Gists: bitesize.Gists{secret("def")}}
+
+	if Compare(a, a) {
+		t.Errorf("Expected diff to be empty, got: %s", Changes())
+	}
+
+	if !Compare(b, a) || !GistChanged("db") {
+		t.Errorf("Expected secret gist hash change to be detected")
+	}
+}
+
+func TestUnknownRoutePathType(t *testing.T) {
+	route := bitesize.ExternalRoute{Host: "www.example.com", Path: "/api", Service: "api", Port: 80}
+	scraped := bitesize.Environment{
+		Services: bitesize.Services{{Name: "a", Version: "1", ExternalRoutes: []bitesize.ExternalRoute{route}}},
+	}
+
+	route.PathType = bitesize.PathTypeExact
+	desired := bitesize.Environment{
+		Services: bitesize.Services{{Name: "a", Version: "1", ExternalRoutes: []bitesize.ExternalRoute{route}}},
+	}
+
+	if Compare(desired, scraped) {
+		t.Errorf("Expected diff to be empty, got: %s", Changes())
+	}
+
+	scraped.Services[0].ExternalRoutes[0].PathType = bitesize.PathTypePrefix
+	if !Compare(desired, scraped) {
+		t.Error("Expected path type change to be detected")
+	}
+}
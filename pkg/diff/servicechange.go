@@ -10,6 +10,17 @@ func addServiceChange(svc, diff string) {
 	changeMap[svc] = diff
 }
 
+// addEnvironmentChange records change to environment-wide settings. These
+// are kept apart from service names so they never trigger a service deploy.
+func addEnvironmentChange(field, diff string) {
+	changeMap["environment."+field] = diff
+}
+
+// addGistChange records change to job or cronjob gist
+func addGistChange(name, diff string) {
+	changeMap["gist."+name] = diff
+}
+
 func ServiceChanged(serviceName string) bool {
 	_, serviceChangeExists := changeMap[serviceName]
 
@@ -19,6 +30,12 @@ func ServiceChanged(serviceName string) bool {
 	return false
 }
 
+// GistChanged returns true if job or cronjob gist content has changed
+func GistChanged(name string) bool {
+	_, gistChangeExists := changeMap["gist."+name]
+	return gistChangeExists
+}
+
 func Changes() map[string]string {
 	return changeMap
 }
@@ -0,0 +1,19 @@
+package git
+
+import (
+	gogit "gopkg.in/src-d/go-git.v4"
+)
+
+// HeadCommit returns SHA of the commit checked out in the repository
+// containing path
+func HeadCommit(path string) (string, error) {
+	repository, err := gogit.PlainOpenWithOptions(path, &gogit.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", err
+	}
+	head, err := repository.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
@@ -0,0 +1,29 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHeadCommit(t *testing.T) {
+	remotePath := createTestRepo(t)
+	localPath := createSrcPath(t)
+	defer cleanupTestPath(localPath)
+	defer cleanupTestPath(remotePath)
+
+	g := initAndClone(t, localPath, remotePath)
+	head, err := g.Repository.Head()
+	checkFatal(t, err)
+
+	subdir := filepath.Join(localPath, "environments")
+	checkFatal(t, os.MkdirAll(subdir, 0755))
+
+	commit, err := HeadCommit(subdir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if commit != head.Hash().String() {
+		t.Errorf("Expected commit %s, got %s", head.Hash().String(), commit)
+	}
+}
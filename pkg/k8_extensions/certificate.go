@@ -0,0 +1,60 @@
+package k8_extensions
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// CertificateAPIVersion is the API version cert-manager Certificates are
+// served under
+const CertificateAPIVersion = "cert-manager.io/v1"
+
+// Certificate represents cert-manager Certificate. Only fields used by the
+// operator are defined.
+type Certificate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CertificateSpec `json:"spec"`
+}
+
+// CertificateList is a list of Certificates
+type CertificateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Certificate `json:"items"`
+}
+
+// CertificateSpec describes the certificate and the secret it is stored in
+type CertificateSpec struct {
+	SecretName string          `json:"secretName"`
+	DNSNames   []string        `json:"dnsNames,omitempty"`
+	IssuerRef  IssuerReference `json:"issuerRef"`
+}
+
+// IssuerReference references Issuer or ClusterIssuer signing the
+// certificate
+type IssuerReference struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+// DeepCopyObject required to satisfy Object interface
+func (in *Certificate) DeepCopyObject() runtime.Object {
+	out := *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.DNSNames = append([]string(nil), in.Spec.DNSNames...)
+	return &out
+}
+
+// DeepCopyObject required to satisfy Object interface
+func (in *CertificateList) DeepCopyObject() runtime.Object {
+	out := *in
+	out.Items = make([]Certificate, len(in.Items))
+	for i := range in.Items {
+		out.Items[i] = *in.Items[i].DeepCopyObject().(*Certificate)
+	}
+	return &out
+}
@@ -0,0 +1,203 @@
+package k8_extensions
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Ingress types below mirror networking.k8s.io/v1, which is not available
+// in the vendored k8s.io/api version. They're kept in sync with upstream by
+// a test round-tripping the k8s.io/api fixture in test/assets.
+
+// IngressAPIVersion is the API version Ingress objects are served under
+const IngressAPIVersion = "networking.k8s.io/v1"
+
+// IngressClassAnnotation sets ingress class on clusters serving only
+// networking.k8s.io/v1beta1 ingresses
+const IngressClassAnnotation = "kubernetes.io/ingress.class"
+
+// PathType determines the interpretation of HTTPIngressPath Path
+type PathType string
+
+const (
+	// PathTypeExact matches the URL path exactly
+	PathTypeExact PathType = "Exact"
+	// PathTypePrefix matches based on a URL path prefix split by '/'
+	PathTypePrefix PathType = "Prefix"
+	// PathTypeImplementationSpecific leaves matching up to the IngressClass
+	PathTypeImplementationSpecific PathType = "ImplementationSpecific"
+)
+
+// Ingress represents networking.k8s.io/v1 Ingress
+type Ingress struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IngressSpec   `json:"spec,omitempty"`
+	Status IngressStatus `json:"status,omitempty"`
+}
+
+// IngressList is a list of networking.k8s.io/v1 Ingresses
+type IngressList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Ingress `json:"items"`
+}
+
+// IngressSpec describes the Ingress
+type IngressSpec struct {
+	IngressClassName *string         `json:"ingressClassName,omitempty"`
+	DefaultBackend   *IngressBackend `json:"defaultBackend,omitempty"`
+	TLS              []IngressTLS    `json:"tls,omitempty"`
+	Rules            []IngressRule   `json:"rules,omitempty"`
+}
+
+// IngressTLS describes the transport layer security for the Ingress
+type IngressTLS struct {
+	Hosts      []string `json:"hosts,omitempty"`
+	SecretName string   `json:"secretName,omitempty"`
+}
+
+// IngressStatus describes the current state of the Ingress
+type IngressStatus struct {
+	LoadBalancer IngressLoadBalancerStatus `json:"loadBalancer,omitempty"`
+}
+
+// IngressLoadBalancerStatus represents the status of a load-balancer
+type IngressLoadBalancerStatus struct {
+	Ingress []IngressLoadBalancerIngress `json:"ingress,omitempty"`
+}
+
+// IngressLoadBalancerIngress represents the status of a load-balancer
+// ingress point
+type IngressLoadBalancerIngress struct {
+	IP       string              `json:"ip,omitempty"`
+	Hostname string              `json:"hostname,omitempty"`
+	Ports    []IngressPortStatus `json:"ports,omitempty"`
+}
+
+// IngressPortStatus represents the error condition of a service port
+type IngressPortStatus struct {
+	Port     int32       `json:"port"`
+	Protocol v1.Protocol `json:"protocol"`
+	Error    *string     `json:"error,omitempty"`
+}
+
+// IngressRule maps the paths under a specified host to backends
+type IngressRule struct {
+	Host             string `json:"host,omitempty"`
+	IngressRuleValue `json:",inline,omitempty"`
+}
+
+// IngressRuleValue represents a rule to apply against incoming requests
+type IngressRuleValue struct {
+	HTTP *HTTPIngressRuleValue `json:"http,omitempty"`
+}
+
+// HTTPIngressRuleValue is a list of http selectors pointing to backends
+type HTTPIngressRuleValue struct {
+	Paths []HTTPIngressPath `json:"paths"`
+}
+
+// HTTPIngressPath associates a path with a backend
+type HTTPIngressPath struct {
+	Path     string         `json:"path,omitempty"`
+	PathType *PathType      `json:"pathType"`
+	Backend  IngressBackend `json:"backend"`
+}
+
+// IngressBackend describes the service or resource requests are sent to
+type IngressBackend struct {
+	Service  *IngressServiceBackend        `json:"service,omitempty"`
+	Resource *v1.TypedLocalObjectReference `json:"resource,omitempty"`
+}
+
+// IngressServiceBackend references a Kubernetes Service as a Backend
+type IngressServiceBackend struct {
+	Name string             `json:"name"`
+	Port ServiceBackendPort `json:"port,omitempty"`
+}
+
+// ServiceBackendPort is the service port being referenced, by name or
+// number
+type ServiceBackendPort struct {
+	Name   string `json:"name,omitempty"`
+	Number int32  `json:"number,omitempty"`
+}
+
+// DeepCopyObject required to satisfy Object interface
+func (in *Ingress) DeepCopyObject() runtime.Object {
+	out := *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = *in.Spec.deepCopy()
+	out.Status.LoadBalancer.Ingress = nil
+	for _, lb := range in.Status.LoadBalancer.Ingress {
+		ingress := IngressLoadBalancerIngress{IP: lb.IP, Hostname: lb.Hostname}
+		for _, p := range lb.Ports {
+			if p.Error != nil {
+				e := *p.Error
+				p.Error = &e
+			}
+			ingress.Ports = append(ingress.Ports, p)
+		}
+		out.Status.LoadBalancer.Ingress = append(out.Status.LoadBalancer.Ingress, ingress)
+	}
+	return &out
+}
+
+// DeepCopyObject required to satisfy Object interface
+func (in *IngressList) DeepCopyObject() runtime.Object {
+	out := *in
+	out.Items = make([]Ingress, len(in.Items))
+	for i := range in.Items {
+		out.Items[i] = *in.Items[i].DeepCopyObject().(*Ingress)
+	}
+	return &out
+}
+
+func (in *IngressSpec) deepCopy() *IngressSpec {
+	out := &IngressSpec{}
+	if in.IngressClassName != nil {
+		c := *in.IngressClassName
+		out.IngressClassName = &c
+	}
+	if in.DefaultBackend != nil {
+		out.DefaultBackend = in.DefaultBackend.deepCopy()
+	}
+	for _, tls := range in.TLS {
+		out.TLS = append(out.TLS, IngressTLS{
+			Hosts:      append([]string(nil), tls.Hosts...),
+			SecretName: tls.SecretName,
+		})
+	}
+	for _, rule := range in.Rules {
+		r := IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			r.HTTP = &HTTPIngressRuleValue{}
+			for _, p := range rule.HTTP.Paths {
+				path := HTTPIngressPath{Path: p.Path, Backend: *p.Backend.deepCopy()}
+				if p.PathType != nil {
+					pt := *p.PathType
+					path.PathType = &pt
+				}
+				r.HTTP.Paths = append(r.HTTP.Paths, path)
+			}
+		}
+		out.Rules = append(out.Rules, r)
+	}
+	return out
+}
+
+func (in *IngressBackend) deepCopy() *IngressBackend {
+	out := &IngressBackend{}
+	if in.Service != nil {
+		s := *in.Service
+		out.Service = &s
+	}
+	if in.Resource != nil {
+		out.Resource = in.Resource.DeepCopy()
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+package k8_extensions
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// TestIngressUpstreamFixture round-trips networking.k8s.io/v1 Ingress
+// fixture with every field set, copied from k8s.io/api testdata/HEAD.
+// Fields missing from Ingress types would be dropped on update.
+func TestIngressUpstreamFixture(t *testing.T) {
+	data, err := ioutil.ReadFile("../../test/assets/networking.k8s.io.v1.Ingress.json")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	var ingress Ingress
+	if err := json.Unmarshal(data, &ingress); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	roundTrip, err := json.Marshal(ingress.DeepCopyObject())
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	var expected, actual map[string]interface{}
+	json.Unmarshal(data, &expected)
+	json.Unmarshal(roundTrip, &actual)
+
+	// metadata is vendored metav1.ObjectMeta, not part of the mirrored types
+	for _, field := range []string{"apiVersion", "kind", "spec", "status"} {
+		if !reflect.DeepEqual(expected[field], actual[field]) {
+			t.Errorf("Ingress %s out of sync with upstream:\n%v\n%v", field, expected[field], actual[field])
+		}
+	}
+}
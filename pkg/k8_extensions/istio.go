@@ -0,0 +1,9 @@
+package k8_extensions
+
+// Kinds of Istio resources managed for services, also used as types of
+// their CustomResourceDefinition clients
+const (
+	GatewayKind         = "Gateway"
+	VirtualServiceKind  = "VirtualService"
+	DestinationRuleKind = "DestinationRule"
+)
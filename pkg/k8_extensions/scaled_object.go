@@ -0,0 +1,106 @@
+package k8_extensions
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ScaledObjectGroupVersion is the API group and version KEDA ScaledObjects
+// are served under
+var ScaledObjectGroupVersion = schema.GroupVersion{Group: "keda.sh", Version: "v1alpha1"}
+
+// ScaledObjectAPIVersion is apiVersion of KEDA ScaledObjects
+var ScaledObjectAPIVersion = ScaledObjectGroupVersion.String()
+
+// ScaledObject represents KEDA ScaledObject. Only fields used by the
+// operator are defined.
+type ScaledObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ScaledObjectSpec `json:"spec"`
+}
+
+// ScaledObjectList is a list of ScaledObjects
+type ScaledObjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ScaledObject `json:"items"`
+}
+
+// ScaledObjectSpec describes the workload scaled and the triggers it's
+// scaled on
+type ScaledObjectSpec struct {
+	ScaleTargetRef  ScaleTarget     `json:"scaleTargetRef"`
+	PollingInterval *int32          `json:"pollingInterval,omitempty"`
+	CooldownPeriod  *int32          `json:"cooldownPeriod,omitempty"`
+	MinReplicaCount *int32          `json:"minReplicaCount,omitempty"`
+	MaxReplicaCount *int32          `json:"maxReplicaCount,omitempty"`
+	Triggers        []ScaleTriggers `json:"triggers"`
+}
+
+// ScaleTarget references the workload scaled
+type ScaleTarget struct {
+	Name       string `json:"name"`
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+}
+
+// ScaleTriggers is an event source the workload is scaled on
+type ScaleTriggers struct {
+	Type              string             `json:"type"`
+	Metadata          map[string]string  `json:"metadata"`
+	AuthenticationRef *AuthenticationRef `json:"authenticationRef,omitempty"`
+}
+
+// AuthenticationRef references TriggerAuthentication providing trigger
+// credentials
+type AuthenticationRef struct {
+	Name string `json:"name"`
+	Kind string `json:"kind,omitempty"`
+}
+
+// DeepCopyObject required to satisfy Object interface
+func (in *ScaledObject) DeepCopyObject() runtime.Object {
+	out := *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.PollingInterval = copyInt32(in.Spec.PollingInterval)
+	out.Spec.CooldownPeriod = copyInt32(in.Spec.CooldownPeriod)
+	out.Spec.MinReplicaCount = copyInt32(in.Spec.MinReplicaCount)
+	out.Spec.MaxReplicaCount = copyInt32(in.Spec.MaxReplicaCount)
+	out.Spec.Triggers = make([]ScaleTriggers, len(in.Spec.Triggers))
+	for i, trigger := range in.Spec.Triggers {
+		out.Spec.Triggers[i] = ScaleTriggers{Type: trigger.Type}
+		if trigger.Metadata != nil {
+			out.Spec.Triggers[i].Metadata = map[string]string{}
+			for k, v := range trigger.Metadata {
+				out.Spec.Triggers[i].Metadata[k] = v
+			}
+		}
+		if trigger.AuthenticationRef != nil {
+			ref := *trigger.AuthenticationRef
+			out.Spec.Triggers[i].AuthenticationRef = &ref
+		}
+	}
+	return &out
+}
+
+// DeepCopyObject required to satisfy Object interface
+func (in *ScaledObjectList) DeepCopyObject() runtime.Object {
+	out := *in
+	out.Items = make([]ScaledObject, len(in.Items))
+	for i := range in.Items {
+		out.Items[i] = *in.Items[i].DeepCopyObject().(*ScaledObject)
+	}
+	return &out
+}
+
+func copyInt32(in *int32) *int32 {
+	if in == nil {
+		return nil
+	}
+	out := *in
+	return &out
+}
@@ -25,7 +25,8 @@ type PrsnExternalResource struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata"`
 
-	Spec PrsnExternalResourceSpec `json:"spec"`
+	Spec   PrsnExternalResourceSpec `json:"spec"`
+	Status map[string]interface{}   `json:"status,omitempty"`
 }
 
 // PrsnExternalResourceSpec represents format for these mappings - which is
@@ -52,6 +53,9 @@ type PrsnExternalResourceSpec struct {
 	ExportTo        []string                      `json:"export_to,omitempty"`
 	SubjectAltNames []string                      `json:"subject_alt_names,omitempty"`
 	HTTP            []*HTTPRoute                  `json:"http,omitempty"`
+	Host            string                        `json:"host,omitempty"`
+	TrafficPolicy   *TrafficPolicy                `json:"trafficPolicy,omitempty"`
+	Subsets         []*Subset                     `json:"subsets,omitempty"`
 }
 
 type ServiceEntry_Endpoint struct {
@@ -88,25 +92,82 @@ type ExternalSecretSecretDescriptor struct {
 
 // HTTPRoute represents format for these mappings
 type HTTPRoute struct {
-	Name  string                  `json:"name,omitempty"`
-	Match []*HTTPMatchRequest     `json:"match,omitempty"`
-	Route []*HTTPRouteDestination `json:"route,omitempty"`
+	Name    string                  `json:"name,omitempty"`
+	Match   []*HTTPMatchRequest     `json:"match,omitempty"`
+	Route   []*HTTPRouteDestination `json:"route,omitempty"`
+	Timeout string                  `json:"timeout,omitempty"`
+	Retries *HTTPRetry              `json:"retries,omitempty"`
+}
+
+// HTTPRetry represents retry policy of HTTP route
+type HTTPRetry struct {
+	Attempts      int32  `json:"attempts"`
+	PerTryTimeout string `json:"perTryTimeout,omitempty"`
+	RetryOn       string `json:"retryOn,omitempty"`
+}
+
+// TrafficPolicy represents DestinationRule traffic policy
+type TrafficPolicy struct {
+	ConnectionPool   *ConnectionPoolSettings `json:"connectionPool,omitempty"`
+	OutlierDetection *OutlierDetection       `json:"outlierDetection,omitempty"`
+	TLS              *ClientTLSSettings      `json:"tls,omitempty"`
+}
+
+// ConnectionPoolSettings represents connection pool limits of a host
+type ConnectionPoolSettings struct {
+	TCP  *TCPSettings  `json:"tcp,omitempty"`
+	HTTP *HTTPSettings `json:"http,omitempty"`
+}
+
+// TCPSettings represents TCP connection pool limits
+type TCPSettings struct {
+	MaxConnections int32  `json:"maxConnections,omitempty"`
+	ConnectTimeout string `json:"connectTimeout,omitempty"`
+}
+
+// HTTPSettings represents HTTP connection pool limits
+type HTTPSettings struct {
+	HTTP1MaxPendingRequests  int32 `json:"http1MaxPendingRequests,omitempty"`
+	HTTP2MaxRequests         int32 `json:"http2MaxRequests,omitempty"`
+	MaxRequestsPerConnection int32 `json:"maxRequestsPerConnection,omitempty"`
+}
+
+// OutlierDetection represents ejection of failing hosts from load balancing
+type OutlierDetection struct {
+	Consecutive5xxErrors int32  `json:"consecutive5xxErrors,omitempty"`
+	Interval             string `json:"interval,omitempty"`
+	BaseEjectionTime     string `json:"baseEjectionTime,omitempty"`
+	MaxEjectionPercent   int32  `json:"maxEjectionPercent,omitempty"`
+}
+
+// ClientTLSSettings represents TLS mode of connections to a host
+type ClientTLSSettings struct {
+	Mode string `json:"mode,omitempty"`
+}
+
+// Subset represents a named group of host endpoints selected by labels
+type Subset struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // HTTPMatchRequest represents format for these mappings
 type HTTPMatchRequest struct {
-	Name string        `json:"name,omitempty"`
-	URI  *StringPrefix `json:"uri,omitempty"`
+	Name      string        `json:"name,omitempty"`
+	URI       *StringPrefix `json:"uri,omitempty"`
+	Authority *StringPrefix `json:"authority,omitempty"`
 }
 
 // StringPrefix represents format for these mappings
 type StringPrefix struct {
 	Prefix string `json:"prefix,omitempty"`
+	Exact  string `json:"exact,omitempty"`
 }
 
 // HTTPRouteDestination represents format for these mappings
 type HTTPRouteDestination struct {
 	Destination *Destination `json:"destination,omitempty"`
+	Weight      int32        `json:"weight,omitempty"`
 }
 
 // Destination represents format for these mappings
@@ -3,10 +3,14 @@ package reaper
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
 	"github.com/pearsontechnology/environment-operator/pkg/cluster"
+	"github.com/pearsontechnology/environment-operator/pkg/config"
+	"github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+	"github.com/pearsontechnology/environment-operator/pkg/translator"
 	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
@@ -46,8 +50,23 @@ func (r *Reaper) Cleanup(cfg *bitesize.Environment) error {
 
 		// delete ingresses that were removed from the service config
 		r.CleanupIngress(cfg.Services.FindByName(service.Name), &service)
+		// delete TLS resources no longer matching the service TLS provider
+		r.CleanupTLS(cfg.Services.FindByName(service.Name), &service)
 		// delete HPA objects  that were removed from the service config
 		r.CleanupHPA(cfg.Services.FindByName(service.Name), &service)
+		// delete KEDA ScaledObjects that were removed from the service config
+		r.CleanupKeda(cfg.Services.FindByName(service.Name), &service)
+		// delete network policies that were removed from the service config
+		r.CleanupNetworkPolicy(cfg.Services.FindByName(service.Name), &service)
+		// delete workloads left behind after the service workload kind changed
+		r.CleanupWorkload(cfg.Services.FindByName(service.Name), &service)
+	}
+
+	if !cfg.NetworkPolicy.DefaultDeny && current.NetworkPolicy.DefaultDeny {
+		log.Infof("REAPER: deleting default deny network policy because it was removed from the environment config")
+		if err := r.destroyNetworkPolicy(translator.DefaultDenyPolicyName); err != nil {
+			log.Error(err)
+		}
 	}
 
 	// cleanup all resources that were removed from the service config
@@ -60,14 +79,33 @@ func (r *Reaper) Cleanup(cfg *bitesize.Environment) error {
 // the BiteSize service from the cluster
 func (r *Reaper) deleteService(svc bitesize.Service) error {
 
+	if svc.IsCustomResource() {
+		if r.deletionProtected(svc) {
+			return nil
+		}
+		return r.Wrapper.DestroyCustomResource(svc, r.Namespace)
+	}
+
 	if err := r.destroyIngress(svc.Name); err != nil {
 		log.Errorf("REAPER: failed to destroy ingress: %s", err.Error())
 	}
 
+	for _, provider := range svc.TLSProviders {
+		if err := r.destroyTLS(svc.Name, provider); err != nil {
+			log.Errorf("REAPER: failed to destroy TLS resources: %s", err.Error())
+		}
+	}
+
 	if err := r.destroyDeployment(svc.Name); err != nil {
 		log.Errorf("REAPER: failed to destroy deployment: %s", err.Error())
 	}
 
+	if svc.IsStatefulSet() {
+		if err := r.destroyStatefulSet(svc.Name); err != nil {
+			log.Errorf("REAPER: failed to destroy statefulset: %s", err.Error())
+		}
+	}
+
 	if err := r.destroyService(svc.Name); err != nil {
 		log.Errorf("REAPER: failed to destroy service failed: %s", err.Error())
 	}
@@ -76,39 +114,74 @@ func (r *Reaper) deleteService(svc bitesize.Service) error {
 		log.Errorf("REAPER: failed to destroy HPA failed: %s", err.Error())
 	}
 
-	for _, volume := range svc.Volumes {
-		if volume.IsConfigMapVolume() || volume.IsSecretVolume() {
-			continue
+	if svc.HasKeda() {
+		if err := r.destroyScaledObject(svc.Name); err != nil {
+			log.Errorf("REAPER: failed to destroy scaled object: %s", err.Error())
 		}
-		if err := r.destroyPersistentVolume(volume.Name); err != nil {
-			log.Errorf("REAPER: failed to destroy persistent volume: %s", err.Error())
+	}
+
+	if svc.HasNetworkPolicy() {
+		if err := r.destroyNetworkPolicy(svc.Name); err != nil {
+			log.Errorf("REAPER: failed to destroy network policy: %s", err.Error())
 		}
 	}
 
-	if err := r.destroyCustomResourceDefinition(svc.Name); err != nil {
-		log.Errorf("REAPER: failed to destroy custom resources: %s", err.Error())
+	r.destroyVolumes(svc)
+
+	if err := r.destroyServiceMesh(svc.Name); err != nil {
+		log.Errorf("REAPER: failed to destroy service mesh resources: %s", err.Error())
+	}
+
+	if svc.Type != "" && !r.deletionProtected(svc) {
+		if err := r.destroyCustomResourceDefinition(svc); err != nil {
+			log.Errorf("REAPER: failed to destroy custom resources: %s", err.Error())
+		}
 	}
 	return nil
 }
 
+// deletionProtected returns true if DELETION_PROTECTION is enabled and
+// service custom resource must be kept
+func (r *Reaper) deletionProtected(svc bitesize.Service) bool {
+	if !config.Env.DeletionProtection || !svc.IsDeletionProtected() {
+		return false
+	}
+	log.Warnf("REAPER: %s %s is deletion protected, apply it with deletion_protection: false before removing", svc.Type, svc.Name)
+	return true
+}
+
 // XXX: I hate this repetition
 
 func (r *Reaper) destroyIngress(name string) error {
 	client := k8s.Ingress{
 		Interface: r.Wrapper.Interface,
 		Namespace: r.Namespace,
-	}
-
-	if err := r.destroyExternalSecret(name); err != nil {
-		log.Errorf("REAPER: failed to destroy ExternalSecret: %s", err.Error())
+		Version:   r.Wrapper.IngressVersion,
 	}
 
 	return client.Destroy(name)
 }
 
-func (r *Reaper) destroyExternalSecret(name string) error {
+// destroyTLS removes ExternalSecret or Certificate created for the service
+// by the TLS provider, along with its copy in istio-system
+func (r *Reaper) destroyTLS(name, provider string) error {
+	switch provider {
+	case bitesize.TLSProviderExternalSecrets:
+		if err := r.destroyExternalSecret(name); err != nil {
+			return err
+		}
+		return r.destroyMeshExternalSecret(name)
+	case bitesize.TLSProviderCertManager:
+		if err := r.destroyCertificate(name); err != nil {
+			return err
+		}
+		return r.destroyMeshCertificate(name)
+	}
+	return nil
+}
 
-	client, err := k8s.CRDClient(&schema.GroupVersion{
+func (r *Reaper) destroyExternalSecret(name string) error {
+	client, err := r.Wrapper.CRDClientFor(schema.GroupVersion{
 		Group:   "kubernetes-client.io",
 		Version: "v1",
 	})
@@ -123,7 +196,49 @@ func (r *Reaper) destroyExternalSecret(name string) error {
 		Type:      "ExternalSecret",
 	}
 
-	return es.Destroy(name)
+	if es.Exist(name) {
+		return es.Destroy(name)
+	}
+	return nil
+}
+
+func (r *Reaper) destroyCertificate(name string) error {
+	client, err := r.Wrapper.CRDClientFor(schema.GroupVersion{
+		Group:   "cert-manager.io",
+		Version: "v1",
+	})
+
+	if err != nil {
+		return err
+	}
+
+	cert := k8s.Certificate{
+		Interface: client,
+		Namespace: r.Namespace,
+	}
+
+	if cert.Exist(name) {
+		return cert.Destroy(name)
+	}
+	return nil
+}
+
+func (r *Reaper) destroyScaledObject(name string) error {
+	client, err := r.Wrapper.CRDClientFor(k8_extensions.ScaledObjectGroupVersion)
+
+	if err != nil {
+		return err
+	}
+
+	so := k8s.ScaledObject{
+		Interface: client,
+		Namespace: r.Namespace,
+	}
+
+	if so.Exist(name) {
+		return so.Destroy(name)
+	}
+	return nil
 }
 
 func (r *Reaper) destroyDeployment(name string) error {
@@ -137,6 +252,17 @@ func (r *Reaper) destroyDeployment(name string) error {
 	return nil
 }
 
+func (r *Reaper) destroyStatefulSet(name string) error {
+	client := k8s.StatefulSet{
+		Interface: r.Wrapper.Interface,
+		Namespace: r.Namespace,
+	}
+	if client.Exist(name) {
+		return client.Destroy(name)
+	}
+	return nil
+}
+
 func (r *Reaper) destroyService(name string) error {
 	client := k8s.Service{
 		Interface: r.Wrapper.Interface,
@@ -153,6 +279,28 @@ func (r *Reaper) destroyHPA(name string) error {
 	return client.Destroy(name)
 }
 
+func (r *Reaper) destroyNetworkPolicy(name string) error {
+	client := k8s.NetworkPolicy{
+		Interface: r.Wrapper.Interface,
+		Namespace: r.Namespace,
+	}
+	return client.Destroy(name)
+}
+
+// destroyVolumes deletes persistent volume claims of service. Claims
+// created from statefulset volumeClaimTemplates are retained, same as
+// kubernetes does when statefulset is deleted.
+func (r *Reaper) destroyVolumes(svc bitesize.Service) {
+	for _, volume := range svc.Volumes {
+		if volume.IsConfigMapVolume() || volume.IsSecretVolume() || svc.IsStatefulSet() {
+			continue
+		}
+		if err := r.destroyPersistentVolume(volume.Name); err != nil {
+			log.Errorf("REAPER: failed to destroy persistent volume: %s", err.Error())
+		}
+	}
+}
+
 func (r *Reaper) destroyPersistentVolume(name string) error {
 	client := k8s.PersistentVolumeClaim{
 		Interface: r.Wrapper.Interface,
@@ -161,10 +309,113 @@ func (r *Reaper) destroyPersistentVolume(name string) error {
 	return client.Destroy(name)
 }
 
-func (r *Reaper) destroyCustomResourceDefinition(name string) error {
+// destroyCustomResourceDefinition deletes custom resource of typed service,
+// using API group of the type, the same as it was listed with
+func (r *Reaper) destroyCustomResourceDefinition(svc bitesize.Service) error {
+	mapper := &translator.KubeMapper{BiteService: &svc, Namespace: r.Namespace}
+	crd, err := mapper.CustomResourceDefinition()
+	if err != nil {
+		return err
+	}
+
+	gv, err := schema.ParseGroupVersion(crd.APIVersion)
+	if err != nil {
+		return err
+	}
+	client, err := r.Wrapper.CRDClientFor(gv)
+	if err != nil {
+		return err
+	}
+
+	rsc := k8s.CustomResourceDefinition{
+		Interface: client,
+		Namespace: r.Namespace,
+		Type:      svc.Type,
+	}
+	if rsc.Exist(svc.Name) {
+		return rsc.Destroy(svc.Name)
+	}
+	return nil
+}
+
+// destroyServiceMesh deletes Istio Gateway, VirtualService and
+// DestinationRule of service mesh enabled service
+func (r *Reaper) destroyServiceMesh(name string) error {
+	client, err := r.Wrapper.CRDClientFor(schema.GroupVersion{
+		Group:   "networking.istio.io",
+		Version: "v1alpha3",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, kind := range []string{k8_extensions.GatewayKind, k8_extensions.VirtualServiceKind, k8_extensions.DestinationRuleKind} {
+		rsc := k8s.CustomResourceDefinition{
+			Interface: client,
+			Namespace: r.Namespace,
+			Type:      kind,
+		}
+		if rsc.Exist(name) {
+			if err := rsc.Destroy(name); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+// destroyMeshExternalSecret deletes ExternalSecret in istio-system created for
+// service in this namespace. Secrets are named after services, so ones
+// reading keys of other namespaces are left alone.
+func (r *Reaper) destroyMeshExternalSecret(name string) error {
+	client, err := r.Wrapper.CRDClientFor(schema.GroupVersion{
+		Group:   "kubernetes-client.io",
+		Version: "v1",
+	})
+	if err != nil {
+		return err
+	}
+
+	es := k8s.ExternalSecret{
+		Interface: client,
+		Namespace: "istio-system",
+		Type:      "ExternalSecret",
+	}
+	secret, _ := es.Get(name)
+	if secret == nil {
+		return nil
+	}
+	suffix := fmt.Sprintf("/%s/%s.crt", r.Namespace, name)
+	for _, data := range secret.SecretDescriptor.Data {
+		if strings.HasSuffix(data["key"], suffix) {
+			return es.Destroy(name)
+		}
+	}
+	return nil
+}
+
+// destroyMeshCertificate deletes Certificate in istio-system created for
+// service in this namespace
+func (r *Reaper) destroyMeshCertificate(name string) error {
+	client, err := r.Wrapper.CRDClientFor(schema.GroupVersion{
+		Group:   "cert-manager.io",
+		Version: "v1",
+	})
+	if err != nil {
+		return err
+	}
+
+	cert := k8s.Certificate{
+		Interface: client,
+		Namespace: "istio-system",
+	}
+	existing, _ := cert.Get(name)
+	if existing == nil || existing.Labels["namespace"] != r.Namespace {
+		return nil
+	}
+	return cert.Destroy(name)
+}
+
 func (r *Reaper) destroyResource(name string, rstype string) error {
 	switch rstype {
 	case bitesize.TypeConfigMap:
@@ -191,6 +442,14 @@ func (r *Reaper) destroyResource(name string, rstype string) error {
 			}
 			return client.Destroy(name)
 		}
+	case bitesize.TypeSecret:
+		{
+			client := k8s.Secret{
+				Interface: r.Wrapper.Interface,
+				Namespace: r.Namespace,
+			}
+			return client.Destroy(name)
+		}
 	}
 
 	return nil
@@ -207,6 +466,24 @@ func (r *Reaper) CleanupIngress(configSvc, clusterSvc *bitesize.Service) {
 	}
 }
 
+// CleanupTLS deletes ExternalSecrets or Certificates left behind after the
+// service TLS provider changed, ssl was disabled or the ingress was removed
+// from the config
+func (r *Reaper) CleanupTLS(configSvc, clusterSvc *bitesize.Service) {
+	if configSvc == nil {
+		return
+	}
+	for _, provider := range clusterSvc.TLSProviders {
+		if provider == configSvc.TLSProvider() {
+			continue
+		}
+		log.Infof("REAPER: deleting %s TLS resources of %s because they are no longer used by the service config", provider, clusterSvc.Name)
+		if err := r.destroyTLS(clusterSvc.Name, provider); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
 // CleanupHPA deletes HPA object if HPA config is removed from the service config
 func (r *Reaper) CleanupHPA(configSvc, clusterSvc *bitesize.Service) {
 	if configSvc != nil && configSvc.HPA.MinReplicas == 0 && clusterSvc.HPA.MinReplicas != 0 {
@@ -215,16 +492,64 @@ func (r *Reaper) CleanupHPA(configSvc, clusterSvc *bitesize.Service) {
 	}
 }
 
+// CleanupKeda deletes ScaledObject if keda config is removed from the
+// service config
+func (r *Reaper) CleanupKeda(configSvc, clusterSvc *bitesize.Service) {
+	if configSvc != nil && !configSvc.HasKeda() && clusterSvc.HasKeda() {
+		log.Infof("REAPER: deleting scaled object %s because keda was removed from the service config", clusterSvc.Name)
+		if err := r.destroyScaledObject(clusterSvc.Name); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// CleanupNetworkPolicy deletes network policy if allow_from and allow_to are removed from the service config
+func (r *Reaper) CleanupNetworkPolicy(configSvc, clusterSvc *bitesize.Service) {
+	if configSvc != nil && !configSvc.HasNetworkPolicy() && clusterSvc.HasNetworkPolicy() {
+		log.Infof("REAPER: deleting network policy %s because it was removed from the service config", clusterSvc.Name)
+		if err := r.destroyNetworkPolicy(clusterSvc.Name); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// CleanupWorkload deletes deployment or statefulset no longer matching the
+// workload kind in the service config, or of services changed to
+// ExternalName services
+func (r *Reaper) CleanupWorkload(configSvc, clusterSvc *bitesize.Service) {
+	if configSvc == nil || configSvc.Type != "" || configSvc.IsBlueGreenParentDeployment() {
+		return
+	}
+
+	if configSvc.IsExternalName() {
+		if clusterSvc.IsStatefulSet() {
+			log.Infof("REAPER: deleting statefulset %s because service is now ExternalName", clusterSvc.Name)
+			if err := r.destroyStatefulSet(clusterSvc.Name); err != nil {
+				log.Error(err)
+			}
+		} else if err := r.destroyDeployment(clusterSvc.Name); err != nil {
+			log.Error(err)
+		}
+		r.destroyVolumes(*clusterSvc)
+		return
+	}
+
+	if configSvc.IsStatefulSet() {
+		if err := r.destroyDeployment(clusterSvc.Name); err != nil {
+			log.Error(err)
+		}
+	} else if clusterSvc.IsStatefulSet() {
+		log.Infof("REAPER: deleting statefulset %s because service workload is no longer statefulset", clusterSvc.Name)
+		if err := r.destroyStatefulSet(clusterSvc.Name); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
 // CleanupGists deletes all gist types imported, if the corresponding gist is removed from the config
 func (r *Reaper) CleanupGists(configRes bitesize.Gists, clusterRes bitesize.Gists) {
 	for _, res := range clusterRes {
-		found := false
-		for _, cfgRes := range configRes {
-			if res.Name == cfgRes.Name {
-				found = true
-			}
-		}
-		if !found {
+		if configRes.FindByName(res.Name, res.Type) == nil {
 			log.Infof("REAPER: Found orphan resource %s, type %s deleting.", res.Name, res.Type)
 			err := r.destroyResource(res.Name, res.Type)
 			if err != nil {
@@ -1,14 +1,25 @@
 package reaper
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
 	"github.com/pearsontechnology/environment-operator/pkg/cluster"
+	"github.com/pearsontechnology/environment-operator/pkg/config"
+	ext "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+	"github.com/pearsontechnology/environment-operator/pkg/translator"
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
 	fakecrd "github.com/pearsontechnology/environment-operator/pkg/util/k8s/fake"
 	apps_v1 "k8s.io/api/apps/v1"
+	v1batch "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	netwk_v1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -80,3 +91,464 @@ func TestDeleteService(t *testing.T) {
 	}
 
 }
+
+func TestCleanupNetworkPolicy(t *testing.T) {
+	c := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "sample",
+			},
+		},
+		&netwk_v1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "abr",
+				Namespace: "sample",
+				Labels: map[string]string{
+					"creator": "pipeline",
+				},
+			},
+			Spec: netwk_v1.NetworkPolicySpec{
+				Ingress: []netwk_v1.NetworkPolicyIngressRule{
+					{
+						From: []netwk_v1.NetworkPolicyPeer{
+							{IPBlock: &netwk_v1.IPBlock{CIDR: "10.0.0.0/8"}},
+						},
+					},
+				},
+			},
+		},
+		translator.DefaultDenyNetworkPolicy("sample"),
+	)
+
+	wrapper := &cluster.Cluster{
+		Interface: c,
+		CRDClient: fakecrd.CRDClient("prsn.io", "v1"),
+	}
+
+	reaper := Reaper{
+		Wrapper:   wrapper,
+		Namespace: "sample",
+	}
+
+	cfg, _ := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment2")
+
+	reaper.Cleanup(cfg)
+
+	if np, err := wrapper.NetworkingV1().NetworkPolicies("sample").Get("abr", metav1.GetOptions{}); err == nil {
+		t.Errorf("Expected network policy nil, got: %+v", np)
+	}
+
+	if np, err := wrapper.NetworkingV1().NetworkPolicies("sample").Get(translator.DefaultDenyPolicyName, metav1.GetOptions{}); err == nil {
+		t.Errorf("Expected default deny network policy nil, got: %+v", np)
+	}
+}
+
+func TestCleanupWorkload(t *testing.T) {
+	c := fake.NewSimpleClientset(
+		&apps_v1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "kafka",
+				Namespace: "sample",
+			},
+		},
+		&apps_v1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cache",
+				Namespace: "sample",
+			},
+		},
+		&apps_v1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "legacy",
+				Namespace: "sample",
+			},
+		},
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "legacy-data",
+				Namespace: "sample",
+			},
+		},
+		&apps_v1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "legacy-db",
+				Namespace: "sample",
+			},
+		},
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "data-legacy-db-0",
+				Namespace: "sample",
+			},
+		},
+	)
+
+	wrapper := &cluster.Cluster{
+		Interface: c,
+		CRDClient: fakecrd.CRDClient("prsn.io", "v1"),
+	}
+
+	reaper := Reaper{
+		Wrapper:   wrapper,
+		Namespace: "sample",
+	}
+
+	// deployment replaced by statefulset
+	reaper.CleanupWorkload(
+		&bitesize.Service{Name: "kafka", Workload: bitesize.WorkloadStatefulSet},
+		&bitesize.Service{Name: "kafka", Workload: bitesize.WorkloadStatefulSet},
+	)
+	if d, err := wrapper.AppsV1().Deployments("sample").Get("kafka", metav1.GetOptions{}); err == nil {
+		t.Errorf("Expected deployment nil, got: %+v", d)
+	}
+
+	// statefulset replaced by deployment
+	reaper.CleanupWorkload(
+		&bitesize.Service{Name: "cache"},
+		&bitesize.Service{Name: "cache", Workload: bitesize.WorkloadStatefulSet},
+	)
+	if s, err := wrapper.AppsV1().StatefulSets("sample").Get("cache", metav1.GetOptions{}); err == nil {
+		t.Errorf("Expected statefulset nil, got: %+v", s)
+	}
+
+	// services changed to ExternalName services
+	externalName := &bitesize.ServiceType{Type: bitesize.ServiceTypeExternalName, ExternalName: "legacy.example.com"}
+	reaper.CleanupWorkload(
+		&bitesize.Service{Name: "legacy", ServiceType: externalName},
+		&bitesize.Service{Name: "legacy", Volumes: []bitesize.Volume{{Name: "legacy-data", Path: "/data"}}},
+	)
+	if d, err := wrapper.AppsV1().Deployments("sample").Get("legacy", metav1.GetOptions{}); err == nil {
+		t.Errorf("Expected deployment nil, got: %+v", d)
+	}
+	if _, err := wrapper.CoreV1().PersistentVolumeClaims("sample").Get("legacy-data", metav1.GetOptions{}); err == nil {
+		t.Error("Expected deployment volume to be deleted")
+	}
+
+	reaper.CleanupWorkload(
+		&bitesize.Service{Name: "legacy-db", ServiceType: externalName},
+		&bitesize.Service{Name: "legacy-db", Workload: bitesize.WorkloadStatefulSet, Volumes: []bitesize.Volume{{Name: "data", Path: "/data"}}},
+	)
+	if s, err := wrapper.AppsV1().StatefulSets("sample").Get("legacy-db", metav1.GetOptions{}); err == nil {
+		t.Errorf("Expected statefulset nil, got: %+v", s)
+	}
+	if _, err := wrapper.CoreV1().PersistentVolumeClaims("sample").Get("data-legacy-db-0", metav1.GetOptions{}); err != nil {
+		t.Error("Expected statefulset claims to be retained")
+	}
+}
+
+func TestCleanupTLS(t *testing.T) {
+	var objects []runtime.Object
+	for _, ns := range []string{"sample", "istio-system"} {
+		objects = append(objects, &ext.Certificate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "frontend",
+				Namespace: ns,
+				Labels: map[string]string{
+					"creator":   "pipeline",
+					"name":      "frontend",
+					"namespace": "sample",
+				},
+			},
+		})
+	}
+	objects = append(objects, &ext.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backend",
+			Namespace: "istio-system",
+			Labels: map[string]string{
+				"creator":   "pipeline",
+				"name":      "backend",
+				"namespace": "other",
+			},
+		},
+	})
+	crdcli := fakecrd.CertificateClient(objects...)
+
+	reaper := Reaper{
+		Wrapper: &cluster.Cluster{
+			Interface: fake.NewSimpleClientset(),
+			CRDClient: crdcli,
+		},
+		Namespace: "sample",
+	}
+	certs := k8s.Certificate{Interface: crdcli, Namespace: "sample"}
+	meshCerts := k8s.Certificate{Interface: crdcli, Namespace: "istio-system"}
+
+	clusterSvc := &bitesize.Service{
+		Name:         "frontend",
+		Ssl:          "true",
+		ExternalURL:  []string{"www.example.com"},
+		TLS:          &bitesize.TLSSettings{Provider: bitesize.TLSProviderCertManager, Issuer: "letsencrypt"},
+		TLSProviders: []string{bitesize.TLSProviderCertManager},
+	}
+	configSvc := *clusterSvc
+
+	reaper.CleanupTLS(&configSvc, clusterSvc)
+	if !certs.Exist("frontend") || !meshCerts.Exist("frontend") {
+		t.Fatalf("Expected certificates to be kept while cert-manager is the tls provider")
+	}
+
+	configSvc.TLS = &bitesize.TLSSettings{Provider: bitesize.TLSProviderSecret, SecretName: "wildcard-tls"}
+	reaper.CleanupTLS(&configSvc, clusterSvc)
+	if certs.Exist("frontend") {
+		t.Errorf("Expected certificate to be deleted after tls provider changed")
+	}
+	if meshCerts.Exist("frontend") {
+		t.Errorf("Expected istio-system certificate to be deleted after tls provider changed")
+	}
+
+	// certificates of other namespaces are left alone
+	backend := bitesize.Service{Name: "backend", TLSProviders: []string{bitesize.TLSProviderCertManager}}
+	reaper.CleanupTLS(&bitesize.Service{Name: "backend"}, &backend)
+	if !meshCerts.Exist("backend") {
+		t.Errorf("Expected istio-system certificate of other namespace to be kept")
+	}
+}
+
+func TestCleanupKeda(t *testing.T) {
+	crdcli := fakecrd.ScaledObjectClient(&ext.ScaledObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "consumer",
+			Namespace: "sample",
+			Labels: map[string]string{
+				"creator": "pipeline",
+				"name":    "consumer",
+			},
+		},
+	})
+
+	reaper := Reaper{
+		Wrapper: &cluster.Cluster{
+			Interface: fake.NewSimpleClientset(),
+			CRDClient: crdcli,
+		},
+		Namespace: "sample",
+	}
+	scaledObjects := k8s.ScaledObject{Interface: crdcli, Namespace: "sample"}
+
+	clusterSvc := &bitesize.Service{
+		Name: "consumer",
+		Keda: &bitesize.KedaScaling{MaxReplicas: 10, Triggers: []bitesize.KedaTrigger{{Type: "kafka"}}},
+	}
+	configSvc := *clusterSvc
+
+	reaper.CleanupKeda(&configSvc, clusterSvc)
+	if !scaledObjects.Exist("consumer") {
+		t.Fatalf("Expected scaled object to be kept while keda is configured")
+	}
+
+	configSvc.Keda = nil
+	configSvc.HPA = bitesize.HorizontalPodAutoscaler{MinReplicas: 1, MaxReplicas: 10}
+	reaper.CleanupKeda(&configSvc, clusterSvc)
+	if scaledObjects.Exist("consumer") {
+		t.Errorf("Expected scaled object to be deleted after keda was replaced with hpa")
+	}
+}
+
+func TestDeleteCustomResource(t *testing.T) {
+	groups := config.Env.CustomResourceGroups
+	config.Env.CustomResourceGroups = []string{"example.com"}
+	defer func() { config.Env.CustomResourceGroups = groups }()
+
+	c := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "sample",
+			},
+		},
+	)
+	c.Fake.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "example.com/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "widgets", Kind: "Widget", Namespaced: true, Verbs: []string{"get", "list", "create", "update", "delete"}},
+			},
+		},
+	}
+	dynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme())
+
+	reaper := Reaper{
+		Wrapper: &cluster.Cluster{
+			Interface: c,
+			CRDClient: fakecrd.UnstructuredClient("example.com", "v1"),
+			Dynamic:   dynamicClient,
+		},
+		Namespace: "sample",
+	}
+	widgets := dynamicClient.Resource(schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}).Namespace("sample")
+
+	widget := &unstructured.Unstructured{}
+	widget.SetAPIVersion("example.com/v1")
+	widget.SetKind("Widget")
+	widget.SetName("widget")
+	widget.SetNamespace("sample")
+	widget.SetLabels(map[string]string{"creator": "pipeline"})
+	if _, err := widgets.Create(widget, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	cfg := &bitesize.Environment{Services: bitesize.Services{}}
+	if err := reaper.Cleanup(cfg); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if _, err := widgets.Get("widget", metav1.GetOptions{}); err == nil {
+		t.Errorf("Expected orphan widget to be deleted")
+	}
+}
+
+func TestDeleteCustomResourceDefinition(t *testing.T) {
+	protection := config.Env.DeletionProtection
+	config.Env.DeletionProtection = true
+	defer func() { config.Env.DeletionProtection = protection }()
+
+	c := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "sample",
+			},
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "api",
+				Namespace: "sample",
+				Labels: map[string]string{
+					"creator": "pipeline",
+				},
+			},
+		},
+	)
+	crdcli := fakecrd.UnstructuredClient("prsn.io", "v1")
+
+	reaper := Reaper{
+		Wrapper: &cluster.Cluster{
+			Interface: c,
+			CRDClient: crdcli,
+		},
+		Namespace: "sample",
+	}
+
+	resources := []struct {
+		Type        string
+		Name        string
+		Annotations map[string]string
+	}{
+		{"mysql", "db", nil},
+		{"s3", "bucket", map[string]string{bitesize.DeletionProtectionAnnotation: "false"}},
+		{"sns", "topic", nil},
+		{ext.GatewayKind, "api", nil},
+		{ext.VirtualServiceKind, "api", nil},
+		{ext.DestinationRuleKind, "api", nil},
+	}
+	for _, rsc := range resources {
+		client := k8s.CustomResourceDefinition{Interface: crdcli, Namespace: "sample", Type: rsc.Type}
+		err := client.Apply(&ext.PrsnExternalResource{
+			TypeMeta: metav1.TypeMeta{Kind: strings.Title(rsc.Type)},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        rsc.Name,
+				Namespace:   "sample",
+				Annotations: rsc.Annotations,
+				Labels:      map[string]string{"creator": "pipeline"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err.Error())
+		}
+	}
+	secrets := k8s.ExternalSecret{Interface: crdcli, Namespace: "sample", Type: "ExternalSecret"}
+	meshSecrets := k8s.ExternalSecret{Interface: crdcli, Namespace: "istio-system", Type: "ExternalSecret"}
+	for _, ns := range []string{"sample", "istio-system"} {
+		client := k8s.ExternalSecret{Interface: crdcli, Namespace: ns, Type: "ExternalSecret"}
+		err := client.Apply(&ext.ExternalSecret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "api",
+				Namespace: ns,
+				Labels:    map[string]string{"creator": "pipeline", "name": "api"},
+			},
+			SecretDescriptor: ext.ExternalSecretSecretDescriptor{
+				Type: "kubernetes.io/tls",
+				Data: []map[string]string{{"key": "tls/dev/env/sample/api.crt", "name": "tls.crt"}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err.Error())
+		}
+	}
+
+	if err := reaper.Cleanup(&bitesize.Environment{Services: bitesize.Services{}}); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	var tests = []struct {
+		Type    string
+		Name    string
+		Deleted bool
+	}{
+		{"mysql", "db", false},
+		{"s3", "bucket", true},
+		{"sns", "topic", true},
+		{ext.GatewayKind, "api", true},
+		{ext.VirtualServiceKind, "api", true},
+		{ext.DestinationRuleKind, "api", true},
+	}
+	for _, tst := range tests {
+		client := k8s.CustomResourceDefinition{Interface: crdcli, Namespace: "sample", Type: tst.Type}
+		if client.Exist(tst.Name) == tst.Deleted {
+			t.Errorf("Expected %s %s deleted: %t", tst.Type, tst.Name, tst.Deleted)
+		}
+	}
+	if secrets.Exist("api") {
+		t.Error("Expected external secret to be deleted")
+	}
+	if meshSecrets.Exist("api") {
+		t.Error("Expected istio-system external secret to be deleted")
+	}
+}
+
+func TestCleanupGists(t *testing.T) {
+	c := fake.NewSimpleClientset(
+		&v1batch.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "migrate",
+				Namespace: "sample",
+			},
+		},
+		&v1batch.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "seed",
+				Namespace: "sample",
+			},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "db",
+				Namespace: "sample",
+			},
+		},
+	)
+
+	reaper := Reaper{
+		Wrapper:   &cluster.Cluster{Interface: c},
+		Namespace: "sample",
+	}
+
+	reaper.CleanupGists(
+		bitesize.Gists{{Name: "seed", Type: bitesize.TypeJob}},
+		bitesize.Gists{
+			{Name: "migrate", Type: bitesize.TypeJob},
+			{Name: "seed", Type: bitesize.TypeJob},
+			{Name: "db", Type: bitesize.TypeSecret},
+		},
+	)
+
+	if j, err := c.BatchV1().Jobs("sample").Get("migrate", metav1.GetOptions{}); err == nil {
+		t.Errorf("Expected job nil, got: %+v", j)
+	}
+
+	if _, err := c.BatchV1().Jobs("sample").Get("seed", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected job seed to be kept, got: %s", err.Error())
+	}
+
+	if s, err := c.CoreV1().Secrets("sample").Get("db", metav1.GetOptions{}); err == nil {
+		t.Errorf("Expected secret nil, got: %+v", s)
+	}
+}
@@ -0,0 +1,35 @@
+package sops
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// ParseAgeIdentities parses age key file, one AGE-SECRET-KEY-1... key per
+// line. Empty lines and lines starting with # are ignored.
+func ParseAgeIdentities(r io.Reader) ([]age.Identity, error) {
+	ids, err := age.ParseIdentities(r)
+	if err != nil {
+		return nil, fmt.Errorf("age: %s", err.Error())
+	}
+	return ids, nil
+}
+
+// ageDecrypt decrypts an armored or binary age file with any of ids
+func ageDecrypt(file string, ids []age.Identity) ([]byte, error) {
+	var src io.Reader = strings.NewReader(file)
+	if strings.HasPrefix(strings.TrimSpace(file), armor.Header) {
+		src = armor.NewReader(strings.NewReader(strings.TrimSpace(file)))
+	}
+
+	r, err := age.Decrypt(src, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,29 @@
+package sops
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAgeIdentities(t *testing.T) {
+	keys := `# created: 2026-10-19T09:00:00Z
+# public key: age1lm05darzv5jhunv8hqawt4gh3d9tz0a99t9zyvh4dmhsxl5ln36sf5dvpf
+AGE-SECRET-KEY-1PTHRA6FT7VY5EZ6R6495T5LUXXXD6THJKNECFN94GNYDJ3UYZJCSX5KACA
+`
+	ids, err := ParseAgeIdentities(strings.NewReader(keys))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(ids) != 1 {
+		t.Fatalf("Expected 1 identity, got %d", len(ids))
+	}
+
+	_, err = ParseAgeIdentities(strings.NewReader("AGE-SECRET-KEY-1PTHRA6FT7VY5EZ6R6495T5LUXXXD6THJKNECFN94GNYDJ3UYZJCSX5KACB"))
+	if err == nil {
+		t.Error("Expected checksum error, got nil")
+	}
+
+	if _, err := ParseAgeIdentities(strings.NewReader("# empty\n")); err == nil {
+		t.Error("Expected error for file without keys, got nil")
+	}
+}
@@ -0,0 +1,27 @@
+package sops
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// pgpDecrypt decrypts armored PGP message with a key from keyring.
+// Passphrase protected keys are not supported.
+func pgpDecrypt(message string, keyring openpgp.EntityList) ([]byte, error) {
+	block, err := armor.Decode(strings.NewReader(message))
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != "PGP MESSAGE" {
+		return nil, errors.New("pgp: not an encrypted message")
+	}
+	md, err := openpgp.ReadMessage(block.Body, keyring, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(md.UnverifiedBody)
+}
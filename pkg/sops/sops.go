@@ -0,0 +1,305 @@
+// Package sops decrypts YAML documents encrypted with SOPS
+// (https://github.com/mozilla/sops). Only age and PGP master keys and the
+// AES256_GCM value cipher are supported. Encrypted comments and shamir key
+// groups are not.
+package sops
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/openpgp"
+	"gopkg.in/yaml.v2"
+)
+
+// metadataKey is the top level key sops stores its metadata under
+const metadataKey = "sops"
+
+var encryptedValue = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+// Keys holds private keys used to decrypt sops data keys
+type Keys struct {
+	Age []age.Identity
+	PGP openpgp.EntityList
+}
+
+type metadata struct {
+	Age []struct {
+		Recipient string `yaml:"recipient"`
+		Enc       string `yaml:"enc"`
+	} `yaml:"age"`
+	PGP []struct {
+		Fingerprint string `yaml:"fp"`
+		Enc         string `yaml:"enc"`
+	} `yaml:"pgp"`
+	LastModified     string `yaml:"lastmodified"`
+	MAC              string `yaml:"mac"`
+	MACOnlyEncrypted bool   `yaml:"mac_only_encrypted"`
+}
+
+// LoadKeys reads age identities and armored PGP private keys from the
+// given files. Files that do not exist are skipped.
+func LoadKeys(ageKeyFile, pgpKeyFile string) (*Keys, error) {
+	keys := &Keys{}
+
+	if f, err := os.Open(ageKeyFile); err == nil {
+		defer f.Close()
+		if keys.Age, err = ParseAgeIdentities(f); err != nil {
+			return nil, fmt.Errorf("sops: reading %s: %s", ageKeyFile, err.Error())
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if f, err := os.Open(pgpKeyFile); err == nil {
+		defer f.Close()
+		if keys.PGP, err = openpgp.ReadArmoredKeyRing(f); err != nil {
+			return nil, fmt.Errorf("sops: reading %s: %s", pgpKeyFile, err.Error())
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// IsEncrypted returns true if data is a YAML document carrying sops
+// metadata
+func IsEncrypted(data []byte) bool {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	_, ok := doc[metadataKey]
+	return ok
+}
+
+// DecryptFile reads and decrypts sops encrypted YAML file
+func DecryptFile(path string, keys *Keys) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data, keys)
+}
+
+// Decrypt decrypts sops encrypted YAML document and returns it as
+// plaintext YAML, without sops metadata. Document integrity is verified
+// against the MAC stored in metadata.
+func Decrypt(data []byte, keys *Keys) ([]byte, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var wrapper struct {
+		Sops *metadata `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &wrapper); err != nil {
+		return nil, err
+	}
+	if wrapper.Sops == nil {
+		return nil, errors.New("sops: metadata not found")
+	}
+	meta := wrapper.Sops
+
+	dataKey, err := keys.dataKey(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := make(yaml.MapSlice, 0, len(doc))
+	for _, item := range doc {
+		if item.Key != metadataKey {
+			tree = append(tree, item)
+		}
+	}
+
+	d := &decryptor{
+		key:              dataKey,
+		hash:             sha512.New(),
+		macOnlyEncrypted: meta.MACOnlyEncrypted,
+	}
+	plain, err := d.walk(tree, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.verify(meta); err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(plain)
+}
+
+// dataKey decrypts document data key with the first matching master key
+func (keys *Keys) dataKey(meta *metadata) ([]byte, error) {
+	if keys == nil {
+		keys = &Keys{}
+	}
+	if len(meta.Age) == 0 && len(meta.PGP) == 0 {
+		return nil, errors.New("sops: no age or pgp master keys in metadata")
+	}
+
+	for _, k := range meta.Age {
+		if len(keys.Age) == 0 {
+			break
+		}
+		if key, err := ageDecrypt(k.Enc, keys.Age); err == nil {
+			return key, nil
+		}
+	}
+
+	for _, k := range meta.PGP {
+		if len(keys.PGP) == 0 {
+			break
+		}
+		if key, err := pgpDecrypt(k.Enc, keys.PGP); err == nil {
+			return key, nil
+		}
+	}
+
+	return nil, errors.New("sops: no available key could decrypt the data key")
+}
+
+type decryptor struct {
+	key              []byte
+	hash             hash.Hash
+	macOnlyEncrypted bool
+}
+
+// walk decrypts tree values in document order. Values are authenticated
+// with their path, joined by ":", as additional data; list items share
+// their parent's path.
+func (d *decryptor) walk(in interface{}, path []string) (interface{}, error) {
+	switch v := in.(type) {
+	case yaml.MapSlice:
+		out := make(yaml.MapSlice, 0, len(v))
+		for _, item := range v {
+			p := append(append([]string{}, path...), fmt.Sprint(item.Key))
+			value, err := d.walk(item.Value, p)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, yaml.MapItem{Key: item.Key, Value: value})
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			value, err := d.walk(item, path)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = value
+		}
+		return out, nil
+	}
+
+	if s, ok := in.(string); ok && strings.HasPrefix(s, "ENC[") {
+		value, err := decryptValue(s, d.key, strings.Join(path, ":")+":")
+		if err != nil {
+			// never include the value in errors
+			return nil, fmt.Errorf("sops: decrypting %s: %s", strings.Join(path, "."), err.Error())
+		}
+		d.hash.Write(toBytes(value))
+		return value, nil
+	}
+
+	if !d.macOnlyEncrypted {
+		d.hash.Write(toBytes(in))
+	}
+	return in, nil
+}
+
+// verify compares the MAC of decrypted values with the one stored in
+// metadata
+func (d *decryptor) verify(meta *metadata) error {
+	lastModified, err := time.Parse(time.RFC3339, meta.LastModified)
+	if err != nil {
+		return fmt.Errorf("sops: invalid lastmodified %q", meta.LastModified)
+	}
+
+	stored, err := decryptValue(meta.MAC, d.key, lastModified.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("sops: decrypting mac: %s", err.Error())
+	}
+
+	if fmt.Sprintf("%X", d.hash.Sum(nil)) != stored {
+		return errors.New("sops: MAC mismatch, file has been tampered with")
+	}
+	return nil
+}
+
+// decryptValue decrypts a single ENC[AES256_GCM,...] value and converts it
+// back to its original type
+func decryptValue(value string, key []byte, aad string) (interface{}, error) {
+	m := encryptedValue.FindStringSubmatch(value)
+	if m == nil {
+		return nil, errors.New("invalid encrypted value format")
+	}
+
+	var data, iv, tag []byte
+	for i, dst := range []*[]byte{&data, &iv, &tag} {
+		b, err := base64.StdEncoding.DecodeString(m[i+1])
+		if err != nil {
+			return nil, err
+		}
+		*dst = b
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, iv, append(data, tag...), []byte(aad))
+	if err != nil {
+		return nil, errors.New("authentication failed")
+	}
+
+	switch m[4] {
+	case "str", "bytes":
+		return string(plain), nil
+	case "int":
+		return strconv.Atoi(string(plain))
+	case "float":
+		return strconv.ParseFloat(string(plain), 64)
+	case "bool":
+		return strconv.ParseBool(string(plain))
+	}
+	return nil, fmt.Errorf("unsupported value type %s", m[4])
+}
+
+// toBytes formats value the way sops does when computing the MAC
+func toBytes(in interface{}) []byte {
+	switch v := in.(type) {
+	case nil:
+		return nil
+	case string:
+		return []byte(v)
+	case bool:
+		if v {
+			return []byte("True")
+		}
+		return []byte("False")
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return []byte(fmt.Sprint(in))
+}
@@ -0,0 +1,85 @@
+package sops
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ageKeyFile = "../../test/assets/sops/age-key.txt"
+	pgpKeyFile = "../../test/assets/sops/pgp-key.asc"
+)
+
+type secret struct {
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+func TestDecrypt(t *testing.T) {
+	t.Run("age encrypted file", func(t *testing.T) {
+		testDecryptFile(t, "../../test/assets/sops/secret.age.yaml", ageKeyFile, "")
+	})
+	t.Run("pgp encrypted file", func(t *testing.T) {
+		testDecryptFile(t, "../../test/assets/sops/secret.pgp.yaml", "", pgpKeyFile)
+	})
+}
+
+func testDecryptFile(t *testing.T, path, ageKey, pgpKey string) {
+	keys, err := LoadKeys(ageKey, pgpKey)
+	if err != nil {
+		t.Fatalf("Unexpected error loading keys: %s", err.Error())
+	}
+
+	out, err := DecryptFile(path, keys)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	var s secret
+	if err := yaml.Unmarshal(out, &s); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if s.Metadata.Name != "db-credentials" {
+		t.Errorf("Expected name db-credentials, got %s", s.Metadata.Name)
+	}
+	if s.StringData["username"] != "app" || s.StringData["password"] != "s3cr3t-p4ss" {
+		t.Errorf("Unexpected stringData %v", s.StringData)
+	}
+	if strings.Contains(string(out), "sops:") {
+		t.Errorf("Expected sops metadata to be removed, got:\n%s", out)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	keys, _ := LoadKeys(ageKeyFile, "")
+	data, _ := ioutil.ReadFile("../../test/assets/sops/secret.age.yaml")
+
+	tampered := strings.Replace(string(data), "name: db-credentials", "name: other", 1)
+	_, err := Decrypt([]byte(tampered), keys)
+	if err == nil || !strings.Contains(err.Error(), "MAC mismatch") {
+		t.Errorf("Expected MAC mismatch error, got %v", err)
+	}
+}
+
+func TestDecryptWithoutKey(t *testing.T) {
+	keys, _ := LoadKeys("", pgpKeyFile)
+	_, err := DecryptFile("../../test/assets/sops/secret.age.yaml", keys)
+	if err == nil {
+		t.Error("Expected error decrypting without matching key, got nil")
+	}
+}
+
+func TestIsEncrypted(t *testing.T) {
+	data, _ := ioutil.ReadFile("../../test/assets/sops/secret.age.yaml")
+	if !IsEncrypted(data) {
+		t.Error("Expected sops file to be detected as encrypted")
+	}
+	if IsEncrypted([]byte("apiVersion: v1\nkind: Secret\n")) {
+		t.Error("Expected plain file not to be detected as encrypted")
+	}
+}
@@ -0,0 +1,69 @@
+package translator
+
+import (
+	"fmt"
+	"strconv"
+
+	ext "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+)
+
+// CanaryLabel marks ingresses routing a share of traffic to canary services
+const CanaryLabel = "canary"
+
+const nginxCanaryPrefix = "nginx.ingress.kubernetes.io/"
+
+// CanaryIngress extracts nginx canary Ingress routing CanaryWeight percent
+// of service traffic to the canary service
+func (w *KubeMapper) CanaryIngress() (*ext.Ingress, error) {
+	if !w.BiteService.IsCanaryParentDeployment() {
+		return nil, fmt.Errorf("service %s deployment method is not canary", w.BiteService.Name)
+	}
+
+	retval, err := w.Ingress()
+	if err != nil {
+		return nil, err
+	}
+
+	retval.Name = w.BiteService.CanaryDeploymentName()
+	retval.Labels["name"] = retval.Name
+	retval.Labels[CanaryLabel] = "true"
+	retval.Annotations = map[string]string{
+		nginxCanaryPrefix + "canary":        "true",
+		nginxCanaryPrefix + "canary-weight": strconv.Itoa(w.BiteService.CanaryWeight()),
+	}
+
+	for _, rule := range retval.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			rule.HTTP.Paths[i].Backend.Service.Name = retval.Name
+		}
+	}
+	return retval, nil
+}
+
+// canaryRoute splits destination traffic between the service and its
+// canary by CanaryWeight
+func (w *KubeMapper) canaryRoute(destination *ext.Destination) []*ext.HTTPRouteDestination {
+	weight := w.BiteService.CanaryWeight()
+	if weight == 0 {
+		return []*ext.HTTPRouteDestination{{Destination: destination}}
+	}
+
+	canary := *destination
+	canary.Host = w.BiteService.CanaryDeploymentName()
+	return []*ext.HTTPRouteDestination{
+		{Destination: destination, Weight: int32(100 - weight)},
+		{Destination: &canary, Weight: int32(weight)},
+	}
+}
+
+// canaryAnnotations returns canary state annotations for "parent" service
+func (w *KubeMapper) canaryAnnotations() map[string]string {
+	state := w.BiteService.CanaryState()
+	if !w.BiteService.IsCanaryParentDeployment() || state == nil {
+		return map[string]string{}
+	}
+	return state.Annotations()
+}
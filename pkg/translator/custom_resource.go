@@ -0,0 +1,46 @@
+package translator
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// CustomResource extracts custom resource declared with kind, api_version
+// and spec from BiteSize definition. Spec is passed through verbatim.
+func (w *KubeMapper) CustomResource() (*unstructured.Unstructured, error) {
+	if !w.BiteService.IsCustomResource() {
+		return nil, fmt.Errorf("service %s doesn't declare a custom resource kind", w.BiteService.Name)
+	}
+
+	retval := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	retval.SetAPIVersion(w.BiteService.APIVersion)
+	retval.SetKind(w.BiteService.Kind)
+	retval.SetName(w.BiteService.Name)
+	retval.SetNamespace(w.Namespace)
+	retval.SetLabels(map[string]string{
+		"creator": "pipeline",
+		"name":    w.BiteService.Name,
+	})
+	if annotations := w.deletionProtectionAnnotations(); annotations != nil {
+		retval.SetAnnotations(annotations)
+	}
+	if w.BiteService.Spec != nil {
+		retval.Object["spec"] = runtime.DeepCopyJSONValue(w.BiteService.Spec)
+	}
+	return retval, nil
+}
+
+// deletionProtectionAnnotations returns annotations keeping service
+// deletion_protection on its custom resource, or nil if it's not set
+func (w *KubeMapper) deletionProtectionAnnotations() map[string]string {
+	if w.BiteService.DeletionProtection == nil {
+		return nil
+	}
+	return map[string]string{
+		bitesize.DeletionProtectionAnnotation: strconv.FormatBool(*w.BiteService.DeletionProtection),
+	}
+}
@@ -17,7 +17,6 @@ import (
 	apps_v1 "k8s.io/api/apps/v1"
 	autoscale_v2beta2 "k8s.io/api/autoscaling/v2beta2"
 	v1 "k8s.io/api/core/v1"
-	netwk_v1beta1 "k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -36,24 +35,15 @@ type KubeMapper struct {
 
 // Service extracts Kubernetes object from Bitesize definition
 func (w *KubeMapper) Service() (*v1.Service, error) {
+	// StatefulSet pods need stable network identities
+	if w.BiteService.IsStatefulSet() {
+		return w.HeadlessService()
+	}
+
 	targetServiceName := w.BiteService.Name
 	if w.BiteService.IsBlueGreenParentDeployment() {
 		targetServiceName = w.BiteService.ActiveDeploymentName()
 	}
-	var ports []v1.ServicePort
-	for _, p := range w.BiteService.Ports {
-		servicePort := v1.ServicePort{
-			Port:       int32(p),
-			TargetPort: intstr.FromInt(p),
-			Name:       fmt.Sprintf("http-%d", p),
-		}
-
-		if strings.EqualFold(w.BiteService.Protocol, "tcp") {
-			servicePort.Name = fmt.Sprintf("tcp-port-%d", p)
-		}
-
-		ports = append(ports, servicePort)
-	}
 	retval := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        w.BiteService.Name,
@@ -62,16 +52,47 @@ func (w *KubeMapper) Service() (*v1.Service, error) {
 			Annotations: w.annotations(),
 		},
 		Spec: v1.ServiceSpec{
-			Ports: ports,
+			Ports: w.servicePorts(),
 			Selector: map[string]string{
 				"creator": "pipeline",
 				"name":    targetServiceName,
 			},
 		},
 	}
+
+	if t := w.BiteService.ServiceType; t != nil {
+		retval.Spec.Type = v1.ServiceType(t.Type)
+		retval.Spec.LoadBalancerSourceRanges = t.LoadBalancerSourceRanges
+		retval.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyType(t.ExternalTrafficPolicy)
+		for k, v := range t.Annotations {
+			retval.Annotations[k] = v
+		}
+		if keys := bitesize.ServiceTypeAnnotationKeys(t); keys != "" {
+			retval.Annotations[bitesize.ServiceTypeAnnotationsAnnotation] = keys
+		}
+	}
+	// ExternalName services are DNS aliases without pods to select
+	if w.BiteService.IsExternalName() {
+		retval.Spec.ExternalName = w.BiteService.ServiceType.ExternalName
+		retval.Spec.Selector = nil
+	}
 	return retval, nil
 }
 
+// servicePorts returns kubernetes service ports of the service
+func (w *KubeMapper) servicePorts() []v1.ServicePort {
+	var retval []v1.ServicePort
+	for _, p := range w.BiteService.Ports {
+		retval = append(retval, v1.ServicePort{
+			Name:       p.Name,
+			Port:       int32(p.Port),
+			TargetPort: intstr.FromInt(p.ContainerPort),
+			Protocol:   p.KubernetesProtocol(),
+		})
+	}
+	return retval
+}
+
 // HeadlessService extracts Kubernetes Headless Service object (No ClusterIP) from Bitesize definition
 func (w *KubeMapper) HeadlessService() (*v1.Service, error) {
 	targetServiceName := w.BiteService.Name
@@ -79,21 +100,7 @@ func (w *KubeMapper) HeadlessService() (*v1.Service, error) {
 		targetServiceName = w.BiteService.ActiveDeploymentName()
 	}
 
-	var ports []v1.ServicePort
 	//Need to update this to have an option to create the headless service (no loadbalancing with Cluster IP not getting set)
-	for _, p := range w.BiteService.Ports {
-		servicePort := v1.ServicePort{
-			Port:       int32(p),
-			TargetPort: intstr.FromInt(p),
-			Name:       fmt.Sprintf("http-%d", p),
-		}
-
-		if strings.EqualFold(w.BiteService.Protocol, "tcp") {
-			servicePort.Name = fmt.Sprintf("tcp-port-%d", p)
-		}
-
-		ports = append(ports, servicePort)
-	}
 	retval := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      w.BiteService.Name,
@@ -106,7 +113,7 @@ func (w *KubeMapper) HeadlessService() (*v1.Service, error) {
 			Annotations: w.annotations(),
 		},
 		Spec: v1.ServiceSpec{
-			Ports: ports,
+			Ports: w.servicePorts(),
 			Selector: map[string]string{
 				"creator": "pipeline",
 				"name":    targetServiceName,
@@ -147,61 +154,115 @@ func (w *KubeMapper) ConfigMaps() ([]v1.ConfigMap, error) {
 	return retval, nil
 }
 
-// PersistentVolumeClaims returns a list of claims for a BiteService
+// PersistentVolumeClaims returns a list of claims for a BiteService.
+// StatefulSets get their claims from volumeClaimTemplates instead.
 func (w *KubeMapper) PersistentVolumeClaims() ([]v1.PersistentVolumeClaim, error) {
 	var retval []v1.PersistentVolumeClaim
 
+	if w.BiteService.IsStatefulSet() {
+		return retval, nil
+	}
+
 	for _, vol := range w.BiteService.Volumes {
 		//Create a PVC only if the volume is not coming from a secret or ConfigMap
 		if vol.IsSecretVolume() || vol.IsConfigMapVolume() {
 			continue
 		}
 
-		ret := v1.PersistentVolumeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      vol.Name,
-				Namespace: w.Namespace,
-				Labels: map[string]string{
-					"creator":    "pipeline",
-					"deployment": w.BiteService.Name,
-					"mount_path": strings.Replace(vol.Path, "/", "2F", -1),
-					"size":       vol.Size,
-					"type":       strings.ToLower(vol.Type),
-				},
+		retval = append(retval, w.persistentVolumeClaim(vol))
+	}
+	return retval, nil
+}
+
+func (w *KubeMapper) persistentVolumeClaim(vol bitesize.Volume) v1.PersistentVolumeClaim {
+	ret := v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      vol.Name,
+			Namespace: w.Namespace,
+			Labels: map[string]string{
+				"creator":    "pipeline",
+				"deployment": w.BiteService.Name,
+				"mount_path": strings.Replace(vol.Path, "/", "2F", -1),
+				"size":       vol.Size,
+				"type":       strings.ToLower(vol.Type),
 			},
-			Spec: v1.PersistentVolumeClaimSpec{
-				AccessModes: getAccessModesFromString(vol.Modes),
-				Resources: v1.ResourceRequirements{
-					Requests: v1.ResourceList{
-						v1.ResourceName(v1.ResourceStorage): resource.MustParse(vol.Size),
-					},
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: getAccessModesFromString(vol.Modes),
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceName(v1.ResourceStorage): resource.MustParse(vol.Size),
 				},
 			},
+		},
+	}
+	if vol.HasManualProvisioning() {
+		ret.Spec.VolumeName = vol.Name
+		ret.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"name": vol.Name,
+			},
 		}
-		if vol.HasManualProvisioning() {
-			ret.Spec.VolumeName = vol.Name
-			ret.Spec.Selector = &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"name": vol.Name,
-				},
-			}
-		} else {
-			ret.ObjectMeta.Annotations = map[string]string{
-				"volume.beta.kubernetes.io/storage-class": "aws-" + strings.ToLower(vol.Type),
-			}
+	} else {
+		ret.ObjectMeta.Annotations = map[string]string{
+			"volume.beta.kubernetes.io/storage-class": "aws-" + strings.ToLower(vol.Type),
 		}
-
-		retval = append(retval, ret)
 	}
-	return retval, nil
+	return ret
 }
 
 // Deployment extracts Kubernetes object from BiteSize definition
 func (w *KubeMapper) Deployment() (*apps_v1.Deployment, error) {
-	if w.BiteService.IsBlueGreenParentDeployment() {
+	if w.BiteService.IsBlueGreenParentDeployment() || w.BiteService.IsStatefulSet() {
 		return nil, nil
 	}
 	replicas := int32(w.BiteService.Replicas)
+
+	template, err := w.podTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	retval := &apps_v1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        w.BiteService.Name,
+			Namespace:   w.Namespace,
+			Annotations: w.workloadAnnotations(),
+			Labels: map[string]string{
+				"creator":     "pipeline",
+				"name":        w.BiteService.Name,
+				"application": w.BiteService.Application,
+				"version":     w.BiteService.Version,
+				"app":         w.BiteService.Application,
+			},
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"creator": "pipeline",
+					"name":    w.BiteService.Name,
+				},
+			},
+			Template: *template,
+		},
+	}
+
+	return retval, nil
+}
+
+// workloadAnnotations returns annotations of Deployment or StatefulSet,
+// keeping env vars set from service outputs. Returns nil if there are none.
+func (w *KubeMapper) workloadAnnotations() map[string]string {
+	outputs := bitesize.ServiceOutputsAnnotationValue(w.BiteService.EnvVars)
+	if outputs == "" {
+		return nil
+	}
+	return map[string]string{bitesize.ServiceOutputsAnnotation: outputs}
+}
+
+// podTemplate returns pod template shared by Deployment and StatefulSet
+func (w *KubeMapper) podTemplate() (*v1.PodTemplateSpec, error) {
 	container, err := w.container()
 	initContainers, _ := w.initContainers()
 
@@ -218,52 +279,43 @@ func (w *KubeMapper) Deployment() (*apps_v1.Deployment, error) {
 		return nil, err
 	}
 
-	retval := &apps_v1.Deployment{
+	return &v1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      w.BiteService.Name,
 			Namespace: w.Namespace,
 			Labels: map[string]string{
 				"creator":     "pipeline",
-				"name":        w.BiteService.Name,
 				"application": w.BiteService.Application,
+				"name":        w.BiteService.Name,
 				"version":     w.BiteService.Version,
 				"app":         w.BiteService.Application,
 			},
+			Annotations: w.podAnnotations(),
 		},
-		Spec: apps_v1.DeploymentSpec{
-			Replicas: &replicas,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"creator": "pipeline",
-					"name":    w.BiteService.Name,
-				},
-			},
-			Template: v1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      w.BiteService.Name,
-					Namespace: w.Namespace,
-					Labels: map[string]string{
-						"creator":     "pipeline",
-						"application": w.BiteService.Application,
-						"name":        w.BiteService.Name,
-						"version":     w.BiteService.Version,
-						"app":         w.BiteService.Application,
-					},
-					Annotations: w.BiteService.Annotations,
-				},
-				Spec: v1.PodSpec{
-					NodeSelector:     map[string]string{"role": "minion"},
-					Containers:       []v1.Container{*container},
-					ImagePullSecrets: imagePullSecrets,
-					Volumes:          volumes,
-					InitContainers:   initContainers,
-				},
-			},
+		Spec: v1.PodSpec{
+			NodeSelector:     map[string]string{"role": "minion"},
+			Containers:       []v1.Container{*container},
+			ImagePullSecrets: imagePullSecrets,
+			Volumes:          volumes,
+			InitContainers:   initContainers,
 		},
-	}
+	}, nil
+}
 
-	return retval, nil
+// podAnnotations returns service annotations with config hash added, if
+// the service references any configmaps or secrets
+func (w *KubeMapper) podAnnotations() map[string]string {
+	if w.BiteService.ConfigHash == "" {
+		return w.BiteService.Annotations
+	}
+	retval := map[string]string{}
+	for k, v := range w.BiteService.Annotations {
+		retval[k] = v
+	}
+	retval[bitesize.ConfigHashAnnotation] = w.BiteService.ConfigHash
+	return retval
 }
+
 func (w *KubeMapper) imagePullSecrets() ([]v1.LocalObjectReference, error) {
 	var retval []v1.LocalObjectReference
 
@@ -285,18 +337,19 @@ func (w *KubeMapper) imagePullSecrets() ([]v1.LocalObjectReference, error) {
 
 // HPA extracts Kubernetes object from Bitesize definition
 func (w *KubeMapper) HPA() (*autoscale_v2beta2.HorizontalPodAutoscaler, error) {
-	if w.BiteService.IsBlueGreenParentDeployment() {
+	if w.BiteService.IsBlueGreenParentDeployment() || w.BiteService.HasKeda() {
 		return nil, nil
 	}
 	retval := &autoscale_v2beta2.HorizontalPodAutoscaler{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      w.BiteService.Name,
-			Namespace: w.Namespace,
-			Labels:    w.labels(),
+			Name:        w.BiteService.Name,
+			Namespace:   w.Namespace,
+			Labels:      w.labels(),
+			Annotations: map[string]string{},
 		},
 		Spec: autoscale_v2beta2.HorizontalPodAutoscalerSpec{
 			ScaleTargetRef: autoscale_v2beta2.CrossVersionObjectReference{
-				Kind:       "Deployment",
+				Kind:       w.workloadKind(),
 				Name:       w.BiteService.Name,
 				APIVersion: "apps/v1",
 			},
@@ -305,58 +358,91 @@ func (w *KubeMapper) HPA() (*autoscale_v2beta2.HorizontalPodAutoscaler, error) {
 			Metrics:     w.getMetricSpec(),
 		},
 	}
+	// spec.behavior is newer than autoscaling/v2beta2 types of the client,
+	// it's patched in from the annotation when the hpa is applied
+	if behavior := bitesize.ScalingBehaviorJSON(w.BiteService.HPA.Behavior); behavior != "" {
+		retval.Annotations[k8s.HPABehaviorAnnotation] = behavior
+	}
 
 	return retval, nil
 }
 
 func (w *KubeMapper) getMetricSpec() (m []autoscale_v2beta2.MetricSpec) {
-	if w.BiteService.HPA.Metric.Name == "cpu" || w.BiteService.HPA.Metric.Name == "memory" {
-		if w.BiteService.HPA.Metric.Name == "cpu" && w.BiteService.HPA.Metric.TargetAverageUtilization != 0 {
+	for _, metric := range w.BiteService.HPA.MetricList() {
+		target := metricTarget(metric)
+		identifier := autoscale_v2beta2.MetricIdentifier{Name: metric.Name}
+		if len(metric.Selector) > 0 {
+			identifier.Selector = &metav1.LabelSelector{MatchLabels: metric.Selector}
+		}
+
+		switch metric.MetricType() {
+		case bitesize.MetricTypeResource:
 			m = append(m, autoscale_v2beta2.MetricSpec{
 				Type: autoscale_v2beta2.ResourceMetricSourceType,
 				Resource: &autoscale_v2beta2.ResourceMetricSource{
-					Target: autoscale_v2beta2.MetricTarget{
-						Type:               "Utilization",
-						AverageUtilization: &w.BiteService.HPA.Metric.TargetAverageUtilization,
-					},
-					Name: "cpu",
+					Name:   v1.ResourceName(metric.Name),
+					Target: target,
 				},
-			},
-			)
-		}
-		if w.BiteService.HPA.Metric.Name == "memory" && w.BiteService.HPA.Metric.TargetAverageUtilization != 0 {
+			})
+		case bitesize.MetricTypePods:
 			m = append(m, autoscale_v2beta2.MetricSpec{
-				Type: autoscale_v2beta2.ResourceMetricSourceType,
-				Resource: &autoscale_v2beta2.ResourceMetricSource{
-					Target: autoscale_v2beta2.MetricTarget{
-						Type:               "Utilization",
-						AverageUtilization: &w.BiteService.HPA.Metric.TargetAverageUtilization,
-					},
-					Name: "memory",
+				Type: autoscale_v2beta2.PodsMetricSourceType,
+				Pods: &autoscale_v2beta2.PodsMetricSource{
+					Metric: identifier,
+					Target: target,
 				},
-			},
-			)
-		}
-	} else {
-		targetValue, _ := resource.ParseQuantity(w.BiteService.HPA.Metric.TargetAverageValue)
-		m = append(m, autoscale_v2beta2.MetricSpec{
-			Type: autoscale_v2beta2.PodsMetricSourceType,
-			Pods: &autoscale_v2beta2.PodsMetricSource{
-				Target: autoscale_v2beta2.MetricTarget{
-					Type:         "AverageValue",
-					AverageValue: &targetValue,
+			})
+		case bitesize.MetricTypeObject:
+			m = append(m, autoscale_v2beta2.MetricSpec{
+				Type: autoscale_v2beta2.ObjectMetricSourceType,
+				Object: &autoscale_v2beta2.ObjectMetricSource{
+					DescribedObject: autoscale_v2beta2.CrossVersionObjectReference{
+						APIVersion: metric.Object.APIVersion,
+						Kind:       metric.Object.Kind,
+						Name:       metric.Object.Name,
+					},
+					Metric: identifier,
+					Target: target,
 				},
-				Metric: autoscale_v2beta2.MetricIdentifier{
-					Name: w.BiteService.HPA.Metric.Name,
+			})
+		case bitesize.MetricTypeExternal:
+			m = append(m, autoscale_v2beta2.MetricSpec{
+				Type: autoscale_v2beta2.ExternalMetricSourceType,
+				External: &autoscale_v2beta2.ExternalMetricSource{
+					Metric: identifier,
+					Target: target,
 				},
-			},
-		},
-		)
+			})
+		}
 	}
-
 	return
 }
 
+// metricTarget returns target of the metric: utilization of resource
+// metrics, value of object and external metrics, or average value per pod
+func metricTarget(metric bitesize.Metric) autoscale_v2beta2.MetricTarget {
+	switch {
+	case metric.MetricType() == bitesize.MetricTypeResource && metric.TargetAverageUtilization != 0:
+		utilization := metric.TargetAverageUtilization
+		return autoscale_v2beta2.MetricTarget{
+			Type:               autoscale_v2beta2.UtilizationMetricType,
+			AverageUtilization: &utilization,
+		}
+	case metric.MetricType() != bitesize.MetricTypePods && metric.TargetValue != "":
+		value, _ := resource.ParseQuantity(metric.TargetValue)
+		return autoscale_v2beta2.MetricTarget{
+			Type:  autoscale_v2beta2.ValueMetricType,
+			Value: &value,
+		}
+	default:
+		value, _ := resource.ParseQuantity(metric.TargetAverageValue)
+		return autoscale_v2beta2.MetricTarget{
+			Type:         autoscale_v2beta2.AverageValueMetricType,
+			AverageValue: &value,
+		}
+	}
+}
+
 func (w *KubeMapper) initContainers() ([]v1.Container, error) {
 	var retval []v1.Container
 	// TODO: Need to add volume, env and other configs support here
@@ -423,13 +509,18 @@ func (w *KubeMapper) container() (*v1.Container, error) {
 		return nil, err
 	}
 
+	// service ports may share a container port
 	var ports []v1.ContainerPort
+	seen := map[v1.ContainerPort]bool{}
 	for _, port := range w.BiteService.Ports {
 		containerPort := v1.ContainerPort{
-			ContainerPort: int32(port),
-			Protocol:      "TCP",
+			ContainerPort: int32(port.ContainerPort),
+			Protocol:      port.KubernetesProtocol(),
+		}
+		if !seen[containerPort] {
+			seen[containerPort] = true
+			ports = append(ports, containerPort)
 		}
-		ports = append(ports, containerPort)
 	}
 
 	retval = &v1.Container{
@@ -623,6 +714,8 @@ func (w *KubeMapper) envVars() ([]v1.EnvVar, error) {
 					},
 				},
 			}
+		case e.ValueFrom != nil:
+			evar = serviceOutputEnvVar(e)
 		case e.Value != "":
 			evar = v1.EnvVar{
 				Name:  e.Name,
@@ -651,6 +744,28 @@ func (w *KubeMapper) envVars() ([]v1.EnvVar, error) {
 	return retval, err
 }
 
+// serviceOutputEnvVar returns env var set from resolved service output,
+// either referencing the outputs secret or holding status field value
+func serviceOutputEnvVar(e bitesize.EnvVar) v1.EnvVar {
+	if e.ValueFrom.Secret == "" {
+		return v1.EnvVar{
+			Name:  e.Name,
+			Value: e.ValueFrom.Value,
+		}
+	}
+	return v1.EnvVar{
+		Name: e.Name,
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: e.ValueFrom.Secret,
+				},
+				Key: e.ValueFrom.Field,
+			},
+		},
+	}
+}
+
 func (w *KubeMapper) initVolumeMounts(container bitesize.Container) ([]v1.VolumeMount, error) {
 	var retval []v1.VolumeMount
 
@@ -694,6 +809,10 @@ func (w *KubeMapper) volumeMounts() ([]v1.VolumeMount, error) {
 func (w *KubeMapper) volumes() ([]v1.Volume, error) {
 	var retval []v1.Volume
 	for _, v := range w.BiteService.Volumes {
+		// StatefulSet claims are mounted from volumeClaimTemplates
+		if w.BiteService.IsStatefulSet() && !v.IsSecretVolume() && !v.IsConfigMapVolume() {
+			continue
+		}
 		vol := v1.Volume{
 			Name:         v.Name,
 			VolumeSource: w.volumeSource(v),
@@ -740,7 +859,7 @@ func (w *KubeMapper) volumeSource(vol bitesize.Volume) v1.VolumeSource {
 }
 
 // Ingress extracts Kubernetes object from BiteSize definition
-func (w *KubeMapper) Ingress() (*netwk_v1beta1.Ingress, error) {
+func (w *KubeMapper) Ingress() (*ext.Ingress, error) {
 	labels := map[string]string{
 		"creator":     "pipeline",
 		"application": w.BiteService.Application,
@@ -759,61 +878,83 @@ func (w *KubeMapper) Ingress() (*netwk_v1beta1.Ingress, error) {
 		labels["http2"] = w.BiteService.HTTP2
 	}
 
-	port := intstr.FromInt(w.BiteService.Ports[0])
-	retval := &netwk_v1beta1.Ingress{
+	if w.BiteService.IngressProfile != "" {
+		labels[bitesize.IngressProfileLabel] = w.BiteService.IngressProfile
+	}
+
+	annotations := bitesize.IngressAnnotationsFor(*w.BiteService)
+	if keys := bitesize.IngressAnnotationKeys(*w.BiteService); keys != "" {
+		annotations[bitesize.IngressAnnotationsAnnotation] = keys
+	}
+	if w.BiteService.HasExternalRoutes() {
+		annotations[bitesize.ExternalURLAnnotation] = strings.Join(w.BiteService.ExternalURL, ",")
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+
+	retval := &ext.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      w.BiteService.Name,
-			Namespace: w.Namespace,
-			Labels:    labels,
+			Name:        w.BiteService.Name,
+			Namespace:   w.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
 		},
-		Spec: netwk_v1beta1.IngressSpec{
-			Rules: []netwk_v1beta1.IngressRule{},
+		Spec: ext.IngressSpec{
+			Rules: []ext.IngressRule{},
 		},
 	}
 
-	for _, url := range w.BiteService.ExternalURL {
-		rule := netwk_v1beta1.IngressRule{
-			Host: url,
-			IngressRuleValue: netwk_v1beta1.IngressRuleValue{
-				HTTP: &netwk_v1beta1.HTTPIngressRuleValue{
-					Paths: []netwk_v1beta1.HTTPIngressPath{
-						{
-							Path: "/",
-							Backend: netwk_v1beta1.IngressBackend{
-								ServiceName: w.BiteService.Name,
-								ServicePort: port,
-							},
-						},
-					},
+	if w.BiteService.IngressClass != "" {
+		class := w.BiteService.IngressClass
+		retval.Spec.IngressClassName = &class
+	}
+
+	// one rule per host, external_url path first
+	rules := map[string]*ext.HTTPIngressRuleValue{}
+	hosts := w.BiteService.ExternalHosts()
+	for _, route := range w.BiteService.Routes() {
+		pathType := ext.PathType(route.PathType)
+		if rules[route.Host] == nil {
+			rules[route.Host] = &ext.HTTPIngressRuleValue{}
+		}
+		rules[route.Host].Paths = append(rules[route.Host].Paths, ext.HTTPIngressPath{
+			Path:     route.Path,
+			PathType: &pathType,
+			Backend: ext.IngressBackend{
+				Service: &ext.IngressServiceBackend{
+					Name: route.Service,
+					Port: ext.ServiceBackendPort{Number: int32(route.Port)},
 				},
 			},
-		}
+		})
+	}
 
-		if w.BiteService.Ssl == "true" {
-			tls := netwk_v1beta1.IngressTLS{
-				Hosts:      []string{url},
-				SecretName: w.BiteService.Name,
-			}
-			retval.Spec.TLS = []netwk_v1beta1.IngressTLS{tls}
-		} else {
-			retval.Spec.TLS = nil
-		}
+	for _, host := range hosts {
+		retval.Spec.Rules = append(retval.Spec.Rules, ext.IngressRule{
+			Host:             host,
+			IngressRuleValue: ext.IngressRuleValue{HTTP: rules[host]},
+		})
+	}
 
-		// Override backend
-		if w.BiteService.Backend != "" {
-			rule.IngressRuleValue.HTTP.Paths[0].Backend.ServiceName = w.BiteService.Backend
-		}
-		if w.BiteService.BackendPort != 0 {
-			rule.IngressRuleValue.HTTP.Paths[0].Backend.ServicePort = intstr.FromInt(w.BiteService.BackendPort)
+	if w.BiteService.Ssl == "true" && len(hosts) > 0 {
+		retval.Spec.TLS = []ext.IngressTLS{
+			{
+				Hosts:      hosts,
+				SecretName: w.BiteService.TLSSecretName(),
+			},
 		}
-		retval.Spec.Rules = append(retval.Spec.Rules, rule)
-
 	}
 
 	return retval, nil
 }
 
+// ExternalSecretTLS extracts ExternalSecret syncing service TLS secret from
+// AWS Secrets Manager
 func (w *KubeMapper) ExternalSecretTLS() (*ext.ExternalSecret, error) {
+	if w.BiteService.TLSProvider() != bitesize.TLSProviderExternalSecrets {
+		return nil, fmt.Errorf("service %s tls provider is not %s", w.BiteService.Name, bitesize.TLSProviderExternalSecrets)
+	}
 
 	labels := map[string]string{
 		"creator":     "pipeline",
@@ -858,6 +999,83 @@ func (w *KubeMapper) ExternalSecretTLS() (*ext.ExternalSecret, error) {
 	}, nil
 }
 
+// Certificate extracts cert-manager Certificate for service TLS secret
+func (w *KubeMapper) Certificate() (*ext.Certificate, error) {
+	if w.BiteService.TLSProvider() != bitesize.TLSProviderCertManager {
+		return nil, fmt.Errorf("service %s tls provider is not %s", w.BiteService.Name, bitesize.TLSProviderCertManager)
+	}
+
+	return &ext.Certificate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: ext.CertificateAPIVersion,
+			Kind:       "Certificate",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      w.BiteService.Name,
+			Namespace: w.Namespace,
+			Labels: map[string]string{
+				"creator":   "pipeline",
+				"name":      w.BiteService.Name,
+				"namespace": w.Namespace,
+			},
+		},
+		Spec: ext.CertificateSpec{
+			SecretName: w.BiteService.TLSSecretName(),
+			DNSNames:   w.BiteService.ExternalHosts(),
+			IssuerRef: ext.IssuerReference{
+				Name:  w.BiteService.TLS.Issuer,
+				Kind:  w.BiteService.TLS.IssuerKind,
+				Group: "cert-manager.io",
+			},
+		},
+	}, nil
+}
+
+// ScaledObject extracts KEDA ScaledObject scaling the service workload from
+// BiteSize definition
+func (w *KubeMapper) ScaledObject() (*ext.ScaledObject, error) {
+	keda := w.BiteService.Keda
+	if keda == nil {
+		return nil, fmt.Errorf("service %s has no keda settings", w.BiteService.Name)
+	}
+
+	var triggers []ext.ScaleTriggers
+	for _, t := range keda.Triggers {
+		trigger := ext.ScaleTriggers{Type: t.Type, Metadata: t.Metadata}
+		if t.AuthenticationRef != "" {
+			trigger.AuthenticationRef = &ext.AuthenticationRef{Name: t.AuthenticationRef}
+		}
+		triggers = append(triggers, trigger)
+	}
+
+	return &ext.ScaledObject{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: ext.ScaledObjectAPIVersion,
+			Kind:       "ScaledObject",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      w.BiteService.Name,
+			Namespace: w.Namespace,
+			Labels: map[string]string{
+				"creator": "pipeline",
+				"name":    w.BiteService.Name,
+			},
+		},
+		Spec: ext.ScaledObjectSpec{
+			ScaleTargetRef: ext.ScaleTarget{
+				Name:       w.BiteService.Name,
+				Kind:       w.workloadKind(),
+				APIVersion: "apps/v1",
+			},
+			PollingInterval: &keda.PollingInterval,
+			CooldownPeriod:  &keda.CooldownPeriod,
+			MinReplicaCount: &keda.MinReplicas,
+			MaxReplicaCount: &keda.MaxReplicas,
+			Triggers:        triggers,
+		},
+	}, nil
+}
+
 // CustomResourceDefinition extracts Kubernetes object from BiteSize definition
 func (w *KubeMapper) CustomResourceDefinition() (*ext.PrsnExternalResource, error) {
 	ports := []*ext.Port{}
@@ -891,6 +1109,7 @@ func (w *KubeMapper) CustomResourceDefinition() (*ext.PrsnExternalResource, erro
 				"creator": "pipeline",
 				"name":    w.BiteService.Name,
 			},
+			Annotations:     w.deletionProtectionAnnotations(),
 			Namespace:       w.Namespace,
 			Name:            w.BiteService.Name,
 			ResourceVersion: w.BiteService.ResourceVersion,
@@ -918,14 +1137,11 @@ func (w *KubeMapper) CustomResourceDefinition() (*ext.PrsnExternalResource, erro
 
 // ServiceMeshGateway extracts Kubernetes object from BiteSize definition
 func (w *KubeMapper) ServiceMeshGateway() (*ext.PrsnExternalResource, error) {
-	hosts := []string{}
-	for _, url := range w.BiteService.ExternalURL {
-		hosts = append(hosts, url)
-	}
+	hosts := append([]string{}, w.BiteService.ExternalHosts()...)
 
 	retval := &ext.PrsnExternalResource{
 		TypeMeta: metav1.TypeMeta{
-			Kind:       "Gateway",
+			Kind:       ext.GatewayKind,
 			APIVersion: "networking.istio.io/v1alpha3",
 		},
 		ObjectMeta: metav1.ObjectMeta{
@@ -976,7 +1192,7 @@ func (w *KubeMapper) ServiceMeshGateway() (*ext.PrsnExternalResource, error) {
 
 		tls := &ext.ServerTLSOptions{
 			Mode:           "SIMPLE",
-			CredentialName: w.BiteService.Name,
+			CredentialName: w.BiteService.TLSSecretName(),
 		}
 		retval.Spec.Servers[0].TLS = tls
 	} else {
@@ -988,21 +1204,70 @@ func (w *KubeMapper) ServiceMeshGateway() (*ext.PrsnExternalResource, error) {
 
 // ServiceMeshVirtualService extracts Kubernetes object from BiteSize definition
 func (w *KubeMapper) ServiceMeshVirtualService() (*ext.PrsnExternalResource, error) {
-	hosts := []string{}
-	port := w.BiteService.Ports[0]
-
-	for _, url := range w.BiteService.ExternalURL {
-		hosts = append(hosts, url)
-	}
+	var routes []*ext.HTTPRoute
+
+	if w.BiteService.HasExternalRoutes() {
+		// VirtualService matches routes in order; host is matched by authority
+		for _, route := range bitesize.SortedRoutes(w.BiteService.Routes()) {
+			uri := &ext.StringPrefix{Prefix: route.Path}
+			if route.PathType == bitesize.PathTypeExact {
+				uri = &ext.StringPrefix{Exact: route.Path}
+			}
+			routes = append(routes, &ext.HTTPRoute{
+				Match: []*ext.HTTPMatchRequest{
+					{
+						URI:       uri,
+						Authority: &ext.StringPrefix{Exact: route.Host},
+					},
+				},
+				Route: []*ext.HTTPRouteDestination{
+					{
+						Destination: &ext.Destination{
+							Host: route.Service,
+							Port: &ext.PortSelector{
+								Number: uint32(route.Port),
+							},
+						},
+					},
+				},
+			})
+		}
+	} else {
+		backend := w.BiteService.Name
+		if w.BiteService.Backend != "" {
+			backend = w.BiteService.Backend
+		}
+		// blue/green services route to the active colour subset
+		// of ServiceMeshDestinationRule
+		subset := ""
+		if w.BiteService.IsBlueGreenParentDeployment() && backend == w.BiteService.Name {
+			subset = w.BiteService.ActiveDeploymentTag().String()
+		}
 
-	backend := w.BiteService.Name
-	if w.BiteService.Backend != "" {
-		backend = w.BiteService.Backend
+		routes = []*ext.HTTPRoute{
+			{
+				Match: []*ext.HTTPMatchRequest{
+					{
+						URI: &ext.StringPrefix{
+							Prefix: "/",
+						},
+					},
+				},
+				Route: w.canaryRoute(&ext.Destination{
+					Host:   backend,
+					Subset: subset,
+					Port: &ext.PortSelector{
+						Number: uint32(w.BiteService.HTTPPort()),
+					},
+				}),
+			},
+		}
 	}
+	w.routePolicy(routes)
 
 	retval := &ext.PrsnExternalResource{
 		TypeMeta: metav1.TypeMeta{
-			Kind:       "VirtualService",
+			Kind:       ext.VirtualServiceKind,
 			APIVersion: "networking.istio.io/v1alpha3",
 		},
 		ObjectMeta: metav1.ObjectMeta{
@@ -1017,28 +1282,8 @@ func (w *KubeMapper) ServiceMeshVirtualService() (*ext.PrsnExternalResource, err
 			Gateways: []string{
 				w.BiteService.Name,
 			},
-			Hosts: hosts,
-			HTTP: []*ext.HTTPRoute{
-				{
-					Match: []*ext.HTTPMatchRequest{
-						{
-							URI: &ext.StringPrefix{
-								Prefix: "/",
-							},
-						},
-					},
-					Route: []*ext.HTTPRouteDestination{
-						{
-							Destination: &ext.Destination{
-								Host: backend,
-								Port: &ext.PortSelector{
-									Number: uint32(port),
-								},
-							},
-						},
-					},
-				},
-			},
+			Hosts: append([]string{}, w.BiteService.ExternalHosts()...),
+			HTTP:  routes,
 		},
 	}
 
@@ -1103,8 +1348,17 @@ func (w *KubeMapper) resources() (v1.ResourceRequirements, error) {
 func (w *KubeMapper) annotations() map[string]string {
 	retval := map[string]string{}
 	retval["deployment_method"] = w.BiteService.DeploymentMethod()
+	if policy := bitesize.TrafficPolicyAnnotation(w.BiteService.TrafficPolicy); policy != "" {
+		retval["traffic_policy"] = policy
+	}
 	if w.BiteService.IsBlueGreenParentDeployment() {
 		retval["deployment_active"] = w.BiteService.ActiveDeploymentTag().String()
+		if configured := w.BiteService.ConfiguredDeploymentTag(); configured != w.BiteService.ActiveDeploymentTag() {
+			retval["deployment_active_configured"] = configured.String()
+		}
+	}
+	for k, v := range w.canaryAnnotations() {
+		retval[k] = v
 	}
 	return retval
 }
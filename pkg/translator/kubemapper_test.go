@@ -3,13 +3,17 @@ package translator
 import (
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	ext "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
+	autoscale_v2beta2 "k8s.io/api/autoscaling/v2beta2"
 	v1 "k8s.io/api/core/v1"
-	netwk_v1beta1 "k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func TestCRD(t *testing.T) {
@@ -42,7 +46,7 @@ func TestTranslatorIngressTLS(t *testing.T) {
 	w.BiteService.Ssl = "true"
 	ingress, _ := w.Ingress()
 
-	tls := netwk_v1beta1.IngressTLS{
+	tls := ext.IngressTLS{
 		Hosts:      w.BiteService.ExternalURL,
 		SecretName: w.BiteService.Name,
 	}
@@ -160,7 +164,7 @@ func TestTranslatorIngressBackendOverride(t *testing.T) {
 	w.BiteService.Backend = "www.example.com"
 
 	ingress, _ := w.Ingress()
-	result := ingress.Spec.Rules[0].IngressRuleValue.HTTP.Paths[0].Backend.ServiceName
+	result := ingress.Spec.Rules[0].IngressRuleValue.HTTP.Paths[0].Backend.Service.Name
 
 	if result != "www.example.com" {
 		t.Errorf("wrong ingress backend value: %s, expecting: %s", result, w.BiteService.Backend)
@@ -174,18 +178,37 @@ func TestTranslatorIngressBackendPortOverride(t *testing.T) {
 	w.BiteService.BackendPort = 81
 
 	ingress, _ := w.Ingress()
-	result := int(ingress.Spec.Rules[0].IngressRuleValue.HTTP.Paths[0].Backend.ServicePort.IntVal)
+	result := int(ingress.Spec.Rules[0].IngressRuleValue.HTTP.Paths[0].Backend.Service.Port.Number)
 
 	if result != w.BiteService.BackendPort {
 		t.Errorf("wrong ingress backend_port value: %v, expecting: %v", result, w.BiteService.BackendPort)
 	}
 }
 
+func TestTranslatorIngressClass(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.ExternalURL = []string{"www.test.com"}
+
+	ingress, _ := w.Ingress()
+	if ingress.Spec.IngressClassName != nil {
+		t.Errorf("Unexpected ingress class: %s", *ingress.Spec.IngressClassName)
+	}
+	if pathType := ingress.Spec.Rules[0].HTTP.Paths[0].PathType; pathType == nil || *pathType != ext.PathTypePrefix {
+		t.Errorf("Expected Prefix path type, got %v", pathType)
+	}
+
+	w.BiteService.IngressClass = "nginx-internal"
+	ingress, _ = w.Ingress()
+	if ingress.Spec.IngressClassName == nil || *ingress.Spec.IngressClassName != "nginx-internal" {
+		t.Errorf("Expected ingress class nginx-internal, got %v", ingress.Spec.IngressClassName)
+	}
+}
+
 func BuildKubeMapper() *KubeMapper {
 	m := &KubeMapper{
 		BiteService: &bitesize.Service{
 			Name:  "test",
-			Ports: []int{80},
+			Ports: bitesize.ServicePorts{{Name: "http-80", Port: 80, ContainerPort: 80}},
 		},
 		Namespace: "testns",
 	}
@@ -248,6 +271,55 @@ func TestTranslatorHPA(t *testing.T) {
 	}
 }
 
+func TestTranslatorHPAMetrics(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.HPA = bitesize.HorizontalPodAutoscaler{
+		MinReplicas: 2,
+		MaxReplicas: 10,
+		Metrics: []bitesize.Metric{
+			{Type: bitesize.MetricTypeResource, Name: "memory", TargetAverageValue: "512Mi"},
+			{
+				Type:        bitesize.MetricTypeObject,
+				Name:        "requests_per_second",
+				Object:      &bitesize.MetricObject{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Name: "test"},
+				TargetValue: "2k",
+			},
+			{Type: bitesize.MetricTypeExternal, Name: "queue_messages_ready", Selector: map[string]string{"queue": "tasks"}, TargetAverageValue: "30"},
+		},
+		Behavior: &bitesize.ScalingBehavior{
+			ScaleDown: &bitesize.ScalingRules{
+				SelectPolicy: "Min",
+				Policies:     []bitesize.ScalingPolicy{{Type: "Pods", Value: 1, PeriodSeconds: 60}},
+			},
+		},
+	}
+
+	h, _ := w.HPA()
+	if len(h.Spec.Metrics) != 3 {
+		t.Fatalf("Expected 3 metrics, got %+v", h.Spec.Metrics)
+	}
+	memory := h.Spec.Metrics[0]
+	if memory.Type != autoscale_v2beta2.ResourceMetricSourceType || memory.Resource.Name != v1.ResourceMemory ||
+		memory.Resource.Target.Type != autoscale_v2beta2.AverageValueMetricType || memory.Resource.Target.AverageValue.String() != "512Mi" {
+		t.Errorf("Unexpected memory metric: %+v", memory.Resource)
+	}
+	object := h.Spec.Metrics[1]
+	if object.Type != autoscale_v2beta2.ObjectMetricSourceType || object.Object.DescribedObject.Kind != "Ingress" ||
+		object.Object.Target.Type != autoscale_v2beta2.ValueMetricType || object.Object.Target.Value.String() != "2k" {
+		t.Errorf("Unexpected object metric: %+v", object.Object)
+	}
+	external := h.Spec.Metrics[2]
+	if external.Type != autoscale_v2beta2.ExternalMetricSourceType || external.External.Metric.Name != "queue_messages_ready" ||
+		!reflect.DeepEqual(external.External.Metric.Selector.MatchLabels, map[string]string{"queue": "tasks"}) {
+		t.Errorf("Unexpected external metric: %+v", external.External)
+	}
+
+	expected := `{"scaleDown":{"selectPolicy":"Min","policies":[{"type":"Pods","value":1,"periodSeconds":60}]}}`
+	if behavior := h.Annotations[k8s.HPABehaviorAnnotation]; behavior != expected {
+		t.Errorf("Unexpected behavior annotation: %s", behavior)
+	}
+}
+
 func TestTranslatorEnvVars(t *testing.T) {
 	w := BuildKubeMapper()
 	w.BiteService.Replicas = 1
@@ -404,3 +476,391 @@ func TestServiceMeshVirtualService(t *testing.T) {
 		t.Errorf("Wrong destination host for the istio virtual service %s", d.Spec.HTTP[0].Route[0].Destination.Host)
 	}
 }
+
+func TestTranslatorServicePorts(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.ExternalURL = []string{"test-api"}
+	w.BiteService.Ports = bitesize.ServicePorts{
+		{Name: "udp-53", Port: 53, ContainerPort: 5353, Protocol: bitesize.ProtocolUDP, AppProtocol: "udp"},
+		{Name: "grpc-api", Port: 9000, ContainerPort: 9090, Protocol: bitesize.ProtocolGRPC, AppProtocol: "grpc"},
+		{Name: "http-metrics", Port: 9100, ContainerPort: 9090, Protocol: bitesize.ProtocolTCP, AppProtocol: "http"},
+	}
+
+	svc, _ := w.Service()
+	expected := []v1.ServicePort{
+		{Name: "udp-53", Port: 53, TargetPort: intstr.FromInt(5353), Protocol: v1.ProtocolUDP},
+		{Name: "grpc-api", Port: 9000, TargetPort: intstr.FromInt(9090), Protocol: v1.ProtocolTCP},
+		{Name: "http-metrics", Port: 9100, TargetPort: intstr.FromInt(9090), Protocol: v1.ProtocolTCP},
+	}
+	if !reflect.DeepEqual(svc.Spec.Ports, expected) {
+		t.Errorf("Unexpected service ports: %+v", svc.Spec.Ports)
+	}
+
+	container, _ := w.container()
+	expectedContainer := []v1.ContainerPort{
+		{ContainerPort: 5353, Protocol: v1.ProtocolUDP},
+		{ContainerPort: 9090, Protocol: v1.ProtocolTCP},
+	}
+	if !reflect.DeepEqual(container.Ports, expectedContainer) {
+		t.Errorf("Unexpected container ports: %+v", container.Ports)
+	}
+
+	vs, _ := w.ServiceMeshVirtualService()
+	if port := vs.Spec.HTTP[0].Route[0].Destination.Port.Number; port != 9000 {
+		t.Errorf("Expected virtual service to route to gRPC port 9000, got %d", port)
+	}
+}
+
+func TestTranslatorServiceType(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.ServiceType = &bitesize.ServiceType{
+		Type:                     bitesize.ServiceTypeLoadBalancer,
+		LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		ExternalTrafficPolicy:    "Local",
+		Annotations: map[string]string{
+			"service.beta.kubernetes.io/aws-load-balancer-type":     "nlb",
+			"service.beta.kubernetes.io/aws-load-balancer-internal": "true",
+		},
+	}
+
+	svc, _ := w.Service()
+	if svc.Spec.Type != v1.ServiceTypeLoadBalancer || svc.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyTypeLocal {
+		t.Errorf("Unexpected service spec: %+v", svc.Spec)
+	}
+	if !reflect.DeepEqual(svc.Spec.LoadBalancerSourceRanges, []string{"10.0.0.0/8"}) {
+		t.Errorf("Unexpected load balancer source ranges: %v", svc.Spec.LoadBalancerSourceRanges)
+	}
+	if svc.Annotations["service.beta.kubernetes.io/aws-load-balancer-type"] != "nlb" ||
+		svc.Annotations[bitesize.ServiceTypeAnnotationsAnnotation] != "service.beta.kubernetes.io/aws-load-balancer-internal,service.beta.kubernetes.io/aws-load-balancer-type" {
+		t.Errorf("Unexpected service annotations: %v", svc.Annotations)
+	}
+
+	w.BiteService.ServiceType = &bitesize.ServiceType{Type: bitesize.ServiceTypeExternalName, ExternalName: "db.example.com"}
+	svc, _ = w.Service()
+	if svc.Spec.Type != v1.ServiceTypeExternalName || svc.Spec.ExternalName != "db.example.com" || svc.Spec.Selector != nil {
+		t.Errorf("Unexpected ExternalName service spec: %+v", svc.Spec)
+	}
+}
+
+func TestTranslatorIngressExternalRoutes(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.Ssl = "true"
+	w.BiteService.ExternalURL = []string{"www.test.com"}
+	w.BiteService.ExternalRoutes = []bitesize.ExternalRoute{
+		{Host: "www.test.com", Path: "/api", PathType: "Prefix", Service: "api", Port: 8080},
+		{Host: "static.test.com", Path: "/", PathType: "Exact", Service: "test", Port: 80},
+	}
+
+	ingress, _ := w.Ingress()
+	if len(ingress.Spec.Rules) != 2 {
+		t.Fatalf("Expected 2 ingress rules, got %d", len(ingress.Spec.Rules))
+	}
+
+	paths := ingress.Spec.Rules[0].HTTP.Paths
+	if ingress.Spec.Rules[0].Host != "www.test.com" || len(paths) != 2 {
+		t.Fatalf("Unexpected rule: %+v", ingress.Spec.Rules[0])
+	}
+	if paths[0].Path != "/" || paths[0].Backend.Service.Name != "test" {
+		t.Errorf("Expected external_url path first, got %+v", paths[0])
+	}
+	if paths[1].Path != "/api" || paths[1].Backend.Service.Name != "api" || paths[1].Backend.Service.Port.Number != 8080 {
+		t.Errorf("Unexpected route path: %+v", paths[1])
+	}
+	if pt := ingress.Spec.Rules[1].HTTP.Paths[0].PathType; *pt != ext.PathTypeExact {
+		t.Errorf("Expected Exact path type, got %s", *pt)
+	}
+
+	if !reflect.DeepEqual(ingress.Spec.TLS[0].Hosts, []string{"www.test.com", "static.test.com"}) {
+		t.Errorf("Unexpected TLS hosts: %v", ingress.Spec.TLS[0].Hosts)
+	}
+	if ingress.Annotations[bitesize.ExternalURLAnnotation] != "www.test.com" {
+		t.Errorf("Unexpected external_url annotation: %v", ingress.Annotations)
+	}
+}
+
+func TestTranslatorIngressAnnotations(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.ExternalURL = []string{"www.test.com"}
+
+	ingress, _ := w.Ingress()
+	if ingress.Annotations != nil {
+		t.Errorf("Unexpected ingress annotations: %v", ingress.Annotations)
+	}
+
+	w.BiteService.IngressProfile = "nginx"
+	w.BiteService.IngressTimeout = 30
+	w.BiteService.IngressAnnotations = map[string]string{"example.com/owner": "docs"}
+	ingress, _ = w.Ingress()
+
+	if ingress.Labels[bitesize.IngressProfileLabel] != "nginx" {
+		t.Errorf("Unexpected ingress profile label: %v", ingress.Labels)
+	}
+	if ingress.Annotations["nginx.ingress.kubernetes.io/proxy-read-timeout"] != "30" ||
+		ingress.Annotations["example.com/owner"] != "docs" {
+		t.Errorf("Unexpected ingress annotations: %v", ingress.Annotations)
+	}
+}
+
+func TestTranslatorCertificate(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.Ssl = "true"
+	w.BiteService.ExternalURL = []string{"www.test.com", "test.com"}
+
+	if _, err := w.Certificate(); err == nil {
+		t.Errorf("Expected error for service without cert-manager tls provider")
+	}
+
+	w.BiteService.TLS = &bitesize.TLSSettings{
+		Provider:   bitesize.TLSProviderCertManager,
+		Issuer:     "letsencrypt",
+		IssuerKind: "ClusterIssuer",
+	}
+	cert, err := w.Certificate()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if cert.Spec.SecretName != "test" || !reflect.DeepEqual(cert.Spec.DNSNames, []string{"www.test.com", "test.com"}) {
+		t.Errorf("Unexpected certificate spec: %+v", cert.Spec)
+	}
+	if cert.Spec.IssuerRef.Name != "letsencrypt" || cert.Spec.IssuerRef.Kind != "ClusterIssuer" {
+		t.Errorf("Unexpected certificate issuer: %+v", cert.Spec.IssuerRef)
+	}
+	if cert.Labels["namespace"] != "testns" {
+		t.Errorf("Unexpected certificate labels: %+v", cert.Labels)
+	}
+}
+
+func TestTranslatorExternalSecretTLS(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.Ssl = "true"
+	w.BiteService.ExternalURL = []string{"www.test.com"}
+	w.BiteService.TLS = &bitesize.TLSSettings{Provider: bitesize.TLSProviderCertManager, Issuer: "letsencrypt"}
+
+	os.Unsetenv("ENVIRONMENT")
+	os.Unsetenv("ENVTYPE")
+	if _, err := w.ExternalSecretTLS(); err == nil || !strings.Contains(err.Error(), "tls provider") {
+		t.Errorf("Expected tls provider error for cert-manager service, got: %v", err)
+	}
+
+	w.BiteService.TLS = &bitesize.TLSSettings{Provider: bitesize.TLSProviderExternalSecrets}
+	if _, err := w.ExternalSecretTLS(); err == nil {
+		t.Errorf("Expected error without ENVIRONMENT and ENVTYPE")
+	}
+
+	os.Setenv("ENVIRONMENT", "dev")
+	os.Setenv("ENVTYPE", "nonprod")
+	defer os.Unsetenv("ENVIRONMENT")
+	defer os.Unsetenv("ENVTYPE")
+	es, err := w.ExternalSecretTLS()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if key := es.SecretDescriptor.Data[0]["key"]; key != "tls/nonprod/dev/testns/test.crt" {
+		t.Errorf("Unexpected external secret key: %s", key)
+	}
+}
+
+func TestTranslatorScaledObject(t *testing.T) {
+	w := BuildKubeMapper()
+
+	if _, err := w.ScaledObject(); err == nil {
+		t.Errorf("Expected error for service without keda settings")
+	}
+
+	w.BiteService.Workload = bitesize.WorkloadStatefulSet
+	w.BiteService.Keda = &bitesize.KedaScaling{
+		MinReplicas:     0,
+		MaxReplicas:     20,
+		PollingInterval: 30,
+		CooldownPeriod:  300,
+		Triggers: []bitesize.KedaTrigger{
+			{Type: "aws-sqs-queue", AuthenticationRef: "keda-aws", Metadata: map[string]string{"queueURL": "orders", "queueLength": "5"}},
+			{Type: "kafka", Metadata: map[string]string{"topic": "payments", "lagThreshold": "50"}},
+		},
+	}
+	so, err := w.ScaledObject()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if so.Spec.ScaleTargetRef.Name != "test" || so.Spec.ScaleTargetRef.Kind != "StatefulSet" {
+		t.Errorf("Unexpected scale target: %+v", so.Spec.ScaleTargetRef)
+	}
+	if *so.Spec.MinReplicaCount != 0 || *so.Spec.MaxReplicaCount != 20 || *so.Spec.PollingInterval != 30 || *so.Spec.CooldownPeriod != 300 {
+		t.Errorf("Unexpected scaled object spec: %+v", so.Spec)
+	}
+	if len(so.Spec.Triggers) != 2 || so.Spec.Triggers[0].AuthenticationRef.Name != "keda-aws" || so.Spec.Triggers[1].AuthenticationRef != nil {
+		t.Fatalf("Unexpected triggers: %+v", so.Spec.Triggers)
+	}
+	if so.Spec.Triggers[1].Type != "kafka" || so.Spec.Triggers[1].Metadata["topic"] != "payments" {
+		t.Errorf("Unexpected kafka trigger: %+v", so.Spec.Triggers[1])
+	}
+
+	if h, _ := w.HPA(); h != nil {
+		t.Errorf("Expected no hpa for keda scaled service, got %+v", h)
+	}
+}
+
+func TestTranslatorTLSSecret(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.Ssl = "true"
+	w.BiteService.ExternalURL = []string{"www.test.com"}
+	w.BiteService.TLS = &bitesize.TLSSettings{
+		Provider:   bitesize.TLSProviderSecret,
+		SecretName: "wildcard-tls",
+	}
+
+	ingress, _ := w.Ingress()
+	if ingress.Spec.TLS[0].SecretName != "wildcard-tls" {
+		t.Errorf("Unexpected ingress tls secret: %s", ingress.Spec.TLS[0].SecretName)
+	}
+
+	gateway, _ := w.ServiceMeshGateway()
+	if gateway.Spec.Servers[0].TLS.CredentialName != "wildcard-tls" {
+		t.Errorf("Unexpected gateway credential: %s", gateway.Spec.Servers[0].TLS.CredentialName)
+	}
+}
+
+func TestTranslatorCanary(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.ExternalURL = []string{"www.test.com"}
+	w.BiteService.Deployment = &bitesize.DeploymentSettings{
+		Method:      "canary",
+		Canary:      &bitesize.CanarySettings{Steps: []int{20, 50}},
+		CanaryState: &bitesize.CanaryState{Step: 0, Status: bitesize.CanaryProgressing},
+	}
+
+	ingress, err := w.CanaryIngress()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if ingress.Name != "test-canary" || ingress.Labels[CanaryLabel] != "true" {
+		t.Errorf("Unexpected canary ingress metadata: %+v", ingress.ObjectMeta)
+	}
+	if ingress.Annotations["nginx.ingress.kubernetes.io/canary-weight"] != "20" {
+		t.Errorf("Unexpected canary ingress annotations: %v", ingress.Annotations)
+	}
+	if backend := ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service; backend.Name != "test-canary" {
+		t.Errorf("Unexpected canary ingress backend: %+v", backend)
+	}
+
+	d, _ := w.ServiceMeshVirtualService()
+	route := d.Spec.HTTP[0].Route
+	if len(route) != 2 || route[0].Weight != 80 || route[1].Weight != 20 || route[1].Destination.Host != "test-canary" {
+		t.Errorf("Unexpected canary route: %+v %+v", route[0], route[len(route)-1])
+	}
+
+	svc, _ := w.Service()
+	if svc.Annotations["canary_status"] != bitesize.CanaryProgressing {
+		t.Errorf("Unexpected service annotations: %v", svc.Annotations)
+	}
+
+	w.BiteService.Deployment.CanaryState.Status = bitesize.CanaryPromoted
+	d, _ = w.ServiceMeshVirtualService()
+	if len(d.Spec.HTTP[0].Route) != 1 || d.Spec.HTTP[0].Route[0].Weight != 0 {
+		t.Errorf("Unexpected route after canary promotion: %+v", d.Spec.HTTP[0].Route)
+	}
+}
+
+func TestServiceMeshVirtualServiceExternalRoutes(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.ExternalURL = []string{"www.test.com"}
+	w.BiteService.ExternalRoutes = []bitesize.ExternalRoute{
+		{Host: "www.test.com", Path: "/api", PathType: "Exact", Service: "api", Port: 8080},
+	}
+
+	d, _ := w.ServiceMeshVirtualService()
+	if len(d.Spec.HTTP) != 2 {
+		t.Fatalf("Expected 2 http routes, got %d", len(d.Spec.HTTP))
+	}
+
+	// longest path is matched first
+	api := d.Spec.HTTP[0]
+	if api.Match[0].URI.Exact != "/api" || api.Match[0].Authority.Exact != "www.test.com" {
+		t.Errorf("Unexpected match: %+v %+v", api.Match[0].URI, api.Match[0].Authority)
+	}
+	if api.Route[0].Destination.Host != "api" || api.Route[0].Destination.Port.Number != 8080 {
+		t.Errorf("Unexpected destination: %+v", api.Route[0].Destination)
+	}
+	if d.Spec.HTTP[1].Match[0].URI.Prefix != "/" || d.Spec.HTTP[1].Route[0].Destination.Host != "test" {
+		t.Errorf("Unexpected default route: %+v", d.Spec.HTTP[1])
+	}
+}
+
+func TestTranslatorConfigHash(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.Annotations = map[string]string{"prometheus.io/scrape": "true"}
+	w.BiteService.ConfigHash = "abc123"
+
+	d, _ := w.Deployment()
+
+	annotations := d.Spec.Template.Annotations
+	if annotations[bitesize.ConfigHashAnnotation] != "abc123" || annotations["prometheus.io/scrape"] != "true" {
+		t.Errorf("Unexpected pod template annotations: %v", annotations)
+	}
+
+	if _, ok := w.BiteService.Annotations[bitesize.ConfigHashAnnotation]; ok {
+		t.Error("Expected service annotations to be left unchanged")
+	}
+}
+
+func TestServiceMeshDestinationRule(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.ExternalURL = []string{"test-api"}
+
+	if d, _ := w.ServiceMeshDestinationRule(); d != nil {
+		t.Errorf("Expected no DestinationRule without traffic_policy, got %+v", d)
+	}
+
+	w.BiteService.TrafficPolicy = &bitesize.TrafficPolicy{
+		ConnectionPool:   &bitesize.ConnectionPool{MaxConnections: 100, HTTP1MaxPendingRequests: 10},
+		OutlierDetection: &bitesize.OutlierDetection{ConsecutiveErrors: 5, Interval: "10s"},
+		Retries:          &bitesize.Retries{Attempts: 3, PerTryTimeout: "2s", RetryOn: "5xx"},
+		Timeout:          "10s",
+		TLSMode:          "ISTIO_MUTUAL",
+	}
+
+	d, _ := w.ServiceMeshDestinationRule()
+	expected := &ext.TrafficPolicy{
+		ConnectionPool: &ext.ConnectionPoolSettings{
+			TCP:  &ext.TCPSettings{MaxConnections: 100},
+			HTTP: &ext.HTTPSettings{HTTP1MaxPendingRequests: 10},
+		},
+		OutlierDetection: &ext.OutlierDetection{Consecutive5xxErrors: 5, Interval: "10s"},
+		TLS:              &ext.ClientTLSSettings{Mode: "ISTIO_MUTUAL"},
+	}
+	if d.Kind != "DestinationRule" || d.Spec.Host != w.BiteService.Name || len(d.Spec.Subsets) != 0 {
+		t.Errorf("Unexpected DestinationRule %+v", d)
+	}
+	if !reflect.DeepEqual(d.Spec.TrafficPolicy, expected) {
+		t.Errorf("Unexpected traffic policy %+v, expected %+v", d.Spec.TrafficPolicy, expected)
+	}
+
+	vs, _ := w.ServiceMeshVirtualService()
+	route := vs.Spec.HTTP[0]
+	if route.Timeout != "10s" || !reflect.DeepEqual(route.Retries, &ext.HTTPRetry{Attempts: 3, PerTryTimeout: "2s", RetryOn: "5xx"}) {
+		t.Errorf("Unexpected route retries %+v and timeout %s", route.Retries, route.Timeout)
+	}
+	if route.Route[0].Destination.Subset != "" {
+		t.Errorf("Expected no subset, got %s", route.Route[0].Destination.Subset)
+	}
+
+	// blue/green services route to the active colour subset
+	green := bitesize.GreenService
+	w.BiteService.TrafficPolicy = nil
+	w.BiteService.Deployment = &bitesize.DeploymentSettings{
+		Method:    "bluegreen",
+		BlueGreen: &bitesize.BlueGreenSettings{Active: &green},
+	}
+	d, _ = w.ServiceMeshDestinationRule()
+	expectedSubsets := []*ext.Subset{
+		{Name: "blue", Labels: map[string]string{"name": w.BiteService.Name + "-blue"}},
+		{Name: "green", Labels: map[string]string{"name": w.BiteService.Name + "-green"}},
+	}
+	if d == nil || !reflect.DeepEqual(d.Spec.Subsets, expectedSubsets) || d.Spec.TrafficPolicy != nil {
+		t.Errorf("Unexpected blue/green DestinationRule %+v", d)
+	}
+	vs, _ = w.ServiceMeshVirtualService()
+	if subset := vs.Spec.HTTP[0].Route[0].Destination.Subset; subset != "green" {
+		t.Errorf("Expected green subset, got %s", subset)
+	}
+}
@@ -0,0 +1,145 @@
+package translator
+
+import (
+	"fmt"
+
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	v1 "k8s.io/api/core/v1"
+	netwk_v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// DefaultDenyPolicyName is the name of environment-wide NetworkPolicy
+// denying all ingress traffic not explicitly allowed by services
+const DefaultDenyPolicyName = "default-deny"
+
+// NamespaceNameLabel is set by Kubernetes on every namespace and is used to
+// select peer namespaces in generated policies
+const NamespaceNameLabel = "kubernetes.io/metadata.name"
+
+// NetworkPolicy extracts Kubernetes object from BiteSize definition
+func (w *KubeMapper) NetworkPolicy() (*netwk_v1.NetworkPolicy, error) {
+	if w.BiteService.IsBlueGreenParentDeployment() || !w.BiteService.HasNetworkPolicy() {
+		return nil, nil
+	}
+
+	retval := &netwk_v1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      w.BiteService.Name,
+			Namespace: w.Namespace,
+			Labels:    w.labels(),
+		},
+		Spec: netwk_v1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"creator": "pipeline",
+					"name":    w.BiteService.Name,
+				},
+			},
+		},
+	}
+
+	if len(w.BiteService.AllowFrom) > 0 {
+		retval.Spec.PolicyTypes = append(retval.Spec.PolicyTypes, netwk_v1.PolicyTypeIngress)
+		for _, p := range w.BiteService.AllowFrom {
+			retval.Spec.Ingress = append(retval.Spec.Ingress, netwk_v1.NetworkPolicyIngressRule{
+				From:  []netwk_v1.NetworkPolicyPeer{networkPolicyPeer(p)},
+				Ports: networkPolicyPorts(p.Ports),
+			})
+		}
+	}
+
+	if len(w.BiteService.AllowTo) > 0 {
+		retval.Spec.PolicyTypes = append(retval.Spec.PolicyTypes, netwk_v1.PolicyTypeEgress)
+		for _, p := range w.BiteService.AllowTo {
+			retval.Spec.Egress = append(retval.Spec.Egress, netwk_v1.NetworkPolicyEgressRule{
+				To:    []netwk_v1.NetworkPolicyPeer{networkPolicyPeer(p)},
+				Ports: networkPolicyPorts(p.Ports),
+			})
+		}
+		// restricting egress would otherwise break name resolution
+		retval.Spec.Egress = append(retval.Spec.Egress, dnsEgressRule())
+	}
+
+	return retval, nil
+}
+
+// DefaultDenyNetworkPolicy returns NetworkPolicy denying all ingress traffic
+// to pods in the namespace
+func DefaultDenyNetworkPolicy(namespace string) *netwk_v1.NetworkPolicy {
+	return &netwk_v1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DefaultDenyPolicyName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"creator": "pipeline",
+			},
+		},
+		Spec: netwk_v1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []netwk_v1.PolicyType{netwk_v1.PolicyTypeIngress},
+		},
+	}
+}
+
+func networkPolicyPeer(p bitesize.NetworkPeer) netwk_v1.NetworkPolicyPeer {
+	var retval netwk_v1.NetworkPolicyPeer
+
+	if p.CIDR != "" {
+		retval.IPBlock = &netwk_v1.IPBlock{CIDR: p.CIDR}
+		return retval
+	}
+
+	if p.Service != "" {
+		// blue/green children run under colour-suffixed names
+		retval.PodSelector = &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{
+					Key:      "name",
+					Operator: metav1.LabelSelectorOpIn,
+					Values: []string{
+						p.Service,
+						fmt.Sprintf("%s-%s", p.Service, bitesize.BlueService),
+						fmt.Sprintf("%s-%s", p.Service, bitesize.GreenService),
+					},
+				},
+			},
+		}
+	}
+
+	if p.Namespace != "" {
+		retval.NamespaceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				NamespaceNameLabel: p.Namespace,
+			},
+		}
+	}
+	return retval
+}
+
+func networkPolicyPorts(ports []int) []netwk_v1.NetworkPolicyPort {
+	var retval []netwk_v1.NetworkPolicyPort
+	for _, p := range ports {
+		protocol := v1.ProtocolTCP
+		port := intstr.FromInt(p)
+		retval = append(retval, netwk_v1.NetworkPolicyPort{
+			Protocol: &protocol,
+			Port:     &port,
+		})
+	}
+	return retval
+}
+
+func dnsEgressRule() netwk_v1.NetworkPolicyEgressRule {
+	udp := v1.ProtocolUDP
+	tcp := v1.ProtocolTCP
+	port := intstr.FromInt(53)
+
+	return netwk_v1.NetworkPolicyEgressRule{
+		Ports: []netwk_v1.NetworkPolicyPort{
+			{Protocol: &udp, Port: &port},
+			{Protocol: &tcp, Port: &port},
+		},
+	}
+}
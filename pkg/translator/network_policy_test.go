@@ -0,0 +1,97 @@
+package translator
+
+import (
+	"testing"
+
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	netwk_v1 "k8s.io/api/networking/v1"
+)
+
+func TestNetworkPolicyNotDeclared(t *testing.T) {
+	w := BuildKubeMapper()
+
+	np, _ := w.NetworkPolicy()
+	if np != nil {
+		t.Errorf("Expected no network policy, got: %+v", np)
+	}
+}
+
+func TestNetworkPolicyAllowFrom(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.AllowFrom = []bitesize.NetworkPeer{
+		{Service: "front", Ports: []int{8080}},
+		{Namespace: "ingress-nginx"},
+		{CIDR: "10.0.0.0/8"},
+	}
+
+	np, _ := w.NetworkPolicy()
+
+	if len(np.Spec.PolicyTypes) != 1 || np.Spec.PolicyTypes[0] != netwk_v1.PolicyTypeIngress {
+		t.Errorf("Unexpected policy types: %v", np.Spec.PolicyTypes)
+	}
+
+	if np.Spec.PodSelector.MatchLabels["name"] != "test" {
+		t.Errorf("Unexpected pod selector: %+v", np.Spec.PodSelector)
+	}
+
+	if len(np.Spec.Ingress) != 3 {
+		t.Fatalf("Expected 3 ingress rules, got: %d", len(np.Spec.Ingress))
+	}
+
+	svc := np.Spec.Ingress[0]
+	if svc.From[0].PodSelector.MatchExpressions[0].Values[0] != "front" {
+		t.Errorf("Unexpected service peer: %+v", svc.From[0].PodSelector)
+	}
+	if svc.Ports[0].Port.IntValue() != 8080 {
+		t.Errorf("Unexpected service peer port: %+v", svc.Ports)
+	}
+
+	ns := np.Spec.Ingress[1]
+	if ns.From[0].PodSelector != nil || ns.From[0].NamespaceSelector.MatchLabels[NamespaceNameLabel] != "ingress-nginx" {
+		t.Errorf("Unexpected namespace peer: %+v", ns.From[0])
+	}
+
+	cidr := np.Spec.Ingress[2]
+	if cidr.From[0].IPBlock.CIDR != "10.0.0.0/8" {
+		t.Errorf("Unexpected cidr peer: %+v", cidr.From[0])
+	}
+}
+
+func TestNetworkPolicyAllowTo(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.AllowTo = []bitesize.NetworkPeer{
+		{Service: "db", Namespace: "data", Ports: []int{5432}},
+	}
+
+	np, _ := w.NetworkPolicy()
+
+	if len(np.Spec.PolicyTypes) != 1 || np.Spec.PolicyTypes[0] != netwk_v1.PolicyTypeEgress {
+		t.Errorf("Unexpected policy types: %v", np.Spec.PolicyTypes)
+	}
+
+	// declared peer plus DNS
+	if len(np.Spec.Egress) != 2 {
+		t.Fatalf("Expected 2 egress rules, got: %d", len(np.Spec.Egress))
+	}
+
+	peer := np.Spec.Egress[0].To[0]
+	if peer.PodSelector == nil || peer.NamespaceSelector.MatchLabels[NamespaceNameLabel] != "data" {
+		t.Errorf("Unexpected egress peer: %+v", peer)
+	}
+
+	if len(np.Spec.Egress[1].To) != 0 || np.Spec.Egress[1].Ports[0].Port.IntValue() != 53 {
+		t.Errorf("Expected DNS egress rule, got: %+v", np.Spec.Egress[1])
+	}
+}
+
+func TestDefaultDenyNetworkPolicy(t *testing.T) {
+	np := DefaultDenyNetworkPolicy("testns")
+
+	if np.Name != DefaultDenyPolicyName || np.Namespace != "testns" {
+		t.Errorf("Unexpected default deny policy metadata: %+v", np.ObjectMeta)
+	}
+
+	if len(np.Spec.PodSelector.MatchLabels) != 0 || len(np.Spec.Ingress) != 0 {
+		t.Errorf("Expected default deny policy to select all pods and allow nothing, got: %+v", np.Spec)
+	}
+}
@@ -0,0 +1,86 @@
+package translator
+
+import (
+	"strings"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StatefulSet extracts Kubernetes object from BiteSize definition. Returns
+// nil for services not using statefulset workload.
+func (w *KubeMapper) StatefulSet() (*apps_v1.StatefulSet, error) {
+	if !w.BiteService.IsStatefulSet() {
+		return nil, nil
+	}
+	replicas := int32(w.BiteService.Replicas)
+
+	template, err := w.podTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	retval := &apps_v1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        w.BiteService.Name,
+			Namespace:   w.Namespace,
+			Annotations: w.workloadAnnotations(),
+			Labels: map[string]string{
+				"creator":     "pipeline",
+				"name":        w.BiteService.Name,
+				"application": w.BiteService.Application,
+				"version":     w.BiteService.Version,
+				"app":         w.BiteService.Application,
+			},
+		},
+		Spec: apps_v1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: w.BiteService.Name,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"creator": "pipeline",
+					"name":    w.BiteService.Name,
+				},
+			},
+			Template:             *template,
+			VolumeClaimTemplates: w.volumeClaimTemplates(),
+		},
+	}
+
+	return retval, nil
+}
+
+// volumeClaimTemplates generates per-replica claims for statefulset. Claims
+// created from templates are labelled with "statefulset" instead of
+// "deployment" so they are not picked up as standalone volumes.
+func (w *KubeMapper) volumeClaimTemplates() []v1.PersistentVolumeClaim {
+	var retval []v1.PersistentVolumeClaim
+
+	for _, vol := range w.BiteService.Volumes {
+		if vol.IsSecretVolume() || vol.IsConfigMapVolume() {
+			continue
+		}
+
+		claim := w.persistentVolumeClaim(vol)
+		claim.ObjectMeta.Namespace = ""
+		delete(claim.ObjectMeta.Labels, "deployment")
+		claim.ObjectMeta.Labels["statefulset"] = w.BiteService.Name
+		// each replica gets its own volume, so claims can't be bound to
+		// a single manually provisioned one
+		claim.Spec.VolumeName = ""
+		claim.Spec.Selector = nil
+		claim.ObjectMeta.Annotations = map[string]string{
+			"volume.beta.kubernetes.io/storage-class": "aws-" + strings.ToLower(vol.Type),
+		}
+		retval = append(retval, claim)
+	}
+	return retval
+}
+
+func (w *KubeMapper) workloadKind() string {
+	if w.BiteService.IsStatefulSet() {
+		return "StatefulSet"
+	}
+	return "Deployment"
+}
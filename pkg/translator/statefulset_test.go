@@ -0,0 +1,78 @@
+package translator
+
+import (
+	"testing"
+
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestStatefulSetNotDeclared(t *testing.T) {
+	w := BuildKubeMapper()
+
+	s, _ := w.StatefulSet()
+	if s != nil {
+		t.Errorf("Expected no statefulset, got: %+v", s)
+	}
+}
+
+func TestStatefulSet(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.Workload = bitesize.WorkloadStatefulSet
+	w.BiteService.Replicas = 3
+	w.BiteService.Volumes = []bitesize.Volume{
+		{Name: "data", Path: "/data", Modes: "ReadWriteOnce", Size: "10G", Type: "ebs"},
+		{Name: "settings", Path: "/etc/settings", Type: bitesize.TypeConfigMap},
+	}
+
+	if d, _ := w.Deployment(); d != nil {
+		t.Errorf("Expected no deployment, got: %+v", d)
+	}
+
+	if pvcs, _ := w.PersistentVolumeClaims(); len(pvcs) != 0 {
+		t.Errorf("Expected no standalone pvcs, got: %+v", pvcs)
+	}
+
+	s, _ := w.StatefulSet()
+	if s.Spec.ServiceName != "test" || *s.Spec.Replicas != 3 {
+		t.Errorf("Unexpected statefulset spec: %+v", s.Spec)
+	}
+
+	if len(s.Spec.VolumeClaimTemplates) != 1 {
+		t.Fatalf("Expected 1 volume claim template, got: %d", len(s.Spec.VolumeClaimTemplates))
+	}
+	claim := s.Spec.VolumeClaimTemplates[0]
+	if claim.Name != "data" || claim.Labels["statefulset"] != "test" || claim.Labels["deployment"] != "" {
+		t.Errorf("Unexpected volume claim template: %+v", claim.ObjectMeta)
+	}
+
+	// only configmap volume is defined in pod spec, claims come from templates
+	volumes := s.Spec.Template.Spec.Volumes
+	if len(volumes) != 1 || volumes[0].Name != "settings" {
+		t.Errorf("Unexpected pod volumes: %+v", volumes)
+	}
+
+	if len(s.Spec.Template.Spec.Containers[0].VolumeMounts) != 2 {
+		t.Errorf("Unexpected volume mounts: %+v", s.Spec.Template.Spec.Containers[0].VolumeMounts)
+	}
+}
+
+func TestStatefulSetHeadlessService(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.Workload = bitesize.WorkloadStatefulSet
+
+	svc, _ := w.Service()
+	if svc.Spec.ClusterIP != v1.ClusterIPNone {
+		t.Errorf("Expected headless service, got ClusterIP %q", svc.Spec.ClusterIP)
+	}
+}
+
+func TestStatefulSetHPA(t *testing.T) {
+	w := BuildKubeMapper()
+	w.BiteService.Workload = bitesize.WorkloadStatefulSet
+
+	hpa, _ := w.HPA()
+	if hpa.Spec.ScaleTargetRef.Kind != "StatefulSet" {
+		t.Errorf("Unexpected scale target: %+v", hpa.Spec.ScaleTargetRef)
+	}
+}
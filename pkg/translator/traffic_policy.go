@@ -0,0 +1,104 @@
+package translator
+
+import (
+	"fmt"
+
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	ext "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceMeshDestinationRule extracts Istio DestinationRule from service
+// traffic_policy. Blue/green "parent" services get a subset for each colour.
+// Returns nil if service has neither.
+func (w *KubeMapper) ServiceMeshDestinationRule() (*ext.PrsnExternalResource, error) {
+	policy := w.BiteService.TrafficPolicy
+	if policy == nil && !w.BiteService.IsBlueGreenParentDeployment() {
+		return nil, nil
+	}
+
+	retval := &ext.PrsnExternalResource{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       ext.DestinationRuleKind,
+			APIVersion: "networking.istio.io/v1alpha3",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"creator": "pipeline",
+				"name":    w.BiteService.Name,
+			},
+			Namespace: w.Namespace,
+			Name:      w.BiteService.Name,
+		},
+		Spec: ext.PrsnExternalResourceSpec{
+			Host: w.BiteService.Name,
+		},
+	}
+
+	if w.BiteService.IsBlueGreenParentDeployment() {
+		for _, colour := range []bitesize.BlueGreenServiceSet{bitesize.BlueService, bitesize.GreenService} {
+			retval.Spec.Subsets = append(retval.Spec.Subsets, &ext.Subset{
+				Name: colour.String(),
+				Labels: map[string]string{
+					"name": fmt.Sprintf("%s-%s", w.BiteService.Name, colour),
+				},
+			})
+		}
+	}
+
+	if policy == nil {
+		return retval, nil
+	}
+
+	trafficPolicy := &ext.TrafficPolicy{}
+	if pool := policy.ConnectionPool; pool != nil {
+		trafficPolicy.ConnectionPool = &ext.ConnectionPoolSettings{}
+		if pool.MaxConnections != 0 || pool.ConnectTimeout != "" {
+			trafficPolicy.ConnectionPool.TCP = &ext.TCPSettings{
+				MaxConnections: pool.MaxConnections,
+				ConnectTimeout: pool.ConnectTimeout,
+			}
+		}
+		if pool.HTTP1MaxPendingRequests != 0 || pool.HTTP2MaxRequests != 0 || pool.MaxRequestsPerConnection != 0 {
+			trafficPolicy.ConnectionPool.HTTP = &ext.HTTPSettings{
+				HTTP1MaxPendingRequests:  pool.HTTP1MaxPendingRequests,
+				HTTP2MaxRequests:         pool.HTTP2MaxRequests,
+				MaxRequestsPerConnection: pool.MaxRequestsPerConnection,
+			}
+		}
+	}
+	if outlier := policy.OutlierDetection; outlier != nil {
+		trafficPolicy.OutlierDetection = &ext.OutlierDetection{
+			Consecutive5xxErrors: outlier.ConsecutiveErrors,
+			Interval:             outlier.Interval,
+			BaseEjectionTime:     outlier.BaseEjectionTime,
+			MaxEjectionPercent:   outlier.MaxEjectionPercent,
+		}
+	}
+	if policy.TLSMode != "" {
+		trafficPolicy.TLS = &ext.ClientTLSSettings{Mode: policy.TLSMode}
+	}
+	retval.Spec.TrafficPolicy = trafficPolicy
+
+	return retval, nil
+}
+
+// routePolicy sets retries and timeout of service traffic_policy on
+// VirtualService routes
+func (w *KubeMapper) routePolicy(routes []*ext.HTTPRoute) {
+	policy := w.BiteService.TrafficPolicy
+	if policy == nil {
+		return
+	}
+
+	for _, route := range routes {
+		route.Timeout = policy.Timeout
+		if policy.Retries != nil {
+			route.Retries = &ext.HTTPRetry{
+				Attempts:      policy.Retries.Attempts,
+				PerTryTimeout: policy.Retries.PerTryTimeout,
+				RetryOn:       policy.Retries.RetryOn,
+			}
+		}
+	}
+}
@@ -3,13 +3,50 @@ package util
 import (
 	log "github.com/Sirupsen/logrus"
 	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
 )
 
-// LogTraceAsYaml logs anything as YAML when trace logging is enabled
+// redactedValue replaces sensitive values in logs
+const redactedValue = "REDACTED"
+
+// LogTraceAsYaml logs anything as YAML when trace logging is enabled.
+// Secret data is redacted.
 func LogTraceAsYaml(identifier string, i interface{}) {
+	i = redact(i)
 	cfgYAML, err := yaml.Marshal(&i)
 	if err != nil {
 		log.Fatalf("Error parsing YAML with id %s: %s", identifier, err.Error())
 	}
 	log.Tracef("%s: %#v", identifier, string(cfgYAML))
 }
+
+func redact(i interface{}) interface{} {
+	switch v := i.(type) {
+	case v1.Secret:
+		return RedactSecret(&v)
+	case *v1.Secret:
+		if v == nil {
+			return v
+		}
+		return RedactSecret(v)
+	case []v1.Secret:
+		secrets := make([]v1.Secret, len(v))
+		for n := range v {
+			secrets[n] = *RedactSecret(&v[n])
+		}
+		return secrets
+	}
+	return i
+}
+
+// RedactSecret returns a copy of secret with all data values replaced
+func RedactSecret(secret *v1.Secret) *v1.Secret {
+	s := secret.DeepCopy()
+	for k := range s.Data {
+		s.Data[k] = []byte(redactedValue)
+	}
+	for k := range s.StringData {
+		s.StringData[k] = redactedValue
+	}
+	return s
+}
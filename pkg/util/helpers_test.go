@@ -0,0 +1,43 @@
+package util
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLogTraceAsYamlRedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetLevel(log.TraceLevel)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetLevel(log.InfoLevel)
+	}()
+
+	secret := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db"},
+		Data:       map[string][]byte{"password": []byte("s3cr3t")},
+		StringData: map[string]string{"username": "admin"},
+	}
+
+	LogTraceAsYaml("secret", secret)
+	LogTraceAsYaml("secret pointer", &secret)
+	LogTraceAsYaml("secret list", []v1.Secret{secret})
+
+	out := buf.String()
+	if strings.Contains(out, "admin") || strings.Contains(out, "s3cr3t") {
+		t.Errorf("Expected secret values to be redacted, got %s", out)
+	}
+	if !strings.Contains(out, "db") {
+		t.Errorf("Expected secret metadata to be logged, got %s", out)
+	}
+	if string(secret.Data["password"]) != "s3cr3t" {
+		t.Error("Expected original secret to be left unchanged")
+	}
+}
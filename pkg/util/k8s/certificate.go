@@ -0,0 +1,100 @@
+package k8s
+
+import (
+	log "github.com/Sirupsen/logrus"
+	extensions "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+	"k8s.io/client-go/rest"
+)
+
+// Certificate represents cert-manager Certificate crd on the cluster
+type Certificate struct {
+	rest.Interface
+
+	Namespace string
+}
+
+// Get retrieves Certificate from the k8s using name
+func (client *Certificate) Get(name string) (*extensions.Certificate, error) {
+	var rsc extensions.Certificate
+
+	err := client.Interface.Get().
+		Resource("certificates").
+		Namespace(client.Namespace).
+		Name(name).
+		Do().Into(&rsc)
+
+	if err != nil {
+		return nil, err
+	}
+	return &rsc, nil
+}
+
+// Exist checks if named resource exist in k8s cluster
+func (client *Certificate) Exist(name string) bool {
+	rsc, _ := client.Get(name)
+	return rsc != nil
+}
+
+// Apply creates or updates Certificate in k8s
+func (client *Certificate) Apply(resource *extensions.Certificate) error {
+	if resource == nil {
+		return nil
+	}
+	if rsc, err := client.Get(resource.Name); err == nil {
+		resource.ResourceVersion = rsc.GetResourceVersion()
+		log.Debugf("Updating certificate: %s", resource.Name)
+		return client.Update(resource)
+	}
+	log.Debugf("Creating certificate: %s", resource.Name)
+	return client.Create(resource)
+}
+
+// Create creates given Certificate in k8s
+func (client *Certificate) Create(resource *extensions.Certificate) error {
+	if resource == nil {
+		return nil
+	}
+	var result extensions.Certificate
+	return client.Interface.Post().
+		Resource("certificates").
+		Namespace(client.Namespace).
+		Body(resource).
+		Do().Into(&result)
+}
+
+// Update updates existing Certificate in k8s
+func (client *Certificate) Update(resource *extensions.Certificate) error {
+	if resource == nil {
+		return nil
+	}
+	var result extensions.Certificate
+	return client.Interface.Put().
+		Resource("certificates").
+		Name(resource.Name).
+		Namespace(client.Namespace).
+		Body(resource).
+		Do().Into(&result)
+}
+
+// Destroy deletes named Certificate
+func (client *Certificate) Destroy(name string) error {
+	return client.Interface.Delete().
+		Resource("certificates").
+		Namespace(client.Namespace).
+		Name(name).
+		Do().Error()
+}
+
+// List returns the list of Certificates maintained by pipeline
+func (client *Certificate) List() ([]extensions.Certificate, error) {
+	var result extensions.CertificateList
+	err := client.Interface.Get().
+		Resource("certificates").
+		Namespace(client.Namespace).
+		Param("labelSelector", listOptions().LabelSelector).
+		Do().Into(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Items, nil
+}
@@ -1,6 +1,8 @@
 package k8s
 
 import (
+	"strings"
+
 	log "github.com/Sirupsen/logrus"
 	extensions "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
 	"k8s.io/client-go/rest"
@@ -110,8 +112,11 @@ func (client *CustomResourceDefinition) List() ([]extensions.PrsnExternalResourc
 	return result.Items, nil
 }
 
+// plural returns resource name of type. Resource names are lower case, so
+// kinds can be used as types.
 func plural(singular string) string {
 	var plural string
+	singular = strings.ToLower(singular)
 
 	switch string(singular[len(singular)-1]) {
 	case "s", "x":
@@ -0,0 +1,109 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	ext "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+type fakeCertificate struct {
+	Store cache.Store
+}
+
+func (f *fakeCertificate) HandleWrite(req *http.Request) (*http.Response, error) {
+	var cert *ext.Certificate
+
+	data, _ := ioutil.ReadAll(req.Body)
+	if err := json.Unmarshal(data, &cert); err != nil {
+		return nil, err
+	}
+	if err := f.Store.Update(cert); err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusCreated, Body: objBody(cert)}, nil
+}
+
+func (f *fakeCertificate) HandleGet(req *http.Request) (*http.Response, error) {
+	header := http.Header{}
+	header.Set("Content-Type", runtime.ContentTypeJSON)
+
+	// /namespaces/<ns>/<resource>[/<name>]
+	pathElems := strings.Split(req.URL.Path, "/")
+
+	if len(pathElems) == 5 {
+		obj, ok, _ := f.Store.GetByKey(pathElems[2] + "/" + pathElems[4])
+		if !ok || pathElems[3] != "certificates" {
+			return &http.Response{StatusCode: http.StatusNotFound, Header: header, Body: objBody(struct{}{})}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: objBody(obj)}, nil
+	}
+
+	items := []ext.Certificate{}
+	if len(pathElems) == 4 && pathElems[3] == "certificates" {
+		for _, obj := range f.Store.List() {
+			cert := obj.(*ext.Certificate)
+			if cert.Namespace == pathElems[2] {
+				items = append(items, *cert)
+			}
+		}
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body: objBody(ext.CertificateList{
+			Items: items,
+		}),
+	}, nil
+}
+
+func (f *fakeCertificate) HandleDelete(req *http.Request) (*http.Response, error) {
+	pathElems := strings.Split(req.URL.Path, "/")
+	if len(pathElems) != 5 {
+		return nil, fmt.Errorf("unexpected request: %#v", req.URL)
+	}
+	obj, ok, _ := f.Store.GetByKey(pathElems[2] + "/" + pathElems[4])
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: objBody(struct{}{})}, nil
+	}
+	_ = f.Store.Delete(obj)
+	return &http.Response{StatusCode: http.StatusOK, Body: objBody(struct{}{})}, nil
+}
+
+// HandleRequest is HTTP API handler for fake cert-manager client
+func (f *fakeCertificate) HandleRequest(req *http.Request) (*http.Response, error) {
+	switch m := req.Method; {
+	case m == http.MethodPost, m == http.MethodPut:
+		return f.HandleWrite(req)
+	case m == http.MethodGet:
+		return f.HandleGet(req)
+	case m == http.MethodDelete:
+		return f.HandleDelete(req)
+	default:
+		return nil, fmt.Errorf("unexpected request: %#v\n%#v", req.URL, req)
+	}
+}
+
+// CertificateClient returns fake REST client serving cert-manager
+// Certificates to be used in unit tests. Other resources are always empty.
+func CertificateClient(objects ...runtime.Object) *fake.RESTClient {
+	f := &fakeCertificate{
+		Store: objectStore(objects),
+	}
+
+	return &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Group: "cert-manager.io", Version: "v1"},
+		NegotiatedSerializer: serializer.WithoutConversionCodecFactory{CodecFactory: scheme.Codecs},
+		Client:               fake.CreateHTTPClient(f.HandleRequest),
+	}
+}
@@ -0,0 +1,109 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	ext "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+type fakeScaledObject struct {
+	Store cache.Store
+}
+
+func (f *fakeScaledObject) HandleWrite(req *http.Request) (*http.Response, error) {
+	var so *ext.ScaledObject
+
+	data, _ := ioutil.ReadAll(req.Body)
+	if err := json.Unmarshal(data, &so); err != nil {
+		return nil, err
+	}
+	if err := f.Store.Update(so); err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusCreated, Body: objBody(so)}, nil
+}
+
+func (f *fakeScaledObject) HandleGet(req *http.Request) (*http.Response, error) {
+	header := http.Header{}
+	header.Set("Content-Type", runtime.ContentTypeJSON)
+
+	// /namespaces/<ns>/<resource>[/<name>]
+	pathElems := strings.Split(req.URL.Path, "/")
+
+	if len(pathElems) == 5 {
+		obj, ok, _ := f.Store.GetByKey(pathElems[2] + "/" + pathElems[4])
+		if !ok || pathElems[3] != "scaledobjects" {
+			return &http.Response{StatusCode: http.StatusNotFound, Header: header, Body: objBody(struct{}{})}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: objBody(obj)}, nil
+	}
+
+	items := []ext.ScaledObject{}
+	if len(pathElems) == 4 && pathElems[3] == "scaledobjects" {
+		for _, obj := range f.Store.List() {
+			so := obj.(*ext.ScaledObject)
+			if so.Namespace == pathElems[2] {
+				items = append(items, *so)
+			}
+		}
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body: objBody(ext.ScaledObjectList{
+			Items: items,
+		}),
+	}, nil
+}
+
+func (f *fakeScaledObject) HandleDelete(req *http.Request) (*http.Response, error) {
+	pathElems := strings.Split(req.URL.Path, "/")
+	if len(pathElems) != 5 {
+		return nil, fmt.Errorf("unexpected request: %#v", req.URL)
+	}
+	obj, ok, _ := f.Store.GetByKey(pathElems[2] + "/" + pathElems[4])
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: objBody(struct{}{})}, nil
+	}
+	_ = f.Store.Delete(obj)
+	return &http.Response{StatusCode: http.StatusOK, Body: objBody(struct{}{})}, nil
+}
+
+// HandleRequest is HTTP API handler for fake KEDA client
+func (f *fakeScaledObject) HandleRequest(req *http.Request) (*http.Response, error) {
+	switch m := req.Method; {
+	case m == http.MethodPost, m == http.MethodPut:
+		return f.HandleWrite(req)
+	case m == http.MethodGet:
+		return f.HandleGet(req)
+	case m == http.MethodDelete:
+		return f.HandleDelete(req)
+	default:
+		return nil, fmt.Errorf("unexpected request: %#v\n%#v", req.URL, req)
+	}
+}
+
+// ScaledObjectClient returns fake REST client serving KEDA ScaledObjects
+// to be used in unit tests. Other resources are always empty.
+func ScaledObjectClient(objects ...runtime.Object) *fake.RESTClient {
+	f := &fakeScaledObject{
+		Store: objectStore(objects),
+	}
+
+	return &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Group: "keda.sh", Version: "v1alpha1"},
+		NegotiatedSerializer: serializer.WithoutConversionCodecFactory{CodecFactory: scheme.Codecs},
+		Client:               fake.CreateHTTPClient(f.HandleRequest),
+	}
+}
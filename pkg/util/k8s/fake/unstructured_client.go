@@ -0,0 +1,137 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+)
+
+type fakeUnstructured struct {
+	sync.Mutex
+	// objects keyed by <namespace>/<resource>/<name>
+	objects map[string]map[string]interface{}
+}
+
+// resourcePath returns /namespaces/<ns>/<resource>[/<name>] path elements,
+// with resource names matched case insensitively
+func resourcePath(req *http.Request) []string {
+	pathElems := strings.Split(req.URL.Path, "/")
+	if len(pathElems) > 3 {
+		pathElems[3] = strings.ToLower(pathElems[3])
+	}
+	return pathElems
+}
+
+func (f *fakeUnstructured) HandleWrite(req *http.Request) (*http.Response, error) {
+	pathElems := resourcePath(req)
+	if len(pathElems) < 4 {
+		return nil, fmt.Errorf("unexpected request: %#v", req.URL)
+	}
+
+	obj := map[string]interface{}{}
+	data, _ := ioutil.ReadAll(req.Body)
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+
+	f.Lock()
+	defer f.Unlock()
+	f.objects[pathElems[2]+"/"+pathElems[3]+"/"+name] = obj
+	return &http.Response{StatusCode: http.StatusCreated, Body: objBody(obj)}, nil
+}
+
+func (f *fakeUnstructured) HandleGet(req *http.Request) (*http.Response, error) {
+	header := http.Header{}
+	header.Set("Content-Type", runtime.ContentTypeJSON)
+
+	pathElems := resourcePath(req)
+
+	f.Lock()
+	defer f.Unlock()
+
+	if len(pathElems) == 5 {
+		obj, ok := f.objects[strings.Join(pathElems[2:], "/")]
+		if !ok {
+			return &http.Response{StatusCode: http.StatusNotFound, Header: header, Body: objBody(struct{}{})}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: objBody(obj)}, nil
+	}
+
+	items := []map[string]interface{}{}
+	if len(pathElems) == 4 {
+		prefix := pathElems[2] + "/" + pathElems[3] + "/"
+		var keys []string
+		for key := range f.objects {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			items = append(items, f.objects[key])
+		}
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       objBody(map[string]interface{}{"items": items}),
+	}, nil
+}
+
+func (f *fakeUnstructured) HandleDelete(req *http.Request) (*http.Response, error) {
+	pathElems := resourcePath(req)
+	if len(pathElems) != 5 {
+		return nil, fmt.Errorf("unexpected request: %#v", req.URL)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	key := strings.Join(pathElems[2:], "/")
+	if _, ok := f.objects[key]; !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: objBody(struct{}{})}, nil
+	}
+	delete(f.objects, key)
+	return &http.Response{StatusCode: http.StatusOK, Body: objBody(struct{}{})}, nil
+}
+
+// HandleRequest is HTTP API handler for fake unstructured client
+func (f *fakeUnstructured) HandleRequest(req *http.Request) (*http.Response, error) {
+	switch m := req.Method; {
+	case m == http.MethodPost, m == http.MethodPut:
+		return f.HandleWrite(req)
+	case m == http.MethodGet:
+		return f.HandleGet(req)
+	case m == http.MethodDelete:
+		return f.HandleDelete(req)
+	default:
+		return nil, fmt.Errorf("unexpected request: %#v\n%#v", req.URL, req)
+	}
+}
+
+// UnstructuredClient returns fake REST client storing custom resources of
+// any kind as unstructured JSON objects, to be used in unit tests
+func UnstructuredClient(group string, version string) *fake.RESTClient {
+	f := &fakeUnstructured{
+		objects: map[string]map[string]interface{}{},
+	}
+
+	return &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Group: group, Version: version},
+		NegotiatedSerializer: serializer.WithoutConversionCodecFactory{CodecFactory: scheme.Codecs},
+		Client:               fake.CreateHTTPClient(f.HandleRequest),
+	}
+}
@@ -1,15 +1,38 @@
 package k8s
 
 import (
+	"encoding/json"
+
 	autoscale_v2beta2 "k8s.io/api/autoscaling/v2beta2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
+// HPABehaviorAnnotation holds spec.behavior of hpa as JSON. Behavior was
+// added to autoscaling/v2beta2 after the types used by this client, so it's
+// added to the request body from the annotation on apply.
+const HPABehaviorAnnotation = "environment-operator/hpa-behavior"
+
 // HorizontalPodAutoscaler type actions in k8s cluster
 type HorizontalPodAutoscaler struct {
 	kubernetes.Interface
 	Namespace string
+	// RESTClient for autoscaling/v2beta2, used to send hpa with behavior.
+	// Defaults to AutoscalingV2beta2() RESTClient.
+	RESTClient rest.Interface
+}
+
+// restClient returns nil for fake clientsets, which have no REST client.
+// Hpa is sent without behavior then.
+func (client *HorizontalPodAutoscaler) restClient() rest.Interface {
+	if client.RESTClient != nil {
+		return client.RESTClient
+	}
+	if rc, ok := client.AutoscalingV2beta2().RESTClient().(*rest.RESTClient); ok && rc != nil {
+		return rc
+	}
+	return nil
 }
 
 // Get returns hpa object from k8s by name
@@ -36,16 +59,24 @@ func (client *HorizontalPodAutoscaler) Apply(resource *autoscale_v2beta2.Horizon
 
 // Create creates new hpa in k8s
 func (client *HorizontalPodAutoscaler) Create(resource *autoscale_v2beta2.HorizontalPodAutoscaler) error {
-	var err error
-	if resource == nil {
+	if resource == nil || *resource.Spec.MinReplicas == 0 {
 		return nil
 	}
-
-	if *resource.Spec.MinReplicas != 0 {
+	body, err := withBehavior(resource)
+	if err != nil {
+		return err
+	}
+	rc := client.restClient()
+	if body == nil || rc == nil {
 		_, err = client.AutoscalingV2beta2().HorizontalPodAutoscalers(client.Namespace).Create(resource)
 		return err
 	}
-	return err
+
+	return rc.Post().
+		Namespace(client.Namespace).
+		Resource("horizontalpodautoscalers").
+		Body(body).
+		Do().Error()
 }
 
 // Update updates existing hpa in k8s
@@ -53,8 +84,45 @@ func (client *HorizontalPodAutoscaler) Update(resource *autoscale_v2beta2.Horizo
 	if resource == nil {
 		return nil
 	}
-	_, err := client.AutoscalingV2beta2().HorizontalPodAutoscalers(client.Namespace).Update(resource)
-	return err
+	body, err := withBehavior(resource)
+	if err != nil {
+		return err
+	}
+	rc := client.restClient()
+	if body == nil || rc == nil {
+		_, err = client.AutoscalingV2beta2().HorizontalPodAutoscalers(client.Namespace).Update(resource)
+		return err
+	}
+
+	return rc.Put().
+		Namespace(client.Namespace).
+		Resource("horizontalpodautoscalers").
+		Name(resource.Name).
+		Body(body).
+		Do().Error()
+}
+
+// withBehavior returns hpa as JSON with spec.behavior set from
+// HPABehaviorAnnotation, or nil if hpa has no behavior
+func withBehavior(resource *autoscale_v2beta2.HorizontalPodAutoscaler) ([]byte, error) {
+	behavior, ok := resource.Annotations[HPABehaviorAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	hpa := resource.DeepCopy()
+	hpa.APIVersion = "autoscaling/v2beta2"
+	hpa.Kind = "HorizontalPodAutoscaler"
+	data, err := json.Marshal(hpa)
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	body["spec"].(map[string]interface{})["behavior"] = json.RawMessage(behavior)
+	return json.Marshal(body)
 }
 
 // Destroy deletes service from the k8 cluster
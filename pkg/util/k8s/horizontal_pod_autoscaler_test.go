@@ -1,12 +1,21 @@
 package k8s
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
 	"testing"
 
 	autoscale_v2beta2 "k8s.io/api/autoscaling/v2beta2"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakerest "k8s.io/client-go/rest/fake"
 )
 
 func TestHPACreate(t *testing.T) {
@@ -306,6 +315,67 @@ func TestHPAExist(t *testing.T) {
 	}
 }
 
+func TestHPAApplyBehavior(t *testing.T) {
+	min := int32(1)
+	behavior := `{"scaleDown":{"selectPolicy":"Min"}}`
+	var requests []*http.Request
+	var bodies []map[string]interface{}
+	handler := func(req *http.Request) (*http.Response, error) {
+		var body map[string]interface{}
+		data, _ := ioutil.ReadAll(req.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+		bodies = append(bodies, body)
+		header := http.Header{}
+		header.Set("Content-Type", runtime.ContentTypeJSON)
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: ioutil.NopCloser(bytes.NewReader(data))}, nil
+	}
+	clientset := createFakeHPAClientset()
+	fakeHPAClient := HorizontalPodAutoscaler{
+		Interface: clientset,
+		Namespace: "sample",
+		RESTClient: &fakerest.RESTClient{
+			GroupVersion:         schema.GroupVersion{Group: "autoscaling", Version: "v2beta2"},
+			NegotiatedSerializer: serializer.WithoutConversionCodecFactory{CodecFactory: scheme.Codecs},
+			Client:               fakerest.CreateHTTPClient(handler),
+		},
+	}
+
+	for _, name := range []string{"fakehpa", "newhpa"} {
+		hpa := &autoscale_v2beta2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "sample",
+				Annotations: map[string]string{HPABehaviorAnnotation: behavior},
+			},
+			Spec: autoscale_v2beta2.HorizontalPodAutoscalerSpec{MinReplicas: &min, MaxReplicas: 3},
+		}
+		if err := fakeHPAClient.Apply(hpa); err != nil {
+			t.Fatalf("Error applying hpa %s", err.Error())
+		}
+	}
+
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() != "get" {
+			t.Errorf("Expected hpa with behavior to be sent in a single request, got %+v", action)
+		}
+	}
+	if len(requests) != 2 || requests[0].Method != http.MethodPut || requests[1].Method != http.MethodPost {
+		t.Fatalf("Expected update and create requests, got %+v", requests)
+	}
+	for _, body := range bodies {
+		spec := body["spec"].(map[string]interface{})
+		if spec["maxReplicas"] != float64(3) {
+			t.Errorf("Unexpected hpa spec %+v", spec)
+		}
+		if b, _ := json.Marshal(spec["behavior"]); string(b) != behavior {
+			t.Errorf("Unexpected hpa behavior %s", b)
+		}
+	}
+}
+
 func createFakeHPAClient() HorizontalPodAutoscaler {
 	return HorizontalPodAutoscaler{
 		Interface: createFakeHPAClientset(),
@@ -1,23 +1,100 @@
 package k8s
 
 import (
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	ext "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
 	netwk_v1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
-// Ingress type actions on ingresses in k8s cluster
+// Ingress type actions on ingresses in k8s cluster. Ingresses are managed
+// through networking.k8s.io/v1 when the cluster serves it, and through
+// networking.k8s.io/v1beta1 otherwise.
 type Ingress struct {
 	kubernetes.Interface
 	Namespace string
+	// RESTClient for networking.k8s.io/v1. Defaults to NetworkingV1()
+	// RESTClient.
+	RESTClient rest.Interface
+	// Version caches discovery of networking.k8s.io/v1 ingresses. Discovered
+	// on every call if not set.
+	Version *IngressVersion
+}
+
+// IngressVersion caches whether the cluster serves networking.k8s.io/v1
+// ingresses. Only results of successful discovery are kept, so failed
+// requests are retried.
+type IngressVersion struct {
+	mu     sync.Mutex
+	served *bool
+}
+
+// ServesV1 returns true if the cluster serves networking.k8s.io/v1
+// ingresses. Discovery errors fall back to v1beta1 without being cached.
+func (client *Ingress) ServesV1() bool {
+	if v := client.Version; v != nil {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		if v.served != nil {
+			return *v.served
+		}
+	}
+
+	found := false
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(ext.IngressAPIVersion)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Warnf("Error discovering %s ingresses: %s", ext.IngressAPIVersion, err.Error())
+		return false
+	}
+	if err == nil {
+		for _, r := range resources.APIResources {
+			if r.Name == "ingresses" {
+				found = true
+			}
+		}
+	}
+	if client.Version != nil {
+		client.Version.served = &found
+	}
+	return found
+}
+
+func (client *Ingress) restClient() rest.Interface {
+	if client.RESTClient != nil {
+		return client.RESTClient
+	}
+	return client.NetworkingV1().RESTClient()
 }
 
 // Get returns ingress object from the k8s by name
-func (client *Ingress) Get(name string) (*netwk_v1beta1.Ingress, error) {
-	return client.
-		NetworkingV1beta1().
-		Ingresses(client.Namespace).
-		Get(name, getOptions())
+func (client *Ingress) Get(name string) (*ext.Ingress, error) {
+	if !client.ServesV1() {
+		ingress, err := client.
+			NetworkingV1beta1().
+			Ingresses(client.Namespace).
+			Get(name, getOptions())
+		if err != nil {
+			return nil, err
+		}
+		return ingressFromV1beta1(ingress), nil
+	}
+
+	var result ext.Ingress
+	err := client.restClient().Get().
+		Namespace(client.Namespace).
+		Resource("ingresses").
+		Name(name).
+		Do().Into(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
 }
 
 // Exist returns boolean value if ingress exists in k8s
@@ -27,7 +104,7 @@ func (client *Ingress) Exist(name string) bool {
 }
 
 // Apply updates or creates ingress in k8s
-func (client *Ingress) Apply(resource *netwk_v1beta1.Ingress) error {
+func (client *Ingress) Apply(resource *ext.Ingress) error {
 	if resource == nil {
 		return nil
 	}
@@ -39,7 +116,7 @@ func (client *Ingress) Apply(resource *netwk_v1beta1.Ingress) error {
 }
 
 // Update updates existing ingress in k8s
-func (client *Ingress) Update(resource *netwk_v1beta1.Ingress) error {
+func (client *Ingress) Update(resource *ext.Ingress) error {
 	if resource == nil {
 		return nil
 	}
@@ -49,35 +126,199 @@ func (client *Ingress) Update(resource *netwk_v1beta1.Ingress) error {
 	}
 	resource.ResourceVersion = current.GetResourceVersion()
 
-	_, err = client.
-		NetworkingV1beta1().
-		Ingresses(client.Namespace).
-		Update(resource)
-	return err
+	if !client.ServesV1() {
+		_, err = client.
+			NetworkingV1beta1().
+			Ingresses(client.Namespace).
+			Update(ingressToV1beta1(resource))
+		return err
+	}
+
+	var result ext.Ingress
+	return client.restClient().Put().
+		Namespace(client.Namespace).
+		Resource("ingresses").
+		Name(resource.Name).
+		Body(withIngressTypeMeta(resource)).
+		Do().Into(&result)
 }
 
 // Create creates new ingress in k8s
-func (client *Ingress) Create(resource *netwk_v1beta1.Ingress) error {
+func (client *Ingress) Create(resource *ext.Ingress) error {
 	if resource == nil {
 		return nil
 	}
-	_, err := client.
-		NetworkingV1beta1().
-		Ingresses(client.Namespace).
-		Create(resource)
-	return err
+
+	if !client.ServesV1() {
+		_, err := client.
+			NetworkingV1beta1().
+			Ingresses(client.Namespace).
+			Create(ingressToV1beta1(resource))
+		return err
+	}
+
+	var result ext.Ingress
+	return client.restClient().Post().
+		Namespace(client.Namespace).
+		Resource("ingresses").
+		Body(withIngressTypeMeta(resource)).
+		Do().Into(&result)
 }
 
 // Destroy deletes ingress from the k8 cluster
 func (client *Ingress) Destroy(name string) error {
-	return client.NetworkingV1beta1().Ingresses(client.Namespace).Delete(name, &metav1.DeleteOptions{})
+	if !client.ServesV1() {
+		return client.NetworkingV1beta1().Ingresses(client.Namespace).Delete(name, &metav1.DeleteOptions{})
+	}
+
+	return client.restClient().Delete().
+		Namespace(client.Namespace).
+		Resource("ingresses").
+		Name(name).
+		Do().Error()
 }
 
 // List returns the list of k8s services maintained by pipeline
-func (client *Ingress) List() ([]netwk_v1beta1.Ingress, error) {
-	list, err := client.NetworkingV1beta1().Ingresses(client.Namespace).List(listOptions())
+func (client *Ingress) List() ([]ext.Ingress, error) {
+	if !client.ServesV1() {
+		list, err := client.NetworkingV1beta1().Ingresses(client.Namespace).List(listOptions())
+		if err != nil {
+			return nil, err
+		}
+		var retval []ext.Ingress
+		for i := range list.Items {
+			retval = append(retval, *ingressFromV1beta1(&list.Items[i]))
+		}
+		return retval, nil
+	}
+
+	var result ext.IngressList
+	err := client.restClient().Get().
+		Namespace(client.Namespace).
+		Resource("ingresses").
+		Param("labelSelector", listOptions().LabelSelector).
+		Do().Into(&result)
 	if err != nil {
 		return nil, err
 	}
-	return list.Items, nil
+	return result.Items, nil
+}
+
+func withIngressTypeMeta(resource *ext.Ingress) *ext.Ingress {
+	resource.APIVersion = ext.IngressAPIVersion
+	resource.Kind = "Ingress"
+	return resource
+}
+
+// ingressToV1beta1 converts ingress for clusters without networking/v1.
+// Ingress class is set with an annotation; path types are dropped.
+func ingressToV1beta1(in *ext.Ingress) *netwk_v1beta1.Ingress {
+	out := &netwk_v1beta1.Ingress{
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+	}
+
+	if in.Spec.IngressClassName != nil {
+		if out.Annotations == nil {
+			out.Annotations = map[string]string{}
+		}
+		out.Annotations[ext.IngressClassAnnotation] = *in.Spec.IngressClassName
+	}
+
+	if in.Spec.DefaultBackend != nil {
+		b := backendToV1beta1(*in.Spec.DefaultBackend)
+		out.Spec.Backend = &b
+	}
+
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, netwk_v1beta1.IngressTLS{
+			Hosts:      tls.Hosts,
+			SecretName: tls.SecretName,
+		})
+	}
+
+	for _, rule := range in.Spec.Rules {
+		r := netwk_v1beta1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			r.HTTP = &netwk_v1beta1.HTTPIngressRuleValue{}
+			for _, p := range rule.HTTP.Paths {
+				r.HTTP.Paths = append(r.HTTP.Paths, netwk_v1beta1.HTTPIngressPath{
+					Path:    p.Path,
+					Backend: backendToV1beta1(p.Backend),
+				})
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, r)
+	}
+	return out
+}
+
+func backendToV1beta1(in ext.IngressBackend) netwk_v1beta1.IngressBackend {
+	var out netwk_v1beta1.IngressBackend
+	if in.Service != nil {
+		out.ServiceName = in.Service.Name
+		if in.Service.Port.Name != "" {
+			out.ServicePort = intstr.FromString(in.Service.Port.Name)
+		} else {
+			out.ServicePort = intstr.FromInt(int(in.Service.Port.Number))
+		}
+	}
+	return out
+}
+
+// ingressFromV1beta1 converts ingress read from clusters without
+// networking/v1. Ingress class is read from the annotation; path types are
+// left unset.
+func ingressFromV1beta1(in *netwk_v1beta1.Ingress) *ext.Ingress {
+	out := &ext.Ingress{
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+	}
+
+	if class, ok := out.Annotations[ext.IngressClassAnnotation]; ok {
+		out.Spec.IngressClassName = &class
+		delete(out.Annotations, ext.IngressClassAnnotation)
+	}
+
+	if in.Spec.Backend != nil {
+		b := backendFromV1beta1(*in.Spec.Backend)
+		out.Spec.DefaultBackend = &b
+	}
+
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, ext.IngressTLS{
+			Hosts:      tls.Hosts,
+			SecretName: tls.SecretName,
+		})
+	}
+
+	for _, rule := range in.Spec.Rules {
+		r := ext.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			r.HTTP = &ext.HTTPIngressRuleValue{}
+			for _, p := range rule.HTTP.Paths {
+				r.HTTP.Paths = append(r.HTTP.Paths, ext.HTTPIngressPath{
+					Path:    p.Path,
+					Backend: backendFromV1beta1(p.Backend),
+				})
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, r)
+	}
+
+	for _, lb := range in.Status.LoadBalancer.Ingress {
+		out.Status.LoadBalancer.Ingress = append(out.Status.LoadBalancer.Ingress, ext.IngressLoadBalancerIngress{
+			IP:       lb.IP,
+			Hostname: lb.Hostname,
+		})
+	}
+	return out
+}
+
+func backendFromV1beta1(in netwk_v1beta1.IngressBackend) ext.IngressBackend {
+	service := &ext.IngressServiceBackend{Name: in.ServiceName}
+	if in.ServicePort.Type == intstr.String {
+		service.Port.Name = in.ServicePort.StrVal
+	} else {
+		service.Port.Number = in.ServicePort.IntVal
+	}
+	return ext.IngressBackend{Service: service}
 }
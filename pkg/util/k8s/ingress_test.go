@@ -1,11 +1,22 @@
 package k8s
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
 
+	ext "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
 	netwk_v1beta1 "k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakerest "k8s.io/client-go/rest/fake"
 )
 
 func TestIngressGet(t *testing.T) {
@@ -39,7 +50,7 @@ func TestIngressExist(t *testing.T) {
 
 func TestIngressApplyNew(t *testing.T) {
 	client := createIngress()
-	newResource := &netwk_v1beta1.Ingress{
+	newResource := &ext.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "new",
 			Namespace: "sample",
@@ -59,7 +70,7 @@ func TestIngressApplyNew(t *testing.T) {
 
 func TestIngressApplyExisting(t *testing.T) {
 	client := createIngress()
-	existing := &netwk_v1beta1.Ingress{
+	existing := &ext.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "test",
 			Namespace: "sample",
@@ -78,6 +89,149 @@ func TestIngressApplyExisting(t *testing.T) {
 	// }
 }
 
+func TestIngressV1beta1Fallback(t *testing.T) {
+	client := createIngress()
+	if client.ServesV1() {
+		t.Fatal("Expected networking/v1 not to be served")
+	}
+
+	if err := client.Apply(testIngress("classy", "nginx")); err != nil {
+		t.Fatalf("Unexpected error applying ingress: %s", err.Error())
+	}
+
+	stored, err := client.NetworkingV1beta1().Ingresses("sample").Get("classy", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if stored.Annotations[ext.IngressClassAnnotation] != "nginx" {
+		t.Errorf("Expected ingress class annotation nginx, got %v", stored.Annotations)
+	}
+	if port := stored.Spec.Rules[0].HTTP.Paths[0].Backend.ServicePort.IntVal; port != 80 {
+		t.Errorf("Expected backend port 80, got %d", port)
+	}
+
+	ingress, err := client.Get("classy")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if ingress.Spec.IngressClassName == nil || *ingress.Spec.IngressClassName != "nginx" {
+		t.Errorf("Expected ingress class nginx, got %v", ingress.Spec.IngressClassName)
+	}
+	if _, ok := ingress.Annotations[ext.IngressClassAnnotation]; ok {
+		t.Errorf("Expected ingress class annotation to be removed, got %v", ingress.Annotations)
+	}
+}
+
+func TestIngressServesV1Cached(t *testing.T) {
+	clientset := createIngressV1(map[string]*ext.Ingress{}).Interface.(*fake.Clientset)
+	version := &IngressVersion{}
+	for i := 0; i < 3; i++ {
+		client := &Ingress{Interface: clientset, Namespace: "sample", Version: version}
+		if !client.ServesV1() {
+			t.Error("Expected networking/v1 to be served")
+		}
+	}
+	if actions := clientset.Actions(); len(actions) != 1 {
+		t.Errorf("Expected a single discovery request, got %d", len(actions))
+	}
+}
+
+func TestIngressServesV1DiscoveryError(t *testing.T) {
+	// fake discovery fails with a plain error for unknown group versions
+	clientset := fake.NewSimpleClientset()
+	client := &Ingress{Interface: clientset, Namespace: "sample", Version: &IngressVersion{}}
+	if client.ServesV1() {
+		t.Fatal("Expected v1beta1 fallback on discovery error")
+	}
+
+	clientset.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: ext.IngressAPIVersion,
+			APIResources: []metav1.APIResource{{Name: "ingresses", Kind: "Ingress", Namespaced: true}},
+		},
+	}
+	if !client.ServesV1() {
+		t.Error("Expected discovery to be retried after an error")
+	}
+}
+
+func TestIngressV1(t *testing.T) {
+	store := map[string]*ext.Ingress{}
+	client := createIngressV1(store)
+	if !client.ServesV1() {
+		t.Fatal("Expected networking/v1 to be served")
+	}
+
+	if err := client.Apply(testIngress("test", "nginx")); err != nil {
+		t.Fatalf("Unexpected error applying ingress: %s", err.Error())
+	}
+	stored, ok := store["test"]
+	if !ok {
+		t.Fatal("Applied ingress not found")
+	}
+	if stored.APIVersion != ext.IngressAPIVersion || stored.Kind != "Ingress" {
+		t.Errorf("Unexpected type meta: %s %s", stored.APIVersion, stored.Kind)
+	}
+	if *stored.Spec.Rules[0].HTTP.Paths[0].PathType != ext.PathTypePrefix {
+		t.Errorf("Expected Prefix path type, got %s", *stored.Spec.Rules[0].HTTP.Paths[0].PathType)
+	}
+
+	if err := client.Apply(testIngress("test", "internal")); err != nil {
+		t.Fatalf("Unexpected error updating ingress: %s", err.Error())
+	}
+	list, err := client.List()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(list) != 1 || *list[0].Spec.IngressClassName != "internal" {
+		t.Errorf("Unexpected ingress list: %+v", list)
+	}
+
+	if err := client.Destroy("test"); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+	if client.Exist("test") {
+		t.Error("Expected ingress to be deleted")
+	}
+}
+
+func testIngress(name, class string) *ext.Ingress {
+	pathType := ext.PathTypePrefix
+	return &ext.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "sample",
+			Labels: map[string]string{
+				"creator": "pipeline",
+			},
+		},
+		Spec: ext.IngressSpec{
+			IngressClassName: &class,
+			Rules: []ext.IngressRule{
+				{
+					Host: "www.example.com",
+					IngressRuleValue: ext.IngressRuleValue{
+						HTTP: &ext.HTTPIngressRuleValue{
+							Paths: []ext.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend: ext.IngressBackend{
+										Service: &ext.IngressServiceBackend{
+											Name: name,
+											Port: ext.ServiceBackendPort{Number: 80},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
 func createIngress() Ingress {
 	return Ingress{
 		Interface: createSimpleIngressClient(),
@@ -98,3 +252,80 @@ func createSimpleIngressClient() *fake.Clientset {
 		},
 	)
 }
+
+// createIngressV1 returns ingress client for a cluster serving
+// networking.k8s.io/v1, backed by store
+func createIngressV1(store map[string]*ext.Ingress) Ingress {
+	clientset := fake.NewSimpleClientset()
+	clientset.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: ext.IngressAPIVersion,
+			APIResources: []metav1.APIResource{{Name: "ingresses", Kind: "Ingress", Namespaced: true}},
+		},
+	}
+
+	respond := func(code int, obj interface{}) (*http.Response, error) {
+		header := http.Header{}
+		header.Set("Content-Type", runtime.ContentTypeJSON)
+		data, _ := json.Marshal(obj)
+		return &http.Response{StatusCode: code, Header: header, Body: ioutil.NopCloser(bytes.NewReader(data))}, nil
+	}
+	notFound := func(name string) (*http.Response, error) {
+		return respond(http.StatusNotFound, metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusFailure,
+			Reason:   metav1.StatusReasonNotFound,
+			Code:     http.StatusNotFound,
+			Message:  name + " not found",
+		})
+	}
+
+	handler := func(req *http.Request) (*http.Response, error) {
+		// /namespaces/sample/ingresses[/name]
+		elems := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+		name := ""
+		if len(elems) == 4 {
+			name = elems[3]
+		}
+
+		switch req.Method {
+		case http.MethodGet:
+			if name == "" {
+				list := ext.IngressList{}
+				for _, i := range store {
+					list.Items = append(list.Items, *i)
+				}
+				return respond(http.StatusOK, list)
+			}
+			if i, ok := store[name]; ok {
+				return respond(http.StatusOK, i)
+			}
+			return notFound(name)
+		case http.MethodPost, http.MethodPut:
+			var i ext.Ingress
+			data, _ := ioutil.ReadAll(req.Body)
+			if err := json.Unmarshal(data, &i); err != nil {
+				return nil, err
+			}
+			store[i.Name] = &i
+			return respond(http.StatusOK, i)
+		case http.MethodDelete:
+			if _, ok := store[name]; !ok {
+				return notFound(name)
+			}
+			delete(store, name)
+			return respond(http.StatusOK, metav1.Status{Status: metav1.StatusSuccess})
+		}
+		return respond(http.StatusMethodNotAllowed, nil)
+	}
+
+	return Ingress{
+		Interface: clientset,
+		Namespace: "sample",
+		RESTClient: &fakerest.RESTClient{
+			GroupVersion:         schema.GroupVersion{Group: "networking.k8s.io", Version: "v1"},
+			NegotiatedSerializer: serializer.WithoutConversionCodecFactory{CodecFactory: scheme.Codecs},
+			Client:               fakerest.CreateHTTPClient(handler),
+		},
+	}
+}
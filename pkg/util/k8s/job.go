@@ -40,25 +40,27 @@ func (client *Job) Create(resource *v1batch.Job) error {
 	return err
 }
 
-// Update updates existing job in k8s
+// Update replaces existing job in k8s. Job pod template is immutable, so
+// the job is deleted and created again, which re-runs it.
 func (client *Job) Update(job *v1batch.Job) error {
-	current, err := client.Get(job.Name)
-	if err != nil {
+	if _, err := client.Get(job.Name); err != nil {
 		return err
 	}
-	job.ResourceVersion = current.GetResourceVersion()
-	_, err = client.
-		BatchV1().
-		Jobs(client.Namespace).
-		Update(job)
-	return err
+	if err := client.Destroy(job.Name); err != nil {
+		return err
+	}
+	return client.Create(job)
 }
 
-// Destroy deletes service from the k8 cluster
+// Destroy deletes job and its pods from the k8 cluster
 func (client *Job) Destroy(name string) error {
+	deletePolicy := metav1.DeletePropagationBackground
+	options := &metav1.DeleteOptions{
+		PropagationPolicy: &deletePolicy,
+	}
 	return client.
 		BatchV1().
-		Jobs(client.Namespace).Delete(name, &metav1.DeleteOptions{})
+		Jobs(client.Namespace).Delete(name, options)
 }
 
 // List returns the list of k8s services maintained by pipeline
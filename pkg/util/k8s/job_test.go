@@ -0,0 +1,80 @@
+package k8s
+
+import (
+	"testing"
+
+	v1batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestJobApplyNew(t *testing.T) {
+	client := createJob()
+	if err := client.Apply(testJob("new", "migrate")); err != nil {
+		t.Errorf("Unexpected error applying job: %s", err.Error())
+	}
+	if !client.Exist("new") {
+		t.Errorf("Applied job not found")
+	}
+}
+
+func TestJobApplyExisting(t *testing.T) {
+	client := createJob()
+	if err := client.Apply(testJob("test", "migrate-again")); err != nil {
+		t.Errorf("Unexpected error applying job: %s", err.Error())
+	}
+
+	j, err := client.Get("test")
+	if err != nil {
+		t.Fatalf("Applied job not found")
+	}
+	if j.Spec.Template.Spec.Containers[0].Name != "migrate-again" {
+		t.Errorf("Expected job to be replaced, got %+v", j.Spec.Template.Spec.Containers)
+	}
+}
+
+func TestJobUpdateNonexisting(t *testing.T) {
+	client := createJob()
+	if err := client.Update(testJob("nonexisting", "migrate")); err == nil {
+		t.Error("Error should be raised, but got nil")
+	}
+}
+
+func TestJobList(t *testing.T) {
+	client := createJob()
+	j, err := client.List()
+	if err != nil {
+		t.Errorf("Unexpected error %s", err.Error())
+	}
+	if len(j) != 1 {
+		t.Errorf("Unexpected count of jobs, expected: 1, got: %d", len(j))
+	}
+}
+
+func testJob(name, container string) *v1batch.Job {
+	return &v1batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "sample",
+			Labels: map[string]string{
+				"creator": "pipeline",
+			},
+		},
+		Spec: v1batch.JobSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{Name: container}},
+				},
+			},
+		},
+	}
+}
+
+func createJob() Job {
+	f := fake.NewSimpleClientset(testJob("test", "migrate"))
+	return Job{
+		Interface: f,
+		Namespace: "sample",
+	}
+}
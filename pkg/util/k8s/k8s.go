@@ -16,6 +16,8 @@ type Client struct {
 	Interface kubernetes.Interface
 	Namespace string
 	CRDClient rest.Interface
+	// IngressVersion caches discovery of ingress API versions
+	IngressVersion *IngressVersion
 }
 
 // ClientForNamespace configures REST client to operate in a given namespace
@@ -97,7 +99,12 @@ func (c *Client) Pod() *Pod {
 
 // Ingress builds Ingress client
 func (c *Client) Ingress() *Ingress {
-	return &Ingress{Interface: c.Interface, Namespace: c.Namespace}
+	return &Ingress{Interface: c.Interface, Namespace: c.Namespace, Version: c.IngressVersion}
+}
+
+// NetworkPolicy builds NetworkPolicy client
+func (c *Client) NetworkPolicy() *NetworkPolicy {
+	return &NetworkPolicy{Interface: c.Interface, Namespace: c.Namespace}
 }
 
 // StatefulSet builds Statefulset client
@@ -110,11 +117,16 @@ func (c *Client) Ns() *Namespace {
 	return &Namespace{Interface: c.Interface, Namespace: c.Namespace}
 }
 
-// ConfigMap builds ConfigMap client
+// Job builds Job client
 func (c *Client) Job() *Job {
 	return &Job{Interface: c.Interface, Namespace: c.Namespace}
 }
 
+// CronJob builds CronJob client
+func (c *Client) CronJob() *CronJob {
+	return &CronJob{Interface: c.Interface, Namespace: c.Namespace}
+}
+
 // CustomResourceDefinition builds CRD client
 func (c *Client) CustomResourceDefinition(kind string) *CustomResourceDefinition {
 
@@ -135,6 +147,22 @@ func (c *Client) ExternalSecret() *ExternalSecret {
 	}
 }
 
+// Certificate builds cert-manager Certificate client
+func (c *Client) Certificate() *Certificate {
+	return &Certificate{
+		Interface: c.CRDClient,
+		Namespace: c.Namespace,
+	}
+}
+
+// ScaledObject builds KEDA ScaledObject client
+func (c *Client) ScaledObject() *ScaledObject {
+	return &ScaledObject{
+		Interface: c.CRDClient,
+		Namespace: c.Namespace,
+	}
+}
+
 func listOptions() metav1.ListOptions {
 	return metav1.ListOptions{
 		LabelSelector: "creator=pipeline",
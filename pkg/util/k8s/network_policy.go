@@ -0,0 +1,82 @@
+package k8s
+
+import (
+	netwk_v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NetworkPolicy type actions on network policies in k8s cluster
+type NetworkPolicy struct {
+	kubernetes.Interface
+	Namespace string
+}
+
+// Get returns network policy object from the k8s by name
+func (client *NetworkPolicy) Get(name string) (*netwk_v1.NetworkPolicy, error) {
+	return client.
+		NetworkingV1().
+		NetworkPolicies(client.Namespace).
+		Get(name, getOptions())
+}
+
+// Exist returns boolean value if network policy exists in k8s
+func (client *NetworkPolicy) Exist(name string) bool {
+	_, err := client.Get(name)
+	return err == nil
+}
+
+// Apply updates or creates network policy in k8s
+func (client *NetworkPolicy) Apply(resource *netwk_v1.NetworkPolicy) error {
+	if resource == nil {
+		return nil
+	}
+	if client.Exist(resource.Name) {
+		return client.Update(resource)
+	}
+	return client.Create(resource)
+}
+
+// Update updates existing network policy in k8s
+func (client *NetworkPolicy) Update(resource *netwk_v1.NetworkPolicy) error {
+	if resource == nil {
+		return nil
+	}
+	current, err := client.Get(resource.Name)
+	if err != nil {
+		return err
+	}
+	resource.ResourceVersion = current.GetResourceVersion()
+
+	_, err = client.
+		NetworkingV1().
+		NetworkPolicies(client.Namespace).
+		Update(resource)
+	return err
+}
+
+// Create creates new network policy in k8s
+func (client *NetworkPolicy) Create(resource *netwk_v1.NetworkPolicy) error {
+	if resource == nil {
+		return nil
+	}
+	_, err := client.
+		NetworkingV1().
+		NetworkPolicies(client.Namespace).
+		Create(resource)
+	return err
+}
+
+// Destroy deletes network policy from the k8 cluster
+func (client *NetworkPolicy) Destroy(name string) error {
+	return client.NetworkingV1().NetworkPolicies(client.Namespace).Delete(name, &metav1.DeleteOptions{})
+}
+
+// List returns the list of k8s network policies maintained by pipeline
+func (client *NetworkPolicy) List() ([]netwk_v1.NetworkPolicy, error) {
+	list, err := client.NetworkingV1().NetworkPolicies(client.Namespace).List(listOptions())
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
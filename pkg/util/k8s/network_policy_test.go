@@ -0,0 +1,103 @@
+package k8s
+
+import (
+	"testing"
+
+	netwk_v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNetworkPolicyGet(t *testing.T) {
+	client := createNetworkPolicy()
+	if _, err := client.Get("test"); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+
+	if m, err := client.Get("nonexistent"); err == nil {
+		t.Errorf("Unexpected network policy: %v", m)
+	}
+}
+
+func TestNetworkPolicyApplyNew(t *testing.T) {
+	client := createNetworkPolicy()
+	newResource := &netwk_v1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "new",
+			Namespace: "sample",
+			Labels: map[string]string{
+				"creator": "pipeline",
+			},
+		},
+	}
+	if err := client.Apply(newResource); err != nil {
+		t.Errorf("Unexpected error applying network policy: %s", err.Error())
+	}
+	if _, err := client.Get("new"); err != nil {
+		t.Errorf("Applied network policy not found")
+	}
+}
+
+func TestNetworkPolicyApplyExisting(t *testing.T) {
+	client := createNetworkPolicy()
+	existing := &netwk_v1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "sample",
+			Labels: map[string]string{
+				"creator": "pipeline",
+			},
+		},
+		Spec: netwk_v1.NetworkPolicySpec{
+			PolicyTypes: []netwk_v1.PolicyType{netwk_v1.PolicyTypeIngress},
+		},
+	}
+	if err := client.Apply(existing); err != nil {
+		t.Errorf("Unexpected error applying network policy: %s", err.Error())
+	}
+
+	np, _ := client.Get("test")
+	if len(np.Spec.PolicyTypes) != 1 {
+		t.Errorf("Expected network policy to be updated, got: %+v", np.Spec)
+	}
+}
+
+func TestNetworkPolicyDestroy(t *testing.T) {
+	client := createNetworkPolicy()
+	if err := client.Destroy("test"); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+
+	if err := client.Destroy("nonexisting"); err == nil {
+		t.Errorf("Unexpected error nil")
+	}
+}
+
+func TestNetworkPolicyList(t *testing.T) {
+	client := createNetworkPolicy()
+	s, err := client.List()
+	if err != nil {
+		t.Errorf("Unexpected error %s", err.Error())
+	}
+	if len(s) != 1 {
+		t.Errorf("Unexpected count of network policies, expected: 1, got: %d", len(s))
+	}
+}
+
+func createNetworkPolicy() NetworkPolicy {
+	f := fake.NewSimpleClientset(
+		&netwk_v1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "sample",
+				Labels: map[string]string{
+					"creator": "pipeline",
+				},
+			},
+		},
+	)
+	return NetworkPolicy{
+		Interface: f,
+		Namespace: "sample",
+	}
+}
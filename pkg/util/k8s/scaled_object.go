@@ -0,0 +1,100 @@
+package k8s
+
+import (
+	log "github.com/Sirupsen/logrus"
+	extensions "github.com/pearsontechnology/environment-operator/pkg/k8_extensions"
+	"k8s.io/client-go/rest"
+)
+
+// ScaledObject represents KEDA ScaledObject crd on the cluster
+type ScaledObject struct {
+	rest.Interface
+
+	Namespace string
+}
+
+// Get retrieves ScaledObject from the k8s using name
+func (client *ScaledObject) Get(name string) (*extensions.ScaledObject, error) {
+	var rsc extensions.ScaledObject
+
+	err := client.Interface.Get().
+		Resource("scaledobjects").
+		Namespace(client.Namespace).
+		Name(name).
+		Do().Into(&rsc)
+
+	if err != nil {
+		return nil, err
+	}
+	return &rsc, nil
+}
+
+// Exist checks if named resource exist in k8s cluster
+func (client *ScaledObject) Exist(name string) bool {
+	rsc, _ := client.Get(name)
+	return rsc != nil
+}
+
+// Apply creates or updates ScaledObject in k8s
+func (client *ScaledObject) Apply(resource *extensions.ScaledObject) error {
+	if resource == nil {
+		return nil
+	}
+	if rsc, err := client.Get(resource.Name); err == nil {
+		resource.ResourceVersion = rsc.GetResourceVersion()
+		log.Debugf("Updating scaled object: %s", resource.Name)
+		return client.Update(resource)
+	}
+	log.Debugf("Creating scaled object: %s", resource.Name)
+	return client.Create(resource)
+}
+
+// Create creates given ScaledObject in k8s
+func (client *ScaledObject) Create(resource *extensions.ScaledObject) error {
+	if resource == nil {
+		return nil
+	}
+	var result extensions.ScaledObject
+	return client.Interface.Post().
+		Resource("scaledobjects").
+		Namespace(client.Namespace).
+		Body(resource).
+		Do().Into(&result)
+}
+
+// Update updates existing ScaledObject in k8s
+func (client *ScaledObject) Update(resource *extensions.ScaledObject) error {
+	if resource == nil {
+		return nil
+	}
+	var result extensions.ScaledObject
+	return client.Interface.Put().
+		Resource("scaledobjects").
+		Name(resource.Name).
+		Namespace(client.Namespace).
+		Body(resource).
+		Do().Into(&result)
+}
+
+// Destroy deletes named ScaledObject
+func (client *ScaledObject) Destroy(name string) error {
+	return client.Interface.Delete().
+		Resource("scaledobjects").
+		Namespace(client.Namespace).
+		Name(name).
+		Do().Error()
+}
+
+// List returns the list of ScaledObjects maintained by pipeline
+func (client *ScaledObject) List() ([]extensions.ScaledObject, error) {
+	var result extensions.ScaledObjectList
+	err := client.Interface.Get().
+		Resource("scaledobjects").
+		Namespace(client.Namespace).
+		Param("labelSelector", listOptions().LabelSelector).
+		Do().Into(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Items, nil
+}
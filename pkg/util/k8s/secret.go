@@ -83,3 +83,8 @@ func (client *Secret) Update(resource *v1.Secret) error {
 func (client *Secret) Get(name string) (*v1.Secret, error) {
 	return client.CoreV1().Secrets(client.Namespace).Get(name, metav1.GetOptions{})
 }
+
+// Destroy deletes secret from the k8 cluster
+func (client *Secret) Destroy(name string) error {
+	return client.CoreV1().Secrets(client.Namespace).Delete(name, &metav1.DeleteOptions{})
+}
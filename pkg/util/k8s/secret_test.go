@@ -40,6 +40,17 @@ func TestSecretList(t *testing.T) {
 	}
 }
 
+func TestSecretDestroy(t *testing.T) {
+	client := createSecret()
+
+	if err := client.Destroy("test-secret"); err != nil {
+		t.Errorf("Unexpected error %s", err.Error())
+	}
+	if client.Exists("test-secret") {
+		t.Error("Expected secret test-secret to be deleted")
+	}
+}
+
 func createSecret() Secret {
 	return Secret{
 		Interface: createSimpleSecretClient(),
@@ -55,8 +55,16 @@ func (client *Service) Update(resource *v1.Service) error {
 	if err != nil {
 		return err
 	}
+	// ClusterIP is immutable; switching to or from a headless service
+	// (e.g. when workload changes to statefulset) requires recreation
+	if (resource.Spec.ClusterIP == v1.ClusterIPNone) != (current.Spec.ClusterIP == v1.ClusterIPNone) {
+		if err = client.Destroy(resource.Name); err != nil {
+			return err
+		}
+		return client.Create(resource)
+	}
 	resource.ResourceVersion = current.GetResourceVersion()
-	resource.Spec.ClusterIP = current.Spec.ClusterIP
+	keepAllocated(resource, current)
 
 	_, err = client.
 		CoreV1().
@@ -65,6 +73,29 @@ func (client *Service) Update(resource *v1.Service) error {
 	return err
 }
 
+// keepAllocated copies cluster IP and node ports allocated to current
+// service to resource. ExternalName services have neither; the API server
+// allocates them when a service stops being ExternalName.
+func keepAllocated(resource, current *v1.Service) {
+	if resource.Spec.Type == v1.ServiceTypeExternalName || current.Spec.Type == v1.ServiceTypeExternalName {
+		return
+	}
+	resource.Spec.ClusterIP = current.Spec.ClusterIP
+	if resource.Spec.Type != v1.ServiceTypeNodePort && resource.Spec.Type != v1.ServiceTypeLoadBalancer {
+		return
+	}
+	for i, port := range resource.Spec.Ports {
+		for _, c := range current.Spec.Ports {
+			if port.Port == c.Port && port.Protocol == c.Protocol && port.NodePort == 0 {
+				resource.Spec.Ports[i].NodePort = c.NodePort
+			}
+		}
+	}
+	if resource.Spec.ExternalTrafficPolicy == current.Spec.ExternalTrafficPolicy {
+		resource.Spec.HealthCheckNodePort = current.Spec.HealthCheckNodePort
+	}
+}
+
 // Destroy deletes service from the k8 cluster
 func (client *Service) Destroy(name string) error {
 	return client.CoreV1().Services(client.Namespace).Delete(name, &metav1.DeleteOptions{})
@@ -73,6 +73,59 @@ func TestServiceApplyExisting(t *testing.T) {
 	}
 }
 
+func TestServiceApplyHeadless(t *testing.T) {
+	client := createService()
+	headless := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "sample",
+			Labels: map[string]string{
+				"creator": "pipeline",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			ClusterIP: v1.ClusterIPNone,
+		},
+	}
+	if err := client.Apply(headless); err != nil {
+		t.Errorf("Unexpected error applying service: %s", err.Error())
+	}
+	s, err := client.Get("test")
+	if err != nil {
+		t.Errorf("Applied service not found")
+	}
+	if s.Spec.ClusterIP != v1.ClusterIPNone {
+		t.Errorf("Expected headless service, got ClusterIP %q", s.Spec.ClusterIP)
+	}
+}
+
+func TestServiceKeepAllocated(t *testing.T) {
+	current := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:      v1.ServiceTypeNodePort,
+			ClusterIP: "10.0.0.1",
+			Ports:     []v1.ServicePort{{Port: 22, Protocol: v1.ProtocolTCP, NodePort: 30022}},
+		},
+	}
+
+	resource := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{{Port: 22, Protocol: v1.ProtocolTCP}, {Port: 2222, Protocol: v1.ProtocolTCP}},
+		},
+	}
+	keepAllocated(resource, current)
+	if resource.Spec.ClusterIP != "10.0.0.1" || resource.Spec.Ports[0].NodePort != 30022 || resource.Spec.Ports[1].NodePort != 0 {
+		t.Errorf("Expected cluster IP and node port of port 22 to be kept, got %+v", resource.Spec)
+	}
+
+	alias := &v1.Service{Spec: v1.ServiceSpec{Type: v1.ServiceTypeExternalName, ExternalName: "db.example.com"}}
+	keepAllocated(alias, current)
+	if alias.Spec.ClusterIP != "" {
+		t.Errorf("Expected no cluster IP for ExternalName service, got %q", alias.Spec.ClusterIP)
+	}
+}
+
 func TestServiceUpdateNonexisting(t *testing.T) {
 	client := createService()
 	resource := &v1.Service{
@@ -1,7 +1,12 @@
 package k8s
 
 import (
+	"fmt"
+	"reflect"
+
+	log "github.com/Sirupsen/logrus"
 	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -37,7 +42,10 @@ func (client *StatefulSet) Apply(resource *apps_v1.StatefulSet) error {
 
 }
 
-// Update stateful set
+// Update updates existing statefulset in k8s. Selector, service name and
+// volume claim templates are immutable, so only replicas, labels,
+// annotations and pod template are updated. Statefulsets with changed
+// volume claim templates are recreated.
 func (client *StatefulSet) Update(resource *apps_v1.StatefulSet) error {
 	if resource == nil {
 		return nil
@@ -46,37 +54,89 @@ func (client *StatefulSet) Update(resource *apps_v1.StatefulSet) error {
 	if err != nil {
 		return err
 	}
+	if current.DeletionTimestamp != nil {
+		return fmt.Errorf("statefulset %s is being deleted; it's created once deleted", resource.Name)
+	}
+
+	if resource.ObjectMeta.Labels["version"] == "" {
+		resource.ObjectMeta.Labels["version"] = current.ObjectMeta.Labels["version"]
+	}
+
+	if len(current.Spec.Template.Spec.Containers) > 0 &&
+		len(resource.Spec.Template.Spec.Containers) > 0 &&
+		resource.Spec.Template.Spec.Containers[0].Image == "" {
+		resource.Spec.Template.Spec.Containers[0].Image = current.Spec.Template.Spec.Containers[0].Image
+	}
+
+	if claimTemplatesChanged(current.Spec.VolumeClaimTemplates, resource.Spec.VolumeClaimTemplates) {
+		return client.recreate(resource)
+	}
 
+	current.ObjectMeta.Labels = resource.ObjectMeta.Labels
+	current.ObjectMeta.Annotations = resource.ObjectMeta.Annotations
 	current.Spec.Replicas = resource.Spec.Replicas
+	current.Spec.Template = resource.Spec.Template
 	_, err = client.
 		AppsV1().
 		StatefulSets(client.Namespace).
 		Update(current)
-
-	/*resource.ResourceVersion = current.GetResourceVersion()
-	_, err = client.
-		Apps().
-		StatefulSets(client.Namespace).
-		Update(resource)
-	*/
 	return err
 }
 
+// recreate replaces statefulset, deleting it without its pods and claims.
+// The new statefulset adopts existing pods, and replicas keep their claims
+// if claim names are kept.
+func (client *StatefulSet) recreate(resource *apps_v1.StatefulSet) error {
+	log.Infof("Volume claim templates of statefulset %s changed, recreating it; existing claims are kept", resource.Name)
+	deletePolicy := metav1.DeletePropagationOrphan
+	options := &metav1.DeleteOptions{
+		PropagationPolicy: &deletePolicy,
+	}
+	if err := client.AppsV1().StatefulSets(client.Namespace).Delete(resource.Name, options); err != nil {
+		return err
+	}
+	return client.Create(resource)
+}
+
+// claimTemplatesChanged returns true if volume claim templates differ in
+// names, labels or access modes. Labels hold volume size, type and path.
+func claimTemplatesChanged(current, desired []v1.PersistentVolumeClaim) bool {
+	if len(current) != len(desired) {
+		return true
+	}
+	for i := range desired {
+		if current[i].Name != desired[i].Name ||
+			!reflect.DeepEqual(current[i].Labels, desired[i].Labels) ||
+			!reflect.DeepEqual(current[i].Spec.AccessModes, desired[i].Spec.AccessModes) {
+			return true
+		}
+	}
+	return false
+}
+
 // Create creates new statefulset in k8s
 func (client *StatefulSet) Create(resource *apps_v1.StatefulSet) error {
 	if resource == nil {
 		return nil
 	}
-	_, err := client.
-		AppsV1().
-		StatefulSets(client.Namespace).
-		Create(resource)
-	return err
+	if len(resource.Spec.Template.Spec.Containers) > 0 &&
+		resource.Spec.Template.Spec.Containers[0].Image != "" {
+		_, err := client.
+			AppsV1().
+			StatefulSets(client.Namespace).
+			Create(resource)
+		return err
+	}
+	return fmt.Errorf("Error creating statefulset %s; image not set", resource.Name)
 }
 
 // Destroy deletes statefulset from the k8 cluster
 func (client *StatefulSet) Destroy(name string) error {
-	return client.AppsV1().StatefulSets(client.Namespace).Delete(name, &metav1.DeleteOptions{})
+	deletePolicy := metav1.DeletePropagationForeground
+	options := &metav1.DeleteOptions{
+		PropagationPolicy: &deletePolicy,
+	}
+	return client.AppsV1().StatefulSets(client.Namespace).Delete(name, options)
 }
 
 // List returns the list of k8s services maintained by pipeline
@@ -0,0 +1,162 @@
+package k8s
+
+import (
+	"testing"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStatefulSetApplyNew(t *testing.T) {
+	client := createStatefulSet()
+	newResource := &apps_v1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "new",
+			Namespace: "sample",
+			Labels: map[string]string{
+				"creator": "pipeline",
+				"version": "0.0.1",
+			},
+		},
+		Spec: apps_v1.StatefulSetSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Image: "test:0.0.1",
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := client.Apply(newResource); err != nil {
+		t.Errorf("Unexpected error applying statefulset: %s", err.Error())
+	}
+	if !client.Exist("new") {
+		t.Errorf("Applied statefulset not found")
+	}
+}
+
+func TestStatefulSetApplyNewWithoutImage(t *testing.T) {
+	client := createStatefulSet()
+	newResource := &apps_v1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "new",
+			Namespace: "sample",
+		},
+		Spec: apps_v1.StatefulSetSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{}},
+				},
+			},
+		},
+	}
+	if err := client.Apply(newResource); err == nil {
+		t.Error("Error should be raised, but got nil")
+	}
+}
+
+func TestStatefulSetApplyExisting(t *testing.T) {
+	client := createStatefulSet()
+	replicas := int32(3)
+	existing := &apps_v1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "sample",
+			Labels: map[string]string{
+				"creator": "pipeline",
+			},
+			Annotations: map[string]string{
+				"env_value_from": `{"DB_HOST":"db.endpoint"}`,
+			},
+		},
+		Spec: apps_v1.StatefulSetSpec{
+			Replicas: &replicas,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Command: []string{"run"},
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := client.Apply(existing); err != nil {
+		t.Errorf("Unexpected error applying statefulset: %s", err.Error())
+	}
+
+	s, err := client.Get("test")
+	if err != nil {
+		t.Errorf("Applied statefulset not found")
+	}
+	if *s.Spec.Replicas != 3 {
+		t.Errorf("Unexpected replicas. Expected 3, got %d", *s.Spec.Replicas)
+	}
+	if s.ObjectMeta.Labels["version"] != "0.0.1" {
+		t.Errorf("Invalid version label. Expected 0.0.1, got %s", s.ObjectMeta.Labels["version"])
+	}
+	if s.ObjectMeta.Annotations["env_value_from"] != `{"DB_HOST":"db.endpoint"}` {
+		t.Errorf("Unexpected annotations after update: %v", s.ObjectMeta.Annotations)
+	}
+	container := s.Spec.Template.Spec.Containers[0]
+	if container.Image != "test:0.0.1" || len(container.Command) != 1 {
+		t.Errorf("Unexpected container after update: %+v", container)
+	}
+}
+
+func TestStatefulSetDestroy(t *testing.T) {
+	client := createStatefulSet()
+	if err := client.Destroy("test"); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+	if client.Exist("test") {
+		t.Error("StatefulSet was not destroyed")
+	}
+}
+
+func TestStatefulSetList(t *testing.T) {
+	client := createStatefulSet()
+	s, err := client.List()
+	if err != nil {
+		t.Errorf("Unexpected error %s", err.Error())
+	}
+	if len(s) != 1 {
+		t.Errorf("Unexpected count of statefulsets, expected: 1, got: %d", len(s))
+	}
+}
+
+func createStatefulSet() StatefulSet {
+	f := fake.NewSimpleClientset(
+		&apps_v1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "sample",
+				Labels: map[string]string{
+					"creator": "pipeline",
+					"version": "0.0.1",
+				},
+			},
+			Spec: apps_v1.StatefulSetSpec{
+				Template: v1.PodTemplateSpec{
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{
+							{
+								Image: "test:0.0.1",
+							},
+						},
+					},
+				},
+			},
+		},
+	)
+	return StatefulSet{
+		Interface: f,
+		Namespace: "sample",
+	}
+}
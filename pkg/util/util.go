@@ -1,6 +1,7 @@
 package util
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 )
@@ -53,3 +54,14 @@ func EqualArrays(a, b []int) bool {
 
 	return true
 }
+
+// ContentHash returns a short hash of obj's JSON encoding. It is used to
+// detect changes in objects the cluster defaults or rewrites, and so can't
+// be compared field by field.
+func ContentHash(obj interface{}) (string, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return encodeHash(hash(string(data)))
+}
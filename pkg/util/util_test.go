@@ -12,3 +12,17 @@ func TestEnvironmentVariableUtility(t *testing.T) {
 		t.Errorf("Unexpected Variable retrieved for DOCKER_PULL_SECRETS")
 	}
 }
+
+func TestContentHash(t *testing.T) {
+	a, _ := ContentHash(map[string]string{"a": "1", "b": "2"})
+	b, _ := ContentHash(map[string]string{"b": "2", "a": "1"})
+	c, _ := ContentHash(map[string]string{"a": "1", "b": "3"})
+
+	if a == "" || a != b {
+		t.Errorf("Expected equal hashes for equal content, got %q and %q", a, b)
+	}
+
+	if a == c {
+		t.Errorf("Expected different hashes for different content, got %q", a)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
@@ -17,6 +18,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// clusterClient returns client handlers apply changes with, replaced in
+// tests
+var clusterClient = cluster.Client
+
 // Router returns mux.Router with all paths served
 func Router() *mux.Router {
 	r := mux.NewRouter()
@@ -24,6 +29,10 @@ func Router() *mux.Router {
 	r.HandleFunc("/status", getStatus).Methods("GET")
 	r.HandleFunc("/status/{service}", getServiceStatus).Methods("GET")
 	r.HandleFunc("/status/{service}/pods", getPodStatus).Methods("GET")
+	r.HandleFunc("/canary/{service}/advance", postCanaryAdvance).Methods("POST")
+	r.HandleFunc("/bluegreen/{service}/promote", postBlueGreenPromote).Methods("POST")
+	r.HandleFunc("/bluegreen/{service}/rollback", postBlueGreenRollback).Methods("POST")
+	r.HandleFunc("/schema", getSchema).Methods("GET")
 	r.Handle("/metrics", promhttp.Handler())
 
 	return r
@@ -53,7 +62,7 @@ func Auth(h http.Handler) http.Handler {
 
 func postDeploy(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-type", "application/json")
-	client, err := cluster.Client()
+	client, err := clusterClient()
 
 	if err != nil {
 		log.Errorf("error creating post-deploy Kubernetes client: %s", err.Error())
@@ -81,7 +90,7 @@ func postDeploy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if service.IsBlueGreenParentDeployment() {
-		service, err = loadServiceFromConfig(service.InactiveDeploymentName())
+		service, err = loadInactiveServiceFromConfig(client, service.Name)
 		if err != nil {
 			log.Errorf("error getting deployment %s: %s", d.Name, err.Error())
 			http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
@@ -92,7 +101,12 @@ func postDeploy(w http.ResponseWriter, r *http.Request) {
 	service.Version = d.Version
 	service.Application = d.Application
 
-	if err := client.ApplyService(service, configmaps, config.Env.Namespace); err != nil {
+	if service.IsCanaryParentDeployment() {
+		err = deployCanary(client, service, configmaps)
+	} else {
+		err = client.ApplyService(service, configmaps, config.Env.Namespace)
+	}
+	if err != nil {
 		log.Errorf("error updating deployment %s: %s", d.Name, err.Error())
 		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
 		metrics.Deploys.With(prometheus.Labels{"status": "failed"}).Inc()
@@ -111,9 +125,108 @@ func postDeploy(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// deployCanary deploys requested version to the canary of service
+func deployCanary(client *cluster.Cluster, service *bitesize.Service, configmaps *bitesize.Gists) error {
+	canary, err := loadServiceFromConfig(service.CanaryDeploymentName())
+	if err != nil {
+		return err
+	}
+	canary.Version = service.Version
+	canary.Application = service.Application
+	return client.DeployCanary(*service, *canary, configmaps, config.Env.Namespace)
+}
+
+func postCanaryAdvance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-type", "application/json")
+	client, err := clusterClient()
+	if err != nil {
+		log.Errorf("error creating canary Kubernetes client: %s", err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	serviceName := mux.Vars(r)["service"]
+	service, err := loadServiceFromConfig(serviceName)
+	if err != nil {
+		log.Errorf("error getting canary %s: %s", serviceName, err.Error())
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	state, err := client.AdvanceCanary(*service, config.Env.Namespace)
+	if err != nil {
+		log.Errorf("error advancing canary %s: %s", serviceName, err.Error())
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	service.Deployment.CanaryState = state
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(statusForCanary(*service)); err != nil {
+		log.Error(err)
+	}
+}
+
+func postBlueGreenPromote(w http.ResponseWriter, r *http.Request) {
+	switchBlueGreen(w, r, (*cluster.Cluster).PromoteBlueGreen)
+}
+
+func postBlueGreenRollback(w http.ResponseWriter, r *http.Request) {
+	switchBlueGreen(w, r, (*cluster.Cluster).RollbackBlueGreen)
+}
+
+// switchBlueGreen switches live traffic of the requested bluegreen service
+// with fn and responds with the active service set
+func switchBlueGreen(w http.ResponseWriter, r *http.Request, fn func(*cluster.Cluster, *bitesize.Environment, string) (*bitesize.Service, error)) {
+	w.Header().Set("Content-type", "application/json")
+	client, err := clusterClient()
+	if err != nil {
+		log.Errorf("error creating bluegreen Kubernetes client: %s", err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	environment, err := loadEnvironmentFromConfig()
+	if err != nil {
+		log.Error(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	serviceName := mux.Vars(r)["service"]
+	service, err := fn(client, environment, serviceName)
+	if err != nil {
+		log.Errorf("error switching bluegreen %s: %s", serviceName, err.Error())
+		http.Error(w, fmt.Sprintf("Bad Request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(StatusBlueGreen{
+		Name:       service.Name,
+		Active:     service.ActiveDeploymentTag().String(),
+		Configured: service.ConfiguredDeploymentTag().String(),
+	})
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func getSchema(w http.ResponseWriter, r *http.Request) {
+	schema, err := bitesize.SchemaJSON()
+	if err != nil {
+		log.Errorf("error generating schema: %s", err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(schema)
+}
+
 func getStatus(w http.ResponseWriter, r *http.Request) {
 
-	client, err := cluster.Client()
+	client, err := clusterClient()
 	if err != nil {
 		log.Errorf("error getting cluster client: %s", err.Error())
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
@@ -142,6 +255,10 @@ func getStatus(w http.ResponseWriter, r *http.Request) {
 		status := statusForService(svc)
 		s.Services = append(s.Services, status)
 	}
+
+	for _, gist := range e.Gists.FindByType(bitesize.TypeJob) {
+		s.Jobs = append(s.Jobs, statusForJob(gist))
+	}
 	err = json.NewEncoder(w).Encode(s)
 	if err != nil {
 		log.Error(err)
@@ -153,7 +270,7 @@ func getPodStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	serviceName := vars["service"]
 	w.Header().Set("Content-Type", "application/json")
-	client, err := cluster.Client()
+	client, err := clusterClient()
 	if err != nil {
 		log.Errorf("Error getting cluster client: %s", err.Error())
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
@@ -235,5 +352,38 @@ func statusForService(svc bitesize.Service) StatusService {
 			UpToDate:  svc.Status.CurrentReplicas,
 			Desired:   svc.Status.DesiredReplicas,
 		},
+		Canary: statusForCanary(svc),
+	}
+}
+
+func statusForCanary(svc bitesize.Service) *StatusCanary {
+	state := svc.CanaryState()
+	if state == nil {
+		return nil
+	}
+	return &StatusCanary{
+		Status:    state.Status,
+		Step:      state.Step,
+		Weight:    svc.CanaryWeight(),
+		StartedAt: state.StartedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func statusForJob(gist bitesize.Gist) StatusJob {
+	status := "running"
+	if gist.IsComplete() {
+		status = "complete"
+	} else if gist.Status.Active == 0 && gist.Status.Failed > 0 {
+		status = "failed"
+	}
+
+	return StatusJob{
+		Name:        gist.Name,
+		Status:      status,
+		Active:      gist.Status.Active,
+		Succeeded:   gist.Status.Succeeded,
+		Failed:      gist.Status.Failed,
+		StartedAt:   gist.Status.StartedAt,
+		CompletedAt: gist.Status.CompletedAt,
 	}
 }
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pearsontechnology/environment-operator/pkg/bitesize"
+	"github.com/pearsontechnology/environment-operator/pkg/cluster"
+	"github.com/pearsontechnology/environment-operator/pkg/config"
+	"github.com/pearsontechnology/environment-operator/pkg/util/k8s"
+	fakecrd "github.com/pearsontechnology/environment-operator/pkg/util/k8s/fake"
+	gogit "gopkg.in/src-d/go-git.v4"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPostDeployServiceOutputs(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "environment-outputs",
+				Labels: map[string]string{"environment": "environment-outputs"},
+			},
+		},
+	)
+	testCluster := &cluster.Cluster{
+		Interface: client,
+		CRDClient: fakecrd.UnstructuredClient("prsn.io", "v1"),
+	}
+	setupDeployTest(t, testCluster, "environment30", "environment-outputs")
+	deployments := k8s.Deployment{Interface: client, Namespace: "environment-outputs"}
+
+	if code := deploy("api", "1.0.1"); code != http.StatusOK {
+		t.Fatalf("Unexpected status code: %d", code)
+	}
+	if deployments.Exist("api") {
+		t.Fatal("Expected api to be held back until orders-db outputs exist")
+	}
+
+	// orders-db deployed by the pipeline, with outputs written by the
+	// controller provisioning the database
+	e, err := bitesize.LoadEnvironment("../../test/assets/environments.bitesize", "environment30")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := testCluster.ApplyService(e.Services.FindByName("orders-db"), &e.Gists, "environment-outputs"); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	databases := k8s.CustomResourceDefinition{Interface: testCluster.CRDClient, Namespace: "environment-outputs", Type: "mysql"}
+	db, err := databases.Get("orders-db")
+	if err != nil {
+		t.Fatalf("Expected orders-db to be created, got: %s", err.Error())
+	}
+	db.Kind = "Mysql"
+	db.Status = map[string]interface{}{"endpoint": "orders.db.example.com"}
+	if err := databases.Apply(db); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	client.CoreV1().Secrets("environment-outputs").Create(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-db-outputs", Namespace: "environment-outputs"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	})
+
+	if code := deploy("api", "1.0.1"); code != http.StatusOK {
+		t.Fatalf("Unexpected status code: %d", code)
+	}
+	deployment, err := deployments.Get("api")
+	if err != nil {
+		t.Fatalf("Expected api to be deployed, got: %s", err.Error())
+	}
+	expected := []v1.EnvVar{
+		{Name: "DB_HOST", Value: "orders.db.example.com"},
+		{Name: "DB_PASSWORD", ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "orders-db-outputs"},
+				Key:                  "password",
+			},
+		}},
+	}
+	if env := deployment.Spec.Template.Spec.Containers[0].Env; !reflect.DeepEqual(env, expected) {
+		t.Errorf("Unexpected api env: %+v", env)
+	}
+}
+
+// setupDeployTest points handlers at cluster and a local git repository
+// holding the test environments file
+func setupDeployTest(t *testing.T, c *cluster.Cluster, envName, namespace string) {
+	dir, err := ioutil.TempDir("", "web")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	local, remote := filepath.Join(dir, "local"), filepath.Join(dir, "remote")
+	for _, path := range []string{local, remote} {
+		if _, err := gogit.PlainInit(path, false); err != nil {
+			t.Fatalf("Unexpected err: %s", err.Error())
+		}
+	}
+	environments, err := ioutil.ReadFile("../../test/assets/environments.bitesize")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+	if err := ioutil.WriteFile(filepath.Join(local, "environments.bitesize"), environments, 0644); err != nil {
+		t.Fatalf("Unexpected err: %s", err.Error())
+	}
+
+	env, client := config.Env, clusterClient
+	config.Env.GitLocalPath = local
+	config.Env.GitRepo = remote
+	config.Env.EnvFile = "environments.bitesize"
+	config.Env.EnvName = envName
+	config.Env.Namespace = namespace
+	clusterClient = func() (*cluster.Cluster, error) { return c, nil }
+
+	t.Cleanup(func() {
+		config.Env, clusterClient = env, client
+		os.RemoveAll(dir)
+	})
+}
+
+func deploy(name, version string) int {
+	body := strings.NewReader(`{"name":"` + name + `","Version":"` + version + `"}`)
+	w := httptest.NewRecorder()
+	Router().ServeHTTP(w, httptest.NewRequest("POST", "/deploy", body))
+	return w.Code
+}
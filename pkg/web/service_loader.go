@@ -11,7 +11,7 @@ import (
 	"github.com/pearsontechnology/environment-operator/pkg/git"
 )
 
-func loadServiceFromConfig(name string) (*bitesize.Service, error) {
+func loadEnvironmentFromConfig() (*bitesize.Environment, error) {
 	gitClient := git.Client()
 	gitClient.Refresh()
 
@@ -19,6 +19,14 @@ func loadServiceFromConfig(name string) (*bitesize.Service, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Could not load env: %s", err.Error())
 	}
+	return environment, nil
+}
+
+func loadServiceFromConfig(name string) (*bitesize.Service, error) {
+	environment, err := loadEnvironmentFromConfig()
+	if err != nil {
+		return nil, err
+	}
 
 	service := environment.Services.FindByName(name)
 	if service == nil {
@@ -28,8 +36,30 @@ func loadServiceFromConfig(name string) (*bitesize.Service, error) {
 	return service, nil
 }
 
+// loadInactiveServiceFromConfig returns inactive service set of bluegreen
+// service name, taking service sets promoted via API into account
+func loadInactiveServiceFromConfig(client *cluster.Cluster, name string) (*bitesize.Service, error) {
+	environment, err := loadEnvironmentFromConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err = client.ResolveActiveDeployments(environment); err != nil {
+		return nil, err
+	}
+
+	service := environment.Services.FindByName(name)
+	if service == nil {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	inactive := environment.Services.FindByName(service.InactiveDeploymentName())
+	if inactive == nil {
+		return nil, fmt.Errorf("%s not found", service.InactiveDeploymentName())
+	}
+	return inactive, nil
+}
+
 func loadServiceFromCluster(name string) (bitesize.Service, error) {
-	client, err := cluster.Client()
+	client, err := clusterClient()
 	if err != nil {
 		return bitesize.Service{}, errors.New(fmt.Sprintf("Error cluster client: %s", err.Error()))
 	}
@@ -47,12 +77,9 @@ func loadServiceFromCluster(name string) (bitesize.Service, error) {
 }
 
 func loadConfigMapsFromConfig() (*bitesize.Gists, error) {
-	gitClient := git.Client()
-	gitClient.Refresh()
-
-	environment, err := bitesize.LoadEnvironmentFromConfig(config.Env)
+	environment, err := loadEnvironmentFromConfig()
 	if err != nil {
-		return nil, fmt.Errorf("Could not load env: %s", err.Error())
+		return nil, err
 	}
 
 	res := environment.Gists.FindByType(bitesize.TypeConfigMap)
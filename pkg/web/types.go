@@ -16,6 +16,7 @@ type StatusResponse struct {
 	EnvironmentName string          `json:"environment"`
 	Namespace       string          `json:"namespace"`
 	Services        []StatusService `json:"services"`
+	Jobs            []StatusJob     `json:"jobs,omitempty"`
 }
 
 type StatusService struct {
@@ -25,6 +26,34 @@ type StatusService struct {
 	DeployedAt string         `json:"deployed_at,omitempty"`
 	Replicas   StatusReplicas `json:"replicas,omitempty"`
 	Status     string         `json:"status,omitempty"`
+	Canary     *StatusCanary  `json:"canary,omitempty"`
+}
+
+// StatusCanary represents progress of canary rollout
+type StatusCanary struct {
+	Status    string `json:"status"`
+	Step      int    `json:"step"`
+	Weight    int    `json:"weight"`
+	StartedAt string `json:"started_at,omitempty"`
+}
+
+// StatusBlueGreen represents service set serving live traffic of bluegreen
+// service, and the one set in environments.bitesize
+type StatusBlueGreen struct {
+	Name       string `json:"name"`
+	Active     string `json:"active"`
+	Configured string `json:"configured"`
+}
+
+// StatusJob represents completion status of job gist
+type StatusJob struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Active      int    `json:"active"`
+	Succeeded   int    `json:"succeeded"`
+	Failed      int    `json:"failed"`
+	StartedAt   string `json:"started_at,omitempty"`
+	CompletedAt string `json:"completed_at,omitempty"`
 }
 
 type StatusPods struct {